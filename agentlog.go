@@ -0,0 +1,279 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AgentEventType is the kind of an AgentEvent
+type AgentEventType string
+
+const (
+	// AgentEventModel is a model turn, with the text and the number of
+	// requested tool calls
+	AgentEventModel AgentEventType = "model"
+	// AgentEventTool is an executed tool call with its result
+	AgentEventTool AgentEventType = "tool"
+	// AgentEventError is a failed model turn
+	AgentEventError AgentEventType = "error"
+)
+
+// AgentEvent is an entry of the trace of an agent run
+type AgentEvent struct {
+	Run      string         `json:"run"`
+	Step     int            `json:"step"`
+	Type     AgentEventType `json:"type"`
+	Time     time.Time      `json:"time"`
+	Duration time.Duration  `json:"duration"`
+
+	// Model turns
+	Model     string `json:"model,omitempty"`
+	Text      string `json:"text,omitempty"`
+	ToolCalls int    `json:"tool_calls,omitempty"`
+	// InputTokens and OutputTokens are estimated with CountTokens
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+
+	// Tool calls
+	Tool      string          `json:"tool,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// AgentEventSink receives the events of AgentTrace. Record may be called
+// concurrently for the tool calls of a step.
+type AgentEventSink interface {
+	Record(event AgentEvent)
+}
+
+// AgentTrace records the steps of a tool calling loop driven by the caller:
+// each Generate call starts a step, and the tool calls executed with Execute
+// belong to it.
+//
+//	trace := ai.NewAgentTrace("run-1", log)
+//	for {
+//		res, err := trace.Generate(ctx, llm, messages, tools)
+//		if err != nil || len(res.ToolCalls) == 0 {
+//			break
+//		}
+//		results := trace.Execute(ctx, executor, res.ToolCalls)
+//		messages = append(messages, res.Message())
+//		messages = append(messages, ai.ToolResultMessages(results)...)
+//	}
+type AgentTrace struct {
+	run  string
+	sink AgentEventSink
+
+	mu   sync.Mutex
+	step int
+}
+
+// NewAgentTrace creates an AgentTrace for the run identified by run
+func NewAgentTrace(run string, sink AgentEventSink) *AgentTrace {
+	return &AgentTrace{run: run, sink: sink}
+}
+
+// Generate calls GenerateWithTools of llm as the next step and records it
+func (t *AgentTrace) Generate(ctx context.Context, llm ToolCaller, messages []Message, tools []Tool) (*Response, error) {
+	t.mu.Lock()
+	t.step++
+	step := t.step
+	t.mu.Unlock()
+
+	var model string
+	if m, ok := llm.(interface{ GetModel() string }); ok {
+		model = m.GetModel()
+	}
+	var input strings.Builder
+	for _, msg := range messages {
+		input.WriteString(msg.Content)
+	}
+
+	start := time.Now()
+	res, err := llm.GenerateWithTools(ctx, messages, tools)
+	event := AgentEvent{
+		Run:         t.run,
+		Step:        step,
+		Type:        AgentEventModel,
+		Time:        start,
+		Duration:    time.Since(start),
+		Model:       model,
+		InputTokens: CountTokens(model, input.String()),
+	}
+	if err != nil {
+		event.Type = AgentEventError
+		event.Error = err.Error()
+	} else {
+		if res.Model != "" {
+			event.Model = res.Model
+		}
+		event.Text = res.Text
+		event.ToolCalls = len(res.ToolCalls)
+		output := res.Text
+		for _, call := range res.ToolCalls {
+			output += call.Name + string(call.Arguments)
+		}
+		event.OutputTokens = CountTokens(event.Model, output)
+	}
+	t.sink.Record(event)
+	return res, err
+}
+
+// Execute runs calls with exec and records them in the current step. The
+// Trace function of exec is still called.
+func (t *AgentTrace) Execute(ctx context.Context, exec *ToolExecutor, calls []ToolCall) []ToolResult {
+	t.mu.Lock()
+	step := t.step
+	t.mu.Unlock()
+
+	traced := *exec
+	traced.Trace = func(trace ToolTrace) {
+		t.sink.Record(AgentEvent{
+			Run:       t.run,
+			Step:      step,
+			Type:      AgentEventTool,
+			Time:      trace.Start,
+			Duration:  trace.Duration,
+			Tool:      trace.Call.Name,
+			CallID:    trace.Call.ID,
+			Arguments: trace.Call.Arguments,
+			Result:    trace.Result.Content,
+			IsError:   trace.Result.IsError,
+		})
+		if exec.Trace != nil {
+			exec.Trace(trace)
+		}
+	}
+	return traced.Execute(ctx, calls)
+}
+
+// AgentLog is an AgentEventSink that keeps events in memory
+type AgentLog struct {
+	mu     sync.Mutex
+	events []AgentEvent
+}
+
+// NewAgentLog creates an AgentLog
+func NewAgentLog() *AgentLog {
+	return &AgentLog{}
+}
+
+func (l *AgentLog) Record(event AgentEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+// Events returns the recorded events
+func (l *AgentLog) Events() []AgentEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]AgentEvent(nil), l.events...)
+}
+
+// WriteMarkdown renders the recorded events, see WriteAgentMarkdown
+func (l *AgentLog) WriteMarkdown(w io.Writer) error {
+	return WriteAgentMarkdown(w, l.Events())
+}
+
+// JSONLAgentSink writes events as JSON lines, e.g. to a file for later
+// inspection with ReadAgentEvents
+type JSONLAgentSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONLAgentSink creates a JSONLAgentSink writing to w
+func NewJSONLAgentSink(w io.Writer) *JSONLAgentSink {
+	return &JSONLAgentSink{enc: json.NewEncoder(w)}
+}
+
+// Record writes event, write errors are ignored
+func (s *JSONLAgentSink) Record(event AgentEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(event)
+}
+
+// ReadAgentEvents reads events written by a JSONLAgentSink
+func ReadAgentEvents(r io.Reader) ([]AgentEvent, error) {
+	var events []AgentEvent
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var event AgentEvent
+		if err := dec.Decode(&event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// WriteAgentMarkdown renders events as markdown, a section per run and step
+// with the model output, the tool calls and their results, and the token
+// totals of the run
+func WriteAgentMarkdown(w io.Writer, events []AgentEvent) error {
+	var sb strings.Builder
+	var run string
+	var step, input, output int
+	totals := func() {
+		fmt.Fprintf(&sb, "**Tokens:** %d input, %d output\n\n", input, output)
+	}
+	for i, e := range events {
+		if i == 0 || e.Run != run {
+			if i > 0 {
+				totals()
+			}
+			run, step, input, output = e.Run, -1, 0, 0
+			fmt.Fprintf(&sb, "## Agent run: %s\n\n", e.Run)
+		}
+		if e.Step != step {
+			step = e.Step
+			fmt.Fprintf(&sb, "### Step %d\n\n", e.Step)
+		}
+		input += e.InputTokens
+		output += e.OutputTokens
+		switch e.Type {
+		case AgentEventModel:
+			fmt.Fprintf(&sb, "**Model** %s (%s, %d input / %d output tokens)", e.Model, e.Duration.Round(time.Millisecond), e.InputTokens, e.OutputTokens)
+			if e.ToolCalls > 0 {
+				fmt.Fprintf(&sb, ", %d tool calls", e.ToolCalls)
+			}
+			sb.WriteString("\n\n")
+			if e.Text != "" {
+				sb.WriteString(quoteMarkdown(e.Text) + "\n\n")
+			}
+		case AgentEventTool:
+			status := "ok"
+			if e.IsError {
+				status = "error"
+			}
+			fmt.Fprintf(&sb, "**Tool** `%s` (%s, %s)\n\n", e.Tool, e.Duration.Round(time.Millisecond), status)
+			if len(e.Arguments) > 0 {
+				fmt.Fprintf(&sb, "```json\n%s\n```\n\n", e.Arguments)
+			}
+			sb.WriteString(quoteMarkdown(e.Result) + "\n\n")
+		case AgentEventError:
+			fmt.Fprintf(&sb, "**Error** %s: %s\n\n", e.Model, e.Error)
+		}
+	}
+	if len(events) > 0 {
+		totals()
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// quoteMarkdown formats text as a markdown block quote
+func quoteMarkdown(text string) string {
+	return "> " + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n> ")
+}
@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// scriptedToolCaller returns its responses in order
+type scriptedToolCaller struct {
+	stubLLM
+	responses []*Response
+}
+
+func (s *scriptedToolCaller) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	res := s.responses[0]
+	s.responses = s.responses[1:]
+	return res, nil
+}
+
+func TestAgentTrace(t *testing.T) {
+	llm := &scriptedToolCaller{stubLLM: stubLLM{model: "gpt-4o"}}
+	echo := ToolFromFunc("echo", "", func(ctx context.Context, args struct {
+		Text string `json:"text"`
+	}) (string, error) {
+		return args.Text, nil
+	})
+	var traced int
+	exec := &ToolExecutor{Tools: []Tool{echo}, Trace: func(ToolTrace) { traced++ }}
+
+	var buf bytes.Buffer
+	log := NewAgentLog()
+	for _, sink := range []AgentEventSink{log, NewJSONLAgentSink(&buf)} {
+		llm.responses = []*Response{
+			{Text: "Checking.", ToolCalls: []ToolCall{{ID: "1", Name: "echo", Arguments: json.RawMessage(`{"text":"sunny"}`)}}},
+			{Text: "It is sunny."},
+		}
+		trace := NewAgentTrace("run-1", sink)
+		messages := []Message{{Role: RoleUser, Content: "Weather?"}}
+		for {
+			res, err := trace.Generate(context.Background(), llm, messages, exec.Tools)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.ToolCalls) == 0 {
+				break
+			}
+			trace.Execute(context.Background(), exec, res.ToolCalls)
+		}
+	}
+
+	events := log.Events()
+	if len(events) != 3 || traced != 2 {
+		t.Fatalf("unexpected events %+v, %d traces", events, traced)
+	}
+	if e := events[0]; e.Type != AgentEventModel || e.Step != 1 || e.Model != "gpt-4o" || e.ToolCalls != 1 || e.InputTokens == 0 || e.OutputTokens == 0 {
+		t.Errorf("unexpected model event %+v", e)
+	}
+	if e := events[1]; e.Type != AgentEventTool || e.Step != 1 || e.Tool != "echo" || string(e.Arguments) != `{"text":"sunny"}` || e.Result != "sunny" {
+		t.Errorf("unexpected tool event %+v", e)
+	}
+	if e := events[2]; e.Step != 2 || e.Text != "It is sunny." {
+		t.Errorf("unexpected model event %+v", e)
+	}
+
+	read, err := ReadAgentEvents(&buf)
+	if err != nil || len(read) != 3 || read[1].Result != "sunny" || read[2].Step != 2 {
+		t.Fatalf("unexpected events read back %+v, %v", read, err)
+	}
+
+	var md strings.Builder
+	if err := log.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"## Agent run: run-1\n", "### Step 2\n", "**Tool** `echo`", "> It is sunny.", "**Tokens:**"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, md.String())
+		}
+	}
+}
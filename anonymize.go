@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RedactionRule replaces matches of Pattern with Placeholder
+type RedactionRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Placeholder string
+	// Valid reports whether the match text[start:end] is an identifier, for
+	// checks regexps cannot express. Nil accepts all matches.
+	Valid func(text string, start, end int) bool
+}
+
+// DefaultRedactionRules detect common personal identifiers. Order matters,
+// earlier rules are applied first.
+var DefaultRedactionRules = []RedactionRule{
+	{Name: "email", Pattern: regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`), Placeholder: "[EMAIL]"},
+	{Name: "url", Pattern: regexp.MustCompile(`https?://[^\s<>"']+`), Placeholder: "[URL]"},
+	{Name: "iban", Pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){3,7}(?: ?[A-Z0-9]{1,3})?\b`), Placeholder: "[IBAN]"},
+	{Name: "card", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Placeholder: "[CARD]"},
+	{Name: "ssn", Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), Placeholder: "[SSN]"},
+	{Name: "ip", Pattern: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), Placeholder: "[IP]"},
+	{Name: "phone", Pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\d{2,4}(?:[ .-]\d{2,4}){2,4}\b`), Placeholder: "[PHONE]", Valid: isPhoneNumber},
+}
+
+// datePattern matches numeric dates, which look like phone numbers
+var datePattern = regexp.MustCompile(`^(?:\d{4}[-./]\d{1,2}[-./]\d{1,2}|\d{1,2}[-./]\d{1,2}[-./]\d{4})$`)
+
+// isPhoneNumber rejects phone rule matches that continue a word, number or
+// version string, have fewer than 7 digits or are dates
+func isPhoneNumber(text string, start, end int) bool {
+	if before, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && (unicode.IsLetter(before) || unicode.IsDigit(before) || before == '.' || before == '-') {
+		return false
+	}
+	match := text[start:end]
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 7 && !datePattern.MatchString(match)
+}
+
+// Redactor strips personal identifiers from text
+type Redactor struct {
+	rules []RedactionRule
+	terms []string
+}
+
+// NewRedactor creates a Redactor with rules, DefaultRedactionRules if none
+func NewRedactor(rules ...RedactionRule) *Redactor {
+	if len(rules) == 0 {
+		rules = DefaultRedactionRules
+	}
+	return &Redactor{rules: rules}
+}
+
+// AddTerms redacts known identifiers, such as customer names, as [NAME].
+// Terms are matched case insensitively on word boundaries.
+func (r *Redactor) AddTerms(terms ...string) {
+	r.terms = append(r.terms, terms...)
+	// Longest first, so "Ann Lee" is redacted before "Ann"
+	sort.SliceStable(r.terms, func(i, j int) bool { return len(r.terms[i]) > len(r.terms[j]) })
+}
+
+// Redact returns text with identifiers replaced by placeholders
+func (r *Redactor) Redact(text string) string {
+	// Rules first, so names in email addresses are redacted as [EMAIL]
+	for _, rule := range r.rules {
+		if rule.Valid == nil {
+			text = rule.Pattern.ReplaceAllString(text, rule.Placeholder)
+			continue
+		}
+		var res strings.Builder
+		last := 0
+		for _, loc := range rule.Pattern.FindAllStringIndex(text, -1) {
+			if !rule.Valid(text, loc[0], loc[1]) {
+				continue
+			}
+			res.WriteString(text[last:loc[0]])
+			res.WriteString(rule.Placeholder)
+			last = loc[1]
+		}
+		text = res.String() + text[last:]
+	}
+	for _, term := range r.terms {
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+		text = re.ReplaceAllString(text, "[NAME]")
+	}
+	return text
+}
+
+// roleAliases maps role names used by stores and providers to Roles
+var roleAliases = map[string]Role{
+	"system":    RoleSystem,
+	"developer": RoleSystem,
+	"user":      RoleUser,
+	"human":     RoleUser,
+	"assistant": RoleAssistant,
+	"ai":        RoleAssistant,
+	"bot":       RoleAssistant,
+	"model":     RoleAssistant,
+}
+
+// Anonymizer turns stored conversations into datasets for fine-tuning or
+// evals, see Export
+type Anonymizer struct {
+	Redactor *Redactor
+	// KeepSystem keeps system messages, they are dropped by default
+	KeepSystem bool
+}
+
+// NewAnonymizer creates an Anonymizer with the default redaction rules
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{Redactor: NewRedactor()}
+}
+
+// Anonymize redacts the messages and normalizes their roles: aliases such as
+// "human" or "model" are mapped to Roles, unknown roles and empty or image
+// only messages are dropped, and consecutive messages of the same role are
+// merged. Conversations that do not contain a user and an assistant message
+// after that are returned as nil.
+func (a *Anonymizer) Anonymize(messages []Message) []Message {
+	var res []Message
+	var user, assistant bool
+	for _, msg := range messages {
+		role, ok := roleAliases[strings.ToLower(strings.TrimSpace(string(msg.Role)))]
+		content := strings.TrimSpace(msg.Content)
+		if !ok || content == "" || role == RoleSystem && !a.KeepSystem {
+			continue
+		}
+		if a.Redactor != nil {
+			content = a.Redactor.Redact(content)
+		}
+		user = user || role == RoleUser
+		assistant = assistant || role == RoleAssistant
+		if n := len(res); n > 0 && res[n-1].Role == role {
+			res[n-1].Content += "\n\n" + content
+			continue
+		}
+		res = append(res, Message{Role: role, Content: content})
+	}
+	if !user || !assistant {
+		return nil
+	}
+	return res
+}
+
+type datasetMessage struct {
+	Role    Role   `json:"role"`
+	Content string `json:"content"`
+}
+
+type datasetLine struct {
+	Messages []datasetMessage `json:"messages"`
+}
+
+// Export walks the conversations returned by next until it returns io.EOF,
+// anonymizes them and writes them to w as JSONL in the chat format used for
+// fine-tuning, one {"messages": [...]} object per line. It returns the number
+// of conversations written.
+//
+//	n, err := ai.NewAnonymizer().Export(f, func() ([]ai.Message, error) {
+//		return store.Next(ctx)
+//	})
+func (a *Anonymizer) Export(w io.Writer, next func() ([]Message, error)) (int, error) {
+	enc := json.NewEncoder(w)
+	written := 0
+	for {
+		messages, err := next()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+		messages = a.Anonymize(messages)
+		if messages == nil {
+			continue
+		}
+		line := datasetLine{Messages: make([]datasetMessage, len(messages))}
+		for i, msg := range messages {
+			line.Messages[i] = datasetMessage{Role: msg.Role, Content: msg.Content}
+		}
+		if err := enc.Encode(line); err != nil {
+			return written, fmt.Errorf("failed to write conversation %d: %w", written, err)
+		}
+		written++
+	}
+}
+
+// ExportConversations exports conversations, see Export
+func (a *Anonymizer) ExportConversations(w io.Writer, conversations [][]Message) (int, error) {
+	i := 0
+	return a.Export(w, func() ([]Message, error) {
+		if i == len(conversations) {
+			return nil, io.EOF
+		}
+		i++
+		return conversations[i-1], nil
+	})
+}
@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor()
+	r.AddTerms("Ann", "Ann Lee")
+	got := r.Redact("Ann Lee (ann@example.com, +1 415-555-0100) paid with 4111 1111 1111 1111 from 10.0.0.1, see https://x.io/u/1")
+	want := "[NAME] ([EMAIL], [PHONE]) paid with [CARD] from [IP], see [URL]"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, text := range []string{
+		"Invoice dated 2024-01-15",
+		"Delivered on 15.01.2024",
+		"Upgrade to v1.22.333.4444",
+		"Windows 10.0.19045.2965",
+		"Order 12 34 56",
+	} {
+		if got := r.Redact(text); got != text {
+			t.Errorf("expected %q to be kept, got %q", text, got)
+		}
+	}
+	if got := r.Redact("Call 555 123 4567 or 020 7946 0958"); got != "Call [PHONE] or [PHONE]" {
+		t.Errorf("unexpected phone redaction %q", got)
+	}
+}
+
+func TestAnonymizerExport(t *testing.T) {
+	conversations := [][]Message{
+		{
+			{Role: RoleSystem, Content: "You are helpful"},
+			{Role: "Human", Content: "Hi, I'm bob@example.com"},
+			{Role: "human", Content: "Can you help?"},
+			{Role: "model", Content: "Sure"},
+			{Role: "tool", Content: "ignored"},
+		},
+		{{Role: RoleUser, Content: "No reply"}},
+	}
+	var out strings.Builder
+	n, err := NewAnonymizer().ExportConversations(&out, conversations)
+	if err != nil || n != 1 {
+		t.Fatalf("unexpected result %d, %v", n, err)
+	}
+	want := `{"messages":[{"role":"user","content":"Hi, I'm [EMAIL]\n\nCan you help?"},{"role":"assistant","content":"Sure"}]}` + "\n"
+	if out.String() != want {
+		t.Errorf("got %s, want %s", out.String(), want)
+	}
+}
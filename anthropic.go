@@ -2,9 +2,12 @@ package ai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
 
 	"github.com/liushuangls/go-anthropic/v2"
 )
@@ -15,74 +18,113 @@ type Anthropic struct {
 	maxTokens   int
 	temperature float32
 	cachePrompt bool
+	bedrock     *bedrockAdapter
+	stop        []string
+	sampling    Sampling
+
+	// apiKey and baseURL are used by features the SDK does not support yet
+	apiKey  string
+	baseURL string
+	// httpClient sends the requests of the SDK and the direct ones, nil for
+	// the default client
+	httpClient *http.Client
 }
 
-func NewAnthropic(apiKey, model string, maxTokens int, temperature float32, cachePrompt bool) *Anthropic {
-	client := anthropic.NewClient(apiKey)
-	if cachePrompt {
-		client = anthropic.NewClient(
-			apiKey,
-			anthropic.WithBetaVersion(anthropic.BetaPromptCaching20240731),
-		)
-	}
+// anthropicAPIVersion is the anthropic-version header of direct API requests
+const anthropicAPIVersion = "2023-06-01"
 
-	return &Anthropic{
-		client:      client,
+func NewAnthropic(apiKey, model string, maxTokens int, temperature float32, cachePrompt bool) *Anthropic {
+	a := &Anthropic{
 		model:       model,
 		maxTokens:   maxTokens,
 		temperature: temperature,
 		cachePrompt: cachePrompt,
+		apiKey:      apiKey,
 	}
+	a.SetBaseURL("https://api.anthropic.com/v1")
+	return a
 }
 
-func (a *Anthropic) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
-	req := anthropic.MessagesRequest{
-		Model:       anthropic.Model(a.model),
-		Temperature: &a.temperature,
-		MaxTokens:   a.maxTokens,
-		Messages: []anthropic.Message{
-			anthropic.NewUserTextMessage(prompt),
-		},
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (a *Anthropic) SetStopSequences(stop ...string) {
+	a.stop = stop
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (a *Anthropic) SetSampling(s Sampling) {
+	a.sampling = s
+}
+
+// SetBaseURL changes the API base URL, e.g. for a proxy
+func (a *Anthropic) SetBaseURL(baseURL string) {
+	a.baseURL = strings.TrimSuffix(baseURL, "/")
+	opts := []anthropic.ClientOption{anthropic.WithBaseURL(a.baseURL)}
+	if a.cachePrompt {
+		opts = append(opts, anthropic.WithBetaVersion(anthropic.BetaPromptCaching20240731))
+	}
+	if a.httpClient != nil {
+		opts = append(opts, anthropic.WithHTTPClient(a.httpClient))
 	}
+	a.client = anthropic.NewClient(a.apiKey, opts...)
+}
 
-	if systemPrompt != "" {
-		if a.cachePrompt {
-			req.MultiSystem = []anthropic.MessageSystemPart{
-				{
-					Type: "text",
-					Text: systemPrompt,
-					CacheControl: &anthropic.MessageCacheControl{
-						Type: anthropic.CacheControlTypeEphemeral,
-					},
-				},
-			}
-		} else {
-			req.System = systemPrompt
-		}
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (a *Anthropic) SetHTTPClient(client *http.Client) {
+	a.httpClient = client
+	if a.bedrock != nil {
+		a.client = newBedrockClient(a.bedrock, client)
+		return
+	}
+	a.SetBaseURL(a.baseURL)
+}
+
+func (a *Anthropic) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	req, err := a.newRequest(ctx, systemPrompt, []Message{{Role: RoleUser, Content: prompt}})
+	if err != nil {
+		return "", err
 	}
 
 	resp, err := a.client.CreateMessages(ctx, req)
 	if err != nil {
-		var apiErr *anthropic.APIError
-		if errors.As(err, &apiErr) {
-			return "", errors.New(apiErr.Message)
-		}
-		return "", err
+		return "", apiError(err)
 	}
 
-	return resp.Content[0].GetText(), nil
+	return a.text(resp)
 }
 
 func (a *Anthropic) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	a.stream(ctx, systemPrompt, []Message{{Role: RoleUser, Content: prompt}}, resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (a *Anthropic) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	a.stream(ctx, "", messages, resultCh, doneCh, errCh)
+}
+
+func (a *Anthropic) stream(ctx context.Context, systemPrompt string, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	if p := prefill(messages); p != "" {
+		select {
+		case resultCh <- p:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if a.bedrock != nil {
+		a.generateStreamBedrock(ctx, systemPrompt, messages, resultCh, doneCh, errCh)
+		return
+	}
+
+	messagesReq, err := a.newRequest(ctx, systemPrompt, messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+
 	req := anthropic.MessagesStreamRequest{
-		MessagesRequest: anthropic.MessagesRequest{
-			Model:       anthropic.Model(a.model),
-			Temperature: &a.temperature,
-			MaxTokens:   a.maxTokens,
-			Messages: []anthropic.Message{
-				anthropic.NewUserTextMessage(prompt),
-			},
-		},
+		MessagesRequest: messagesReq,
 		OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
 			if data.Delta.Text != nil {
 				select {
@@ -100,23 +142,7 @@ func (a *Anthropic) GenerateStream(ctx context.Context, systemPrompt, prompt str
 		},
 	}
 
-	if systemPrompt != "" {
-		if a.cachePrompt {
-			req.MultiSystem = []anthropic.MessageSystemPart{
-				{
-					Type: "text",
-					Text: systemPrompt,
-					CacheControl: &anthropic.MessageCacheControl{
-						Type: anthropic.CacheControlTypeEphemeral,
-					},
-				},
-			}
-		} else {
-			req.System = systemPrompt
-		}
-	}
-
-	_, err := a.client.CreateMessagesStream(ctx, req)
+	_, err = a.client.CreateMessagesStream(ctx, req)
 	if err != nil {
 		if err == io.EOF {
 			// Stream completed successfully
@@ -125,17 +151,9 @@ func (a *Anthropic) GenerateStream(ctx context.Context, systemPrompt, prompt str
 			case <-ctx.Done():
 			}
 		} else {
-			var apiErr *anthropic.APIError
-			if errors.As(err, &apiErr) {
-				select {
-				case errCh <- errors.New(apiErr.Message):
-				case <-ctx.Done():
-				}
-			} else {
-				select {
-				case errCh <- err:
-				case <-ctx.Done():
-				}
+			select {
+			case errCh <- apiError(err):
+			case <-ctx.Done():
 			}
 		}
 		return
@@ -145,10 +163,215 @@ func (a *Anthropic) GenerateStream(ctx context.Context, systemPrompt, prompt str
 	<-ctx.Done()
 }
 
+// newRequest converts messages to a request. System messages and systemPrompt
+// are combined into the system prompt. If cachePrompt is set, the system
+// prompt and the messages before the last one are cached. Messages with
+// CacheControl get a cache breakpoint.
+// Claude has no seed, so deterministic mode only sets the temperature to 0.
+func (a *Anthropic) newRequest(ctx context.Context, systemPrompt string, messages []Message) (anthropic.MessagesRequest, error) {
+	temperature := a.temperature
+	if IsDeterministic(ctx) {
+		temperature = 0
+	}
+	req := anthropic.MessagesRequest{
+		Model:         anthropic.Model(requestModel(ctx, a.model)),
+		Temperature:   &temperature,
+		MaxTokens:     a.maxTokens,
+		StopSequences: stopSequences(ctx, a.stop),
+	}
+	// Claude has no penalties
+	s := sampling(ctx, a.sampling)
+	if s.TopP != 0 {
+		req.SetTopP(float32(s.TopP))
+	}
+	if s.TopK != 0 {
+		req.SetTopK(s.TopK)
+	}
+
+	// system messages are combined, a cached one ends a system part
+	var system []anthropic.MessageSystemPart
+	if systemPrompt != "" {
+		system = append(system, anthropic.MessageSystemPart{Type: "text", Text: systemPrompt})
+	}
+	for i, msg := range messages {
+		if msg.Role == RoleSystem && !msg.hasMedia() {
+			if n := len(system); n > 0 && system[n-1].CacheControl == nil {
+				system[n-1].Text += "\n\n" + msg.text()
+			} else {
+				system = append(system, anthropic.MessageSystemPart{Type: "text", Text: msg.text()})
+			}
+			if msg.CacheControl {
+				system[len(system)-1].CacheControl = &anthropic.MessageCacheControl{Type: anthropic.CacheControlTypeEphemeral}
+			}
+			continue
+		}
+
+		if msg.Role == RoleTool {
+			// results of parallel tool calls go in one user message
+			result := anthropic.NewToolResultMessageContent(msg.ToolCallID, msg.text(), msg.IsError)
+			if n := len(req.Messages); n > 0 && req.Messages[n-1].Role == anthropic.RoleUser &&
+				len(req.Messages[n-1].Content) > 0 && req.Messages[n-1].Content[0].Type == anthropic.MessagesContentTypeToolResult {
+				req.Messages[n-1].Content = append(req.Messages[n-1].Content, result)
+			} else {
+				req.Messages = append(req.Messages, anthropic.Message{Role: anthropic.RoleUser, Content: []anthropic.MessageContent{result}})
+			}
+			if msg.CacheControl {
+				cacheLastContent(&req.Messages[len(req.Messages)-1])
+			}
+			continue
+		}
+
+		var contents []anthropic.MessageContent
+		parts := msg.ContentParts()
+		if p := prefill(messages); p != "" && i == len(messages)-1 {
+			parts = []ContentPart{TextPart(p)}
+		}
+		for _, part := range parts {
+			switch part.Type {
+			case PartText:
+				if part.Text != "" {
+					contents = append(contents, anthropic.NewTextMessageContent(part.Text))
+				}
+			case PartImage:
+				data, err := io.ReadAll(part.Data)
+				if err != nil {
+					return req, err
+				}
+				contents = append(contents, anthropic.NewImageMessageContent(
+					anthropic.NewMessageContentSource(
+						anthropic.MessagesContentSourceTypeBase64,
+						string(part.MimeType),
+						data,
+					),
+				))
+			case PartDocument:
+				if part.MimeType != MimeTypePDF {
+					return req, fmt.Errorf("unsupported document format %s, Claude accepts PDF", part.MimeType)
+				}
+				data, err := io.ReadAll(part.Data)
+				if err != nil {
+					return req, fmt.Errorf("failed to read document: %v", err)
+				}
+				contents = append(contents, anthropic.NewDocumentMessageContent(
+					anthropic.NewMessageContentSource(
+						anthropic.MessagesContentSourceTypeBase64,
+						string(part.MimeType),
+						data,
+					),
+				))
+			case PartAudio:
+				return req, fmt.Errorf("audio input is not supported by Claude")
+			case PartFile:
+				return req, fmt.Errorf("uploaded files are not supported by Claude")
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			input := call.Arguments
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			contents = append(contents, anthropic.NewToolUseMessageContent(call.ID, call.Name, input))
+		}
+
+		role := anthropic.RoleUser
+		if msg.Role == RoleAssistant {
+			role = anthropic.RoleAssistant
+		}
+		req.Messages = append(req.Messages, anthropic.Message{
+			Role:    role,
+			Content: contents,
+		})
+		if msg.CacheControl {
+			cacheLastContent(&req.Messages[len(req.Messages)-1])
+		}
+	}
+
+	// Cache the conversation before the new turn, as written by Prewarm
+	if a.cachePrompt && len(req.Messages) >= 2 {
+		cacheLastContent(&req.Messages[len(req.Messages)-2])
+	}
+
+	if len(system) > 0 {
+		if a.cachePrompt {
+			system[len(system)-1].CacheControl = &anthropic.MessageCacheControl{
+				Type: anthropic.CacheControlTypeEphemeral,
+			}
+		}
+		if system[0].CacheControl != nil || len(system) > 1 {
+			req.MultiSystem = system
+		} else {
+			req.System = system[0].Text
+		}
+	}
+	return req, nil
+}
+
+// cacheLastContent sets a cache breakpoint at the end of msg
+func cacheLastContent(msg *anthropic.Message) {
+	if len(msg.Content) > 0 {
+		msg.Content[len(msg.Content)-1].SetCacheControl()
+	}
+}
+
+// Prewarm writes messages to the prompt cache, so that a following request
+// continuing the conversation reads them from the cache. It requires
+// cachePrompt, see Prefetcher.
+func (a *Anthropic) Prewarm(ctx context.Context, messages []Message) error {
+	if !a.cachePrompt {
+		return errors.New("prompt caching is disabled")
+	}
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return err
+	}
+	if len(req.Messages) > 0 {
+		cacheLastContent(&req.Messages[len(req.Messages)-1])
+	}
+	req.MaxTokens = 1
+	if _, err := a.client.CreateMessages(ctx, req); err != nil {
+		return apiError(err)
+	}
+	return nil
+}
+
+// AnthropicError is an error reported by the Anthropic API, either as a failed
+// request or as an error event in the middle of a stream
+type AnthropicError struct {
+	Type    string
+	Message string
+}
+
+func (e *AnthropicError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+// Retryable reports whether the error is transient: the API is overloaded,
+// rate limited or failed internally
+func (e *AnthropicError) Retryable() bool {
+	switch anthropic.ErrType(e.Type) {
+	case anthropic.ErrTypeOverloaded, anthropic.ErrTypeRateLimit, anthropic.ErrTypeApi:
+		return true
+	}
+	return false
+}
+
+// apiError converts API errors to AnthropicError
+func apiError(err error) error {
+	var apiErr *anthropic.APIError
+	if errors.As(err, &apiErr) {
+		return &AnthropicError{Type: string(apiErr.Type), Message: apiErr.Message}
+	}
+	return err
+}
+
 func (a *Anthropic) GetModel() string {
 	return a.model
 }
 
+func (a *Anthropic) Close() error {
+	return nil
+}
+
 func (a *Anthropic) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
 	return a.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
 }
@@ -183,47 +406,95 @@ func (a *Anthropic) GenerateWithImages(ctx context.Context, prompt string, image
 }
 
 func (a *Anthropic) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
-	var anthropicMessages []anthropic.Message
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return "", err
+	}
 
-	for _, msg := range messages {
-		var contents []anthropic.MessageContent
+	resp, err := a.client.CreateMessages(ctx, req)
+	if err != nil {
+		return "", apiError(err)
+	}
+
+	text, err := a.text(resp)
+	if err != nil {
+		return "", err
+	}
+	// Claude continues the prefill without repeating it
+	return prefill(messages) + text, nil
+}
+
+// GenerateWithTools lets the model call the given tools. Requested calls are
+// returned in Response.ToolCalls, to be executed by the caller.
+func (a *Anthropic) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return nil, err
+	}
 
-		// Handle image if present
-		if msg.Image != nil {
-			imageBytes, err := io.ReadAll(msg.Image)
-			if err != nil {
-				return "", err
+	req.Tools = anthropicTools(tools)
+
+	resp, err := a.client.CreateMessages(ctx, req)
+	if err != nil {
+		return nil, apiError(err)
+	}
+
+	res := &Response{Model: string(resp.Model)}
+	for _, content := range resp.Content {
+		switch content.Type {
+		case anthropic.MessagesContentTypeText:
+			res.Text += content.GetText()
+		case anthropic.MessagesContentTypeToolUse:
+			if content.MessageContentToolUse == nil {
+				continue
 			}
-			contents = append(contents, anthropic.NewImageMessageContent(
-				anthropic.NewMessageContentSource(
-					anthropic.MessagesContentSourceTypeBase64,
-					string(msg.MimeType),
-					imageBytes,
-				),
-			))
+			res.ToolCalls = append(res.ToolCalls, ToolCall{
+				ID:        content.ID,
+				Name:      content.Name,
+				Arguments: content.Input,
+			})
 		}
+	}
+	if resp.StopReason == anthropicStopReasonRefusal {
+		res.Refusal = res.Text
+		res.Text = ""
+	}
+	attachRaw(ctx, res, resp)
+	return res, nil
+}
 
-		// Add text content
-		if msg.Content != "" {
-			contents = append(contents, anthropic.NewTextMessageContent(msg.Content))
+// anthropicTools converts tools to tool definitions
+func anthropicTools(tools []Tool) []anthropic.ToolDefinition {
+	var defs []anthropic.ToolDefinition
+	for _, tool := range tools {
+		// Claude requires an input schema, even for tools without arguments
+		var schema any = Object()
+		if tool.Parameters != nil {
+			schema = tool.Parameters
 		}
-
-		anthropicMessages = append(anthropicMessages, anthropic.Message{
-			Role:    anthropic.ChatRole(msg.Role),
-			Content: contents,
+		defs = append(defs, anthropic.ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: schema,
 		})
 	}
+	return defs
+}
 
-	req := anthropic.MessagesRequest{
-		Model:     anthropic.Model(a.model),
-		Messages:  anthropicMessages,
-		MaxTokens: a.maxTokens,
-	}
+// anthropicStopReasonRefusal is returned when Claude declines to continue
+const anthropicStopReasonRefusal anthropic.MessagesStopReason = "refusal"
 
-	resp, err := a.client.CreateMessages(ctx, req)
-	if err != nil {
-		return "", err
+// text returns the response text, or a RefusalError if the model refused
+func (a *Anthropic) text(resp anthropic.MessagesResponse) (string, error) {
+	var text string
+	if len(resp.Content) > 0 {
+		text = resp.Content[0].GetText()
 	}
-
-	return resp.Content[0].GetText(), nil
+	if resp.StopReason == anthropicStopReasonRefusal {
+		return "", &RefusalError{Model: a.model, Message: text}
+	}
+	if len(resp.Content) == 0 {
+		return "", errors.New("no content generated")
+	}
+	return text, nil
 }
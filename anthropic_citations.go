@@ -0,0 +1,156 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+// Citation links generated text to the passage of a document supporting it
+type Citation struct {
+	// Type is the kind of location: "char_location" for text documents,
+	// "page_location" for PDFs or "content_block_location"
+	Type      string `json:"type"`
+	CitedText string `json:"cited_text"`
+	// DocumentIndex is the index of the cited document in the request
+	DocumentIndex int    `json:"document_index"`
+	DocumentTitle string `json:"document_title,omitempty"`
+	// Start and End delimit the passage in characters, pages or blocks
+	// depending on Type, End is exclusive
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// anthropicCitation is a citation in the API format
+type anthropicCitation struct {
+	Type            string `json:"type"`
+	CitedText       string `json:"cited_text"`
+	DocumentIndex   int    `json:"document_index"`
+	DocumentTitle   string `json:"document_title"`
+	StartCharIndex  int    `json:"start_char_index"`
+	EndCharIndex    int    `json:"end_char_index"`
+	StartPageNumber int    `json:"start_page_number"`
+	EndPageNumber   int    `json:"end_page_number"`
+	StartBlockIndex int    `json:"start_block_index"`
+	EndBlockIndex   int    `json:"end_block_index"`
+}
+
+func (c anthropicCitation) citation() *Citation {
+	res := &Citation{Type: c.Type, CitedText: c.CitedText, DocumentIndex: c.DocumentIndex, DocumentTitle: c.DocumentTitle}
+	switch c.Type {
+	case "page_location":
+		res.Start, res.End = c.StartPageNumber, c.EndPageNumber
+	case "content_block_location":
+		res.Start, res.End = c.StartBlockIndex, c.EndBlockIndex
+	default:
+		res.Start, res.End = c.StartCharIndex, c.EndCharIndex
+	}
+	return res
+}
+
+// StreamWithCitations streams an answer to prompt grounded on docs, with
+// Claude citations enabled, as delta and citation events so UIs can render
+// source markers live. Claude splits the answer into text blocks, a cited
+// block starts with its citation events followed by its text as delta events.
+// Events are numbered from 0, the stream ends when it returns.
+// Returning an error from fn stops the stream and returns the error.
+//
+// The go-anthropic SDK drops citations, so the API is called directly. It is
+// not supported on Bedrock.
+func (a *Anthropic) StreamWithCitations(ctx context.Context, systemPrompt, prompt string, docs []Document, fn func(StreamEvent) error) error {
+	if a.bedrock != nil {
+		return errors.New("citations are not supported on Bedrock")
+	}
+	req, err := a.newRequest(ctx, systemPrompt, []Message{{Role: RoleUser, Content: prompt}})
+	if err != nil {
+		return err
+	}
+	req.Stream = true
+
+	// Documents go before the question in the user message
+	var content []interface{}
+	for _, doc := range docs {
+		block := map[string]interface{}{
+			"type": "document",
+			"source": map[string]string{
+				"type":       "text",
+				"media_type": "text/plain",
+				"data":       doc.Text,
+			},
+			"citations": map[string]bool{"enabled": true},
+		}
+		if doc.Title != "" {
+			block["title"] = doc.Title
+		}
+		content = append(content, block)
+	}
+	for _, c := range req.Messages[0].Content {
+		content = append(content, c)
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return err
+	}
+	body["messages"] = []map[string]interface{}{{"role": "user", "content": content}}
+
+	headers := map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+		"Accept":            "text/event-stream",
+	}
+	resp, err := sendJSON(ctx, a.httpClient, http.MethodPost, a.baseURL+"/messages", headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	seq := 0
+	emit := func(event StreamEvent) error {
+		event.Seq = seq
+		seq++
+		return fn(event)
+	}
+	return readSSE(resp.Body, func(event, data string) error {
+		var msg struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type     string            `json:"type"`
+				Text     string            `json:"text"`
+				Citation anthropicCitation `json:"citation"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return fmt.Errorf("invalid stream event: %v", err)
+		}
+
+		switch anthropic.MessagesEvent(msg.Type) {
+		case anthropic.MessagesEventContentBlockDelta:
+			switch msg.Delta.Type {
+			case "text_delta":
+				if msg.Delta.Text != "" {
+					return emit(StreamEvent{Type: StreamEventDelta, Text: msg.Delta.Text})
+				}
+			case "citations_delta":
+				return emit(StreamEvent{Type: StreamEventCitation, Citation: msg.Delta.Citation.citation()})
+			}
+		case anthropic.MessagesEventMessageStop:
+			return io.EOF
+		case anthropic.MessagesEventError:
+			return &AnthropicError{Type: msg.Error.Type, Message: msg.Error.Message}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicStreamWithCitations(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "key" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\",\"citations\":[]}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"citations_delta\",\"citation\":{\"type\":\"char_location\",\"cited_text\":\"The grass is green.\",\"document_index\":0,\"document_title\":\"Facts\",\"start_char_index\":0,\"end_char_index\":19}}}\n\n")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"the grass is green\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	llm.SetBaseURL(server.URL)
+
+	var events []StreamEvent
+	err := llm.StreamWithCitations(context.Background(), "", "What color is the grass?",
+		[]Document{{Title: "Facts", Text: "The grass is green."}}, func(event StreamEvent) error {
+			events = append(events, event)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := body["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	doc := content[0].(map[string]interface{})
+	if len(content) != 2 || doc["type"] != "document" || doc["title"] != "Facts" || body["stream"] != true {
+		t.Errorf("unexpected request %v", body)
+	}
+
+	if len(events) != 2 || events[0].Type != StreamEventCitation || events[1].Type != StreamEventDelta || events[1].Seq != 1 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if c := events[0].Citation; c.CitedText != "The grass is green." || c.DocumentTitle != "Facts" || c.End != 19 {
+		t.Errorf("unexpected citation %+v", c)
+	}
+}
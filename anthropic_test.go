@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicCacheControl(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	}))
+	defer server.Close()
+
+	messages := []Message{
+		{Role: RoleSystem, Content: "You answer questions about the manual.", CacheControl: true},
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "The manual: ...", CacheControl: true},
+		{Role: RoleUser, Content: "How do I reset it?"},
+	}
+	cached := func(v interface{}) bool {
+		m := v.(map[string]interface{})
+		return m["cache_control"] != nil
+	}
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	llm.SetBaseURL(server.URL)
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	system := body["system"].([]interface{})
+	if len(system) != 2 || !cached(system[0]) || cached(system[1]) {
+		t.Errorf("expected a cached system part followed by an uncached one, got %v", system)
+	}
+	list := body["messages"].([]interface{})
+	content := func(i int) interface{} {
+		return list[i].(map[string]interface{})["content"].([]interface{})[0]
+	}
+	if !cached(content(0)) || cached(content(1)) {
+		t.Errorf("expected only the first message to be cached, got %v", list)
+	}
+
+	if _, err := llm.GenerateWithMessages(context.Background(), []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if body["system"] != "Be brief." {
+		t.Errorf("expected a plain system prompt without cache control, got %v", body["system"])
+	}
+}
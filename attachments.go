@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sync"
+)
+
+// ContentHash returns the hex SHA-256 of data, used to recognize attachments
+// that were sent before
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AttachmentCache keeps the base64 encodings of recently sent attachments by
+// content hash, so images and documents sent repeatedly in a chat session are
+// encoded once. It holds up to maxBytes of encoded data, evicting the least
+// recently used.
+type AttachmentCache struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int
+	hits    int
+	misses  int
+
+	metrics     Metrics
+	metricsName string
+}
+
+type attachmentEntry struct {
+	hash    string
+	encoded string
+}
+
+// NewAttachmentCache creates an AttachmentCache
+func NewAttachmentCache(maxBytes int) *AttachmentCache {
+	return &AttachmentCache{maxBytes: maxBytes, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// Base64 returns the standard base64 encoding of data
+func (c *AttachmentCache) Base64(data []byte) string {
+	hash := ContentHash(data)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[hash]; ok {
+		c.hits++
+		c.observe("hit")
+		c.lru.MoveToFront(el)
+		return el.Value.(*attachmentEntry).encoded
+	}
+	c.misses++
+	c.observe("miss")
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if len(encoded) > c.maxBytes {
+		return encoded
+	}
+	c.entries[hash] = c.lru.PushFront(&attachmentEntry{hash: hash, encoded: encoded})
+	c.size += len(encoded)
+	for c.size > c.maxBytes {
+		el := c.lru.Back()
+		entry := el.Value.(*attachmentEntry)
+		c.lru.Remove(el)
+		delete(c.entries, entry.hash)
+		c.size -= len(entry.encoded)
+	}
+	return encoded
+}
+
+// Stats returns the number of cache hits and misses
+func (c *AttachmentCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// SetMetrics reports cache hits and misses to metrics, labeled with name
+func (c *AttachmentCache) SetMetrics(metrics Metrics, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics, c.metricsName = metrics, name
+}
+
+// observe reports a lookup result, the caller must hold the lock
+func (c *AttachmentCache) observe(result string) {
+	if c.metrics != nil {
+		c.metrics.AddCounter(MetricCacheRequests, 1, map[string]string{"cache": c.metricsName, "result": result})
+	}
+}
+
+type attachmentCacheKey struct{}
+
+// WithAttachmentCache returns a context that makes clients encode inline
+// images with cache
+func WithAttachmentCache(ctx context.Context, cache *AttachmentCache) context.Context {
+	return context.WithValue(ctx, attachmentCacheKey{}, cache)
+}
+
+// encodeBase64 encodes an attachment, using the cache of the context if set
+func encodeBase64(ctx context.Context, data []byte) string {
+	if cache, ok := ctx.Value(attachmentCacheKey{}).(*AttachmentCache); ok && cache != nil {
+		return cache.Base64(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAttachmentCache(t *testing.T) {
+	var urls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content []struct {
+					ImageURL struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		urls = append(urls, body.Messages[0].Content[0].ImageURL.URL)
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+
+	cache := NewAttachmentCache(1024)
+	ctx := WithAttachmentCache(context.Background(), cache)
+	llm := NewDatabricks(server.URL, "token", "endpoint", 100, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := llm.GenerateWithImage(ctx, "describe", bytes.NewReader([]byte("png")), MimeTypePNG); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(urls) != 2 || urls[0] != "data:image/png;base64,cG5n" || urls[1] != urls[0] {
+		t.Fatalf("unexpected image urls %v", urls)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("unexpected cache stats %d hits, %d misses", hits, misses)
+	}
+
+	small := NewAttachmentCache(8)
+	small.Base64([]byte("aaaa"))
+	small.Base64([]byte("bbbb"))
+	small.Base64([]byte("aaaa"))
+	if hits, _ := small.Stats(); hits != 0 || small.size > 8 {
+		t.Fatalf("least recently used entry should be evicted, %d hits, size %d", hits, small.size)
+	}
+}
+
+func TestAudioInput(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/chat/completions" {
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+			return
+		}
+		io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	messages := func(mimeType MimeType) []Message {
+		return []Message{{Role: RoleUser, Content: "Summarize", Audio: bytes.NewReader([]byte("wav")), AudioMimeType: mimeType}}
+	}
+	part := func(key string, i int) map[string]interface{} {
+		msg := body[key].([]interface{})[0].(map[string]interface{})
+		if key == "contents" {
+			return msg["parts"].([]interface{})[i].(map[string]interface{})
+		}
+		return msg["content"].([]interface{})[i].(map[string]interface{})
+	}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o-audio-preview", 100, 0, false)
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages(MimeTypeWAV)); err != nil {
+		t.Fatal(err)
+	}
+	audio := part("messages", 0)["input_audio"].(map[string]interface{})
+	if part("messages", 1)["text"] != "Summarize" || audio["data"] != "d2F2" || audio["format"] != "wav" {
+		t.Errorf("unexpected OpenAI audio message %v", body["messages"])
+	}
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages(MimeTypeOGG)); err == nil {
+		t.Error("expected OGG to be rejected by OpenAI")
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if _, err := gemini.GenerateResponse(context.Background(), messages(MimeTypeOGG)); err != nil {
+		t.Fatal(err)
+	}
+	data := part("contents", 0)["inlineData"].(map[string]interface{})
+	if data["mimeType"] != "audio/ogg" || data["data"] != "d2F2" || part("contents", 1)["text"] != "Summarize" {
+		t.Errorf("unexpected Gemini audio message %v", body["contents"])
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	if _, err := anthropic.GenerateWithMessages(context.Background(), messages(MimeTypeMP3)); err == nil {
+		t.Error("expected audio to be rejected by Claude")
+	}
+}
+
+func TestDocumentInput(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/messages" {
+			io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+			return
+		}
+		io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	messages := func(mimeType MimeType) []Message {
+		return []Message{{Role: RoleUser, Content: "Total of the invoice?", Document: bytes.NewReader([]byte("pdf")), DocumentMimeType: mimeType}}
+	}
+	part := func(key, parts string, i int) map[string]interface{} {
+		msg := body[key].([]interface{})[0].(map[string]interface{})
+		return msg[parts].([]interface{})[i].(map[string]interface{})
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	if _, err := anthropic.GenerateWithMessages(context.Background(), messages(MimeTypePDF)); err != nil {
+		t.Fatal(err)
+	}
+	doc := part("messages", "content", 0)
+	source := doc["source"].(map[string]interface{})
+	if doc["type"] != "document" || source["media_type"] != "application/pdf" || source["data"] != "cGRm" ||
+		part("messages", "content", 1)["text"] != "Total of the invoice?" {
+		t.Errorf("unexpected Anthropic document message %v", body["messages"])
+	}
+	if _, err := anthropic.GenerateWithMessages(context.Background(), messages("application/msword")); err == nil {
+		t.Error("expected Word documents to be rejected by Claude")
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if _, err := gemini.GenerateResponse(context.Background(), messages(MimeTypePDF)); err != nil {
+		t.Fatal(err)
+	}
+	data := part("contents", "parts", 0)["inlineData"].(map[string]interface{})
+	if data["mimeType"] != "application/pdf" || data["data"] != "cGRm" {
+		t.Errorf("unexpected Gemini document message %v", body["contents"])
+	}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o", 100, 0, false)
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages(MimeTypePDF)); err == nil {
+		t.Error("expected documents to be rejected by OpenAI")
+	}
+}
@@ -0,0 +1,241 @@
+package ai
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials are static credentials used to sign AWS requests
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSCredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func AWSCredentialsFromEnv() AWSCredentials {
+	return AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// signAWSRequest signs req with AWS Signature Version 4.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func signAWSRequest(req *http.Request, body []byte, creds AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for _, name := range []string{"content-type", "x-amz-content-sha256", "x-amz-date", "x-amz-security-token"} {
+		if v := req.Header.Get(name); v != "" {
+			headers[name] = strings.TrimSpace(v)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL.EscapedPath()),
+		awsCanonicalQuery(req),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalURI encodes every segment of an already escaped path once more,
+// as required for all services except S3
+func awsCanonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = awsURIEncode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func awsCanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsURIEncode(k)+"="+awsURIEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything but unreserved characters
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEventMessage is a message of the binary application/vnd.amazon.eventstream
+// encoding, used by AWS for streaming responses. Only string headers are kept.
+type awsEventMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+// readAWSEventStream reads event stream messages, calling fn for every message.
+// Reading stops when fn returns an error, which is returned (io.EOF is not).
+// https://docs.aws.amazon.com/transcribe/latest/dg/streaming-setting-up.html
+func readAWSEventStream(r io.Reader, fn func(msg awsEventMessage) error) error {
+	for {
+		prelude := make([]byte, 12)
+		if _, err := io.ReadFull(r, prelude); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		totalLen := binary.BigEndian.Uint32(prelude[0:4])
+		headersLen := binary.BigEndian.Uint32(prelude[4:8])
+		if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+			return fmt.Errorf("event stream: prelude checksum mismatch")
+		}
+		if uint64(totalLen) < 16+uint64(headersLen) || totalLen > 16*1024*1024 {
+			return fmt.Errorf("event stream: invalid message length %d", totalLen)
+		}
+
+		rest := make([]byte, totalLen-12)
+		if _, err := io.ReadFull(r, rest); err != nil {
+			return err
+		}
+		crc := crc32.NewIEEE()
+		crc.Write(prelude)
+		crc.Write(rest[:len(rest)-4])
+		if crc.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+			return fmt.Errorf("event stream: message checksum mismatch")
+		}
+
+		headers, err := parseAWSEventHeaders(rest[:headersLen])
+		if err != nil {
+			return err
+		}
+		msg := awsEventMessage{Headers: headers, Payload: rest[headersLen : len(rest)-4]}
+		if err := fn(msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func parseAWSEventHeaders(data []byte) (map[string]string, error) {
+	// sizes of the fixed length value types, by type id
+	fixedSizes := map[byte]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 4, 5: 8, 8: 8, 9: 16}
+
+	headers := map[string]string{}
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		nameLen, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, fmt.Errorf("event stream: invalid header: %v", err)
+		}
+		typ, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("event stream: invalid header: %v", err)
+		}
+
+		size, fixed := fixedSizes[typ]
+		if !fixed {
+			if typ != 6 && typ != 7 {
+				return nil, fmt.Errorf("event stream: unknown header type %d", typ)
+			}
+			var n uint16
+			if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+				return nil, fmt.Errorf("event stream: invalid header: %v", err)
+			}
+			size = int(n)
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, fmt.Errorf("event stream: invalid header: %v", err)
+		}
+		if typ == 7 {
+			headers[string(name)] = string(value)
+		}
+	}
+	return headers, nil
+}
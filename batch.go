@@ -0,0 +1,366 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+)
+
+// BatchStatus is the status of a batch job
+type BatchStatus string
+
+const (
+	BatchInProgress BatchStatus = "in_progress"
+	BatchCompleted  BatchStatus = "completed"
+	BatchFailed     BatchStatus = "failed"
+	BatchCancelled  BatchStatus = "cancelled"
+	BatchExpired    BatchStatus = "expired"
+)
+
+// Done reports whether the job finished, successfully or not. Results of
+// cancelled and expired jobs are available for the requests processed.
+func (s BatchStatus) Done() bool {
+	return s != BatchInProgress
+}
+
+// BatchRequest is a conversation to process in a batch
+type BatchRequest struct {
+	// ID identifies the result of the request, unique within the batch
+	ID       string
+	Messages []Message
+}
+
+// BatchJob is a batch of requests processed asynchronously
+type BatchJob struct {
+	ID     string
+	Status BatchStatus
+	// Total, Succeeded and Failed count the requests
+	Total     int
+	Succeeded int
+	Failed    int
+	// Error describes why the job failed
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt time.Time // zero until the job is done
+}
+
+// BatchResult is the result of a batch request
+type BatchResult struct {
+	// ID is the ID of the request
+	ID    string
+	Text  string
+	Usage TokenUsage
+	// Err is set if the request failed, expired or was cancelled
+	Err error
+}
+
+// Batcher processes requests asynchronously, at a discount over synchronous
+// requests. Results are returned in no particular order, match them to the
+// requests by ID.
+//
+//	batches := client.Batches()
+//	job, _ := batches.Submit(ctx, requests)
+//	job, _ = ai.WaitBatch(ctx, batches, job.ID, time.Minute)
+//	results, _ := batches.Results(ctx, job.ID)
+type Batcher interface {
+	Submit(ctx context.Context, requests []BatchRequest) (BatchJob, error)
+	Get(ctx context.Context, id string) (BatchJob, error)
+	Cancel(ctx context.Context, id string) (BatchJob, error)
+	// Results returns the results of a done job
+	Results(ctx context.Context, id string) ([]BatchResult, error)
+}
+
+// WaitBatch polls the job every interval until it is done. It returns the job
+// with an error if it failed.
+func WaitBatch(ctx context.Context, b Batcher, id string, interval time.Duration) (BatchJob, error) {
+	for {
+		job, err := b.Get(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		if job.Status == BatchFailed {
+			return job, fmt.Errorf("batch job %s failed: %s", id, job.Error)
+		}
+		if job.Status.Done() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// OpenAIBatches processes chat completions with the OpenAI Batch API, within
+// 24 hours. Requests use the settings of the client, except the top K of
+// Sampling.
+type OpenAIBatches struct {
+	o *OpenAI
+}
+
+// Batches returns the Batch API of the client
+func (o *OpenAI) Batches() *OpenAIBatches {
+	return &OpenAIBatches{o: o}
+}
+
+// openAIBatchLine is a line of a batch input or output file
+type openAIBatchLine struct {
+	CustomID string      `json:"custom_id"`
+	Method   string      `json:"method,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Body     interface{} `json:"body,omitempty"`
+	Response *struct {
+		StatusCode int                   `json:"status_code"`
+		Body       openai.ChatCompletion `json:"body"`
+	} `json:"response,omitempty"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Submit uploads the requests as a JSONL file and creates a batch job
+func (b *OpenAIBatches) Submit(ctx context.Context, requests []BatchRequest) (BatchJob, error) {
+	var input bytes.Buffer
+	enc := json.NewEncoder(&input)
+	for _, req := range requests {
+		params, err := b.o.messagesParams(ctx, req.Messages)
+		if err != nil {
+			return BatchJob{}, fmt.Errorf("request %s: %w", req.ID, err)
+		}
+		b.o.requestParams(ctx, &params)
+		line := openAIBatchLine{CustomID: req.ID, Method: "POST", URL: string(openai.BatchNewParamsEndpointV1ChatCompletions), Body: params}
+		if err := enc.Encode(line); err != nil {
+			return BatchJob{}, fmt.Errorf("request %s: %w", req.ID, err)
+		}
+	}
+
+	files := &openAIFiles{client: b.o.client, purpose: openai.FilePurposeBatch}
+	file, err := files.Upload(ctx, "batch.jsonl", &input, "application/jsonl")
+	if err != nil {
+		return BatchJob{}, fmt.Errorf("failed to upload batch: %w", err)
+	}
+	batch, err := b.o.client.Batches.New(ctx, openai.BatchNewParams{
+		CompletionWindow: openai.F(openai.BatchNewParamsCompletionWindow24h),
+		Endpoint:         openai.F(openai.BatchNewParamsEndpointV1ChatCompletions),
+		InputFileID:      openai.F(file.ID),
+	})
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return openAIBatchJob(batch), nil
+}
+
+func (b *OpenAIBatches) Get(ctx context.Context, id string) (BatchJob, error) {
+	batch, err := b.o.client.Batches.Get(ctx, id)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return openAIBatchJob(batch), nil
+}
+
+func (b *OpenAIBatches) Cancel(ctx context.Context, id string) (BatchJob, error) {
+	batch, err := b.o.client.Batches.Cancel(ctx, id)
+	if err != nil {
+		return BatchJob{}, err
+	}
+	return openAIBatchJob(batch), nil
+}
+
+// Results downloads the output and error files of the job
+func (b *OpenAIBatches) Results(ctx context.Context, id string) ([]BatchResult, error) {
+	batch, err := b.o.client.Batches.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.OutputFileID == "" && batch.ErrorFileID == "" {
+		return nil, fmt.Errorf("batch job %s has no results", id)
+	}
+	var results []BatchResult
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		lines, err := b.download(ctx, fileID)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			results = append(results, b.result(line))
+		}
+	}
+	return results, nil
+}
+
+// download returns the lines of a batch output file
+func (b *OpenAIBatches) download(ctx context.Context, fileID string) ([]openAIBatchLine, error) {
+	resp, err := b.o.client.Files.Content(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download batch results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var lines []openAIBatchLine
+	scanner := bufio.NewScanner(resp.Body)
+	// completions can be larger than the default limit of 64KB
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line openAIBatchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("failed to decode batch result: %v", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func (b *OpenAIBatches) result(line openAIBatchLine) BatchResult {
+	res := BatchResult{ID: line.CustomID}
+	switch {
+	case line.Error != nil:
+		res.Err = fmt.Errorf("%s: %s", line.Error.Code, line.Error.Message)
+	case line.Response == nil:
+		res.Err = errors.New("no response")
+	case line.Response.StatusCode != 200:
+		res.Err = fmt.Errorf("request failed with status %d", line.Response.StatusCode)
+	default:
+		completion := line.Response.Body
+		res.Usage = TokenUsage{InputTokens: int(completion.Usage.PromptTokens), OutputTokens: int(completion.Usage.CompletionTokens)}
+		res.Text, res.Err = b.o.content(&completion)
+	}
+	return res
+}
+
+func openAIBatchJob(batch *openai.Batch) BatchJob {
+	job := BatchJob{
+		ID:        batch.ID,
+		Total:     int(batch.RequestCounts.Total),
+		Succeeded: int(batch.RequestCounts.Completed),
+		Failed:    int(batch.RequestCounts.Failed),
+		CreatedAt: time.Unix(batch.CreatedAt, 0),
+	}
+	var finishedAt int64
+	switch batch.Status {
+	case openai.BatchStatusCompleted:
+		job.Status, finishedAt = BatchCompleted, batch.CompletedAt
+	case openai.BatchStatusFailed:
+		job.Status, finishedAt = BatchFailed, batch.FailedAt
+		for _, e := range batch.Errors.Data {
+			if job.Error != "" {
+				job.Error += "; "
+			}
+			job.Error += e.Message
+		}
+	case openai.BatchStatusCancelled:
+		job.Status, finishedAt = BatchCancelled, batch.CancelledAt
+	case openai.BatchStatusExpired:
+		job.Status, finishedAt = BatchExpired, batch.ExpiredAt
+	default:
+		job.Status = BatchInProgress
+	}
+	if finishedAt != 0 {
+		job.FinishedAt = time.Unix(finishedAt, 0)
+	}
+	return job
+}
+
+// AnthropicBatches processes requests with the Anthropic Message Batches API,
+// within 24 hours. Requests use the settings of the client. Bedrock is not
+// supported.
+type AnthropicBatches struct {
+	a *Anthropic
+}
+
+// Batches returns the Message Batches API of the client
+func (a *Anthropic) Batches() *AnthropicBatches {
+	return &AnthropicBatches{a: a}
+}
+
+func (b *AnthropicBatches) Submit(ctx context.Context, requests []BatchRequest) (BatchJob, error) {
+	if b.a.bedrock != nil {
+		return BatchJob{}, errors.New("message batches are not supported on Bedrock")
+	}
+	batch := anthropic.BatchRequest{Requests: make([]anthropic.InnerRequests, len(requests))}
+	for i, req := range requests {
+		params, err := b.a.newRequest(ctx, "", req.Messages)
+		if err != nil {
+			return BatchJob{}, fmt.Errorf("request %s: %w", req.ID, err)
+		}
+		batch.Requests[i] = anthropic.InnerRequests{CustomId: req.ID, Params: params}
+	}
+	resp, err := b.a.client.CreateBatch(ctx, batch)
+	if err != nil {
+		return BatchJob{}, apiError(err)
+	}
+	return anthropicBatchJob(resp.BatchRespCore), nil
+}
+
+func (b *AnthropicBatches) Get(ctx context.Context, id string) (BatchJob, error) {
+	resp, err := b.a.client.RetrieveBatch(ctx, anthropic.BatchId(id))
+	if err != nil {
+		return BatchJob{}, apiError(err)
+	}
+	return anthropicBatchJob(resp.BatchRespCore), nil
+}
+
+func (b *AnthropicBatches) Cancel(ctx context.Context, id string) (BatchJob, error) {
+	resp, err := b.a.client.CancelBatch(ctx, anthropic.BatchId(id))
+	if err != nil {
+		return BatchJob{}, apiError(err)
+	}
+	return anthropicBatchJob(resp.BatchRespCore), nil
+}
+
+func (b *AnthropicBatches) Results(ctx context.Context, id string) ([]BatchResult, error) {
+	resp, err := b.a.client.RetrieveBatchResults(ctx, anthropic.BatchId(id))
+	if err != nil {
+		return nil, apiError(err)
+	}
+	results := make([]BatchResult, len(resp.Responses))
+	for i, r := range resp.Responses {
+		results[i] = BatchResult{ID: r.CustomId}
+		if r.Result.Type != anthropic.ResultTypeSucceeded {
+			results[i].Err = fmt.Errorf("request %s", r.Result.Type)
+			continue
+		}
+		msg := r.Result.Result
+		results[i].Usage = TokenUsage{InputTokens: msg.Usage.InputTokens, OutputTokens: msg.Usage.OutputTokens}
+		results[i].Text, results[i].Err = b.a.text(msg)
+	}
+	return results, nil
+}
+
+func anthropicBatchJob(batch anthropic.BatchRespCore) BatchJob {
+	counts := batch.RequestCounts
+	job := BatchJob{
+		ID:        string(batch.Id),
+		Status:    BatchInProgress,
+		Total:     counts.Processing + counts.Succeeded + counts.Errored + counts.Canceled + counts.Expired,
+		Succeeded: counts.Succeeded,
+		Failed:    counts.Errored + counts.Canceled + counts.Expired,
+		CreatedAt: batch.CreatedAt,
+	}
+	if batch.ProcessingStatus == anthropic.ProcessingStatusEnded {
+		job.Status = BatchCompleted
+		if batch.CancelInitiatedAt != nil {
+			job.Status = BatchCancelled
+		} else if counts.Expired > 0 {
+			job.Status = BatchExpired
+		}
+		if batch.EndedAt != nil {
+			job.FinishedAt = *batch.EndedAt
+		}
+	}
+	return job
+}
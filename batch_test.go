@@ -0,0 +1,148 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAIBatches(t *testing.T) {
+	var input string
+	batchStatus := "in_progress"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		batch := `{"id": "batch_1", "status": "` + batchStatus + `", "created_at": 1700000000, "completed_at": 1700000100,
+			"output_file_id": "file-out", "error_file_id": "file-err", "request_counts": {"total": 3, "completed": 1, "failed": 2}}`
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/files":
+			r.ParseMultipartForm(1 << 20)
+			if r.FormValue("purpose") != "batch" {
+				t.Errorf("unexpected purpose %q", r.FormValue("purpose"))
+			}
+			file, _, _ := r.FormFile("file")
+			data, _ := io.ReadAll(file)
+			input = string(data)
+			w.Write([]byte(`{"id": "file-in", "filename": "batch.jsonl", "created_at": 1700000000}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/batches":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["input_file_id"] != "file-in" || body["endpoint"] != "/v1/chat/completions" {
+				t.Errorf("unexpected batch %v", body)
+			}
+			w.Write([]byte(batch))
+		case r.URL.Path == "/batches/batch_1":
+			w.Write([]byte(batch))
+			batchStatus = "completed"
+		case r.URL.Path == "/files/file-out/content":
+			w.Write([]byte(`{"custom_id": "a", "response": {"status_code": 200, "body": {"choices": [{"message": {"content": "A"}}], "usage": {"prompt_tokens": 5, "completion_tokens": 1}}}}
+{"custom_id": "b", "response": {"status_code": 400, "body": {}}}
+`))
+		case r.URL.Path == "/files/file-err/content":
+			w.Write([]byte(`{"custom_id": "c", "error": {"code": "batch_expired", "message": "expired"}}` + "\n"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	batches := NewOpenAICompatible(server.URL, "key", "gpt-4o-mini", 100, 0, false).Batches()
+	job, err := batches.Submit(ctx, []BatchRequest{
+		{ID: "a", Messages: []Message{{Role: RoleUser, Content: "say A"}}},
+		{ID: "b", Messages: []Message{{Role: RoleUser, Content: "say B"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(input), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"custom_id":"a"`) || !strings.Contains(lines[0], `"model":"gpt-4o-mini"`) || !strings.Contains(lines[1], "say B") {
+		t.Fatalf("unexpected input file:\n%s", input)
+	}
+	if job.ID != "batch_1" || job.Status != BatchInProgress || job.Status.Done() || !job.FinishedAt.IsZero() {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	job, err = WaitBatch(ctx, batches, job.ID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != BatchCompleted || job.Total != 3 || job.Failed != 2 || job.FinishedAt.Unix() != 1700000100 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+
+	results, err := batches.Results(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].ID != "a" || results[0].Text != "A" || results[0].Err != nil || results[0].Usage.InputTokens != 5 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[1].Err == nil || results[2].ID != "c" || results[2].Err == nil {
+		t.Fatalf("failed requests should have errors: %+v", results)
+	}
+}
+
+func TestAnthropicBatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/messages/batches":
+			var body struct {
+				Requests []struct {
+					CustomID string `json:"custom_id"`
+					Params   struct {
+						Model string `json:"model"`
+					} `json:"params"`
+				} `json:"requests"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if len(body.Requests) != 1 || body.Requests[0].CustomID != "a" || body.Requests[0].Params.Model != "claude-3-5-haiku-latest" {
+				t.Errorf("unexpected batch %+v", body)
+			}
+			w.Write([]byte(`{"id": "msgbatch_1", "type": "message_batch", "processing_status": "in_progress",
+				"request_counts": {"processing": 1}, "created_at": "2024-09-24T18:37:24Z", "expires_at": "2024-09-25T18:37:24Z"}`))
+		case "/messages/batches/msgbatch_1":
+			w.Write([]byte(`{"id": "msgbatch_1", "type": "message_batch", "processing_status": "ended",
+				"request_counts": {"succeeded": 1, "errored": 1}, "created_at": "2024-09-24T18:37:24Z",
+				"ended_at": "2024-09-24T19:00:00Z", "expires_at": "2024-09-25T18:37:24Z"}`))
+		case "/messages/batches/msgbatch_1/results":
+			w.Write([]byte(`{"custom_id": "a", "result": {"type": "succeeded", "message": {"content": [{"type": "text", "text": "A"}], "usage": {"input_tokens": 5, "output_tokens": 1}}}}
+{"custom_id": "b", "result": {"type": "errored"}}
+`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client := NewAnthropic("key", "claude-3-5-haiku-latest", 100, 0, false)
+	client.SetBaseURL(server.URL)
+	batches := client.Batches()
+	job, err := batches.Submit(ctx, []BatchRequest{{ID: "a", Messages: []Message{{Role: RoleUser, Content: "say A"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != BatchInProgress || job.Total != 1 {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	job, err = WaitBatch(ctx, batches, job.ID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != BatchCompleted || job.Succeeded != 1 || job.Failed != 1 || job.FinishedAt.IsZero() {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	results, err := batches.Results(ctx, job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Text != "A" || results[0].Usage.OutputTokens != 1 || results[1].Err == nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
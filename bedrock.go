@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+// bedrockAnthropicVersion is sent in the request body instead of the anthropic-version header
+const bedrockAnthropicVersion = "bedrock-2023-05-31"
+
+// NewAnthropicBedrock creates a client for Claude on AWS Bedrock. Requests are
+// signed with SigV4, model is a Bedrock model or inference profile ID,
+// e.g. "anthropic.claude-3-5-sonnet-20240620-v1:0".
+// https://docs.anthropic.com/en/api/claude-on-amazon-bedrock
+func NewAnthropicBedrock(region string, creds AWSCredentials, model string, maxTokens int, temperature float32, cachePrompt bool) *Anthropic {
+	adapter := &bedrockAdapter{
+		region:  region,
+		creds:   creds,
+		baseURL: "https://bedrock-runtime." + region + ".amazonaws.com",
+	}
+	return &Anthropic{
+		client:      newBedrockClient(adapter, nil),
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		cachePrompt: cachePrompt,
+		bedrock:     adapter,
+	}
+}
+
+// newBedrockClient creates a go-anthropic client sending requests through
+// adapter with client, or the default client if nil
+func newBedrockClient(adapter *bedrockAdapter, client *http.Client) *anthropic.Client {
+	return anthropic.NewClient("", func(c *anthropic.ClientConfig) {
+		c.Adapter = adapter
+		c.APIVersion = bedrockAnthropicVersion
+		if client != nil {
+			c.HTTPClient = client
+		}
+	})
+}
+
+// bedrockAdapter routes go-anthropic requests to the Bedrock runtime API
+type bedrockAdapter struct {
+	region  string
+	creds   AWSCredentials
+	baseURL string
+}
+
+func (b *bedrockAdapter) url(model string, stream bool) string {
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	return b.baseURL + "/model/" + awsURIEncode(model) + "/" + action
+}
+
+func (b *bedrockAdapter) TranslateError(resp *http.Response, body []byte) (error, bool) {
+	return &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}, true
+}
+
+func (b *bedrockAdapter) PrepareRequest(c *anthropic.Client, method, urlSuffix string, body any) (string, error) {
+	req, ok := body.(anthropic.VertexAISupport)
+	if !ok || urlSuffix != "/messages" {
+		return "", fmt.Errorf("this call is not supported by Bedrock")
+	}
+	model := string(req.GetModel())
+	req.SetAnthropicVersion(bedrockAnthropicVersion)
+	return b.url(model, req.IsStreaming()), nil
+}
+
+func (b *bedrockAdapter) SetRequestHeaders(c *anthropic.Client, req *http.Request) error {
+	var body []byte
+	if req.GetBody != nil {
+		r, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if body, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	}
+	signAWSRequest(req, body, b.creds, b.region, "bedrock", time.Now())
+	return nil
+}
+
+// generateStreamBedrock streams from invoke-with-response-stream, which wraps
+// the regular Anthropic stream events in the AWS event stream encoding
+func (a *Anthropic) generateStreamBedrock(ctx context.Context, systemPrompt string, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	messagesReq, err := a.newRequest(ctx, systemPrompt, messages)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	messagesReq.SetAnthropicVersion(bedrockAnthropicVersion)
+	body, err := json.Marshal(&messagesReq)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.bedrock.url(requestModel(ctx, a.model), true), bytes.NewReader(body))
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	signAWSRequest(req, body, a.bedrock.creds, a.bedrock.region, "bedrock", time.Now())
+
+	client := a.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		sendErr(&HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(data)})
+		return
+	}
+
+	err = readAWSEventStream(resp.Body, func(msg awsEventMessage) error {
+		switch msg.Headers[":message-type"] {
+		case "exception":
+			return fmt.Errorf("bedrock %s: %s", msg.Headers[":exception-type"], strings.TrimSpace(string(msg.Payload)))
+		case "error":
+			return fmt.Errorf("bedrock %s: %s", msg.Headers[":error-code"], msg.Headers[":error-message"])
+		}
+		if msg.Headers[":event-type"] != "chunk" {
+			return nil
+		}
+
+		var chunk struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(chunk.Bytes)
+		if err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("invalid stream event: %v", err)
+		}
+
+		switch anthropic.MessagesEvent(event.Type) {
+		case anthropic.MessagesEventContentBlockDelta:
+			if event.Delta.Text == "" {
+				return nil
+			}
+			select {
+			case resultCh <- event.Delta.Text:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case anthropic.MessagesEventMessageStop:
+			return io.EOF
+		case anthropic.MessagesEventError:
+			return &AnthropicError{Type: event.Error.Type, Message: event.Error.Message}
+		}
+		return nil
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
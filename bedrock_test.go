@@ -0,0 +1,118 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// encodeAWSEvent encodes a message with string headers in the AWS event stream format
+func encodeAWSEvent(headers map[string]string, payload []byte) []byte {
+	var hdr []byte
+	for name, value := range headers {
+		hdr = append(hdr, byte(len(name)))
+		hdr = append(hdr, name...)
+		hdr = append(hdr, 7)
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(len(value)))
+		hdr = append(hdr, value...)
+	}
+	total := 16 + len(hdr) + len(payload)
+	msg := binary.BigEndian.AppendUint32(nil, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(hdr)))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, hdr...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+func bedrockChunk(event string) []byte {
+	payload, _ := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString([]byte(event))})
+	return encodeAWSEvent(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, payload)
+}
+
+func TestAnthropicBedrock(t *testing.T) {
+	var paths []string
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			r.Header.Get("X-Amz-Security-Token") != "token" {
+			t.Errorf("request is not signed: %v", r.Header)
+		}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+
+		if strings.HasSuffix(r.URL.Path, "/invoke") {
+			w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"hello"}],"stop_reason":"end_turn"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.Write(bedrockChunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hel"}}`))
+		w.Write(bedrockChunk(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`))
+		w.Write(bedrockChunk(`{"type":"message_stop"}`))
+	}))
+	defer server.Close()
+
+	llm := NewAnthropicBedrock("us-east-1", AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret", SessionToken: "token"},
+		"anthropic.claude-3-haiku-20240307-v1:0", 100, 0, false)
+	llm.bedrock.baseURL = server.URL
+
+	res, err := llm.Generate(context.Background(), "Be brief", "Say hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "hello" {
+		t.Errorf("unexpected response %q", res)
+	}
+	if body["anthropic_version"] != bedrockAnthropicVersion || body["model"] != nil || body["system"] != "Be brief" {
+		t.Errorf("unexpected body %v", body)
+	}
+
+	var out strings.Builder
+	err = consumeStream(context.Background(), llm, "", "Say hello", func(chunk string) error {
+		out.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello" {
+		t.Errorf("unexpected stream output %q", out.String())
+	}
+	if _, ok := body["stream"]; ok {
+		t.Errorf("stream field must not be sent to Bedrock: %v", body)
+	}
+
+	want := []string{
+		"/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke",
+		"/model/anthropic.claude-3-haiku-20240307-v1%3A0/invoke-with-response-stream",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("unexpected paths %v", paths)
+	}
+}
+
+func TestReadAWSEventStreamInvalidLength(t *testing.T) {
+	// 16+headersLen wraps around in 32 bits
+	msg := binary.BigEndian.AppendUint32(nil, 32)
+	msg = binary.BigEndian.AppendUint32(msg, 0xfffffff8)
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+	msg = append(msg, make([]byte, 20)...)
+	binary.BigEndian.PutUint32(msg[28:], crc32.ChecksumIEEE(msg[:28]))
+
+	err := readAWSEventStream(strings.NewReader(string(msg)), func(awsEventMessage) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "invalid message length") {
+		t.Fatalf("expected an invalid length error, got %v", err)
+	}
+}
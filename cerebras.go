@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"github.com/openai/openai-go/option"
+)
+
+// https://inference-docs.cerebras.ai/api-reference/chat-completions
+// Cerebras is OpenAI compatible, but names the output limit
+// max_completion_tokens and rejects some sampling parameters.
+func NewCerebras(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	return NewOpenAICompatible("https://api.cerebras.ai/v1/", apiKey, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(rewriteJSONBody(cerebrasCompat)))
+}
+
+var cerebrasUnsupportedParams = []string{
+	"frequency_penalty", "presence_penalty", "logit_bias", "n",
+	"store", "metadata", "modalities", "audio", "prediction", "service_tier",
+	// streamed usage is sent in the last chunk without asking
+	"stream_options",
+}
+
+func cerebrasCompat(body map[string]interface{}) {
+	if v, ok := body["max_tokens"]; ok {
+		if _, set := body["max_completion_tokens"]; !set {
+			body["max_completion_tokens"] = v
+		}
+		delete(body, "max_tokens")
+	}
+	for _, key := range cerebrasUnsupportedParams {
+		delete(body, key)
+	}
+}
@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ChaosConfig is the degradation injected by ChaosLLM. The zero value
+// injects nothing.
+type ChaosConfig struct {
+	// Latency delays every call
+	Latency time.Duration
+	// Jitter adds a random delay of up to Jitter to every call
+	Jitter time.Duration
+	// ErrorRate is the share of calls (0 to 1) that fail with status 503
+	ErrorRate float64
+	// RateLimitRate is the share of calls that fail with status 429
+	RateLimitRate float64
+	// HangRate is the share of calls that never answer, until the context
+	// is done
+	HangRate float64
+	// Seed makes the random choices reproducible
+	Seed int64
+}
+
+// ChaosLLM injects latency, jitter and errors into the calls of a real
+// provider, to check timeouts, retries and fallbacks under degraded
+// conditions in staging. Injected failures happen before the provider is
+// called and are HTTPErrors, classified like real ones.
+//
+//	llm := ai.NewOpenAI(key, "gpt-4o", 1000, 0, false)
+//	if env == "staging" {
+//		llm = ai.NewChaosLLM(llm, ai.ChaosConfig{Jitter: 2 * time.Second, ErrorRate: 0.05})
+//	}
+type ChaosLLM struct {
+	LLM
+
+	mu     sync.Mutex
+	config ChaosConfig
+	rand   *rand.Rand
+}
+
+// NewChaosLLM creates a ChaosLLM
+func NewChaosLLM(llm LLM, config ChaosConfig) *ChaosLLM {
+	c := &ChaosLLM{LLM: llm}
+	c.SetConfig(config)
+	return c
+}
+
+// SetConfig changes the injected degradation, e.g. to run scenarios one after
+// another or to turn it off with a zero config
+func (c *ChaosLLM) SetConfig(config ChaosConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+	c.rand = rand.New(rand.NewSource(config.Seed))
+}
+
+// inject waits for the injected delay and returns the injected failure, if any
+func (c *ChaosLLM) inject(ctx context.Context) error {
+	c.mu.Lock()
+	config := c.config
+	delay := config.Latency
+	if config.Jitter > 0 {
+		delay += time.Duration(c.rand.Int63n(int64(config.Jitter)))
+	}
+	// One draw, so that the rates add up
+	roll := c.rand.Float64()
+	c.mu.Unlock()
+
+	var err error
+	switch {
+	case roll < config.HangRate:
+		<-ctx.Done()
+		return ctx.Err()
+	case roll < config.HangRate+config.ErrorRate:
+		err = &HTTPError{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: "chaos: injected service unavailable"}
+	case roll < config.HangRate+config.ErrorRate+config.RateLimitRate:
+		err = &HTTPError{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: "chaos: injected rate limit"}
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (c *ChaosLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	if err := c.inject(ctx); err != nil {
+		return "", err
+	}
+	return c.LLM.Generate(ctx, systemPrompt, prompt)
+}
+
+func (c *ChaosLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	if err := c.inject(ctx); err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	c.LLM.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+}
+
+func (c *ChaosLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	if err := c.inject(ctx); err != nil {
+		return "", err
+	}
+	return c.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+}
+
+func (c *ChaosLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if err := c.inject(ctx); err != nil {
+		return "", err
+	}
+	return c.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+}
+
+func (c *ChaosLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	if err := c.inject(ctx); err != nil {
+		return "", err
+	}
+	return c.LLM.GenerateWithMessages(ctx, messages)
+}
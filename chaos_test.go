@@ -0,0 +1,52 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChaosLLM(t *testing.T) {
+	ctx := context.Background()
+	llm := NewChaosLLM(NewMockLLM("mock", "ok"), ChaosConfig{ErrorRate: 0.2, RateLimitRate: 0.1, Seed: 3})
+	var failed, limited int
+	for i := 0; i < 500; i++ {
+		_, err := llm.Generate(ctx, "", "hi")
+		if err == nil {
+			continue
+		}
+		if !IsRetryable(err) {
+			t.Fatalf("injected errors should be retryable, got %v", err)
+		}
+		failed++
+		if ok, _ := rateLimited(err); ok {
+			limited++
+		}
+	}
+	if failed < 120 || failed > 180 || limited < 30 || limited > 70 {
+		t.Errorf("unexpected failures %d, rate limits %d out of 500", failed, limited)
+	}
+
+	llm.SetConfig(ChaosConfig{Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond})
+	start := time.Now()
+	if res, err := llm.Generate(ctx, "", "hi"); err != nil || res != "ok" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 200*time.Millisecond {
+		t.Errorf("unexpected latency %v", elapsed)
+	}
+
+	// Hanging calls are cut by timeouts, and fallbacks take over
+	llm.SetConfig(ChaosConfig{HangRate: 1})
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := llm.Generate(timeoutCtx, "", "hi"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	llm.SetConfig(ChaosConfig{ErrorRate: 1})
+	router := NewFallbackLLM([]LLM{llm, NewMockLLM("backup", "backup answer")}, nil)
+	if res, err := router.Generate(ctx, "", "hi"); err != nil || res != "backup answer" {
+		t.Errorf("unexpected fallback result %q, %v", res, err)
+	}
+}
@@ -0,0 +1,55 @@
+package ai
+
+import "io"
+
+// Chat builds a conversation fluently and can be passed wherever []Message
+// is expected:
+//
+//	messages := ai.Chat{}.
+//		System("You are a helpful assistant.").
+//		UserImage(image, ai.MimeTypePNG).
+//		User("What is in the picture?")
+//	res, err := llm.GenerateWithMessages(ctx, messages)
+//
+// Every method returns a new Chat, so a common prefix can be extended in
+// different ways.
+type Chat []Message
+
+// Add returns c with msg appended
+func (c Chat) Add(msg Message) Chat {
+	// the capacity is clipped so that chats sharing a prefix never share the rest
+	return append(c[:len(c):len(c)], msg)
+}
+
+// System returns c with a system message appended
+func (c Chat) System(content string) Chat {
+	return c.Add(Message{Role: RoleSystem, Content: content})
+}
+
+// User returns c with a user message appended
+func (c Chat) User(content string) Chat {
+	return c.Add(Message{Role: RoleUser, Content: content})
+}
+
+// UserImage returns c with a user message holding image appended. The image
+// is read when the chat is sent, so a chat with images can be sent once.
+func (c Chat) UserImage(image io.Reader, mimeType MimeType) Chat {
+	return c.Add(Message{Role: RoleUser, Image: image, MimeType: mimeType})
+}
+
+// UserParts returns c with a user message of interleaved parts appended,
+// e.g. ai.TextPart("Before:"), ai.ImagePart(before, ai.MimeTypePNG)
+func (c Chat) UserParts(parts ...ContentPart) Chat {
+	return c.Add(Message{Role: RoleUser, Parts: parts})
+}
+
+// Assistant returns c with an assistant message appended, e.g. a previous
+// reply or a few-shot example
+func (c Chat) Assistant(content string) Chat {
+	return c.Add(Message{Role: RoleAssistant, Content: content})
+}
+
+// Messages returns the messages of c
+func (c Chat) Messages() []Message {
+	return c
+}
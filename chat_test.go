@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestChat(t *testing.T) {
+	base := Chat{}.System("Be brief.").User("Hi").Assistant("Hello!")
+	a := base.User("First")
+	b := base.User("Second")
+	if len(base) != 3 || a[3].Content != "First" || b[3].Content != "Second" {
+		t.Fatalf("chats sharing a prefix interfere: %v %v", a, b)
+	}
+	if base[0].Role != RoleSystem || base[1].Role != RoleUser || base[2].Role != RoleAssistant {
+		t.Errorf("unexpected roles %v", base)
+	}
+
+	image := bytes.NewReader([]byte("png"))
+	messages := Chat{}.UserImage(image, MimeTypePNG).User("What is this?").Messages()
+	if messages[0].Image != image || messages[0].MimeType != MimeTypePNG || messages[0].Role != RoleUser {
+		t.Errorf("unexpected image message %+v", messages[0])
+	}
+
+	var received []Message
+	llm := &messagesLLM{stubLLM: stubLLM{model: "m"}, fn: func(m []Message) { received = m }}
+	if _, err := llm.GenerateWithMessages(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 4 {
+		t.Errorf("expected the chat to be sent as messages, got %v", received)
+	}
+}
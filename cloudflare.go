@@ -0,0 +1,187 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Cloudflare runs models on Cloudflare Workers AI, e.g. "@cf/meta/llama-3.1-8b-instruct"
+// https://developers.cloudflare.com/workers-ai/
+type Cloudflare struct {
+	accountID   string
+	apiToken    string
+	model       string
+	maxTokens   int
+	temperature float32
+	baseURL     string
+	httpClient  *http.Client
+	seed        *int64
+}
+
+func NewCloudflare(accountID, apiToken, model string, maxTokens int, temperature float32) *Cloudflare {
+	return &Cloudflare{
+		accountID:   accountID,
+		apiToken:    apiToken,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		baseURL:     "https://api.cloudflare.com/client/v4/",
+		httpClient:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (c *Cloudflare) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+type cloudflareMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type cloudflareResponse struct {
+	Result struct {
+		Response string `json:"response"`
+	} `json:"result"`
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// SetSeed sets the seed of every request, see WithSeed
+func (c *Cloudflare) SetSeed(seed int64) {
+	c.seed = &seed
+}
+
+func (c *Cloudflare) url(ctx context.Context) string {
+	return c.baseURL + "accounts/" + c.accountID + "/ai/run/" + requestModel(ctx, c.model)
+}
+
+func (c *Cloudflare) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + c.apiToken}
+}
+
+func (c *Cloudflare) request(messages []Message, stream bool) (map[string]interface{}, error) {
+	var msgs []cloudflareMessage
+	for _, msg := range messages {
+		msg = msg.flat()
+		if msg.Image != nil {
+			return nil, fmt.Errorf("cloudflare client does not support images")
+		}
+		msgs = append(msgs, cloudflareMessage{Role: string(msg.Role), Content: msg.Content})
+	}
+	return map[string]interface{}{
+		"messages":    msgs,
+		"max_tokens":  c.maxTokens,
+		"temperature": c.temperature,
+		"stream":      stream,
+	}, nil
+}
+
+func (c *Cloudflare) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return c.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (c *Cloudflare) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	c.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (c *Cloudflare) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	body, err := c.request(messages, true)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	seedBody(ctx, body, "seed", c.seed)
+	deterministicBody(ctx, body, "seed")
+	resp, err := sendJSON(ctx, c.httpClient, http.MethodPost, c.url(ctx), c.headers(), body)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		var chunk struct {
+			Response string `json:"response"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if chunk.Response == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk.Response:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (c *Cloudflare) GetModel() string {
+	return c.model
+}
+
+func (c *Cloudflare) Close() error {
+	return nil
+}
+
+func (c *Cloudflare) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return "", fmt.Errorf("cloudflare client does not support images")
+}
+
+func (c *Cloudflare) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return "", fmt.Errorf("cloudflare client does not support images")
+}
+
+func (c *Cloudflare) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	body, err := c.request(messages, false)
+	if err != nil {
+		return "", err
+	}
+	seedBody(ctx, body, "seed", c.seed)
+	deterministicBody(ctx, body, "seed")
+
+	var resp cloudflareResponse
+	err = doJSON(ctx, c.httpClient, http.MethodPost, c.url(ctx), c.headers(), body, &resp)
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success {
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return "", fmt.Errorf("workers ai error: %s", strings.Join(msgs, "; "))
+	}
+	return resp.Result.Response, nil
+}
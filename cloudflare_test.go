@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCloudflare(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/accounts/acc/ai/run/@cf/meta/llama-3.1-8b-instruct" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case body["stream"] == true:
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"response\":\"he\"}\n\n")
+			io.WriteString(w, "data: {\"response\":\"\"}\n\n")
+			io.WriteString(w, "data: {\"response\":\"llo\"}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+		case body["max_tokens"] == float64(1):
+			io.WriteString(w, `{"result":null,"success":false,"errors":[{"code":5007,"message":"No such model"}]}`)
+		default:
+			io.WriteString(w, `{"result":{"response":"hello"},"success":true,"errors":[]}`)
+		}
+	}))
+	defer server.Close()
+
+	llm := NewCloudflare("acc", "token", "@cf/meta/llama-3.1-8b-instruct", 100, 0.5)
+	llm.baseURL = server.URL + "/"
+	res, err := llm.Generate(WithSeed(context.Background(), 7), "Be brief", "hi")
+	if err != nil || res != "hello" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	messages, _ := body["messages"].([]interface{})
+	if len(messages) != 2 || body["max_tokens"] != float64(100) || body["temperature"] != 0.5 || body["seed"] != float64(7) {
+		t.Fatalf("unexpected request body %v", body)
+	}
+	if first, _ := messages[0].(map[string]interface{}); first["role"] != "system" || first["content"] != "Be brief" {
+		t.Fatalf("unexpected system message %v", messages[0])
+	}
+
+	var streamed string
+	err = consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		streamed += chunk
+		return nil
+	})
+	if err != nil || streamed != "hello" {
+		t.Fatalf("unexpected stream %q, %v", streamed, err)
+	}
+
+	failing := NewCloudflare("acc", "token", "@cf/meta/llama-3.1-8b-instruct", 1, 0.5)
+	failing.baseURL = server.URL + "/"
+	if _, err := failing.Generate(context.Background(), "", "hi"); err == nil || !strings.Contains(err.Error(), "5007: No such model") {
+		t.Fatalf("expected a workers ai error, got %v", err)
+	}
+
+	if _, err := llm.GenerateWithImage(context.Background(), "hi", strings.NewReader("img"), MimeTypePNG); err == nil {
+		t.Fatal("expected images to be rejected")
+	}
+}
@@ -0,0 +1,253 @@
+// Command ai-doctor checks every configured provider: credentials,
+// reachability, model availability, latency and quota headroom, and prints a
+// diagnostic report, exiting with status 1 if a configured provider fails.
+//
+// Providers are configured like ai.NewLLMFromSpec: PROVIDER_API_KEY and
+// PROVIDER_MODEL environment variables, or explicit specs.
+//
+//	ai-doctor
+//	ai-doctor -providers openai:gpt-4o-mini,anthropic:claude-3-5-haiku-latest
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alehano/ai"
+)
+
+// providers are checked when no specs are given, with the environment
+// variables holding their credentials
+var providers = []struct {
+	name string
+	env  []string
+}{
+	{"openai", []string{"OPENAI_API_KEY"}},
+	{"anthropic", []string{"ANTHROPIC_API_KEY"}},
+	{"google", []string{"GOOGLE_API_KEY"}},
+	{"xai", []string{"XAI_API_KEY"}},
+	{"groq", []string{"GROQ_API_KEY"}},
+	{"together", []string{"TOGETHER_API_KEY"}},
+	{"fireworks", []string{"FIREWORKS_API_KEY"}},
+	{"replicate", []string{"REPLICATE_API_KEY"}},
+	{"huggingface", []string{"HUGGINGFACE_API_KEY"}},
+	{"cloudflare", []string{"CLOUDFLARE_API_KEY", "CLOUDFLARE_ACCOUNT_ID"}},
+	{"bedrock", []string{"AWS_REGION", "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}},
+	{"lambda_lab", []string{"LAMBDA_LAB_API_KEY"}},
+	{"llamacpp", []string{"LLAMACPP_URL"}},
+}
+
+// check is the outcome of a check of a provider
+type check struct {
+	name   string
+	ok     bool
+	detail string
+}
+
+func main() {
+	specs := flag.String("providers", "", "comma separated provider:model specs (default: every provider with credentials in the environment)")
+	prompt := flag.String("prompt", "Reply with OK.", "prompt used to measure latency")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of each provider")
+	flag.Parse()
+
+	recorder := &headerRecorder{next: http.DefaultTransport}
+
+	var names []string
+	if *specs != "" {
+		names = strings.Split(*specs, ",")
+	} else {
+		for _, p := range providers {
+			names = append(names, p.name)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	failed := 0
+	for _, spec := range names {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		provider, _, _ := strings.Cut(spec, ":")
+		missing := missingEnv(provider)
+		if *specs == "" && len(missing) > 0 {
+			fmt.Fprintf(w, "%s\t-\tnot configured\t%s not set\n", provider, strings.Join(missing, ", "))
+			continue
+		}
+
+		checks := diagnose(spec, missing, *prompt, *timeout, recorder)
+		for i, c := range checks {
+			label := ""
+			if i == 0 {
+				label = spec
+			}
+			status := "ok"
+			if !c.ok {
+				status = "FAIL"
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", label, status, c.name, c.detail)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d checks failed\n", failed)
+		os.Exit(1)
+	}
+}
+
+// missingEnv returns the unset environment variables of provider's credentials
+func missingEnv(provider string) []string {
+	var missing []string
+	for _, p := range providers {
+		if p.name != strings.ToLower(provider) {
+			continue
+		}
+		for _, env := range p.env {
+			if os.Getenv(env) == "" {
+				missing = append(missing, env)
+			}
+		}
+	}
+	return missing
+}
+
+// diagnose runs the checks of a provider, stopping at the first one that
+// makes the following ones meaningless
+func diagnose(spec string, missing []string, prompt string, timeout time.Duration, recorder *headerRecorder) []check {
+	if len(missing) > 0 {
+		return []check{{"credentials", false, strings.Join(missing, ", ") + " not set"}}
+	}
+	checks := []check{{"credentials", true, "set"}}
+	llm, err := ai.NewLLMFromSpec(spec)
+	if err != nil {
+		return append(checks, check{"model", false, err.Error()})
+	}
+	defer llm.Close()
+	// the quota headers are recorded by the HTTP client of the provider
+	if s, ok := llm.(ai.HTTPClientSetter); ok {
+		s.SetHTTPClient(&http.Client{Transport: recorder})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	recorder.reset()
+
+	models, err := ai.ListModels(ctx, llm)
+	switch {
+	case err != nil && !isLister(llm):
+		checks = append(checks, check{"reachability", true, "model listing not supported, see latency"})
+	case err != nil:
+		return append(checks, check{"reachability", false, err.Error()})
+	default:
+		checks = append(checks, check{"reachability", true, fmt.Sprintf("%d models listed", len(models))})
+		checks = append(checks, availability(llm.GetModel(), models))
+	}
+
+	start := time.Now()
+	res, err := llm.Generate(ctx, "", prompt)
+	if err != nil {
+		return append(checks, check{"latency", false, err.Error()})
+	}
+	checks = append(checks, check{"latency", true, fmt.Sprintf("%s, %d characters", time.Since(start).Round(time.Millisecond), len(res))})
+	return append(checks, quota(recorder.last()))
+}
+
+func isLister(llm ai.LLM) bool {
+	_, ok := llm.(ai.ModelLister)
+	return ok
+}
+
+// availability checks that model is listed, ignoring a provider prefix such
+// as "models/" or "accounts/fireworks/models/"
+func availability(model string, models []ai.ModelInfo) check {
+	for _, m := range models {
+		if m.ID == model || strings.HasSuffix(m.ID, "/"+model) || strings.HasSuffix(model, "/"+m.ID) {
+			return check{"model", true, model + " available"}
+		}
+	}
+	return check{"model", false, model + " not listed"}
+}
+
+// quotaHeaders are the rate limit headers of the providers, as remaining and
+// limit pairs
+var quotaHeaders = []struct {
+	name, remaining, limit string
+}{
+	{"requests", "x-ratelimit-remaining-requests", "x-ratelimit-limit-requests"},
+	{"tokens", "x-ratelimit-remaining-tokens", "x-ratelimit-limit-tokens"},
+	{"requests", "anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-limit"},
+	{"tokens", "anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-limit"},
+}
+
+// quota reports the rate limit headroom from the headers of the last
+// response, failing below 10% of a limit
+func quota(header http.Header) check {
+	var details []string
+	ok := true
+	for _, h := range quotaHeaders {
+		remaining, err1 := strconv.ParseFloat(header.Get(h.remaining), 64)
+		limit, err2 := strconv.ParseFloat(header.Get(h.limit), 64)
+		if err1 != nil || err2 != nil || limit == 0 {
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s %.0f/%.0f", h.name, remaining, limit))
+		if remaining < limit/10 {
+			ok = false
+		}
+	}
+	if len(details) == 0 {
+		return check{"quota", true, "not reported"}
+	}
+	return check{"quota", ok, strings.Join(details, ", ") + " remaining"}
+}
+
+// headerRecorder keeps the headers of the last response with rate limit
+// headers
+type headerRecorder struct {
+	next   http.RoundTripper
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (r *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	for _, h := range quotaHeaders {
+		if resp.Header.Get(h.remaining) != "" {
+			r.mu.Lock()
+			r.header = resp.Header.Clone()
+			r.mu.Unlock()
+			break
+		}
+	}
+	return resp, nil
+}
+
+func (r *headerRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.header = nil
+}
+
+func (r *headerRecorder) last() http.Header {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.header == nil {
+		return http.Header{}
+	}
+	return r.header
+}
@@ -0,0 +1,87 @@
+// Command ai-eval runs an eval dataset against a provider chain and writes
+// JUnit and/or markdown reports, exiting with status 1 if any case fails.
+//
+//	ai-eval -dataset evals.yaml -providers openai:gpt-4o-mini,anthropic:claude-3-5-haiku-latest -junit report.xml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/alehano/ai"
+)
+
+func main() {
+	dataset := flag.String("dataset", "", "path to the dataset (.jsonl, .json, .yaml)")
+	providers := flag.String("providers", "", "comma separated provider:model specs, tried in order")
+	graderSpec := flag.String("grader", "", "provider:model used for grader rubrics (defaults to the tested chain)")
+	junitPath := flag.String("junit", "", "write a JUnit XML report to this path")
+	markdownPath := flag.String("markdown", "", "write a markdown report to this path (default: stdout)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "overall timeout")
+	flag.Parse()
+
+	if *dataset == "" || *providers == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cases, err := ai.LoadEvalDataset(*dataset)
+	if err != nil {
+		log.Fatalf("load dataset: %v", err)
+	}
+
+	llm, err := ai.NewLLMChainFromSpecs(*providers, func(err error) {
+		log.Printf("provider error: %v", err)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var grader ai.LLM
+	if *graderSpec != "" {
+		grader, err = ai.NewLLMFromSpec(*graderSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report := ai.RunEval(ctx, llm, grader, cases)
+
+	if *junitPath != "" {
+		if err := writeFile(*junitPath, report.WriteJUnit); err != nil {
+			log.Fatalf("write junit report: %v", err)
+		}
+	}
+	if *markdownPath != "" {
+		if err := writeFile(*markdownPath, report.WriteMarkdown); err != nil {
+			log.Fatalf("write markdown report: %v", err)
+		}
+	} else if err := report.WriteMarkdown(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	if failed := report.Failed(); failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d cases failed\n", failed, len(report.Results))
+		os.Exit(1)
+	}
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/openai/openai-go/option"
+)
+
+// rewriteJSONBody returns a middleware that lets OpenAI-compatible backends
+// patch the JSON request body before it is sent, to work around unsupported
+// or differently named parameters
+func rewriteJSONBody(fn func(body map[string]interface{})) option.Middleware {
+	return func(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		if req.Body == nil || req.Method != http.MethodPost {
+			return next(req)
+		}
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err == nil {
+			fn(body)
+			if patched, err := json.Marshal(body); err == nil {
+				data = patched
+			}
+		}
+
+		req.ContentLength = int64(len(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.Body, _ = req.GetBody()
+		return next(req)
+	}
+}
@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+// newCaptureServer returns a server answering with a fixed chat completion
+// and recording the last decoded request body
+func newCaptureServer(t *testing.T, body *map[string]interface{}) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, body); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGroqCompat(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "llama", 100, 0.5, true,
+		option.WithMiddleware(rewriteJSONBody(groqCompat)),
+		option.WithJSONSet("logprobs", true),
+		option.WithJSONSet("stop", []string{"END"}))
+
+	res, err := llm.Generate(context.Background(), "Reply in JSON", "hi")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if res != "ok" {
+		t.Fatalf("unexpected response: %q", res)
+	}
+	if _, ok := body["logprobs"]; ok {
+		t.Fatalf("logprobs should be removed: %v", body)
+	}
+	if _, ok := body["stop"]; ok {
+		t.Fatalf("stop should be removed in JSON mode: %v", body)
+	}
+	if _, ok := body["response_format"]; !ok {
+		t.Fatalf("response_format should be kept: %v", body)
+	}
+}
+
+func TestVLLMParams(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewVLLM(srv.URL+"/", "", "qwen", 100, 0.5, false, &VLLMParams{
+		BestOf:     3,
+		GuidedJSON: Object().Prop("name", String()).Required("name"),
+	})
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if body["best_of"] != float64(3) {
+		t.Fatalf("best_of not set: %v", body)
+	}
+	if _, ok := body["guided_json"].(map[string]interface{}); !ok {
+		t.Fatalf("guided_json not set: %v", body)
+	}
+	if _, ok := body["use_beam_search"]; ok {
+		t.Fatalf("unset params should be omitted: %v", body)
+	}
+}
+
+func TestNIM(t *testing.T) {
+	var accept []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/models":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"object":"list","data":[{"id":"meta/llama-3.1-8b-instruct","object":"model"}]}`)
+		default:
+			accept = append(accept, r.Header.Get("Accept"))
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["stream"] == true {
+				w.Header().Set("Content-Type", "text/event-stream")
+				io.WriteString(w, "data: {\"id\":\"1\",\"created\":1,\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	llm := NewNIM(srv.URL+"/v1", "", "meta/llama-3.1-8b-instruct", 100, 0.5, false)
+	models, err := llm.ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "meta/llama-3.1-8b-instruct" {
+		t.Fatalf("unexpected models %v, %v", models, err)
+	}
+
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumeStream(context.Background(), llm, "", "hi", func(string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(accept) != 2 || accept[0] != "application/json" || accept[1] != "text/event-stream" {
+		t.Fatalf("unexpected Accept headers %v", accept)
+	}
+}
+
+func TestSambaNovaCompat(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "Meta-Llama-3.1-8B-Instruct", 100, 0.5, false,
+		option.WithMiddleware(rewriteJSONBody(sambaNovaCompat)),
+		option.WithJSONSet("seed", 1),
+		option.WithJSONSet("messages.0.name", "bot"))
+
+	if _, err := llm.Generate(context.Background(), "Be brief", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	for key := range body {
+		if !sambaNovaParams[key] {
+			t.Fatalf("unsupported param %s sent: %v", key, body)
+		}
+	}
+	messages := body["messages"].([]interface{})
+	system := messages[0].(map[string]interface{})
+	if _, ok := system["name"]; ok {
+		t.Fatalf("message name should be removed: %v", system)
+	}
+	if user := messages[1].(map[string]interface{}); user["content"] != "hi" {
+		t.Fatalf("text content should be flattened: %v", user)
+	}
+}
+
+func TestCerebrasCompat(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "llama3.1-8b", 100, 0.5, false,
+		option.WithMiddleware(rewriteJSONBody(cerebrasCompat)),
+		option.WithJSONSet("frequency_penalty", 0.5))
+
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if body["max_completion_tokens"] != float64(100) {
+		t.Fatalf("max_completion_tokens not set: %v", body)
+	}
+	if _, ok := body["max_tokens"]; ok {
+		t.Fatalf("max_tokens should be renamed: %v", body)
+	}
+	if _, ok := body["frequency_penalty"]; ok {
+		t.Fatalf("frequency_penalty should be removed: %v", body)
+	}
+}
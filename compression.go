@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Compression is a content encoding for request bodies
+type Compression string
+
+const (
+	CompressionGzip    Compression = "gzip"
+	CompressionDeflate Compression = "deflate"
+)
+
+// compressionMinSize is the body size below which compression is not worth it
+const compressionMinSize = 1024
+
+type compressionKey struct{}
+
+// WithCompression returns a context that makes requests made with it send
+// bodies larger than 1KB compressed with c, which saves bandwidth for long
+// documents and base64 images. Responses are accepted gzip or deflate
+// encoded. Only enable it for providers that accept compressed requests
+// (e.g. Google APIs, or self-hosted servers behind a proxy that decodes
+// them), others reject them with 400 or 415.
+//
+// It is applied by the REST based clients and OpenAI-compatible clients, wrap
+// the HTTP client of other SDKs with CompressionTransport.
+func WithCompression(ctx context.Context, c Compression) context.Context {
+	return context.WithValue(ctx, compressionKey{}, c)
+}
+
+func compressionFromContext(ctx context.Context) Compression {
+	c, _ := ctx.Value(compressionKey{}).(Compression)
+	return c
+}
+
+// CompressionTransport applies WithCompression to requests of an HTTP client
+type CompressionTransport struct {
+	// Base is the transport used to send requests, http.DefaultTransport if nil
+	Base http.RoundTripper
+}
+
+func (t *CompressionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if compressionFromContext(req.Context()) == "" {
+		return base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	if err := compressRequest(req); err != nil {
+		return nil, err
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, decompressResponse(resp)
+}
+
+// compressionMiddleware applies WithCompression to OpenAI-compatible clients.
+// It must be the last middleware, as others may need the plain body.
+func compressionMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if compressionFromContext(req.Context()) == "" {
+		return next(req)
+	}
+	if err := compressRequest(req); err != nil {
+		return nil, err
+	}
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp, decompressResponse(resp)
+}
+
+// compressRequest compresses the body of req and advertises accepted
+// encodings, if enabled by its context
+func compressRequest(req *http.Request) error {
+	c := compressionFromContext(req.Context())
+	if c == "" {
+		return nil
+	}
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(data) >= compressionMinSize {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch c {
+		case CompressionGzip:
+			w = gzip.NewWriter(&buf)
+		case CompressionDeflate:
+			// HTTP deflate is the zlib format (RFC 9110)
+			w = zlib.NewWriter(&buf)
+		default:
+			return fmt.Errorf("unsupported compression %q", c)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+		req.Header.Set("Content-Encoding", string(c))
+	}
+
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// decompressResponse decodes the response body. It is needed because
+// net/http only decodes gzip transparently if it set Accept-Encoding itself.
+func decompressResponse(resp *http.Response) error {
+	var r io.ReadCloser
+	switch strings.ToLower(resp.Header.Get("Content-Encoding")) {
+	case "gzip":
+		gr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("failed to decode gzip response: %v", err)
+		}
+		r = gr
+	case "deflate":
+		zr, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return fmt.Errorf("failed to decode deflate response: %v", err)
+		}
+		r = zr
+	default:
+		return nil
+	}
+	resp.Body = &decodedBody{Reader: r, body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody reads a decoded response body and closes the original one
+type decodedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	return b.body.Close()
+}
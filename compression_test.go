@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compressedServer decodes compressed requests and answers with a gzip
+// encoded chat completion if the client accepts it
+func compressedServer(t *testing.T, encodings *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			body, _ = gzip.NewReader(r.Body)
+		case "deflate":
+			body, _ = zlib.NewReader(r.Body)
+		}
+		*encodings = append(*encodings, r.Header.Get("Content-Encoding"))
+		var req map[string]interface{}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		res := `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			io.WriteString(w, res)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		io.WriteString(gw, res)
+		gw.Close()
+	}))
+}
+
+func TestCompression(t *testing.T) {
+	var encodings []string
+	server := compressedServer(t, &encodings)
+	defer server.Close()
+
+	long := strings.Repeat("a long document ", 200)
+	ctx := WithCompression(context.Background(), CompressionGzip)
+
+	grok := NewGrok("key", "grok-4", 100, 0, false)
+	grok.SetBaseURL(server.URL)
+	for _, prompt := range []string{long, "short"} {
+		res, err := grok.Generate(ctx, "", prompt)
+		if err != nil || res != "ok" {
+			t.Fatalf("unexpected result %q, %v", res, err)
+		}
+	}
+
+	llm := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	res, err := llm.Generate(WithCompression(context.Background(), CompressionDeflate), "", long)
+	if err != nil || res != "ok" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	if _, err := llm.Generate(context.Background(), "", long); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: &CompressionTransport{}}
+	var out map[string]interface{}
+	if err := doJSON(ctx, client, http.MethodPost, server.URL, nil, map[string]string{"prompt": long}, &out); err != nil || out["model"] != "m" {
+		t.Fatalf("unexpected result %v, %v", out, err)
+	}
+
+	if strings.Join(encodings, ",") != "gzip,,deflate,,gzip" {
+		t.Errorf("unexpected request encodings %q", encodings)
+	}
+}
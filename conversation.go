@@ -0,0 +1,154 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxTitleLength is the maximum title length in characters
+	maxTitleLength = 60
+	// maxTranscriptMessageLength clips long messages when building a title
+	maxTranscriptMessageLength = 1000
+	// DefaultSummaryWords is the summary length used when maxWords is 0
+	DefaultSummaryWords = 100
+)
+
+const titleSystemPrompt = `You write titles for chat conversations.
+Reply with the title only: 3 to 6 words in the language of the conversation, capturing its main topic.
+No quotes, no trailing punctuation, no emojis, no prefixes like "Title:".`
+
+const summarySystemPrompt = `You summarize chat conversations.
+Reply with the summary only, in plain prose and in the language of the conversation.
+Keep the facts, decisions, open questions and user preferences that matter for continuing the conversation. Do not add anything that was not said.`
+
+// CheapLLM is implemented by clients that can route simple tasks, like
+// titles and summaries, to a cheaper model
+type CheapLLM interface {
+	Cheap() LLM
+}
+
+type cheapRoutedLLM struct {
+	LLM
+	cheap LLM
+}
+
+func (c *cheapRoutedLLM) Cheap() LLM {
+	return c.cheap
+}
+
+// WithCheapModel returns llm with cheap used for titles and summaries
+func WithCheapModel(llm, cheap LLM) LLM {
+	return &cheapRoutedLLM{LLM: llm, cheap: cheap}
+}
+
+func cheapLLM(llm LLM) LLM {
+	if c, ok := llm.(CheapLLM); ok && c.Cheap() != nil {
+		return c.Cheap()
+	}
+	return llm
+}
+
+// GenerateTitle returns a short title for a conversation, using the cheap
+// model if llm provides one
+func GenerateTitle(ctx context.Context, llm LLM, messages []Message) (string, error) {
+	transcript := conversationTranscript(messages, maxTranscriptMessageLength)
+	if transcript == "" {
+		return "", fmt.Errorf("conversation is empty")
+	}
+
+	res, err := cheapLLM(llm).Generate(ctx, titleSystemPrompt, "Conversation:\n\n"+transcript)
+	if err != nil {
+		return "", err
+	}
+	title := cleanTitle(res)
+	if title == "" {
+		return "", fmt.Errorf("no title generated")
+	}
+	return title, nil
+}
+
+// GenerateSummary returns a summary of a conversation of at most maxWords
+// words (DefaultSummaryWords if 0), using the cheap model if llm provides one
+func GenerateSummary(ctx context.Context, llm LLM, messages []Message, maxWords int) (string, error) {
+	if maxWords <= 0 {
+		maxWords = DefaultSummaryWords
+	}
+	transcript := conversationTranscript(messages, 0)
+	if transcript == "" {
+		return "", fmt.Errorf("conversation is empty")
+	}
+
+	prompt := fmt.Sprintf("Summarize this conversation in at most %d words.\n\nConversation:\n\n%s", maxWords, transcript)
+	res, err := cheapLLM(llm).Generate(ctx, summarySystemPrompt, prompt)
+	if err != nil {
+		return "", err
+	}
+	summary := limitWords(strings.TrimSpace(res), maxWords)
+	if summary == "" {
+		return "", fmt.Errorf("no summary generated")
+	}
+	return summary, nil
+}
+
+// conversationTranscript formats user and assistant messages, clipping each
+// to maxMessageLength characters if it is not 0
+func conversationTranscript(messages []Message, maxMessageLength int) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		content := strings.TrimSpace(msg.Content)
+		if msg.Role == RoleSystem || content == "" {
+			continue
+		}
+		if maxMessageLength > 0 && utf8.RuneCountInString(content) > maxMessageLength {
+			content = string([]rune(content)[:maxMessageLength]) + "…"
+		}
+		if msg.Role == RoleAssistant {
+			sb.WriteString("Assistant: ")
+		} else {
+			sb.WriteString("User: ")
+		}
+		sb.WriteString(content + "\n\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// cleanTitle removes the decorations models tend to add despite instructions
+// and enforces maxTitleLength
+func cleanTitle(title string) string {
+	title = strings.TrimSpace(title)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.TrimPrefix(strings.TrimPrefix(title, "Title:"), "title:")
+	title = strings.TrimSpace(strings.Trim(strings.TrimSpace(title), "\"'`*#“”«»"))
+	title = strings.TrimRight(title, ".!;:")
+
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		runes := []rune(title)[:maxTitleLength]
+		title = string(runes)
+		if i := strings.LastIndexByte(title, ' '); i > 0 {
+			title = title[:i]
+		}
+		title = strings.TrimRight(title, ",;:-– ")
+	}
+	return title
+}
+
+// limitWords cuts text to maxWords words, at the end of a sentence if one
+// ends in the second half of the allowed text
+func limitWords(text string, maxWords int) string {
+	words := strings.Fields(text)
+	if len(words) <= maxWords {
+		return text
+	}
+	words = words[:maxWords]
+	for i := len(words) - 1; i >= maxWords/2; i-- {
+		if strings.HasSuffix(words[i], ".") || strings.HasSuffix(words[i], "!") || strings.HasSuffix(words[i], "?") {
+			return strings.Join(words[:i+1], " ")
+		}
+	}
+	return strings.Join(words, " ") + "…"
+}
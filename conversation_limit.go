@@ -0,0 +1,220 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type conversationKey struct{}
+
+// WithConversation returns a context that attributes requests made with it
+// to the conversation id, for ConversationLimitLLM
+func WithConversation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationKey{}, id)
+}
+
+// ConversationFromContext returns the conversation set by WithConversation, or ""
+func ConversationFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationKey{}).(string)
+	return id
+}
+
+// ConversationLimits are the limits of each conversation over a sliding
+// window, zero values disable a limit
+type ConversationLimits struct {
+	// MaxTurns is the number of requests per window
+	MaxTurns int
+	// MaxTokens is the number of prompt and response tokens per window
+	MaxTokens int
+	// Window defaults to an hour
+	Window time.Duration
+}
+
+// ConversationLimitError is returned when a conversation exceeds its limits
+type ConversationLimitError struct {
+	Conversation string
+	// Limit is "turns" or "tokens"
+	Limit string
+	// RetryAt is when enough usage leaves the window for another turn
+	RetryAt time.Time
+}
+
+func (e *ConversationLimitError) Error() string {
+	return fmt.Sprintf("conversation %q exceeded its %s limit, retry at %s", e.Conversation, e.Limit, e.RetryAt.Format(time.RFC3339))
+}
+
+// ConversationLimitLLM limits the turns and tokens of each conversation,
+// identified with WithConversation, to stop runaway or abusive chat loops
+// independently of tenant quotas. Requests without a conversation are not
+// limited. Tokens are counted like BudgetLLM does: a turn is rejected if its
+// prompt does not fit, the response may overshoot.
+type ConversationLimitLLM struct {
+	LLM
+	limits ConversationLimits
+
+	mu        sync.Mutex
+	usage     map[string][]*throttleUsage
+	lastSweep time.Time
+}
+
+// NewConversationLimitLLM creates a ConversationLimitLLM
+func NewConversationLimitLLM(llm LLM, limits ConversationLimits) *ConversationLimitLLM {
+	if limits.Window <= 0 {
+		limits.Window = time.Hour
+	}
+	return &ConversationLimitLLM{LLM: llm, limits: limits, usage: make(map[string][]*throttleUsage), lastSweep: time.Now()}
+}
+
+// recent returns the usage of a conversation within the window, the caller
+// must hold the lock
+func (c *ConversationLimitLLM) recent(id string, now time.Time) []*throttleUsage {
+	usage := c.usage[id]
+	i := 0
+	for i < len(usage) && now.Sub(usage[i].at) >= c.limits.Window {
+		i++
+	}
+	usage = usage[i:]
+	if len(usage) == 0 {
+		delete(c.usage, id)
+	} else {
+		c.usage[id] = usage
+	}
+	return usage
+}
+
+// sweep forgets idle conversations, the caller must hold the lock
+func (c *ConversationLimitLLM) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.limits.Window {
+		return
+	}
+	c.lastSweep = now
+	for id := range c.usage {
+		c.recent(id, now)
+	}
+}
+
+// Usage returns the turns and tokens of a conversation within the window
+func (c *ConversationLimitLLM) Usage(id string) (turns, tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	usage := c.recent(id, time.Now())
+	for _, u := range usage {
+		tokens += u.tokens
+	}
+	return len(usage), tokens
+}
+
+// admit records a turn of a conversation with tokens of input and returns its
+// usage, or returns the exceeded limit
+func (c *ConversationLimitLLM) admit(id string, tokens int) (*throttleUsage, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	c.sweep(now)
+	usage := c.recent(id, now)
+
+	if c.limits.MaxTurns > 0 && len(usage) >= c.limits.MaxTurns {
+		// The oldest turns have to leave the window
+		oldest := usage[len(usage)-c.limits.MaxTurns]
+		return nil, &ConversationLimitError{Conversation: id, Limit: "turns", RetryAt: oldest.at.Add(c.limits.Window)}
+	}
+	if c.limits.MaxTokens > 0 {
+		var used int
+		for _, u := range usage {
+			used += u.tokens
+		}
+		if used+tokens > c.limits.MaxTokens {
+			retryAt := now
+			for _, u := range usage {
+				used -= u.tokens
+				retryAt = u.at.Add(c.limits.Window)
+				if used+tokens <= c.limits.MaxTokens {
+					break
+				}
+			}
+			return nil, &ConversationLimitError{Conversation: id, Limit: "tokens", RetryAt: retryAt}
+		}
+	}
+	u := &throttleUsage{at: now, tokens: tokens}
+	c.usage[id] = append(usage, u)
+	return u, nil
+}
+
+// addTokens counts the response tokens of a turn
+func (c *ConversationLimitLLM) addTokens(u *throttleUsage, tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u.tokens += tokens
+}
+
+func (c *ConversationLimitLLM) do(ctx context.Context, input string, fn func() (string, error)) (string, error) {
+	id := ConversationFromContext(ctx)
+	if id == "" {
+		return fn()
+	}
+	usage, err := c.admit(id, CountTokens(c.LLM.GetModel(), input))
+	if err != nil {
+		return "", err
+	}
+	res, err := fn()
+	c.addTokens(usage, CountTokens(c.LLM.GetModel(), res))
+	return res, err
+}
+
+func (c *ConversationLimitLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return c.do(ctx, systemPrompt+prompt, func() (string, error) {
+		return c.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (c *ConversationLimitLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	_, err := c.do(ctx, systemPrompt+prompt, func() (string, error) {
+		var out string
+		err := consumeStream(ctx, c.LLM, systemPrompt, prompt, func(chunk string) error {
+			out += chunk
+			select {
+			case resultCh <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		return out, err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (c *ConversationLimitLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return c.do(ctx, prompt, func() (string, error) {
+		return c.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+	})
+}
+
+func (c *ConversationLimitLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return c.do(ctx, prompt, func() (string, error) {
+		return c.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+	})
+}
+
+func (c *ConversationLimitLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	var input string
+	for _, msg := range messages {
+		input += msg.text()
+	}
+	return c.do(ctx, input, func() (string, error) {
+		return c.LLM.GenerateWithMessages(ctx, messages)
+	})
+}
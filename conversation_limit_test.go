@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConversationLimitLLM(t *testing.T) {
+	llm := NewConversationLimitLLM(NewMockLLM("mock", "ok"), ConversationLimits{MaxTurns: 2, Window: 50 * time.Millisecond})
+	ctx := WithConversation(context.Background(), "c1")
+	for i := 0; i < 2; i++ {
+		if _, err := llm.Generate(ctx, "", "hi"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := llm.Generate(ctx, "", "hi")
+	var limitErr *ConversationLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "turns" || IsRetryable(err) {
+		t.Fatalf("expected a turn limit error, got %v", err)
+	}
+
+	// Other conversations and requests without one are not affected
+	if _, err := llm.Generate(WithConversation(context.Background(), "c2"), "", "hi"); err != nil {
+		t.Error(err)
+	}
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(time.Until(limitErr.RetryAt))
+	if _, err := llm.Generate(ctx, "", "hi"); err != nil {
+		t.Errorf("expected the window to slide, got %v", err)
+	}
+
+	llm = NewConversationLimitLLM(NewMockLLM("mock", "ok"), ConversationLimits{MaxTokens: 30})
+	if _, err := llm.Generate(ctx, "", strings.Repeat("word ", 10)); err != nil {
+		t.Fatal(err)
+	}
+	if turns, tokens := llm.Usage("c1"); turns != 1 || tokens == 0 {
+		t.Errorf("unexpected usage %d turns, %d tokens", turns, tokens)
+	}
+	_, err = llm.Generate(ctx, "", strings.Repeat("word ", 30))
+	if !errors.As(err, &limitErr) || limitErr.Limit != "tokens" || limitErr.RetryAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Errorf("expected a token limit error, got %v", err)
+	}
+}
+
+func TestConversationLimitConcurrentTurns(t *testing.T) {
+	release := make(chan struct{})
+	stub := &stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		if prompt == "slow" {
+			<-release
+			return strings.Repeat("word ", 40), nil
+		}
+		return "ok", nil
+	}}
+	llm := NewConversationLimitLLM(stub, ConversationLimits{MaxTokens: 1000})
+	ctx := WithConversation(context.Background(), "c1")
+
+	done := make(chan error)
+	go func() {
+		_, err := llm.Generate(ctx, "", "slow")
+		done <- err
+	}()
+	for turns, _ := llm.Usage("c1"); turns == 0; turns, _ = llm.Usage("c1") {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := llm.Generate(ctx, "", "fast"); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The response tokens of each turn are added to its own usage
+	llm.mu.Lock()
+	defer llm.mu.Unlock()
+	usage := llm.usage["c1"]
+	if len(usage) != 2 || usage[0].tokens <= usage[1].tokens {
+		t.Errorf("response tokens attributed to the wrong turn: %d and %d", usage[0].tokens, usage[1].tokens)
+	}
+}
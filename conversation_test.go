@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTitleAndSummary(t *testing.T) {
+	expensive := &stubLLM{model: "expensive", response: func(systemPrompt, prompt string) (string, error) {
+		t.Error("expensive model must not be used")
+		return "", nil
+	}}
+	var prompts []string
+	cheap := &stubLLM{model: "cheap", response: func(systemPrompt, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		if systemPrompt == titleSystemPrompt {
+			return "Title: \"Planning a Trip to Lisbon.\"\nHope this helps!", nil
+		}
+		return "The user plans a trip. They prefer trains. Budget is open. More words follow here.", nil
+	}}
+	llm := WithCheapModel(expensive, cheap)
+	messages := []Message{
+		{Role: RoleSystem, Content: "You are a travel agent"},
+		{Role: RoleUser, Content: "I want to visit Lisbon in May"},
+		{Role: RoleAssistant, Content: "Great choice!"},
+	}
+
+	title, err := GenerateTitle(context.Background(), llm, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Planning a Trip to Lisbon" {
+		t.Errorf("unexpected title %q", title)
+	}
+	if strings.Contains(prompts[0], "travel agent") || !strings.Contains(prompts[0], "Assistant: Great choice!") {
+		t.Errorf("unexpected transcript %q", prompts[0])
+	}
+
+	summary, err := GenerateSummary(context.Background(), llm, messages, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "The user plans a trip. They prefer trains." {
+		t.Errorf("unexpected summary %q", summary)
+	}
+
+	if _, err := GenerateTitle(context.Background(), llm, messages[:1]); err == nil {
+		t.Error("expected error for empty conversation")
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	long := cleanTitle(strings.Repeat("word ", 20))
+	if len(long) > maxTitleLength || strings.HasSuffix(long, " ") {
+		t.Errorf("title not shortened at a word boundary: %q", long)
+	}
+}
@@ -0,0 +1,417 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// DefaultStripElements are removed with their content by HTMLConverter, as they
+// rarely carry the content of a page
+var DefaultStripElements = []string{
+	"head", "script", "style", "noscript", "template", "iframe", "svg", "canvas",
+	"nav", "header", "footer", "aside", "form", "button", "select",
+}
+
+// HTMLConverter converts HTML, e.g. scraped web pages, to Markdown, which is
+// more compact and easier for models to follow
+type HTMLConverter struct {
+	// StripElements are tag names removed along with their content
+	StripElements []string
+	// SkipImages drops images instead of rendering them as ![alt](src)
+	SkipImages bool
+	// SkipLinkURLs renders links as their text only
+	SkipLinkURLs bool
+}
+
+func NewHTMLConverter() *HTMLConverter {
+	return &HTMLConverter{StripElements: DefaultStripElements}
+}
+
+// HTMLToMarkdown converts HTML to Markdown with the default settings
+func HTMLToMarkdown(text string) (string, error) {
+	return NewHTMLConverter().Convert(text)
+}
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// Convert converts an HTML document or fragment to Markdown
+func (c *HTMLConverter) Convert(text string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(text))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %v", err)
+	}
+	strip := make(map[string]bool, len(c.StripElements))
+	for _, name := range c.StripElements {
+		strip[strings.ToLower(name)] = true
+	}
+
+	var w strings.Builder
+	c.renderChildren(&w, doc, strip)
+	return cleanMarkdown(w.String()), nil
+}
+
+// cleanMarkdown trims trailing spaces and collapses runs of blank lines
+func cleanMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text = blankLinesRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.Trim(text, "\n")
+}
+
+// block makes sure the output continues on a new paragraph
+func block(w *strings.Builder) {
+	s := w.String()
+	switch {
+	case s == "" || strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		w.WriteString("\n")
+	default:
+		w.WriteString("\n\n")
+	}
+}
+
+func (c *HTMLConverter) renderChildren(w *strings.Builder, n *html.Node, strip map[string]bool) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.render(w, child, strip)
+	}
+}
+
+// renderInner renders the children of n on their own
+func (c *HTMLConverter) renderInner(n *html.Node, strip map[string]bool) string {
+	var w strings.Builder
+	c.renderChildren(&w, n, strip)
+	return cleanMarkdown(w.String())
+}
+
+func (c *HTMLConverter) render(w *strings.Builder, n *html.Node, strip map[string]bool) {
+	switch n.Type {
+	case html.TextNode:
+		writeText(w, n.Data)
+		return
+	case html.DocumentNode:
+		c.renderChildren(w, n, strip)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+	if strip[n.Data] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		block(w)
+		level := int(n.Data[1] - '0')
+		w.WriteString(strings.Repeat("#", level) + " " + strings.ReplaceAll(c.renderInner(n, strip), "\n", " "))
+		block(w)
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Figure, atom.Figcaption, atom.Dl, atom.Dd, atom.Dt:
+		block(w)
+		c.renderChildren(w, n, strip)
+		block(w)
+	case atom.Br:
+		w.WriteString("\n")
+	case atom.Hr:
+		block(w)
+		w.WriteString("---")
+		block(w)
+	case atom.Strong, atom.B:
+		writeWrapped(w, c.renderInner(n, strip), "**")
+	case atom.Em, atom.I:
+		writeWrapped(w, c.renderInner(n, strip), "*")
+	case atom.Del, atom.S:
+		writeWrapped(w, c.renderInner(n, strip), "~~")
+	case atom.Code:
+		writeWrapped(w, textContent(n), "`")
+	case atom.Pre:
+		block(w)
+		w.WriteString("```\n" + strings.Trim(textContent(n), "\n") + "\n```")
+		block(w)
+	case atom.A:
+		text := c.renderInner(n, strip)
+		href := attr(n, "href")
+		if c.SkipLinkURLs || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "javascript:") {
+			writeText(w, text)
+			return
+		}
+		if text == "" {
+			text = href
+		}
+		w.WriteString("[" + text + "](" + href + ")")
+	case atom.Img:
+		if c.SkipImages || attr(n, "src") == "" {
+			return
+		}
+		w.WriteString("![" + attr(n, "alt") + "](" + attr(n, "src") + ")")
+	case atom.Ul, atom.Ol:
+		block(w)
+		c.renderList(w, n, strip)
+		block(w)
+	case atom.Blockquote:
+		block(w)
+		inner := c.renderInner(n, strip)
+		w.WriteString("> " + strings.ReplaceAll(inner, "\n", "\n> "))
+		block(w)
+	case atom.Table:
+		block(w)
+		c.renderTable(w, n, strip)
+		block(w)
+	default:
+		c.renderChildren(w, n, strip)
+	}
+}
+
+func (c *HTMLConverter) renderList(w *strings.Builder, n *html.Node, strip map[string]bool) {
+	i := 0
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li {
+			continue
+		}
+		i++
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", i)
+		}
+		inner := strings.ReplaceAll(c.renderInner(item, strip), "\n\n", "\n")
+		inner = strings.ReplaceAll(inner, "\n", "\n"+strings.Repeat(" ", len(marker)))
+		if !strings.HasSuffix(w.String(), "\n") && w.Len() > 0 {
+			w.WriteString("\n")
+		}
+		w.WriteString(marker + inner + "\n")
+	}
+}
+
+func (c *HTMLConverter) renderTable(w *strings.Builder, n *html.Node, strip map[string]bool) {
+	var rows [][]string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if child.DataAtom != atom.Tr {
+				walk(child)
+				continue
+			}
+			var row []string
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					text := strings.Join(strings.Fields(c.renderInner(cell, strip)), " ")
+					row = append(row, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	walk(n)
+
+	for i, row := range rows {
+		w.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+		}
+	}
+}
+
+// writeText writes text with whitespace collapsed as a browser would
+func writeText(w *strings.Builder, text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text != "" && w.Len() > 0 && !strings.HasSuffix(w.String(), " ") && !strings.HasSuffix(w.String(), "\n") {
+			w.WriteString(" ")
+		}
+		return
+	}
+	s := w.String()
+	atLineStart := s == "" || strings.HasSuffix(s, "\n") || strings.HasSuffix(s, " ")
+	first, _ := utf8.DecodeRuneInString(text)
+	if !atLineStart && isSpace(first) {
+		w.WriteString(" ")
+	}
+	w.WriteString(strings.Join(fields, " "))
+	last, _ := utf8.DecodeLastRuneInString(text)
+	if isSpace(last) {
+		w.WriteString(" ")
+	}
+}
+
+func writeWrapped(w *strings.Builder, text, marker string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	w.WriteString(marker + strings.TrimSpace(text) + marker)
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// PlainTextConverter converts Markdown answers to plain text for channels
+// that do not render it, like SMS or email subject lines
+type PlainTextConverter struct {
+	// StripCodeBlocks removes fenced code blocks instead of keeping their content
+	StripCodeBlocks bool
+	// StripImages removes images instead of keeping their alt text
+	StripImages bool
+	// StripTables removes tables instead of keeping their rows
+	StripTables bool
+	// KeepLinkURLs renders links as "text (url)" instead of their text only
+	KeepLinkURLs bool
+	// SingleLine joins everything into one line
+	SingleLine bool
+	// MaxLength truncates the result to this many characters, 0 for no limit
+	MaxLength int
+}
+
+// MarkdownToPlain converts Markdown to plain text with the default settings
+func MarkdownToPlain(text string) string {
+	return (&PlainTextConverter{}).Convert(text)
+}
+
+var (
+	mdFenceRe       = regexp.MustCompile("^\\s*(```|~~~)")
+	mdHeadingRe     = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)\s*#*\s*$`)
+	mdQuoteRe       = regexp.MustCompile(`^\s{0,3}>\s?`)
+	mdBulletRe      = regexp.MustCompile(`^(\s*)[*+-]\s+`)
+	mdRuleRe        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	mdTableSepRe    = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdTableRowRe    = regexp.MustCompile(`^\s*\|.*\|\s*$`)
+	mdEscapeRe      = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!|~>])")
+	mdImageRe       = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]*)(?:\s+"[^"]*")?\)`)
+	mdLinkRe        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]*)(?:\s+"[^"]*")?\)`)
+	mdCodeRe        = regexp.MustCompile("`([^`]+)`")
+	mdStrongRe      = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdEmRe          = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:[^*_]*?\S)?)[*_]($|[^\w*])`)
+	mdStrikeRe      = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdPlaceholder   = regexp.MustCompile("\x00(\\d+)\x00")
+	whitespaceRunRe = regexp.MustCompile(`\s+`)
+)
+
+// Convert converts Markdown to plain text
+func (p *PlainTextConverter) Convert(text string) string {
+	var out []string
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if mdFenceRe.MatchString(line) {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			if !p.StripCodeBlocks {
+				out = append(out, line)
+			}
+			continue
+		}
+		if mdRuleRe.MatchString(line) || (mdTableSepRe.MatchString(line) && strings.Contains(line, "|")) {
+			continue
+		}
+		if mdTableRowRe.MatchString(line) {
+			if p.StripTables {
+				continue
+			}
+			cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+			for i, cell := range cells {
+				cells[i] = strings.TrimSpace(cell)
+			}
+			line = strings.Join(cells, ", ")
+		}
+		line = mdQuoteRe.ReplaceAllString(line, "")
+		if m := mdHeadingRe.FindStringSubmatch(line); m != nil {
+			line = m[1]
+		}
+		line = mdBulletRe.ReplaceAllString(line, "$1- ")
+		out = append(out, p.convertInline(line))
+	}
+
+	text = cleanMarkdown(strings.Join(out, "\n"))
+	if p.SingleLine {
+		text = strings.TrimSpace(whitespaceRunRe.ReplaceAllString(text, " "))
+	}
+	if p.MaxLength > 0 {
+		text = truncateText(text, p.MaxLength)
+	}
+	return text
+}
+
+// Process implements the PostProcessLLM signature
+func (p *PlainTextConverter) Process(ctx context.Context, text string) (string, error) {
+	return p.Convert(text), nil
+}
+
+// convertInline removes inline formatting from a line
+func (p *PlainTextConverter) convertInline(line string) string {
+	// Escaped characters and code spans are set aside, so their content is not
+	// treated as formatting
+	var protected []string
+	protect := func(s string) string {
+		protected = append(protected, s)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+	line = mdEscapeRe.ReplaceAllStringFunc(line, func(s string) string { return protect(s[1:]) })
+	line = mdCodeRe.ReplaceAllStringFunc(line, func(s string) string { return protect(s[1 : len(s)-1]) })
+
+	line = mdImageRe.ReplaceAllStringFunc(line, func(s string) string {
+		if p.StripImages {
+			return ""
+		}
+		return mdImageRe.FindStringSubmatch(s)[1]
+	})
+	line = mdLinkRe.ReplaceAllStringFunc(line, func(s string) string {
+		m := mdLinkRe.FindStringSubmatch(s)
+		if p.KeepLinkURLs && m[2] != "" && m[2] != m[1] {
+			return m[1] + " (" + m[2] + ")"
+		}
+		return m[1]
+	})
+	line = mdStrongRe.ReplaceAllString(line, "$2")
+	line = mdStrikeRe.ReplaceAllString(line, "$1")
+	line = mdEmRe.ReplaceAllString(line, "$1$2$3")
+
+	return mdPlaceholder.ReplaceAllStringFunc(line, func(s string) string {
+		i, _ := strconv.Atoi(mdPlaceholder.FindStringSubmatch(s)[1])
+		return protected[i]
+	})
+}
+
+// truncateText cuts text to at most n characters, preferably at a word
+// boundary, and marks the cut with an ellipsis
+func truncateText(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)[:n-1]
+	cut := string(runes)
+	if i := strings.LastIndexAny(cut, " \n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " \n,;:.-") + "…"
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	page := `<html><head><title>T</title><style>p{}</style></head><body>
+<nav><a href="/">Home</a></nav>
+<h1>Weather  report</h1>
+<p>It is <b>sunny</b> in <a href="https://example.com/paris">Paris</a>.<br>Wind is <em>calm</em>.</p>
+<ul><li>Morning: 12°C</li><li>Evening <ul><li>late: 9°C</li></ul></li></ul>
+<table><tr><th>Day</th><th>Max</th></tr><tr><td>Mon</td><td>14</td></tr></table>
+<pre><code>go run .
+</code></pre>
+<script>alert(1)</script>
+<footer>© 2024</footer>
+</body></html>`
+
+	res, err := HTMLToMarkdown(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Weather report\n\n" +
+		"It is **sunny** in [Paris](https://example.com/paris).\nWind is *calm*.\n\n" +
+		"- Morning: 12°C\n- Evening\n  - late: 9°C\n\n" +
+		"| Day | Max |\n| --- | --- |\n| Mon | 14 |\n\n" +
+		"```\ngo run .\n```"
+	if res != want {
+		t.Fatalf("unexpected markdown:\n%s", res)
+	}
+
+	conv := &HTMLConverter{StripElements: []string{"head", "script", "table", "pre", "ul"}, SkipLinkURLs: true}
+	res, err = conv.Convert(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "Home\n\n# Weather report\n\nIt is **sunny** in Paris.\nWind is *calm*.\n\n© 2024"
+	if res != want {
+		t.Fatalf("unexpected markdown:\n%s", res)
+	}
+}
+
+func TestMarkdownToPlain(t *testing.T) {
+	md := "## Your order\n\n" +
+		"Order **#1234** is *on its way*, track it [here](https://example.com/t/1234).\n\n" +
+		"- Item: `snake_case` book\n- Price: 12\\*3\n\n" +
+		"| Item | Qty |\n|---|---:|\n| Book | 1 |\n\n" +
+		"```\ncode\n```\n\n---\n\n> Thanks! ![logo](logo.png)"
+
+	want := "Your order\n\n" +
+		"Order #1234 is on its way, track it here.\n\n" +
+		"- Item: snake_case book\n- Price: 12*3\n\n" +
+		"Item, Qty\nBook, 1\n\n" +
+		"code\n\n" +
+		"Thanks! logo"
+	if res := MarkdownToPlain(md); res != want {
+		t.Fatalf("unexpected text:\n%s", res)
+	}
+
+	conv := &PlainTextConverter{KeepLinkURLs: true, StripCodeBlocks: true, StripTables: true, StripImages: true, SingleLine: true}
+	want = "Your order Order #1234 is on its way, track it here (https://example.com/t/1234). - Item: snake_case book - Price: 12*3 Thanks!"
+	if res, _ := conv.Process(context.Background(), md); res != want {
+		t.Fatalf("unexpected text:\n%s", res)
+	}
+
+	conv = &PlainTextConverter{SingleLine: true, MaxLength: 20}
+	if res := conv.Convert("**Meeting** moved to Thursday afternoon"); res != "Meeting moved to…" {
+		t.Fatalf("unexpected subject: %q", res)
+	}
+}
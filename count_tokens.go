@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// TokenCounter is implemented by clients that count the input tokens of a
+// request with their provider
+type TokenCounter interface {
+	// CountTokens returns the number of input tokens of messages
+	CountTokens(ctx context.Context, messages []Message) (int, error)
+}
+
+// messageTokenOverhead estimates the tokens of the role and separators of a
+// message, see PackOptions
+const messageTokenOverhead = 4
+
+// CountMessageTokens returns the number of input tokens of messages for llm,
+// to check prompts against context limits before sending them. Gemini and
+// Claude count them with their APIs, other clients estimate them with the
+// tokenizer of the model (see CountTokens), without images.
+func CountMessageTokens(ctx context.Context, llm LLM, messages []Message) (int, error) {
+	if c, ok := llm.(TokenCounter); ok {
+		return c.CountTokens(ctx, messages)
+	}
+	return estimateMessageTokens(requestModel(ctx, llm.GetModel()), messages), nil
+}
+
+// estimateMessageTokens counts the tokens of messages with the tokenizer of
+// model
+func estimateMessageTokens(model string, messages []Message) int {
+	tokenizer := TokenizerFor(model)
+	n := 0
+	for _, msg := range messages {
+		n += tokenizer.CountTokens(msg.text()) + messageTokenOverhead
+	}
+	return n
+}
+
+// CountTokens estimates the tokens of messages with the tokenizer of the
+// model, OpenAI has no endpoint to count them
+func (o *OpenAI) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	return estimateMessageTokens(requestModel(ctx, o.model), messages), nil
+}
+
+// CountTokens counts the tokens of messages with the count_tokens endpoint.
+// Clients on Bedrock estimate them.
+func (a *Anthropic) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	if a.bedrock != nil {
+		return estimateMessageTokens(a.model, messages), nil
+	}
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return 0, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return 0, err
+	}
+	// the endpoint rejects generation parameters
+	body := map[string]json.RawMessage{}
+	for _, name := range []string{"model", "messages", "system", "tools"} {
+		if v, ok := fields[name]; ok {
+			body[name] = v
+		}
+	}
+
+	headers := map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+	var resp struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := doJSON(ctx, a.httpClient, http.MethodPost, a.baseURL+"/messages/count_tokens", headers, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.InputTokens, nil
+}
+
+// CountTokens counts the tokens of messages with the countTokens endpoint
+func (g *GoogleSimpleLLM) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	req, err := g.restRequest(ctx, messages)
+	if err != nil {
+		return 0, err
+	}
+	model := "models/" + requestModel(ctx, g.model)
+	body := map[string]interface{}{
+		"generateContentRequest": map[string]interface{}{
+			"model":             model,
+			"contents":          req.Contents,
+			"systemInstruction": req.SystemInstruction,
+		},
+	}
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = geminiAPIBaseURL
+	}
+	headers := map[string]string{"x-goog-api-key": g.apiKey}
+	var resp struct {
+		TotalTokens int `json:"totalTokens"`
+	}
+	if err := doJSON(ctx, nil, http.MethodPost, baseURL+model+":countTokens", headers, body, &resp); err != nil {
+		return 0, err
+	}
+	return resp.TotalTokens, nil
+}
+
+// CountTokens counts the tokens of messages with the CountTokens RPC. Vertex
+// AI counts a single content, so the roles of the messages are not counted.
+func (g *Google) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	client := g.getNextClient()
+	if client == nil {
+		return 0, fmt.Errorf("no available client")
+	}
+	var parts []genai.Part
+	for _, msg := range messages {
+		msgParts, err := vertexParts(msg)
+		if err != nil {
+			return 0, err
+		}
+		parts = append(parts, msgParts...)
+	}
+	if len(parts) == 0 {
+		return 0, nil
+	}
+	resp, err := client.GenerativeModel(requestModel(ctx, g.model)).CountTokens(ctx, parts...)
+	if err != nil {
+		return 0, g.wrapError("failed to count tokens", err)
+	}
+	return int(resp.TotalTokens), nil
+}
@@ -0,0 +1,69 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountMessageTokens(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/messages/count_tokens":
+			fmt.Fprint(w, `{"input_tokens":42}`)
+		case "/models/gemini-2.0-flash:countTokens":
+			fmt.Fprint(w, `{"totalTokens":17}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hello there"},
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-haiku-latest", 100, 0.5, false)
+	anthropic.SetBaseURL(server.URL + "/v1")
+	transport := &countingTransport{}
+	anthropic.SetHTTPClient(&http.Client{Transport: transport})
+	n, err := CountMessageTokens(context.Background(), anthropic, messages)
+	if err != nil || n != 42 {
+		t.Fatalf("expected 42 tokens, got %d %v", n, err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("expected the request to use the HTTP client of the client, got %d requests", transport.requests)
+	}
+	if _, ok := body["max_tokens"]; ok || body["system"] == nil || body["model"] != "claude-3-5-haiku-latest" {
+		t.Errorf("unexpected count_tokens body %v", body)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	n, err = CountMessageTokens(context.Background(), gemini, messages)
+	if err != nil || n != 17 {
+		t.Fatalf("expected 17 tokens, got %d %v", n, err)
+	}
+	req, _ := body["generateContentRequest"].(map[string]interface{})
+	if req["model"] != "models/gemini-2.0-flash" || req["systemInstruction"] == nil || len(req["contents"].([]interface{})) != 1 {
+		t.Errorf("unexpected countTokens body %v", body)
+	}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o", 100, 0, false)
+	n, err = CountMessageTokens(context.Background(), openAI, messages)
+	if want := CountTokens("gpt-4o", "Be brief.") + CountTokens("gpt-4o", "Hello there") + 2*messageTokenOverhead; err != nil || n != want {
+		t.Errorf("expected estimate %d, got %d %v", want, n, err)
+	}
+	n, err = CountMessageTokens(context.Background(), &stubLLM{model: "claude-3"}, messages)
+	if want := CountTokens("claude", "Be brief.") + CountTokens("claude", "Hello there") + 2*messageTokenOverhead; err != nil || n != want {
+		t.Errorf("expected estimate %d, got %d %v", want, n, err)
+	}
+}
@@ -0,0 +1,309 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// CustomProviderConfig describes an HTTP API that is not supported out of the
+// box, e.g. an in-house LLM gateway. The request body is built by
+// BuildRequest or RequestTemplate, the output is extracted by ParseResponse
+// or ResponsePath.
+type CustomProviderConfig struct {
+	URL string
+	// Method defaults to POST
+	Method  string
+	Headers map[string]string
+
+	Model       string
+	MaxTokens   int
+	Temperature float32
+	// StopSequences are passed to the request, see WithStopSequences
+	StopSequences []string
+	// Sampling is passed to the request, see WithSampling
+	Sampling Sampling
+
+	// BuildRequest returns the request body, it is encoded as JSON
+	BuildRequest func(req CustomRequest) (interface{}, error)
+	// RequestTemplate is a text/template executed with a CustomRequest that
+	// renders the JSON body. The json function encodes a value, e.g.
+	// {"prompt": {{json .Prompt}}}.
+	RequestTemplate string
+
+	// ParseResponse extracts the output from the response body
+	ParseResponse func(body []byte) (string, error)
+	// ResponsePath is the dot separated path to the output in the JSON
+	// response, array elements are selected by index, e.g.
+	// "choices.0.message.content"
+	ResponsePath string
+
+	// StreamPath is the path to the output in server-sent event data. If set,
+	// GenerateStream requests with Stream set to true, the stream ends with
+	// the event data [DONE] or when the body ends. Without it, GenerateStream
+	// sends the whole output as one chunk.
+	StreamPath string
+
+	HTTPClient *http.Client
+}
+
+// CustomRequest is the input of BuildRequest and RequestTemplate
+type CustomRequest struct {
+	Model       string
+	MaxTokens   int
+	Temperature float32
+	Stream      bool
+	// StopSequences are the ones of the context, or of the config
+	StopSequences []string
+	// Sampling are the parameters of the config overridden by the context
+	Sampling Sampling
+	// SystemPrompt and Prompt join the system and the other messages, for
+	// APIs that take a single prompt
+	SystemPrompt string
+	Prompt       string
+	Messages     []CustomMessage
+}
+
+// CustomMessage is a message with its image, if any, encoded as base64
+type CustomMessage struct {
+	Role        string
+	Content     string
+	ImageBase64 string
+	MimeType    string
+}
+
+// CustomProvider is a client for the API described by a CustomProviderConfig
+type CustomProvider struct {
+	config   CustomProviderConfig
+	template *template.Template
+}
+
+// NewCustomProvider validates config and creates the client
+func NewCustomProvider(config CustomProviderConfig) (*CustomProvider, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("URL is required")
+	}
+	if (config.BuildRequest == nil) == (config.RequestTemplate == "") {
+		return nil, fmt.Errorf("one of BuildRequest and RequestTemplate is required")
+	}
+	if (config.ParseResponse == nil) == (config.ResponsePath == "") {
+		return nil, fmt.Errorf("one of ParseResponse and ResponsePath is required")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+
+	c := &CustomProvider{config: config}
+	if config.RequestTemplate != "" {
+		tmpl, err := template.New("request").Funcs(template.FuncMap{
+			"json": func(v interface{}) (string, error) {
+				data, err := json.Marshal(v)
+				return string(data), err
+			},
+		}).Parse(config.RequestTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request template: %v", err)
+		}
+		c.template = tmpl
+	}
+	return c, nil
+}
+
+func (c *CustomProvider) body(ctx context.Context, messages []Message, stream bool) (interface{}, error) {
+	req := CustomRequest{
+		Model:         c.config.Model,
+		MaxTokens:     c.config.MaxTokens,
+		Temperature:   c.config.Temperature,
+		Stream:        stream,
+		StopSequences: stopSequences(ctx, c.config.StopSequences),
+		Sampling:      sampling(ctx, c.config.Sampling),
+	}
+	if IsDeterministic(ctx) {
+		req.Temperature = 0
+	}
+	req.SystemPrompt, req.Prompt = messagesToPrompt(messages)
+	for _, msg := range messages {
+		msg = msg.flat()
+		m := CustomMessage{Role: string(msg.Role), Content: msg.Content, MimeType: string(msg.MimeType)}
+		if m.Role == "" {
+			m.Role = string(RoleUser)
+		}
+		if msg.Image != nil {
+			data, err := io.ReadAll(msg.Image)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image: %v", err)
+			}
+			m.ImageBase64 = encodeBase64(ctx, data)
+		}
+		req.Messages = append(req.Messages, m)
+	}
+
+	if c.config.BuildRequest != nil {
+		return c.config.BuildRequest(req)
+	}
+	var buf bytes.Buffer
+	if err := c.template.Execute(&buf, req); err != nil {
+		return nil, fmt.Errorf("failed to render request: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("request template rendered invalid JSON: %s", buf.String())
+	}
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// jsonPathString returns the string at a dot separated path in JSON data
+func jsonPathString(data []byte, path string) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "", fmt.Errorf("invalid JSON response: %v", err)
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("no element %s in response at %s", key, path)
+			}
+			v = node[i]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return "", fmt.Errorf("no %s in response", path)
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("%s in response is not a string", path)
+}
+
+func (c *CustomProvider) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return c.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (c *CustomProvider) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	c.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (c *CustomProvider) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	if c.config.StreamPath == "" {
+		res, err := c.GenerateWithMessages(ctx, messages)
+		if err != nil {
+			sendErr(err)
+			return
+		}
+		select {
+		case resultCh <- res:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case doneCh <- true:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	body, err := c.body(ctx, messages, true)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	resp, err := sendJSON(ctx, c.config.HTTPClient, c.config.Method, c.config.URL, c.config.Headers, body)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		// events without output, e.g. the role or usage, are skipped
+		chunk, err := jsonPathString([]byte(data), c.config.StreamPath)
+		if err != nil || chunk == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (c *CustomProvider) GetModel() string {
+	return c.config.Model
+}
+
+func (c *CustomProvider) Close() error {
+	return nil
+}
+
+func (c *CustomProvider) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return c.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
+}
+
+func (c *CustomProvider) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if len(images) != len(mimeTypes) {
+		return "", fmt.Errorf("number of images and mime types must match")
+	}
+	var msgs []Message
+	for i, image := range images {
+		msgs = append(msgs, Message{Role: RoleUser, Image: image, MimeType: mimeTypes[i]})
+	}
+	msgs = append(msgs, Message{Role: RoleUser, Content: prompt})
+	return c.GenerateWithMessages(ctx, msgs)
+}
+
+func (c *CustomProvider) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	body, err := c.body(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	resp, err := sendJSON(ctx, c.config.HTTPClient, c.config.Method, c.config.URL, c.config.Headers, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if c.config.ParseResponse != nil {
+		return c.config.ParseResponse(data)
+	}
+	return jsonPathString(data, c.config.ResponsePath)
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCustomProvider(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("missing header %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			io.WriteString(w, "data: {\"output\":{\"text\":\"he\"}}\n\ndata: {\"output\":{\"text\":\"llo\"}}\n\ndata: {\"usage\":{}}\n\ndata: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"result":{"outputs":[{"text":"hello"}]}}`)
+	}))
+	defer server.Close()
+
+	llm, err := NewCustomProvider(CustomProviderConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"X-Api-Key": "secret"},
+		Model:   "internal-7b",
+		RequestTemplate: `{"model": {{json .Model}}, "system": {{json .SystemPrompt}}, "input": {{json .Prompt}},
+			"stream": {{.Stream}}, "messages": [{{range $i, $m := .Messages}}{{if $i}},{{end}}{"role": {{json $m.Role}}, "text": {{json $m.Content}}}{{end}}]}`,
+		ResponsePath: "result.outputs.0.text",
+		StreamPath:   "output.text",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := llm.Generate(context.Background(), "Be brief", `say "hello"`)
+	if err != nil || res != "hello" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	if body["input"] != `say "hello"` || body["system"] != "Be brief" || len(body["messages"].([]interface{})) != 2 {
+		t.Fatalf("unexpected request %v", body)
+	}
+
+	var streamed strings.Builder
+	err = consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		streamed.WriteString(chunk)
+		return nil
+	})
+	if err != nil || streamed.String() != "hello" {
+		t.Fatalf("unexpected stream %q, %v", streamed.String(), err)
+	}
+
+	llm, err = NewCustomProvider(CustomProviderConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"X-Api-Key": "secret"},
+		BuildRequest: func(req CustomRequest) (interface{}, error) {
+			return map[string]string{"q": req.Prompt}, nil
+		},
+		ParseResponse: func(body []byte) (string, error) {
+			return strings.ToUpper(string(body[:9])), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = nil
+	if res, err := llm.Generate(context.Background(), "", "hi"); err != nil || res != `{"RESULT"` || body["q"] != "hi" {
+		t.Fatalf("unexpected result %q, %v, request %v", res, err, body)
+	}
+
+	if _, err := NewCustomProvider(CustomProviderConfig{URL: server.URL, RequestTemplate: "{}"}); err == nil {
+		t.Fatalf("expected error for missing response parser")
+	}
+}
@@ -0,0 +1,248 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Databricks calls a Databricks model serving endpoint, e.g. the Foundation
+// Model API endpoint "databricks-meta-llama-3-3-70b-instruct", authenticated
+// with a personal access token.
+// https://docs.databricks.com/en/machine-learning/foundation-models/api-reference.html
+type Databricks struct {
+	workspaceURL string
+	token        string
+	endpoint     string
+	maxTokens    int
+	temperature  float32
+	httpClient   *http.Client
+	stop         []string
+	sampling     Sampling
+}
+
+// NewDatabricks creates a client for the serving endpoint of a workspace,
+// e.g. "https://adb-1234567890123456.7.azuredatabricks.net"
+func NewDatabricks(workspaceURL, token, endpoint string, maxTokens int, temperature float32) *Databricks {
+	return &Databricks{
+		workspaceURL: strings.TrimSuffix(workspaceURL, "/"),
+		token:        token,
+		endpoint:     endpoint,
+		maxTokens:    maxTokens,
+		temperature:  temperature,
+		httpClient:   http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (d *Databricks) SetHTTPClient(client *http.Client) {
+	d.httpClient = client
+}
+
+type databricksMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of parts for messages with images
+	Content interface{} `json:"content"`
+}
+
+type databricksPart struct {
+	Type     string                  `json:"type"`
+	Text     string                  `json:"text,omitempty"`
+	ImageURL *databricksPartImageURL `json:"image_url,omitempty"`
+}
+
+type databricksPartImageURL struct {
+	URL string `json:"url"`
+}
+
+type databricksResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (d *Databricks) SetSampling(s Sampling) {
+	d.sampling = s
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (d *Databricks) SetStopSequences(stop ...string) {
+	d.stop = stop
+}
+
+func (d *Databricks) url() string {
+	return d.workspaceURL + "/serving-endpoints/" + d.endpoint + "/invocations"
+}
+
+func (d *Databricks) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + d.token}
+}
+
+// imagePart reads an image into a data URL content part
+func (d *Databricks) imagePart(ctx context.Context, image io.Reader, mimeType MimeType) (databricksPart, error) {
+	data, err := io.ReadAll(image)
+	if err != nil {
+		return databricksPart{}, err
+	}
+	return databricksPart{
+		Type:     "image_url",
+		ImageURL: &databricksPartImageURL{URL: "data:" + string(mimeType) + ";base64," + encodeBase64(ctx, data)},
+	}, nil
+}
+
+func (d *Databricks) request(ctx context.Context, messages []Message, stream bool) (map[string]interface{}, error) {
+	var msgs []databricksMessage
+	for _, msg := range messages {
+		msg = msg.flat()
+		role := msg.Role
+		if role == "" {
+			role = RoleUser
+		}
+		if msg.Image == nil {
+			msgs = append(msgs, databricksMessage{Role: string(role), Content: msg.Content})
+			continue
+		}
+
+		image, err := d.imagePart(ctx, msg.Image, msg.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		parts := []databricksPart{image}
+		if msg.Content != "" {
+			parts = append(parts, databricksPart{Type: "text", Text: msg.Content})
+		}
+		msgs = append(msgs, databricksMessage{Role: string(role), Content: parts})
+	}
+	body := map[string]interface{}{
+		"messages":    msgs,
+		"max_tokens":  d.maxTokens,
+		"temperature": d.temperature,
+		"stream":      stream,
+	}
+	stopBody(ctx, body, "stop", d.stop)
+	samplingBody(ctx, body, d.sampling)
+	return body, nil
+}
+
+func (d *Databricks) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return d.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (d *Databricks) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	d.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (d *Databricks) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	body, err := d.request(ctx, messages, true)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	deterministicBody(ctx, body, "")
+	resp, err := sendJSON(ctx, d.httpClient, http.MethodPost, d.url(), d.headers(), body)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		var chunk databricksResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk.Choices[0].Delta.Content:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (d *Databricks) GetModel() string {
+	return d.endpoint
+}
+
+func (d *Databricks) Close() error {
+	return nil
+}
+
+func (d *Databricks) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return d.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
+}
+
+// GenerateWithImages sends the images and the prompt as one user message
+func (d *Databricks) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if len(images) != len(mimeTypes) {
+		return "", fmt.Errorf("number of images and mime types must match")
+	}
+
+	var parts []databricksPart
+	for i, image := range images {
+		part, err := d.imagePart(ctx, image, mimeTypes[i])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	parts = append(parts, databricksPart{Type: "text", Text: prompt})
+
+	return d.send(ctx, map[string]interface{}{
+		"messages":    []databricksMessage{{Role: string(RoleUser), Content: parts}},
+		"max_tokens":  d.maxTokens,
+		"temperature": d.temperature,
+	})
+}
+
+func (d *Databricks) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	body, err := d.request(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	return d.send(ctx, body)
+}
+
+func (d *Databricks) send(ctx context.Context, body map[string]interface{}) (string, error) {
+	deterministicBody(ctx, body, "")
+	var resp databricksResponse
+	if err := doJSON(ctx, d.httpClient, http.MethodPost, d.url(), d.headers(), body, &resp); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no content generated")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
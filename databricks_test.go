@@ -0,0 +1,55 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDatabricks(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/serving-endpoints/databricks-claude/invocations" || r.Header.Get("Authorization") != "Bearer dapi123" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"he\"}}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"llo\"}}]}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		io.WriteString(w, `{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	llm := NewDatabricks(server.URL+"/", "dapi123", "databricks-claude", 100, 0)
+	res, err := llm.GenerateWithMessages(context.Background(), []Message{
+		{Role: RoleSystem, Content: "Be brief"},
+		{Role: RoleUser, Content: "What is this?", Image: bytes.NewReader([]byte("png")), MimeType: MimeTypePNG},
+	})
+	if err != nil || res != "hello" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	messages := body["messages"].([]interface{})
+	parts, ok := messages[1].(map[string]interface{})["content"].([]interface{})
+	if messages[0].(map[string]interface{})["content"] != "Be brief" || !ok || len(parts) != 2 {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+	if url := parts[0].(map[string]interface{})["image_url"].(map[string]interface{})["url"]; url != "data:image/png;base64,cG5n" {
+		t.Fatalf("unexpected image url %v", url)
+	}
+
+	var streamed string
+	err = consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		streamed += chunk
+		return nil
+	})
+	if err != nil || streamed != "hello" {
+		t.Fatalf("unexpected stream %q, %v", streamed, err)
+	}
+}
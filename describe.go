@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImageDescription is the structured description of an image
+type ImageDescription struct {
+	Caption string   `json:"caption" jsonschema:"description=One sentence describing the image"`
+	Tags    []string `json:"tags" jsonschema:"description=Lowercase tags for the subjects, setting and style"`
+	// Text is the text visible in the image
+	Text string `json:"text" jsonschema:"description=Text visible in the image verbatim, empty if none"`
+}
+
+// imageExtensions are the files described by DescribeImages
+var imageExtensions = map[string]MimeType{
+	".png":  MimeTypePNG,
+	".jpg":  MimeTypeJPEG,
+	".jpeg": MimeTypeJPEG,
+	".webp": MimeTypeWEBP,
+	".heic": MimeTypeHEIC,
+	".heif": MimeTypeHEIF,
+}
+
+// DescribeImage returns a caption, tags and the text of an image with
+// structured output, see GenerateStructured. The prompt gets MaxTags, see
+// PromptDescribeImage.
+func DescribeImage(ctx context.Context, llm LLM, image io.Reader, mimeType MimeType) (*ImageDescription, error) {
+	return describeImage(ctx, llm, image, mimeType, DefaultDescribeOptions.MaxTags)
+}
+
+func describeImage(ctx context.Context, llm LLM, image io.Reader, mimeType MimeType, maxTags int) (*ImageDescription, error) {
+	schema, err := SchemaFrom(ImageDescription{})
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptDescribeImage, struct {
+		MaxTags int
+	}{maxTags})
+	if err != nil {
+		return nil, err
+	}
+	messages := []Message{
+		{Role: RoleSystem, Content: systemPrompt},
+		{Role: RoleUser, Content: prompt, Image: image, MimeType: mimeType},
+	}
+	res, err := GenerateStructured(ctx, llm, messages, "image_description", schema)
+	if err != nil {
+		return nil, err
+	}
+	var desc ImageDescription
+	if err := json.Unmarshal([]byte(res), &desc); err != nil {
+		return nil, &StructuredOutputError{Output: res, Err: err}
+	}
+	return &desc, nil
+}
+
+// DescribeOptions configures DescribeImages
+type DescribeOptions struct {
+	// Concurrency is the number of images described at once
+	Concurrency int
+	// MaxRetries is the number of retries per image, on any error
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled for each retry
+	RetryDelay time.Duration
+	// MaxTags limits the number of tags asked for
+	MaxTags int
+	// Progress is called after each image (optional, not called concurrently)
+	Progress func(ImageProgress)
+}
+
+// DefaultDescribeOptions provide Concurrency, RetryDelay and MaxTags when
+// they are zero
+var DefaultDescribeOptions = DescribeOptions{Concurrency: 4, RetryDelay: time.Second, MaxTags: 10}
+
+// ImageResult is the outcome of describing an image
+type ImageResult struct {
+	// Path is the path of the image in the file system
+	Path        string
+	Description *ImageDescription
+	// Err is set if the image could not be described after all retries
+	Err      error
+	Attempts int
+}
+
+// ImageProgress reports the progress of DescribeImages
+type ImageProgress struct {
+	Done   int
+	Total  int
+	Result ImageResult
+}
+
+// DescribeImages describes the images of fsys (PNG, JPEG, WebP, HEIC and
+// HEIF files found recursively), e.g. os.DirFS(dir), with DescribeImage.
+// Results are returned in path order, images that failed have Err set. The
+// error is only set if fsys cannot be read or ctx is done.
+//
+//	results, err := ai.DescribeImages(ctx, llm, os.DirFS("photos"), ai.DescribeOptions{
+//		Progress: func(p ai.ImageProgress) { log.Printf("%d/%d %s", p.Done, p.Total, p.Result.Path) },
+//	})
+func DescribeImages(ctx context.Context, llm LLM, fsys fs.FS, opts DescribeOptions) ([]ImageResult, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultDescribeOptions.Concurrency
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultDescribeOptions.RetryDelay
+	}
+	if opts.MaxTags <= 0 {
+		opts.MaxTags = DefaultDescribeOptions.MaxTags
+	}
+
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if _, ok := imageExtensions[strings.ToLower(path.Ext(p))]; ok && !d.IsDir() {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	results := make([]ImageResult, len(paths))
+	sem := make(chan struct{}, opts.Concurrency)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for i, p := range paths {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, p string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = describeFile(ctx, llm, fsys, p, opts)
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if opts.Progress != nil {
+				opts.Progress(ImageProgress{Done: done, Total: len(paths), Result: results[i]})
+			}
+		}(i, p)
+	}
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+// describeFile describes an image of fsys with retries
+func describeFile(ctx context.Context, llm LLM, fsys fs.FS, p string, opts DescribeOptions) ImageResult {
+	res := ImageResult{Path: p}
+	data, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	mimeType := imageExtensions[strings.ToLower(path.Ext(p))]
+	delay := opts.RetryDelay
+	for {
+		res.Attempts++
+		res.Description, res.Err = describeImage(ctx, llm, bytes.NewReader(data), mimeType, opts.MaxTags)
+		if res.Err == nil || res.Attempts > opts.MaxRetries {
+			break
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			res.Err = fmt.Errorf("%w, last error: %v", ctx.Err(), res.Err)
+			return res
+		}
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// imageLLM replies with a description of the image content
+type imageLLM struct {
+	stubLLM
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (l *imageLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	msg := messages[len(messages)-1]
+	if msg.Image == nil || msg.MimeType == "" || !strings.Contains(msg.Content, "up to 3 ") {
+		return "", fmt.Errorf("unexpected message %+v", msg)
+	}
+	data, err := io.ReadAll(msg.Image)
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	l.calls[string(data)]++
+	calls := l.calls[string(data)]
+	l.mu.Unlock()
+	if string(data) == "flaky" && calls == 1 || string(data) == "broken" {
+		return "", errors.New("overloaded")
+	}
+	return fmt.Sprintf(`{"caption":"A %s","tags":["%s"],"text":""}`, data, data), nil
+}
+
+func TestDescribeImages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"b.JPG":      {Data: []byte("dog")},
+		"a.png":      {Data: []byte("flaky")},
+		"dir/c.webp": {Data: []byte("broken")},
+		"notes.txt":  {Data: []byte("not an image")},
+	}
+	llm := &imageLLM{stubLLM: stubLLM{model: "vision"}, calls: map[string]int{}}
+	var progress []ImageProgress
+	results, err := DescribeImages(context.Background(), llm, fsys, DescribeOptions{
+		Concurrency: 2,
+		MaxRetries:  1,
+		RetryDelay:  time.Millisecond,
+		MaxTags:     3,
+		Progress:    func(p ImageProgress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Path != "a.png" || results[1].Path != "b.JPG" || results[2].Path != "dir/c.webp" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[0].Err != nil || results[0].Attempts != 2 || results[0].Description.Caption != "A flaky" {
+		t.Errorf("retry failed: %+v", results[0])
+	}
+	if results[1].Err != nil || results[1].Attempts != 1 || len(results[1].Description.Tags) != 1 || results[1].Description.Tags[0] != "dog" {
+		t.Errorf("unexpected result %+v", results[1])
+	}
+	if results[2].Err == nil || results[2].Attempts != 2 || results[2].Description != nil {
+		t.Errorf("expected failure after retries: %+v", results[2])
+	}
+	if len(progress) != 3 || progress[2].Done != 3 || progress[2].Total != 3 {
+		t.Errorf("unexpected progress %+v", progress)
+	}
+}
+
+func TestDescribeImagesCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	llm := &imageLLM{stubLLM: stubLLM{model: "vision"}, calls: map[string]int{}}
+	_, err := DescribeImages(ctx, llm, fstest.MapFS{"a.png": {Data: []byte("cat")}}, DescribeOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got %v", err)
+	}
+}
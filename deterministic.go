@@ -0,0 +1,43 @@
+package ai
+
+import "context"
+
+// DeterministicSeed is the seed sent in deterministic mode to providers that
+// accept one
+const DeterministicSeed = 42
+
+type deterministicKey struct{}
+
+// WithDeterministic returns a context that makes requests made with it as
+// reproducible as the provider allows, for pipelines and caching: temperature
+// 0, DeterministicSeed where supported and no sampling penalties. Outputs can
+// still change with the model version, which GenerateResponse records along
+// with the system fingerprint if the provider reports them. A seed set with
+// WithSeed or on the client is used instead of DeterministicSeed.
+func WithDeterministic(ctx context.Context) context.Context {
+	return context.WithValue(ctx, deterministicKey{}, true)
+}
+
+// IsDeterministic reports whether ctx was created by WithDeterministic
+func IsDeterministic(ctx context.Context) bool {
+	deterministic, _ := ctx.Value(deterministicKey{}).(bool)
+	return deterministic
+}
+
+// samplingPenalties are the JSON request parameters removed in deterministic mode
+var samplingPenalties = []string{"frequency_penalty", "presence_penalty", "repetition_penalty"}
+
+// deterministicBody applies deterministic mode to a JSON request body, seedKey
+// is the name of the seed parameter or empty if there is none
+func deterministicBody(ctx context.Context, body map[string]interface{}, seedKey string) {
+	if !IsDeterministic(ctx) {
+		return
+	}
+	body["temperature"] = 0
+	if _, ok := body[seedKey]; seedKey != "" && !ok {
+		body[seedKey] = DeterministicSeed
+	}
+	for _, key := range samplingPenalties {
+		delete(body, key)
+	}
+}
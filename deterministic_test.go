@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+func TestDeterministic(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "llama", 100, 0.7, false,
+		option.WithJSONSet("repetition_penalty", 1.2))
+
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if body["temperature"] != 0.7 || body["repetition_penalty"] != 1.2 {
+		t.Fatalf("sampling params should be kept without deterministic mode: %v", body)
+	}
+
+	body = nil
+	res, err := llm.GenerateResponse(WithDeterministic(context.Background()), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["temperature"] != float64(0) || body["seed"] != float64(DeterministicSeed) {
+		t.Fatalf("temperature and seed not set: %v", body)
+	}
+	if _, ok := body["repetition_penalty"]; ok {
+		t.Fatalf("repetition_penalty should be removed: %v", body)
+	}
+	if res.Model != "m" {
+		t.Fatalf("model not recorded: %+v", res)
+	}
+}
+
+func TestSeed(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "llama", 100, 0.7, false)
+	messages := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["seed"]; ok {
+		t.Fatalf("seed should not be sent by default: %v", body)
+	}
+
+	llm.SetSeed(7)
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["seed"] != 7.0 || body["temperature"] != 0.7 {
+		t.Fatalf("client seed not sent: %v", body)
+	}
+
+	if _, err := llm.GenerateWithMessages(WithDeterministic(WithSeed(context.Background(), 123)), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["seed"] != 123.0 || body["temperature"] != 0.0 {
+		t.Fatalf("request seed should replace DeterministicSeed: %v", body)
+	}
+
+	grok := NewGrok("key", "grok-2", 100, 0, false)
+	grokBody, err := grok.request(WithDeterministic(WithSeed(context.Background(), 5)), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grokBody["seed"] != int64(5) {
+		t.Fatalf("request seed should replace DeterministicSeed: %v", grokBody)
+	}
+}
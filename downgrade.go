@@ -0,0 +1,190 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var errSoftTimeout = errors.New("soft timeout exceeded")
+
+// DowngradeLLM bounds latency with a soft and a hard timeout. When the primary
+// model exceeds the soft timeout, it is canceled and the remaining work goes to
+// a faster model, continuing from the partial output. The hard timeout aborts
+// the request as a whole. Methods without streaming restart on the fast model.
+type DowngradeLLM struct {
+	LLM
+	fast         LLM
+	softTimeout  time.Duration
+	hardTimeout  time.Duration
+	currentModel string
+}
+
+func NewDowngradeLLM(primary, fast LLM, softTimeout, hardTimeout time.Duration) *DowngradeLLM {
+	return &DowngradeLLM{
+		LLM:         primary,
+		fast:        fast,
+		softTimeout: softTimeout,
+		hardTimeout: hardTimeout,
+	}
+}
+
+func (d *DowngradeLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	var out strings.Builder
+	err := d.stream(ctx, systemPrompt, prompt, func(chunk string) error {
+		out.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (d *DowngradeLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	err := d.stream(ctx, systemPrompt, prompt, func(chunk string) error {
+		select {
+		case resultCh <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (d *DowngradeLLM) stream(ctx context.Context, systemPrompt, prompt string, fn func(chunk string) error) error {
+	ctx, cancel := d.withHardTimeout(ctx)
+	defer cancel()
+
+	primaryCtx, cancelPrimary := context.WithCancelCause(ctx)
+	defer cancelPrimary(nil)
+	timer := time.AfterFunc(d.softTimeout, func() { cancelPrimary(errSoftTimeout) })
+	defer timer.Stop()
+
+	var partial strings.Builder
+	err := consumeStream(primaryCtx, d.LLM, systemPrompt, prompt, func(chunk string) error {
+		partial.WriteString(chunk)
+		return fn(chunk)
+	})
+	if err == nil {
+		d.currentModel = d.LLM.GetModel()
+		return nil
+	}
+	if context.Cause(primaryCtx) != errSoftTimeout || ctx.Err() != nil {
+		return err
+	}
+
+	if partial.Len() > 0 {
+		prompt = continuationPrompt(prompt, partial.String())
+	}
+	if err := consumeStream(ctx, d.fast, systemPrompt, prompt, fn); err != nil {
+		return fmt.Errorf("downgraded model %s: %w", d.fast.GetModel(), err)
+	}
+	d.currentModel = d.fast.GetModel()
+	return nil
+}
+
+func (d *DowngradeLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	return d.downgrade(ctx, func(ctx context.Context, llm LLM) (string, error) {
+		return llm.GenerateWithMessages(ctx, replay())
+	})
+}
+
+func (d *DowngradeLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	imageBuf, err := bufferImage(image)
+	if err != nil {
+		return "", err
+	}
+	return d.downgrade(ctx, func(ctx context.Context, llm LLM) (string, error) {
+		var imageReader io.Reader
+		if imageBuf != nil {
+			imageReader = bytes.NewReader(imageBuf.Bytes())
+		}
+		return llm.GenerateWithImage(ctx, prompt, imageReader, mimeType)
+	})
+}
+
+func (d *DowngradeLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	imageBufs, err := bufferImages(images)
+	if err != nil {
+		return "", err
+	}
+	return d.downgrade(ctx, func(ctx context.Context, llm LLM) (string, error) {
+		return llm.GenerateWithImages(ctx, prompt, newReadersFromBuffers(imageBufs), mimeTypes)
+	})
+}
+
+// downgrade calls generate with the primary model, and restarts it with the
+// fast model if the primary exceeds the soft timeout
+func (d *DowngradeLLM) downgrade(ctx context.Context, generate func(ctx context.Context, llm LLM) (string, error)) (string, error) {
+	ctx, cancel := d.withHardTimeout(ctx)
+	defer cancel()
+
+	primaryCtx, cancelPrimary := context.WithTimeoutCause(ctx, d.softTimeout, errSoftTimeout)
+	defer cancelPrimary()
+
+	res, err := generate(primaryCtx, d.LLM)
+	if err == nil {
+		d.currentModel = d.LLM.GetModel()
+		return res, nil
+	}
+	if context.Cause(primaryCtx) != errSoftTimeout || ctx.Err() != nil {
+		return "", err
+	}
+
+	res, err = generate(ctx, d.fast)
+	if err != nil {
+		return "", fmt.Errorf("downgraded model %s: %w", d.fast.GetModel(), err)
+	}
+	d.currentModel = d.fast.GetModel()
+	return res, nil
+}
+
+func (d *DowngradeLLM) withHardTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.hardTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d.hardTimeout)
+}
+
+// GetModel returns the model that produced the last response
+func (d *DowngradeLLM) GetModel() string {
+	if d.currentModel == "" {
+		return d.LLM.GetModel()
+	}
+	return d.currentModel
+}
+
+// Close closes the primary and the fast model
+func (d *DowngradeLLM) Close() error {
+	return errors.Join(d.LLM.Close(), d.fast.Close())
+}
+
+func continuationPrompt(prompt, partial string) string {
+	return prompt + "\n\nA partial answer has already been written:\n\n" + partial +
+		"\n\nContinue the answer exactly where it stops. Do not repeat any of it and do not add any preamble."
+}
+
+// Cheap returns the fast model, see CheapLLM
+func (d *DowngradeLLM) Cheap() LLM {
+	return d.fast
+}
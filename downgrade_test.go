@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowLLM streams its first chunk immediately and then stalls until canceled
+type slowLLM struct {
+	stubLLM
+	first string
+}
+
+func (s *slowLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	select {
+	case resultCh <- s.first:
+	case <-ctx.Done():
+		return
+	}
+	<-ctx.Done()
+	select {
+	case errCh <- ctx.Err():
+	case <-time.After(time.Second):
+	}
+}
+
+func TestDowngradeLLM(t *testing.T) {
+	primary := &slowLLM{stubLLM: stubLLM{model: "big"}, first: "Once upon"}
+	var fastPrompt string
+	fast := &stubLLM{model: "fast", response: func(systemPrompt, prompt string) (string, error) {
+		fastPrompt = prompt
+		return " a time.", nil
+	}}
+
+	llm := NewDowngradeLLM(primary, fast, 20*time.Millisecond, time.Second)
+	res, err := llm.Generate(context.Background(), "", "Tell a story")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if res != "Once upon a time." {
+		t.Fatalf("unexpected response: %q", res)
+	}
+	if !strings.Contains(fastPrompt, "Once upon") {
+		t.Fatalf("fast model did not get the partial output: %q", fastPrompt)
+	}
+	if llm.GetModel() != "fast" {
+		t.Fatalf("unexpected model: %s", llm.GetModel())
+	}
+
+	llm = NewDowngradeLLM(primary, &slowLLM{stubLLM: stubLLM{model: "fast"}}, 10*time.Millisecond, 50*time.Millisecond)
+	if _, err := llm.Generate(context.Background(), "", "Tell a story"); err == nil {
+		t.Fatalf("expected hard timeout error")
+	}
+}
+
+// mediaLLM reads the media it is sent and records its size. A stalling one
+// then waits until canceled, a failing one returns err.
+type mediaLLM struct {
+	stubLLM
+	stall bool
+	err   error
+	sizes []int
+}
+
+func (m *mediaLLM) read(ctx context.Context, r io.Reader) (string, error) {
+	data, _ := io.ReadAll(r)
+	m.sizes = append(m.sizes, len(data))
+	if m.stall {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	if m.err != nil {
+		return "", m.err
+	}
+	return "ok", nil
+}
+
+func (m *mediaLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	return m.read(ctx, messages[0].Image)
+}
+
+func (m *mediaLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	res, err := m.GenerateWithMessages(ctx, messages)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	resultCh <- res
+	doneCh <- true
+}
+
+func (m *mediaLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return m.read(ctx, image)
+}
+
+func (m *mediaLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return m.read(ctx, images[0])
+}
+
+func TestDowngradeMedia(t *testing.T) {
+	primary, fast := &mediaLLM{stall: true}, &mediaLLM{}
+	llm := NewDowngradeLLM(primary, fast, 10*time.Millisecond, time.Second)
+	ctx := context.Background()
+
+	messages := []Message{{Role: RoleUser, Content: "describe", Image: strings.NewReader("image"), MimeType: MimeTypePNG}}
+	if res, err := llm.GenerateWithMessages(ctx, messages); err != nil || res != "ok" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	if _, err := llm.GenerateWithImage(ctx, "describe", strings.NewReader("image"), MimeTypePNG); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llm.GenerateWithImages(ctx, "describe", []io.Reader{strings.NewReader("image")}, []MimeType{MimeTypePNG}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fast.sizes) != 3 || fast.sizes[0] != 5 || fast.sizes[1] != 5 || fast.sizes[2] != 5 {
+		t.Fatalf("expected the fast model to get the images, got sizes %v", fast.sizes)
+	}
+
+	// The hard timeout applies to images too
+	llm = NewDowngradeLLM(primary, &mediaLLM{stall: true}, 10*time.Millisecond, 30*time.Millisecond)
+	if _, err := llm.GenerateWithImage(ctx, "describe", strings.NewReader("image"), MimeTypePNG); err == nil {
+		t.Fatal("expected hard timeout error")
+	}
+}
@@ -0,0 +1,337 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"github.com/openai/openai-go"
+	"google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// EmbedInputType tells retrieval embedding models whether texts are stored
+// documents or search queries. Providers without input types ignore it.
+type EmbedInputType string
+
+const (
+	EmbedDocument EmbedInputType = "document"
+	EmbedQuery    EmbedInputType = "query"
+)
+
+// Maximum number of texts per request, larger inputs are sent in batches
+const (
+	openAIEmbedBatchSize = 2048
+	geminiEmbedBatchSize = 100
+	vertexEmbedBatchSize = 100
+	cohereEmbedBatchSize = 96
+	voyageEmbedBatchSize = 128
+)
+
+const (
+	cohereBaseURL = "https://api.cohere.com/v2"
+	voyageBaseURL = "https://api.voyageai.com/v1"
+)
+
+// embedBatches embeds texts in batches of size, in order
+func embedBatches(ctx context.Context, texts []string, size int, embed func(ctx context.Context, texts []string) ([][]float32, error)) ([][]float32, error) {
+	res := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := start + size
+		if end > len(texts) {
+			end = len(texts)
+		}
+		vectors, err := embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(vectors))
+		}
+		res = append(res, vectors...)
+	}
+	return res, nil
+}
+
+func toFloat32(values []float64) []float32 {
+	res := make([]float32, len(values))
+	for i, v := range values {
+		res[i] = float32(v)
+	}
+	return res
+}
+
+// OpenAIEmbedder embeds texts with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	client     *openai.Client
+	model      string
+	dimensions int64
+}
+
+// NewOpenAIEmbedder creates an embedder for model, e.g. "text-embedding-3-small"
+func NewOpenAIEmbedder(apiKey, model string) *OpenAIEmbedder {
+	return NewOpenAI(apiKey, "", 0, 0, false).Embedder(model)
+}
+
+// Embedder returns an embedder for model sharing the client, e.g. for
+// OpenAI compatible servers
+func (o *OpenAI) Embedder(model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{client: o.client, model: model}
+}
+
+// SetDimensions shortens the embeddings of text-embedding-3 and later models
+func (e *OpenAIEmbedder) SetDimensions(dimensions int) {
+	e.dimensions = int64(dimensions)
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, texts, openAIEmbedBatchSize, func(ctx context.Context, texts []string) ([][]float32, error) {
+		params := openai.EmbeddingNewParams{
+			Input: openai.F[openai.EmbeddingNewParamsInputUnion](openai.EmbeddingNewParamsInputArrayOfStrings(texts)),
+			Model: openai.F(openai.EmbeddingModel(e.model)),
+		}
+		if e.dimensions > 0 {
+			params.Dimensions = openai.F(e.dimensions)
+		}
+		resp, err := e.client.Embeddings.New(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		vectors := make([][]float32, len(texts))
+		for _, data := range resp.Data {
+			if data.Index < 0 || int(data.Index) >= len(vectors) {
+				return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+			}
+			vectors[data.Index] = toFloat32(data.Embedding)
+		}
+		return vectors, nil
+	})
+}
+
+// geminiTaskTypes are the Gemini and Vertex AI task types of input types
+var geminiTaskTypes = map[EmbedInputType]string{
+	EmbedDocument: "RETRIEVAL_DOCUMENT",
+	EmbedQuery:    "RETRIEVAL_QUERY",
+}
+
+// GeminiEmbedder embeds texts with the Gemini API
+type GeminiEmbedder struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	inputType EmbedInputType
+}
+
+// NewGeminiEmbedder creates an embedder for model, e.g. "text-embedding-004"
+func NewGeminiEmbedder(apiKey, model string) *GeminiEmbedder {
+	return &GeminiEmbedder{apiKey: apiKey, model: model, baseURL: geminiAPIBaseURL}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (e *GeminiEmbedder) SetBaseURL(baseURL string) {
+	e.baseURL = baseURL
+}
+
+// SetInputType sets the task type of the texts
+func (e *GeminiEmbedder) SetInputType(t EmbedInputType) {
+	e.inputType = t
+}
+
+func (e *GeminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	model := "models/" + e.model
+	return embedBatches(ctx, texts, geminiEmbedBatchSize, func(ctx context.Context, texts []string) ([][]float32, error) {
+		requests := make([]map[string]interface{}, len(texts))
+		for i, text := range texts {
+			requests[i] = map[string]interface{}{
+				"model":   model,
+				"content": geminiContent{Parts: []geminiPart{{Text: text}}},
+			}
+			if taskType := geminiTaskTypes[e.inputType]; taskType != "" {
+				requests[i]["taskType"] = taskType
+			}
+		}
+		headers := map[string]string{"x-goog-api-key": e.apiKey}
+		var resp struct {
+			Embeddings []struct {
+				Values []float32 `json:"values"`
+			} `json:"embeddings"`
+		}
+		if err := doJSON(ctx, nil, http.MethodPost, e.baseURL+model+":batchEmbedContents", headers, map[string]interface{}{"requests": requests}, &resp); err != nil {
+			return nil, err
+		}
+		vectors := make([][]float32, len(resp.Embeddings))
+		for i, embedding := range resp.Embeddings {
+			vectors[i] = embedding.Values
+		}
+		return vectors, nil
+	})
+}
+
+// VertexEmbedder embeds texts with Vertex AI text embedding models
+type VertexEmbedder struct {
+	client    *aiplatform.PredictionClient
+	endpoint  string
+	inputType EmbedInputType
+}
+
+// Embedder returns an embedder for model, e.g. "text-embedding-005", in the
+// first location of the client. Close it when done.
+func (g *Google) Embedder(ctx context.Context, model string) (*VertexEmbedder, error) {
+	if len(g.locations) == 0 {
+		return nil, fmt.Errorf("no locations configured")
+	}
+	location := g.locations[0]
+	opts := append([]option.ClientOption{
+		option.WithEndpoint(location + "-aiplatform.googleapis.com:443"),
+	}, g.clientOpts...)
+	client, err := aiplatform.NewPredictionClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction client: %v", err)
+	}
+	return &VertexEmbedder{
+		client:   client,
+		endpoint: fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", g.projectID, location, model),
+	}, nil
+}
+
+func (e *VertexEmbedder) Close() error {
+	return e.client.Close()
+}
+
+// SetInputType sets the task type of the texts
+func (e *VertexEmbedder) SetInputType(t EmbedInputType) {
+	e.inputType = t
+}
+
+func (e *VertexEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, texts, vertexEmbedBatchSize, func(ctx context.Context, texts []string) ([][]float32, error) {
+		instances := make([]*structpb.Value, len(texts))
+		for i, text := range texts {
+			fields := map[string]interface{}{"content": text}
+			if taskType := geminiTaskTypes[e.inputType]; taskType != "" {
+				fields["task_type"] = taskType
+			}
+			instance, err := structpb.NewValue(fields)
+			if err != nil {
+				return nil, err
+			}
+			instances[i] = instance
+		}
+		resp, err := e.client.Predict(ctx, &aiplatformpb.PredictRequest{Endpoint: e.endpoint, Instances: instances})
+		if err != nil {
+			return nil, err
+		}
+		vectors := make([][]float32, len(resp.Predictions))
+		for i, prediction := range resp.Predictions {
+			values := prediction.GetStructValue().GetFields()["embeddings"].GetStructValue().GetFields()["values"].GetListValue().GetValues()
+			vectors[i] = make([]float32, len(values))
+			for j, v := range values {
+				vectors[i][j] = float32(v.GetNumberValue())
+			}
+		}
+		return vectors, nil
+	})
+}
+
+// cohereInputTypes are the Cohere input types, required by v3 models
+var cohereInputTypes = map[EmbedInputType]string{
+	EmbedDocument: "search_document",
+	EmbedQuery:    "search_query",
+}
+
+// CohereEmbedder embeds texts with the Cohere embed API
+type CohereEmbedder struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	inputType EmbedInputType
+}
+
+// NewCohereEmbedder creates an embedder for model, e.g. "embed-english-v3.0".
+// Texts are embedded as documents unless set otherwise with SetInputType.
+func NewCohereEmbedder(apiKey, model string) *CohereEmbedder {
+	return &CohereEmbedder{apiKey: apiKey, model: model, baseURL: cohereBaseURL, inputType: EmbedDocument}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (e *CohereEmbedder) SetBaseURL(baseURL string) {
+	e.baseURL = baseURL
+}
+
+// SetInputType sets the input type of the texts
+func (e *CohereEmbedder) SetInputType(t EmbedInputType) {
+	e.inputType = t
+}
+
+func (e *CohereEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, texts, cohereEmbedBatchSize, func(ctx context.Context, texts []string) ([][]float32, error) {
+		body := map[string]interface{}{
+			"model":           e.model,
+			"texts":           texts,
+			"input_type":      cohereInputTypes[e.inputType],
+			"embedding_types": []string{"float"},
+		}
+		headers := map[string]string{"Authorization": "Bearer " + e.apiKey}
+		var resp struct {
+			Embeddings struct {
+				Float [][]float32 `json:"float"`
+			} `json:"embeddings"`
+		}
+		if err := doJSON(ctx, nil, http.MethodPost, e.baseURL+"/embed", headers, body, &resp); err != nil {
+			return nil, err
+		}
+		return resp.Embeddings.Float, nil
+	})
+}
+
+// VoyageEmbedder embeds texts with the Voyage AI embeddings API
+type VoyageEmbedder struct {
+	apiKey    string
+	model     string
+	baseURL   string
+	inputType EmbedInputType
+}
+
+// NewVoyageEmbedder creates an embedder for model, e.g. "voyage-3"
+func NewVoyageEmbedder(apiKey, model string) *VoyageEmbedder {
+	return &VoyageEmbedder{apiKey: apiKey, model: model, baseURL: voyageBaseURL}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (e *VoyageEmbedder) SetBaseURL(baseURL string) {
+	e.baseURL = baseURL
+}
+
+// SetInputType sets the input type of the texts
+func (e *VoyageEmbedder) SetInputType(t EmbedInputType) {
+	e.inputType = t
+}
+
+func (e *VoyageEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, texts, voyageEmbedBatchSize, func(ctx context.Context, texts []string) ([][]float32, error) {
+		body := map[string]interface{}{"model": e.model, "input": texts}
+		if e.inputType != "" {
+			body["input_type"] = string(e.inputType)
+		}
+		headers := map[string]string{"Authorization": "Bearer " + e.apiKey}
+		var resp struct {
+			Data []struct {
+				Embedding []float32 `json:"embedding"`
+				Index     int       `json:"index"`
+			} `json:"data"`
+		}
+		if err := doJSON(ctx, nil, http.MethodPost, e.baseURL+"/embeddings", headers, body, &resp); err != nil {
+			return nil, err
+		}
+		vectors := make([][]float32, len(texts))
+		for _, data := range resp.Data {
+			if data.Index < 0 || data.Index >= len(vectors) {
+				return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+			}
+			vectors[data.Index] = data.Embedding
+		}
+		return vectors, nil
+	})
+}
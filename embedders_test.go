@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEmbedders(t *testing.T) {
+	var requests []string
+	var inputTypes []string
+	value := func(text string) []float32 {
+		n, _ := strconv.Atoi(text)
+		return []float32{float32(n)}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		var body struct {
+			Input     []string `json:"input"`
+			Texts     []string `json:"texts"`
+			InputType string   `json:"input_type"`
+			Requests  []struct {
+				Content  geminiContent `json:"content"`
+				TaskType string        `json:"taskType"`
+			} `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/embeddings"):
+			// data in reverse order, placed by index
+			var data []map[string]interface{}
+			for i := len(body.Input) - 1; i >= 0; i-- {
+				data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": value(body.Input[i])})
+			}
+			inputTypes = append(inputTypes, body.InputType)
+			json.NewEncoder(w).Encode(map[string]interface{}{"object": "list", "model": "m", "data": data})
+		case strings.HasSuffix(r.URL.Path, ":batchEmbedContents"):
+			var embeddings []map[string]interface{}
+			for _, req := range body.Requests {
+				embeddings = append(embeddings, map[string]interface{}{"values": value(req.Content.Parts[0].Text)})
+			}
+			inputTypes = append(inputTypes, body.Requests[0].TaskType)
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+		case r.URL.Path == "/embed":
+			var vectors [][]float32
+			for _, text := range body.Texts {
+				vectors = append(vectors, value(text))
+			}
+			inputTypes = append(inputTypes, body.InputType)
+			json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": map[string]interface{}{"float": vectors}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	texts := make([]string, 150)
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+	gemini := NewGeminiEmbedder("key", "text-embedding-004")
+	gemini.SetBaseURL(server.URL + "/")
+	gemini.SetInputType(EmbedQuery)
+	cohere := NewCohereEmbedder("key", "embed-english-v3.0")
+	cohere.SetBaseURL(server.URL)
+	voyage := NewVoyageEmbedder("key", "voyage-3")
+	voyage.SetBaseURL(server.URL)
+
+	for _, tc := range []struct {
+		name      string
+		embedder  Embedder
+		requests  int
+		inputType string
+	}{
+		{"openai", NewOpenAICompatible(server.URL, "key", "", 0, 0, false).Embedder("text-embedding-3-small"), 1, ""},
+		{"gemini", gemini, 2, "RETRIEVAL_QUERY"},
+		{"cohere", cohere, 2, "search_document"},
+		{"voyage", voyage, 2, ""},
+	} {
+		requests, inputTypes = nil, nil
+		vectors, err := tc.embedder.Embed(context.Background(), texts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(vectors) != len(texts) || vectors[0][0] != 0 || vectors[149][0] != 149 {
+			t.Errorf("%s: unexpected vectors %v", tc.name, vectors)
+		}
+		if len(requests) != tc.requests || inputTypes[0] != tc.inputType {
+			t.Errorf("%s: expected %d requests with input type %q, got %v %v", tc.name, tc.requests, tc.inputType, requests, inputTypes)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Embedder converts texts to embedding vectors, one per text in the same order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// BatchEmbedder splits large inputs into provider sized batches, embeds them
+// in parallel with per-batch retries and reassembles the results in order
+type BatchEmbedder struct {
+	embedder    Embedder
+	batchSize   int
+	parallelism int
+	maxRetries  int
+	retryDelay  time.Duration
+}
+
+// NewBatchEmbedder wraps embedder. parallelism is the number of batches
+// in flight (min 1), maxRetries is the number of retries per batch.
+func NewBatchEmbedder(embedder Embedder, batchSize, parallelism, maxRetries int) *BatchEmbedder {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	return &BatchEmbedder{
+		embedder:    embedder,
+		batchSize:   batchSize,
+		parallelism: parallelism,
+		maxRetries:  maxRetries,
+		retryDelay:  500 * time.Millisecond,
+	}
+}
+
+func (b *BatchEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	result := make([][]float32, len(texts))
+	sem := make(chan struct{}, b.parallelism)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for start := 0; start < len(texts); start += b.batchSize {
+		end := start + b.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			vectors, err := b.embedBatch(ctx, texts[start:end])
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("batch %d-%d: %w", start, end, err)
+					cancel()
+				})
+				return
+			}
+			copy(result[start:end], vectors)
+		}(start, end)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (b *BatchEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	var lastErr error
+	delay := b.retryDelay
+	for attempt := 0; attempt <= b.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+				delay *= 2
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		vectors, err := b.embedder.Embed(ctx, texts)
+		if err == nil {
+			if len(vectors) != len(texts) {
+				return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(vectors))
+			}
+			return vectors, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyEmbedder embeds texts as their numeric value and fails the first call of every batch
+type flakyEmbedder struct {
+	mu     sync.Mutex
+	failed map[string]bool
+	calls  int
+}
+
+func (f *flakyEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	f.calls++
+	if !f.failed[texts[0]] {
+		f.failed[texts[0]] = true
+		f.mu.Unlock()
+		return nil, errors.New("temporary failure")
+	}
+	f.mu.Unlock()
+
+	res := make([][]float32, len(texts))
+	for i, text := range texts {
+		n, _ := strconv.Atoi(text)
+		res[i] = []float32{float32(n)}
+	}
+	return res, nil
+}
+
+func TestBatchEmbedder(t *testing.T) {
+	texts := make([]string, 25)
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+
+	inner := &flakyEmbedder{failed: map[string]bool{}}
+	embedder := NewBatchEmbedder(inner, 10, 3, 1)
+	embedder.retryDelay = time.Millisecond
+
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Error embedding: %v", err)
+	}
+	for i, v := range vectors {
+		if v[0] != float32(i) {
+			t.Fatalf("vector %d out of order: %v", i, v)
+		}
+	}
+	if inner.calls != 6 {
+		t.Fatalf("expected 6 calls (3 batches with one retry each), got %d", inner.calls)
+	}
+
+	embedder = NewBatchEmbedder(&flakyEmbedder{failed: map[string]bool{}}, 10, 3, 0)
+	if _, err := embedder.Embed(context.Background(), texts); err == nil {
+		t.Fatalf("expected error without retries")
+	}
+}
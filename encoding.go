@@ -0,0 +1,283 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// EncodingError is returned when text is not valid in its declared encoding,
+// instead of sending it and getting an opaque 400 from the provider
+type EncodingError struct {
+	Field   string // e.g. "prompt" or "messages[1]"
+	Charset string
+	Offset  int // byte offset of the first invalid sequence, -1 if unknown
+	Reason  string
+}
+
+func (e *EncodingError) Error() string {
+	msg := fmt.Sprintf("invalid %s text", e.Charset)
+	if e.Field != "" {
+		msg = e.Field + ": " + msg
+	}
+	if e.Offset >= 0 {
+		msg += fmt.Sprintf(" at byte %d", e.Offset)
+	}
+	return msg + ": " + e.Reason
+}
+
+// DecodeText converts data in the given charset, a WHATWG label such as
+// "utf-8", "utf-16le", "windows-1252" or "shift_jis", to sanitized UTF-8.
+// Invalid input is reported as an EncodingError.
+func DecodeText(data []byte, charset string) (string, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return "", &EncodingError{Charset: charset, Offset: -1, Reason: "unsupported charset"}
+	}
+	name, _ := htmlindex.Name(enc)
+
+	var text string
+	switch name {
+	case "utf-8":
+		if i := invalidUTF8Offset(data); i >= 0 {
+			return "", &EncodingError{Charset: name, Offset: i, Reason: fmt.Sprintf("invalid byte 0x%02x", data[i])}
+		}
+		text = string(data)
+	case "utf-16le", "utf-16be":
+		if len(data)%2 != 0 {
+			return "", &EncodingError{Charset: name, Offset: len(data) - 1, Reason: "odd number of bytes"}
+		}
+		text = decodeUTF16(data, name == "utf-16be")
+	default:
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", &EncodingError{Charset: name, Offset: -1, Reason: err.Error()}
+		}
+		if strings.ContainsRune(string(decoded), utf8.RuneError) && !strings.ContainsRune(string(data), utf8.RuneError) {
+			return "", &EncodingError{Charset: name, Offset: -1, Reason: "contains bytes that are not valid in this charset"}
+		}
+		text = string(decoded)
+	}
+	return cleanText(text), nil
+}
+
+// SanitizeText makes text safe to send to providers. Invalid UTF-8 is
+// transcoded: UTF-16 is detected by its byte order mark or zero bytes, mostly
+// valid UTF-8 has the invalid bytes (e.g. lone surrogates) dropped, and
+// anything else is read as Windows-1252. Byte order marks and control
+// characters other than tab and newlines are removed.
+func SanitizeText(text string) string {
+	if utf8.ValidString(text) {
+		return cleanText(text)
+	}
+
+	data := []byte(text)
+	switch {
+	case len(data) >= 2 && data[0] == 0xff && data[1] == 0xfe:
+		return cleanText(decodeUTF16(data[2:], false))
+	case len(data) >= 2 && data[0] == 0xfe && data[1] == 0xff:
+		return cleanText(decodeUTF16(data[2:], true))
+	}
+	if bigEndian, ok := looksLikeUTF16(data); ok {
+		return cleanText(decodeUTF16(data, bigEndian))
+	}
+
+	var valid strings.Builder
+	multibyte := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r != utf8.RuneError || size > 1 {
+			valid.WriteString(text[i : i+size])
+			multibyte = multibyte || size > 1
+		}
+		i += size
+	}
+	if multibyte {
+		return cleanText(valid.String())
+	}
+
+	decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+	if err != nil {
+		return cleanText(valid.String())
+	}
+	return cleanText(string(decoded))
+}
+
+// invalidUTF8Offset returns the offset of the first invalid sequence, or -1
+func invalidUTF8Offset(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// looksLikeUTF16 detects UTF-16 text without a byte order mark by the zero
+// high bytes of ASCII characters
+func looksLikeUTF16(data []byte) (bigEndian bool, ok bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return false, false
+	}
+	var even, odd int
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			even++
+		}
+		if data[i+1] == 0 {
+			odd++
+		}
+	}
+	units := len(data) / 2
+	switch {
+	case odd*10 >= units*3 && even == 0:
+		return false, true
+	case even*10 >= units*3 && odd == 0:
+		return true, true
+	}
+	return false, false
+}
+
+// decodeUTF16 decodes UTF-16, dropping unpaired surrogates
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(units); i++ {
+		u := rune(units[i])
+		switch {
+		case utf16.IsSurrogate(u) && u < 0xdc00 && i+1 < len(units):
+			if r := utf16.DecodeRune(u, rune(units[i+1])); r != utf8.RuneError {
+				sb.WriteRune(r)
+				i++
+			}
+		case utf16.IsSurrogate(u):
+		default:
+			sb.WriteRune(u)
+		}
+	}
+	return sb.String()
+}
+
+// cleanText removes byte order marks, noncharacters and control characters
+// other than tab and newlines from valid UTF-8
+func cleanText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20 || r == 0x7f || r == 0xfeff || r == 0xfffe || r == 0xffff:
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// SanitizingLLM sanitizes prompts and messages before they are sent, see
+// SanitizeText. With a charset set, input is decoded from it instead, and
+// invalid input fails with an EncodingError naming the field.
+type SanitizingLLM struct {
+	LLM
+	charset string
+}
+
+// NewSanitizingLLM creates a SanitizingLLM, charset is a label for DecodeText
+// or empty to detect the encoding
+func NewSanitizingLLM(llm LLM, charset string) *SanitizingLLM {
+	return &SanitizingLLM{LLM: llm, charset: charset}
+}
+
+func (s *SanitizingLLM) sanitize(field, text string) (string, error) {
+	if s.charset == "" {
+		return SanitizeText(text), nil
+	}
+	res, err := DecodeText([]byte(text), s.charset)
+	if err != nil {
+		err.(*EncodingError).Field = field
+		return "", err
+	}
+	return res, nil
+}
+
+func (s *SanitizingLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	systemPrompt, err := s.sanitize("systemPrompt", systemPrompt)
+	if err != nil {
+		return "", err
+	}
+	prompt, err = s.sanitize("prompt", prompt)
+	if err != nil {
+		return "", err
+	}
+	return s.LLM.Generate(ctx, systemPrompt, prompt)
+}
+
+func (s *SanitizingLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	systemPrompt, err := s.sanitize("systemPrompt", systemPrompt)
+	if err == nil {
+		prompt, err = s.sanitize("prompt", prompt)
+	}
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	s.LLM.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+}
+
+func (s *SanitizingLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	prompt, err := s.sanitize("prompt", prompt)
+	if err != nil {
+		return "", err
+	}
+	return s.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+}
+
+func (s *SanitizingLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	prompt, err := s.sanitize("prompt", prompt)
+	if err != nil {
+		return "", err
+	}
+	return s.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+}
+
+func (s *SanitizingLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	msgs := make([]Message, len(messages))
+	for i, msg := range messages {
+		content, err := s.sanitize(fmt.Sprintf("messages[%d]", i), msg.Content)
+		if err != nil {
+			return "", err
+		}
+		msg.Content = content
+		if len(msg.Parts) > 0 {
+			parts := make([]ContentPart, len(msg.Parts))
+			for j, part := range msg.Parts {
+				if part.Type == PartText {
+					if part.Text, err = s.sanitize(fmt.Sprintf("messages[%d].parts[%d]", i, j), part.Text); err != nil {
+						return "", err
+					}
+				}
+				parts[j] = part
+			}
+			msg.Parts = parts
+		}
+		msgs[i] = msg
+	}
+	return s.LLM.GenerateWithMessages(ctx, msgs)
+}
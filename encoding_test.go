@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"valid", "\ufeffhé\x00llo\tworld\n", "héllo\tworld\n"},
+		{"lone surrogate", "caf\xc3\xa9 \xed\xa0\x80ok", "café ok"},
+		{"windows-1252", "caf\xe9 \x93quoted\x94", "café “quoted”"},
+		{"utf-16le bom", "\xff\xfeh\x00i\x00", "hi"},
+		{"utf-16be", "\x00h\x00\xe9\x00l\x00l\x00o", "héllo"},
+	}
+	for _, tt := range tests {
+		if got := SanitizeText(tt.in); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	res, err := DecodeText([]byte("\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd"), "shift_jis")
+	if err != nil || res != "こんにちは" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	res, err = DecodeText([]byte{'h', 0, 0x3d, 0xd8, 'i', 0}, "utf-16le")
+	if err != nil || res != "hi" {
+		t.Fatalf("unpaired surrogate not stripped: %q, %v", res, err)
+	}
+
+	_, err = DecodeText([]byte("ok \xff"), "utf-8")
+	var encErr *EncodingError
+	if !errors.As(err, &encErr) || encErr.Offset != 3 {
+		t.Fatalf("expected encoding error at byte 3, got %v", err)
+	}
+	if _, err := DecodeText([]byte("hi"), "klingon"); err == nil {
+		t.Fatalf("expected unsupported charset error")
+	}
+}
+
+func TestSanitizingLLM(t *testing.T) {
+	var got string
+	stub := &stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		got = prompt
+		return "ok", nil
+	}}
+
+	if _, err := NewSanitizingLLM(stub, "").Generate(context.Background(), "", "caf\xe9"); err != nil || got != "café" {
+		t.Fatalf("unexpected prompt %q, %v", got, err)
+	}
+
+	_, err := NewSanitizingLLM(stub, "utf-8").GenerateWithMessages(context.Background(), []Message{
+		{Role: RoleUser, Content: "fine"},
+		{Role: RoleUser, Content: "caf\xe9"},
+	})
+	var encErr *EncodingError
+	if !errors.As(err, &encErr) || encErr.Field != "messages[1]" {
+		t.Fatalf("expected encoding error for messages[1], got %v", err)
+	}
+}
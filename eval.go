@@ -0,0 +1,327 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EvalCase is a single entry of an eval dataset.
+// Every expectation that is set must pass for the case to pass.
+type EvalCase struct {
+	Name         string         `json:"name" yaml:"name"`
+	SystemPrompt string         `json:"system,omitempty" yaml:"system"`
+	Prompt       string         `json:"prompt" yaml:"prompt"`
+	ExpectRegex  string         `json:"expect_regex,omitempty" yaml:"expect_regex"`
+	ExpectSchema map[string]any `json:"expect_schema,omitempty" yaml:"expect_schema"`
+	// Grader is a rubric checked by a grader model, which must answer PASS or FAIL
+	Grader string `json:"grader,omitempty" yaml:"grader"`
+}
+
+type EvalResult struct {
+	Case     EvalCase
+	Output   string
+	Passed   bool
+	Failure  string
+	Duration time.Duration
+}
+
+type EvalReport struct {
+	Model   string
+	Results []EvalResult
+}
+
+// LoadEvalDataset loads eval cases from a .jsonl, .json or .yaml/.yml file
+func LoadEvalDataset(path string) ([]EvalCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cases []EvalCase
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var c EvalCase
+			if err := json.Unmarshal([]byte(text), &c); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			cases = append(cases, c)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case ".json":
+		if err := json.NewDecoder(f).Decode(&cases); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&cases); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported dataset format: %s", path)
+	}
+
+	for i := range cases {
+		if cases[i].Prompt == "" {
+			return nil, fmt.Errorf("case %d: prompt is required", i+1)
+		}
+		if cases[i].Name == "" {
+			cases[i].Name = fmt.Sprintf("case_%d", i+1)
+		}
+	}
+	return cases, nil
+}
+
+// RunEval runs every case against llm. Grader rubrics are checked by grader,
+// or by llm itself if grader is nil.
+func RunEval(ctx context.Context, llm LLM, grader LLM, cases []EvalCase) *EvalReport {
+	if grader == nil {
+		grader = llm
+	}
+	report := &EvalReport{}
+	for _, c := range cases {
+		start := time.Now()
+		output, err := llm.Generate(ctx, c.SystemPrompt, c.Prompt)
+		res := EvalResult{Case: c, Output: output, Duration: time.Since(start)}
+		if err != nil {
+			res.Failure = fmt.Sprintf("generation failed: %v", err)
+		} else {
+			res.Failure = checkEvalCase(ctx, grader, c, output)
+		}
+		res.Passed = res.Failure == ""
+		report.Results = append(report.Results, res)
+	}
+	report.Model = llm.GetModel()
+	return report
+}
+
+func checkEvalCase(ctx context.Context, grader LLM, c EvalCase, output string) string {
+	if c.ExpectRegex != "" {
+		re, err := regexp.Compile(c.ExpectRegex)
+		if err != nil {
+			return fmt.Sprintf("invalid regex: %v", err)
+		}
+		if !re.MatchString(output) {
+			return fmt.Sprintf("output does not match %q", c.ExpectRegex)
+		}
+	}
+
+	if c.ExpectSchema != nil {
+		var v any
+		if err := json.Unmarshal([]byte(trimCodeFence(output)), &v); err != nil {
+			return fmt.Sprintf("output is not valid JSON: %v", err)
+		}
+		if err := validateJSONSchema(c.ExpectSchema, v, "$"); err != nil {
+			return err.Error()
+		}
+	}
+
+	if c.Grader != "" {
+		verdict, err := Judge(ctx, grader, c.Grader, c.Prompt, output)
+		if err != nil {
+			return fmt.Sprintf("grader failed: %v", err)
+		}
+		if !verdict.Pass {
+			return fmt.Sprintf("grader: %s", verdict.Reason)
+		}
+	}
+	return ""
+}
+
+// trimCodeFence removes a markdown code fence around the text, if any
+func trimCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
+
+// validateJSONSchema checks v against a subset of JSON Schema:
+// type, properties, required, items and enum
+func validateJSONSchema(schema map[string]any, v any, path string) error {
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of %v", path, v, enum)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				if _, ok := obj[fmt.Sprint(r)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, r)
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]any); ok {
+			for name, p := range props {
+				propSchema, ok := p.(map[string]any)
+				if !ok {
+					continue
+				}
+				if val, ok := obj[name]; ok {
+					if err := validateJSONSchema(propSchema, val, path+"."+name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range arr {
+				if err := validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "null":
+		if v != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	}
+	return nil
+}
+
+func (r *EvalReport) Failed() int {
+	failed := 0
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report in JUnit XML format
+func (r *EvalReport) WriteJUnit(w io.Writer) error {
+	suite := junitTestSuite{
+		Name:     r.Model,
+		Tests:    len(r.Results),
+		Failures: r.Failed(),
+	}
+	var total time.Duration
+	for _, res := range r.Results {
+		total += res.Duration
+		tc := junitTestCase{
+			Name:      res.Case.Name,
+			ClassName: r.Model,
+			Time:      fmt.Sprintf("%.3f", res.Duration.Seconds()),
+			SystemOut: res.Output,
+		}
+		if !res.Passed {
+			tc.Failure = &junitFailure{Message: res.Failure}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteMarkdown writes the report as a markdown table
+func (r *EvalReport) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Eval report: %s\n\n", r.Model)
+	fmt.Fprintf(&sb, "%d/%d passed\n\n", len(r.Results)-r.Failed(), len(r.Results))
+	sb.WriteString("| Case | Result | Duration | Details |\n")
+	sb.WriteString("|------|--------|----------|---------|\n")
+	for _, res := range r.Results {
+		status := "PASS"
+		if !res.Passed {
+			status = "FAIL"
+		}
+		details := strings.NewReplacer("|", "\\|", "\n", " ").Replace(res.Failure)
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", res.Case.Name, status, res.Duration.Round(time.Millisecond), details)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
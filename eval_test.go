@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubLLM returns canned responses and is shared by offline tests
+type stubLLM struct {
+	model    string
+	response func(systemPrompt, prompt string) (string, error)
+}
+
+func (s *stubLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return s.response(systemPrompt, prompt)
+}
+
+func (s *stubLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	res, err := s.response(systemPrompt, prompt)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	resultCh <- res
+	doneCh <- true
+}
+
+func (s *stubLLM) GetModel() string {
+	return s.model
+}
+
+func (s *stubLLM) Close() error {
+	return nil
+}
+
+func (s *stubLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return s.response("", prompt)
+}
+
+func (s *stubLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return s.response("", prompt)
+}
+
+func (s *stubLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	if len(messages) == 0 {
+		return s.response("", "")
+	}
+	return s.response("", messages[len(messages)-1].Content)
+}
+
+func TestRunEval(t *testing.T) {
+	dataset := `
+- name: capital
+  prompt: capital of France?
+  expect_regex: (?i)paris
+- name: json
+  prompt: give json
+  expect_schema:
+    type: object
+    required: [age]
+    properties:
+      age: {type: integer}
+- name: graded
+  prompt: be polite
+  grader: must be polite
+`
+	path := filepath.Join(t.TempDir(), "evals.yaml")
+	if err := os.WriteFile(path, []byte(dataset), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases, err := LoadEvalDataset(path)
+	if err != nil {
+		t.Fatalf("Error loading dataset: %v", err)
+	}
+
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		switch {
+		case strings.HasPrefix(prompt, "capital"):
+			return "It is Paris.", nil
+		case strings.HasPrefix(prompt, "give json"):
+			return "```json\n{\"age\": 1.5}\n```", nil
+		case strings.HasPrefix(prompt, "Rubric"):
+			return "PASS", nil
+		}
+		return "thank you", nil
+	}}
+
+	report := RunEval(context.Background(), llm, nil, cases)
+	if len(report.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(report.Results))
+	}
+	if !report.Results[0].Passed || !report.Results[2].Passed {
+		t.Fatalf("expected regex and grader cases to pass: %+v", report.Results)
+	}
+	if report.Results[1].Passed {
+		t.Fatalf("expected schema case to fail on non-integer age")
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `failures="1"`) {
+		t.Fatalf("unexpected junit report: %s", buf.String())
+	}
+}
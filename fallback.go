@@ -6,106 +6,314 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"text/template"
 )
 
 type FallbackLLM struct {
 	llms          []LLM
 	currentModel  string
 	errorCallback func(error)
+
+	metrics     Metrics
+	metricsName string
+
+	degraded *template.Template
+
+	status *ModelStatusChecker
+}
+
+// DegradedError is reported to the error callback when every model failed
+// and the degraded response was returned instead
+type DegradedError struct {
+	// Response is the degraded response returned to the caller
+	Response string
+	Err      error
+}
+
+func (e *DegradedError) Error() string {
+	return fmt.Sprintf("degraded response returned: %v", e.Err)
+}
+
+func (e *DegradedError) Unwrap() error {
+	return e.Err
+}
+
+// DegradedData is the data of degraded response templates
+type DegradedData struct {
+	// Prompt is the last user prompt
+	Prompt string
+	// Err is the error of the last model tried
+	Err error
 }
 
 func NewFallbackLLM(gens []LLM, errorCallback func(error)) *FallbackLLM {
 	return &FallbackLLM{llms: gens, errorCallback: errorCallback}
 }
 
-func (f *FallbackLLM) generateWithFallback(fn func(gen LLM) (string, error)) (string, error) {
+// SetMetrics reports every model tried and its outcome to metrics as router
+// decisions, labeled with name
+func (f *FallbackLLM) SetMetrics(metrics Metrics, name string) {
+	f.metrics, f.metricsName = metrics, name
+}
+
+func (f *FallbackLLM) decision(gen LLM, err error) {
+	if f.metrics == nil {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	f.metrics.AddCounter(MetricRouterDecisions, 1, map[string]string{"router": f.metricsName, "arm": gen.GetModel(), "outcome": outcome})
+}
+
+// SetDegradedResponse makes the router answer with a message instead of
+// failing when every model failed, so user facing products fail softly. The
+// message is a text/template executed with DegradedData, e.g. "Sorry, I can't
+// answer right now, please try again later." A DegradedError is reported to the
+// error callback and MetricDegradedResponses is incremented. An empty message
+// disables the degraded mode.
+func (f *FallbackLLM) SetDegradedResponse(message string) error {
+	if message == "" {
+		f.degraded = nil
+		return nil
+	}
+	tmpl, err := template.New("degraded").Parse(message)
+	if err != nil {
+		return err
+	}
+	f.degraded = tmpl
+	return nil
+}
+
+// SetModelStatus skips models refused by checker, e.g. retired models.
+// If the replacement of a refused model is in the chain, it is tried in its
+// place instead of at its own position.
+func (f *FallbackLLM) SetModelStatus(checker *ModelStatusChecker) {
+	f.status = checker
+}
+
+// chain returns the models to try in order, and the error of the last
+// refused model
+func (f *FallbackLLM) chain() ([]LLM, error) {
+	if f.status == nil {
+		return f.llms, nil
+	}
 	var lastErr error
+	var chain []LLM
+	used := make(map[LLM]bool)
 	for _, gen := range f.llms {
-		response, err := fn(gen)
+		err := f.status.Check(gen.GetModel())
+		if err == nil {
+			if !used[gen] {
+				chain = append(chain, gen)
+				used[gen] = true
+			}
+			continue
+		}
+		if f.errorCallback != nil {
+			f.errorCallback(err)
+		}
+		lastErr = err
+		replacement := err.(*ModelStatusError).Status.Replacement
+		for _, r := range f.llms {
+			if replacement != "" && !used[r] && matchModel(r.GetModel(), replacement) && f.status.Check(r.GetModel()) == nil {
+				chain = append(chain, r)
+				used[r] = true
+				break
+			}
+		}
+	}
+	return chain, lastErr
+}
+
+// fail returns the degraded response if set, or the error. The error of ctx
+// is returned as is, a cancelled request has no one to answer to.
+func (f *FallbackLLM) fail(ctx context.Context, prompt string, lastErr error) (string, error) {
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	err := errors.New("LLM failed")
+	if lastErr != nil {
+		err = fmt.Errorf("LLM failed, last error: %w", lastErr)
+	}
+	if f.degraded == nil {
+		return "", err
+	}
+	var res strings.Builder
+	if tmplErr := f.degraded.Execute(&res, DegradedData{Prompt: prompt, Err: lastErr}); tmplErr != nil {
+		return "", fmt.Errorf("%w, degraded response: %v", err, tmplErr)
+	}
+	f.currentModel = ""
+	if f.metrics != nil {
+		f.metrics.AddCounter(MetricDegradedResponses, 1, map[string]string{"router": f.metricsName})
+	}
+	if f.errorCallback != nil {
+		f.errorCallback(&DegradedError{Response: res.String(), Err: err})
+	}
+	return res.String(), nil
+}
+
+// modelContext returns the context of a request to gen. The model override of
+// ctx (see WithModel) only applies to the primary model, the others are
+// usually of other providers, which would reject it.
+func (f *FallbackLLM) modelContext(ctx context.Context, gen LLM) context.Context {
+	if len(f.llms) == 0 || gen == f.llms[0] || requestModel(ctx, "") == "" {
+		return ctx
+	}
+	return WithModel(ctx, "")
+}
+
+func (f *FallbackLLM) generateWithFallback(ctx context.Context, prompt string, fn func(ctx context.Context, gen LLM) (string, error)) (string, error) {
+	llms, lastErr := f.chain()
+	for _, gen := range llms {
+		response, err := fn(f.modelContext(ctx, gen), gen)
+		f.decision(gen, err)
 		if err == nil {
 			f.currentModel = gen.GetModel()
 			return response, nil
 		}
 		if f.errorCallback != nil {
-			f.errorCallback(fmt.Errorf("Model %s error: %v", gen.GetModel(), err))
+			f.errorCallback(fmt.Errorf("Model %s error: %w", gen.GetModel(), err))
 		}
 		lastErr = err
 	}
-	return "", fmt.Errorf("LLM failed, last error: %v", lastErr)
+	return f.fail(ctx, prompt, lastErr)
 }
 
 func (f *FallbackLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
-	return f.generateWithFallback(func(gen LLM) (string, error) {
+	return f.generateWithFallback(ctx, prompt, func(ctx context.Context, gen LLM) (string, error) {
 		return gen.Generate(ctx, systemPrompt, prompt)
 	})
 }
 
 func (f *FallbackLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
-	var lastErr error
-	for i, gen := range f.llms {
+	f.streamWithFallback(ctx, prompt, resultCh, doneCh, errCh, func(ctx context.Context, gen LLM, doneCh chan bool, errCh chan error) {
+		gen.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+	})
+}
+
+// GenerateWithMessagesStream streams the reply to messages, falling back like
+// GenerateStream. Models that are not a MessagesStreamer get the
+// conversation flattened, see GenerateWithMessagesStream.
+func (f *FallbackLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	f.streamWithFallback(ctx, lastUserPrompt(messages), resultCh, doneCh, errCh, func(ctx context.Context, gen LLM, doneCh chan bool, errCh chan error) {
+		GenerateWithMessagesStream(ctx, gen, replay(), resultCh, doneCh, errCh)
+	})
+}
+
+// streamWithFallback runs stream with the models of the chain until one
+// succeeds. A "[CLEAR]" chunk is sent before falling back, to discard the
+// output of the failed model.
+func (f *FallbackLLM) streamWithFallback(ctx context.Context, prompt string, resultCh chan string, doneCh chan bool, errCh chan error, stream func(ctx context.Context, gen LLM, doneCh chan bool, errCh chan error)) {
+	llms, lastErr := f.chain()
+	for i, gen := range llms {
 		genLocal := gen // Create local copy for goroutine
 		// Send [CLEAR] message if this is not the first generator
 		if i > 0 {
 			select {
 			case resultCh <- "[CLEAR]":
 			case <-ctx.Done():
-				errCh <- ctx.Err()
+				select {
+				case errCh <- ctx.Err():
+				default:
+				}
 				return
 			}
 		}
 
 		select {
 		case <-ctx.Done():
-			errCh <- ctx.Err()
+			select {
+			case errCh <- ctx.Err():
+			default:
+			}
 			return
 		default:
-			genCtx, cancel := context.WithCancel(ctx)
+			genCtx, cancel := context.WithCancel(f.modelContext(ctx, gen))
 			genErrCh := make(chan error, 1)
 			genDoneCh := make(chan bool, 1)
 
 			go func() {
 				// fmt.Printf("[Debug] Generating with model: %s\n", gen.GetModel())
-				genLocal.GenerateStream(genCtx, systemPrompt, prompt, resultCh, genDoneCh, genErrCh)
+				stream(genCtx, genLocal, genDoneCh, genErrCh)
 			}()
 
 			select {
 			case <-genDoneCh:
 				cancel()
+				f.decision(gen, nil)
 				f.currentModel = gen.GetModel() // Set the current model
-				doneCh <- true
+				select {
+				case doneCh <- true:
+				case <-ctx.Done():
+				}
 				return
 			case err := <-genErrCh:
 				cancel()
 				if err == context.Canceled {
-					errCh <- err
+					select {
+					case errCh <- err:
+					case <-ctx.Done():
+					}
 					return
 				}
+				f.decision(gen, err)
 				if err != nil {
 					lastErr = err
-					f.errorCallback(fmt.Errorf("Model %s error: %v", gen.GetModel(), err))
+					if f.errorCallback != nil {
+						f.errorCallback(fmt.Errorf("Model %s error: %w", gen.GetModel(), err))
+					}
 					// Continue to the next generator
 				} else {
 					// Wait for all results before returning
 					<-genDoneCh
-					doneCh <- true
+					select {
+					case doneCh <- true:
+					case <-ctx.Done():
+					}
 					return
 				}
 			case <-ctx.Done():
 				cancel()
-				errCh <- ctx.Err()
+				select {
+				case errCh <- ctx.Err():
+				default:
+				}
 				return
 			}
 		}
 	}
-	var finalErr error
-	if lastErr != nil {
-		finalErr = fmt.Errorf("LLM failed, last error: %v", lastErr)
-	} else {
-		finalErr = errors.New("LLM failed")
+	res, err := f.fail(ctx, prompt, lastErr)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	for _, chunk := range []string{"[CLEAR]", res} {
+		select {
+		case resultCh <- chunk:
+		case <-ctx.Done():
+			select {
+			case errCh <- ctx.Err():
+			default:
+			}
+			return
+		}
 	}
-
 	select {
-	case errCh <- finalErr:
+	case doneCh <- true:
 	case <-ctx.Done():
 	}
 }
@@ -114,6 +322,17 @@ func (f *FallbackLLM) GetModel() string {
 	return f.currentModel
 }
 
+// Close closes all models of the chain
+func (f *FallbackLLM) Close() error {
+	var errs []error
+	for _, gen := range f.llms {
+		if err := gen.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // Add a helper function to handle buffering of images
 func bufferImage(image io.Reader) (*bytes.Buffer, error) {
 	if image == nil {
@@ -143,7 +362,7 @@ func (f *FallbackLLM) GenerateWithImage(ctx context.Context, prompt string, imag
 		return "", err
 	}
 
-	return f.generateWithFallback(func(gen LLM) (string, error) {
+	return f.generateWithFallback(ctx, prompt, func(ctx context.Context, gen LLM) (string, error) {
 		var currentImageReader io.Reader
 		if imageBuf != nil {
 			currentImageReader = bytes.NewReader(imageBuf.Bytes())
@@ -167,23 +386,27 @@ func (f *FallbackLLM) GenerateWithImages(ctx context.Context, prompt string, ima
 		imageBufs[i] = buf
 	}
 
-	return f.generateWithFallback(func(gen LLM) (string, error) {
+	return f.generateWithFallback(ctx, prompt, func(ctx context.Context, gen LLM) (string, error) {
 		return gen.GenerateWithImages(ctx, prompt, newReadersFromBuffers(imageBufs), mimeTypes)
 	})
 }
 
 func (f *FallbackLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
-	var lastErr error
-	for _, gen := range f.llms {
-		response, err := gen.GenerateWithMessages(ctx, messages)
-		if err == nil {
-			f.currentModel = gen.GetModel()
-			return response, nil
-		}
-		if f.errorCallback != nil {
-			f.errorCallback(fmt.Errorf("Model %s error: %v", gen.GetModel(), err))
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	return f.generateWithFallback(ctx, lastUserPrompt(messages), func(ctx context.Context, gen LLM) (string, error) {
+		return gen.GenerateWithMessages(ctx, replay())
+	})
+}
+
+// lastUserPrompt returns the content of the last user message
+func lastUserPrompt(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
 		}
-		lastErr = err
 	}
-	return "", fmt.Errorf("LLM failed, last error: %v", lastErr)
+	return ""
 }
@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFallbackDegradedResponse(t *testing.T) {
+	failing := &stubLLM{model: "primary", response: func(systemPrompt, prompt string) (string, error) {
+		return "", errors.New("down")
+	}}
+	var reported []error
+	router := NewFallbackLLM([]LLM{failing, failing}, func(err error) { reported = append(reported, err) })
+	metrics := NewPrometheusMetrics()
+	router.SetMetrics(metrics, "main")
+	ctx := context.Background()
+
+	if _, err := router.Generate(ctx, "", "hello"); err == nil {
+		t.Fatal("expected an error without degraded mode")
+	}
+
+	if err := router.SetDegradedResponse(`Sorry, I can't answer "{{.Prompt}}" right now.`); err != nil {
+		t.Fatal(err)
+	}
+	reported = nil
+	res, err := router.Generate(ctx, "", "hello")
+	if err != nil || res != `Sorry, I can't answer "hello" right now.` {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	var degraded *DegradedError
+	if len(reported) != 3 || !errors.As(reported[2], &degraded) || degraded.Response != res {
+		t.Errorf("expected a DegradedError to be reported, got %v", reported)
+	}
+
+	var chunks []string
+	if err := consumeStream(ctx, router, "", "hi", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if chunks[len(chunks)-1] != `Sorry, I can't answer "hi" right now.` {
+		t.Errorf("unexpected stream %q", chunks)
+	}
+	if got := metrics.Value(MetricDegradedResponses, map[string]string{"router": "main"}); got != 2 {
+		t.Errorf("expected 2 degraded responses, got %v", got)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if res, err := router.Generate(cancelled, "", "hello"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error instead of a degraded response, got %q, %v", res, err)
+	}
+	// nobody reads the channels of a cancelled stream, it must not block
+	done := make(chan struct{})
+	go func() {
+		router.GenerateStream(cancelled, "", "hi", make(chan string), make(chan bool), make(chan error))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("GenerateStream blocked on a cancelled context")
+	}
+}
+
+// closingLLM records Close calls
+type closingLLM struct {
+	stubLLM
+	closed int
+	err    error
+}
+
+func (c *closingLLM) Close() error {
+	c.closed++
+	return c.err
+}
+
+func TestFallbackClose(t *testing.T) {
+	a, b := &closingLLM{err: errors.New("busy")}, &closingLLM{}
+	router := NewFallbackLLM([]LLM{a, b}, nil)
+	// Wrappers close what they wrap
+	llm := NewMetricsLLM(router, NewPrometheusMetrics())
+	if err := llm.Close(); err == nil || err.Error() != "busy" {
+		t.Errorf("expected the error of the first model, got %v", err)
+	}
+	if a.closed != 1 || b.closed != 1 {
+		t.Errorf("expected every model to be closed once, got %d and %d", a.closed, b.closed)
+	}
+}
+
+// modelLLM records the requested models and fails for models it does not
+// serve, like a provider rejecting models of other providers
+type modelLLM struct {
+	stubLLM
+	serves    []string
+	requested []string
+}
+
+func (m *modelLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	model := requestModel(ctx, m.model)
+	m.requested = append(m.requested, model)
+	if !containsString(m.serves, model) {
+		return "", errors.New("unknown model " + model)
+	}
+	return m.response(systemPrompt, prompt)
+}
+
+func TestFallbackModelOverride(t *testing.T) {
+	primary := &modelLLM{stubLLM: stubLLM{model: "gpt-4o-mini", response: func(systemPrompt, prompt string) (string, error) {
+		return "", errors.New("overloaded")
+	}}, serves: []string{"gpt-4o-mini", "gpt-4o"}}
+	fallback := &modelLLM{stubLLM: stubLLM{model: "claude", response: func(systemPrompt, prompt string) (string, error) {
+		return "ok", nil
+	}}, serves: []string{"claude"}}
+	router := NewFallbackLLM([]LLM{primary, fallback}, nil)
+
+	res, err := router.Generate(WithModel(context.Background(), "gpt-4o"), "", "hi")
+	if err != nil || res != "ok" {
+		t.Fatalf("expected the fallback model to answer, got %q, %v", res, err)
+	}
+	if len(primary.requested) != 1 || primary.requested[0] != "gpt-4o" || fallback.requested[0] != "claude" {
+		t.Errorf("the override should only apply to the primary model, got %v and %v", primary.requested, fallback.requested)
+	}
+}
+
+func TestFallbackMessagesStream(t *testing.T) {
+	failing := &stubLLM{model: "primary", response: func(systemPrompt, prompt string) (string, error) {
+		return "", errors.New("down")
+	}}
+	var received []Message
+	fallback := &messagesLLM{stubLLM: stubLLM{model: "fallback"}, fn: func(messages []Message) { received = messages }}
+	router := NewFallbackLLM([]LLM{failing, fallback}, nil)
+
+	var _ MessagesStreamer = router
+	messages := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}, {Role: RoleUser, Content: "how are you?"}}
+	var chunks []string
+	if err := consumeMessagesStream(context.Background(), router, messages, func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 3 || strings.Join(chunks, "|") != "[CLEAR]|ok" || router.GetModel() != "fallback" {
+		t.Errorf("unexpected stream %q from %s with messages %v", chunks, router.GetModel(), received)
+	}
+}
+
+func TestFallbackMessagesMedia(t *testing.T) {
+	failing, fallback := &mediaLLM{err: errors.New("down")}, &mediaLLM{}
+	router := NewFallbackLLM([]LLM{failing, fallback}, nil)
+	image := func() []Message {
+		return []Message{{Role: RoleUser, Content: "describe", Image: strings.NewReader("image"), MimeType: MimeTypePNG}}
+	}
+
+	if res, err := router.GenerateWithMessages(context.Background(), image()); err != nil || res != "ok" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	if err := consumeMessagesStream(context.Background(), router, image(), func(string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(failing.sizes) != 2 || len(fallback.sizes) != 2 || fallback.sizes[0] != 5 || fallback.sizes[1] != 5 {
+		t.Errorf("expected every model to get the image, got sizes %v and %v", failing.sizes, fallback.sizes)
+	}
+}
@@ -0,0 +1,328 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/openai/openai-go"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProviderFile is a file stored with a provider's Files API. Attach it to a
+// Message with File to send it without uploading its content again.
+type ProviderFile struct {
+	// ID references the file in requests, e.g. "file-abc" or "files/abc"
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	MimeType  MimeType
+	// URI references the file in Gemini requests
+	URI string
+}
+
+// FileStore is a provider's Files API. Delete succeeds for files that no
+// longer exist.
+type FileStore interface {
+	Upload(ctx context.Context, name string, r io.Reader, mimeType MimeType) (ProviderFile, error)
+	Get(ctx context.Context, id string) (ProviderFile, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]ProviderFile, error)
+}
+
+// openAIFiles is the OpenAI Files API
+type openAIFiles struct {
+	client  *openai.Client
+	purpose openai.FilePurpose
+}
+
+// Files returns the Files API of the client, files are uploaded for use as
+// chat inputs
+func (o *OpenAI) Files() FileStore {
+	return &openAIFiles{client: o.client, purpose: openai.FilePurpose("user_data")}
+}
+
+func (f *openAIFiles) Upload(ctx context.Context, name string, r io.Reader, mimeType MimeType) (ProviderFile, error) {
+	file, err := f.client.Files.New(ctx, openai.FileNewParams{
+		File:    openai.FileParam(r, name, string(mimeType)),
+		Purpose: openai.F(f.purpose),
+	})
+	if err != nil {
+		return ProviderFile{}, err
+	}
+	return ProviderFile{ID: file.ID, Name: file.Filename, CreatedAt: time.Unix(file.CreatedAt, 0), MimeType: mimeType}, nil
+}
+
+// Get returns the metadata of a file, OpenAI does not keep its MIME type
+func (f *openAIFiles) Get(ctx context.Context, id string) (ProviderFile, error) {
+	file, err := f.client.Files.Get(ctx, id)
+	if err != nil {
+		return ProviderFile{}, err
+	}
+	return ProviderFile{ID: file.ID, Name: file.Filename, CreatedAt: time.Unix(file.CreatedAt, 0)}, nil
+}
+
+func (f *openAIFiles) Delete(ctx context.Context, id string) error {
+	_, err := f.client.Files.Delete(ctx, id)
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (f *openAIFiles) List(ctx context.Context) ([]ProviderFile, error) {
+	var files []ProviderFile
+	iter := f.client.Files.ListAutoPaging(ctx, openai.FileListParams{})
+	for iter.Next() {
+		file := iter.Current()
+		files = append(files, ProviderFile{ID: file.ID, Name: file.Filename, CreatedAt: time.Unix(file.CreatedAt, 0)})
+	}
+	return files, iter.Err()
+}
+
+// GeminiFiles is the Gemini API Files API. Gemini deletes files after 48
+// hours on its own, but they count against the project storage until then.
+type GeminiFiles struct {
+	client *genai.Client
+}
+
+// NewGeminiFiles creates a Gemini Files API client, Close it when done
+func NewGeminiFiles(ctx context.Context, apiKey string) (*GeminiFiles, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Google client: %v", err)
+	}
+	return &GeminiFiles{client: client}, nil
+}
+
+func (g *GeminiFiles) Close() error {
+	return g.client.Close()
+}
+
+func (g *GeminiFiles) Upload(ctx context.Context, name string, r io.Reader, mimeType MimeType) (ProviderFile, error) {
+	file, err := g.client.UploadFile(ctx, "", r, &genai.UploadFileOptions{DisplayName: name, MIMEType: string(mimeType)})
+	if err != nil {
+		return ProviderFile{}, err
+	}
+	return geminiFile(file), nil
+}
+
+func (g *GeminiFiles) Get(ctx context.Context, id string) (ProviderFile, error) {
+	file, err := g.client.GetFile(ctx, id)
+	if err != nil {
+		return ProviderFile{}, err
+	}
+	return geminiFile(file), nil
+}
+
+func (g *GeminiFiles) Delete(ctx context.Context, id string) error {
+	err := g.client.DeleteFile(ctx, id)
+	var httpErr interface{ HTTPCode() int }
+	if status.Code(err) == codes.NotFound || (errors.As(err, &httpErr) && httpErr.HTTPCode() == http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (g *GeminiFiles) List(ctx context.Context) ([]ProviderFile, error) {
+	var files []ProviderFile
+	iter := g.client.ListFiles(ctx)
+	for {
+		file, err := iter.Next()
+		if err == iterator.Done {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		files = append(files, geminiFile(file))
+	}
+}
+
+func geminiFile(file *genai.File) ProviderFile {
+	return ProviderFile{ID: file.Name, Name: file.DisplayName, CreatedAt: file.CreateTime, MimeType: MimeType(file.MIMEType), URI: file.URI}
+}
+
+// FileManager tracks files uploaded through it and deletes them once their
+// TTL passed and nothing references them anymore. Uploaded file names get a
+// prefix, so files left behind by a previous process can be swept as orphans.
+// Uploading content that is already stored returns the existing file.
+type FileManager struct {
+	store  FileStore
+	prefix string
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	files  map[string]*managedFile
+	byHash map[string]string
+}
+
+type managedFile struct {
+	file    ProviderFile
+	hash    string
+	expires time.Time
+	refs    int
+	// deleting is set while Cleanup deletes the file, it is not returned
+	// or retained anymore
+	deleting bool
+}
+
+// NewFileManager creates a FileManager, prefix is prepended to the names of
+// uploaded files and ttl is how long they are kept at least
+func NewFileManager(store FileStore, prefix string, ttl time.Duration) *FileManager {
+	return &FileManager{store: store, prefix: prefix, ttl: ttl, files: make(map[string]*managedFile), byHash: make(map[string]string)}
+}
+
+// Upload uploads a file that expires after the TTL. If a tracked file has the
+// same content, it is returned instead and its TTL restarts.
+func (m *FileManager) Upload(ctx context.Context, name string, r io.Reader, mimeType MimeType) (ProviderFile, error) {
+	var data []byte
+	if r != nil {
+		var err error
+		if data, err = io.ReadAll(r); err != nil {
+			return ProviderFile{}, err
+		}
+	}
+	hash := ContentHash(data)
+
+	m.mu.Lock()
+	if f, ok := m.files[m.byHash[hash]]; ok && !f.deleting {
+		f.expires = time.Now().Add(m.ttl)
+		m.mu.Unlock()
+		return f.file, nil
+	}
+	m.mu.Unlock()
+
+	file, err := m.store.Upload(ctx, m.prefix+name, bytes.NewReader(data), mimeType)
+	if err != nil {
+		return ProviderFile{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[file.ID] = &managedFile{file: file, hash: hash, expires: time.Now().Add(m.ttl)}
+	m.byHash[hash] = file.ID
+	return file, nil
+}
+
+// Retain keeps the file while it is referenced, e.g. by a cached content
+// handle that outlives the TTL. Every Retain needs a Release. Files that are
+// being deleted by Cleanup are not retained.
+func (m *FileManager) Retain(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[id]; ok && !f.deleting {
+		f.refs++
+	}
+}
+
+// Release drops a reference taken by Retain
+func (m *FileManager) Release(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.files[id]; ok && f.refs > 0 {
+		f.refs--
+	}
+}
+
+// Tracked returns the number of files the manager keeps track of
+func (m *FileManager) Tracked() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.files)
+}
+
+// Cleanup deletes the expired files without references and returns how many
+// were deleted. Files that fail to delete stay tracked for the next cleanup.
+func (m *FileManager) Cleanup(ctx context.Context) (int, error) {
+	now := time.Now()
+	var expired []string
+	m.mu.Lock()
+	for id, f := range m.files {
+		if f.refs == 0 && now.After(f.expires) && !f.deleting {
+			f.deleting = true
+			expired = append(expired, id)
+		}
+	}
+	m.mu.Unlock()
+
+	var deleted int
+	var errs []error
+	for _, id := range expired {
+		if err := m.store.Delete(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete file %s: %w", id, err))
+			m.mu.Lock()
+			m.files[id].deleting = false
+			m.mu.Unlock()
+			continue
+		}
+		deleted++
+		m.mu.Lock()
+		if f, ok := m.files[id]; ok && m.byHash[f.hash] == id {
+			delete(m.byHash, f.hash)
+		}
+		delete(m.files, id)
+		m.mu.Unlock()
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// SweepOrphans deletes files with the manager's prefix that it does not track
+// and that are older than minAge, e.g. uploads of a process that crashed.
+// Other managers sharing the account should use a different prefix. It fails
+// without a prefix, which would match the files of other applications.
+func (m *FileManager) SweepOrphans(ctx context.Context, minAge time.Duration) (int, error) {
+	if m.prefix == "" {
+		return 0, errors.New("sweeping orphans requires a file name prefix")
+	}
+	files, err := m.store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	var errs []error
+	for _, file := range files {
+		m.mu.Lock()
+		_, tracked := m.files[file.ID]
+		m.mu.Unlock()
+		if tracked || !strings.HasPrefix(file.Name, m.prefix) || time.Since(file.CreatedAt) < minAge {
+			continue
+		}
+		if err := m.store.Delete(ctx, file.ID); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete file %s: %w", file.ID, err))
+			continue
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// Run cleans up expired files every interval and sweeps orphans older than
+// orphanAge (0 to disable) until ctx is done. Failed deletions are retried on
+// the next run.
+func (m *FileManager) Run(ctx context.Context, interval, orphanAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Cleanup(ctx)
+		if orphanAge > 0 {
+			m.SweepOrphans(ctx, orphanAge)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
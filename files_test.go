@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memFileStore keeps files in memory
+type memFileStore struct {
+	mu    sync.Mutex
+	files map[string]ProviderFile
+	next  int
+	// onDelete is called before a file is deleted (optional)
+	onDelete func(id string)
+}
+
+func (s *memFileStore) Upload(ctx context.Context, name string, r io.Reader, mimeType MimeType) (ProviderFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	file := ProviderFile{ID: fmt.Sprintf("file-%d", s.next), Name: name, CreatedAt: time.Now()}
+	s.files[file.ID] = file
+	return file, nil
+}
+
+func (s *memFileStore) Get(ctx context.Context, id string) (ProviderFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[id]
+	if !ok {
+		return ProviderFile{}, fmt.Errorf("file %s not found", id)
+	}
+	return file, nil
+}
+
+func (s *memFileStore) Delete(ctx context.Context, id string) error {
+	if s.onDelete != nil {
+		s.onDelete(id)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, id)
+	return nil
+}
+
+func (s *memFileStore) List(ctx context.Context) ([]ProviderFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var files []ProviderFile
+	for _, f := range s.files {
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+func TestFileManager(t *testing.T) {
+	ctx := context.Background()
+	store := &memFileStore{files: map[string]ProviderFile{
+		"old":   {ID: "old", Name: "app-old.pdf", CreatedAt: time.Now().Add(-time.Hour)},
+		"other": {ID: "other", Name: "other.pdf", CreatedAt: time.Now().Add(-time.Hour)},
+	}}
+	m := NewFileManager(store, "app-", 10*time.Millisecond)
+
+	a, _ := m.Upload(ctx, "a.pdf", strings.NewReader("a"), MimeType("application/pdf"))
+	b, _ := m.Upload(ctx, "b.pdf", strings.NewReader("b"), MimeType("application/pdf"))
+	if a.Name != "app-a.pdf" {
+		t.Fatalf("prefix not applied: %s", a.Name)
+	}
+	if again, _ := m.Upload(ctx, "copy.pdf", strings.NewReader("a"), MimeType("application/pdf")); again.ID != a.ID || len(store.files) != 4 {
+		t.Fatalf("same content should reuse the uploaded file, got %v", again)
+	}
+	m.Retain(b.ID)
+
+	if n, err := m.Cleanup(ctx); n != 0 || err != nil {
+		t.Fatalf("files deleted before TTL: %d, %v", n, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n, err := m.Cleanup(ctx); n != 1 || err != nil {
+		t.Fatalf("expected expired file to be deleted: %d, %v", n, err)
+	}
+	if _, ok := store.files[b.ID]; !ok {
+		t.Fatalf("retained file was deleted")
+	}
+	m.Release(b.ID)
+	if n, _ := m.Cleanup(ctx); n != 1 || m.Tracked() != 0 {
+		t.Fatalf("released file should be deleted")
+	}
+
+	if n, err := m.SweepOrphans(ctx, time.Minute); n != 1 || err != nil {
+		t.Fatalf("expected one orphan, got %d, %v", n, err)
+	}
+	if _, ok := store.files["other"]; !ok || len(store.files) != 1 {
+		t.Fatalf("unexpected files left %v", store.files)
+	}
+
+	unprefixed := NewFileManager(store, "", time.Minute)
+	if n, err := unprefixed.SweepOrphans(ctx, 0); n != 0 || err == nil || len(store.files) != 1 {
+		t.Fatalf("sweeping without a prefix should fail and keep other files: %d, %v", n, err)
+	}
+}
+
+func TestFileManagerCleanupRace(t *testing.T) {
+	ctx := context.Background()
+	store := &memFileStore{files: map[string]ProviderFile{}}
+	m := NewFileManager(store, "app-", time.Millisecond)
+	a, _ := m.Upload(ctx, "a.pdf", strings.NewReader("a"), MimeType("application/pdf"))
+	time.Sleep(5 * time.Millisecond)
+
+	// Uploads and retains while the file is deleted must not return it
+	var again ProviderFile
+	store.onDelete = func(id string) {
+		m.Retain(id)
+		again, _ = m.Upload(ctx, "copy.pdf", strings.NewReader("a"), MimeType("application/pdf"))
+	}
+	if n, err := m.Cleanup(ctx); n != 1 || err != nil {
+		t.Fatalf("expected the expired file to be deleted: %d, %v", n, err)
+	}
+	if again.ID == "" || again.ID == a.ID {
+		t.Fatalf("expected a new upload instead of the deleted file, got %v", again)
+	}
+	if _, ok := store.files[again.ID]; !ok || m.Tracked() != 1 {
+		t.Fatalf("expected the new upload to be tracked, got %d files", m.Tracked())
+	}
+	store.onDelete = nil
+	time.Sleep(5 * time.Millisecond)
+	if reused, _ := m.Upload(ctx, "copy.pdf", strings.NewReader("a"), MimeType("application/pdf")); reused.ID != again.ID {
+		t.Fatalf("expected the new upload to be reused, got %v", reused)
+	}
+}
+
+func TestFileAttachments(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/files", "/files/file-1":
+			io.WriteString(w, `{"id":"file-1","object":"file","bytes":3,"created_at":1700000000,"filename":"invoice.pdf","purpose":"user_data","status":"processed"}`)
+		case "/chat/completions":
+			body = nil
+			json.NewDecoder(r.Body).Decode(&body)
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		default:
+			body = nil
+			json.NewDecoder(r.Body).Decode(&body)
+			io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o", 100, 0, false)
+	file, err := openAI.Files().Upload(context.Background(), "invoice.pdf", strings.NewReader("pdf"), MimeTypePDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := openAI.Files().Get(context.Background(), file.ID); err != nil || got.Name != "invoice.pdf" {
+		t.Fatalf("unexpected file %v, %v", got, err)
+	}
+	messages := []Message{{Role: RoleUser, Content: "Total?", File: &file}}
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	parts := body["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	if len(parts) != 2 || parts[0].(map[string]interface{})["file"].(map[string]interface{})["file_id"] != "file-1" {
+		t.Errorf("unexpected OpenAI file message %v", parts)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	uploaded := ProviderFile{ID: "files/abc", MimeType: MimeTypePDF, URI: "https://generativelanguage.googleapis.com/v1beta/files/abc"}
+	if _, err := gemini.GenerateResponse(context.Background(), []Message{{Role: RoleUser, Content: "Total?", File: &uploaded}}); err != nil {
+		t.Fatal(err)
+	}
+	part := body["contents"].([]interface{})[0].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+	if data := part["fileData"].(map[string]interface{}); data["fileUri"] != uploaded.URI || data["mimeType"] != "application/pdf" {
+		t.Errorf("unexpected Gemini file part %v", part)
+	}
+}
@@ -0,0 +1,233 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// FineTuneStatus is the status of a fine-tuning job
+type FineTuneStatus string
+
+const (
+	FineTuneValidatingFiles FineTuneStatus = "validating_files"
+	FineTuneQueued          FineTuneStatus = "queued"
+	FineTuneRunning         FineTuneStatus = "running"
+	FineTuneSucceeded       FineTuneStatus = "succeeded"
+	FineTuneFailed          FineTuneStatus = "failed"
+	FineTuneCancelled       FineTuneStatus = "cancelled"
+)
+
+// Done reports whether the job finished, successfully or not
+func (s FineTuneStatus) Done() bool {
+	return s == FineTuneSucceeded || s == FineTuneFailed || s == FineTuneCancelled
+}
+
+// FineTuneJob is a fine-tuning job
+type FineTuneJob struct {
+	ID string
+	// BaseModel is the model being fine-tuned
+	BaseModel string
+	// Model is the name of the fine-tuned model once the job succeeded
+	Model        string
+	Status       FineTuneStatus
+	TrainingFile string
+	// Error describes why the job failed
+	Error         string
+	TrainedTokens int64
+	CreatedAt     time.Time
+	FinishedAt    time.Time // zero until the job is done
+}
+
+// FineTuneCheckpoint is a model saved at the end of a training epoch, which
+// can be used like the final model
+type FineTuneCheckpoint struct {
+	ID string
+	// Model is the name of the checkpoint model
+	Model     string
+	Step      int64
+	TrainLoss float64
+	ValidLoss float64
+	CreatedAt time.Time
+}
+
+// FineTuneParams configures a fine-tuning job, zero values use the provider
+// defaults
+type FineTuneParams struct {
+	BaseModel string
+	// TrainingFile and ValidationFile are the IDs of uploaded files, see
+	// UploadTrainingFile
+	TrainingFile   string
+	ValidationFile string
+	// Suffix is added to the fine-tuned model name
+	Suffix string
+	Epochs int
+	Seed   int64
+}
+
+// FineTuner manages the fine-tuning jobs of a provider, see OpenAI.FineTuning
+// and Google.Tuning. The tuned model of a succeeded job is used with the
+// WithModel method of the client.
+type FineTuner interface {
+	Create(ctx context.Context, params FineTuneParams) (FineTuneJob, error)
+	Get(ctx context.Context, id string) (FineTuneJob, error)
+	List(ctx context.Context) ([]FineTuneJob, error)
+	// Cancel cancels a job that is not done yet, returning its state
+	Cancel(ctx context.Context, id string) (FineTuneJob, error)
+	// Wait polls the job every interval until it is done
+	Wait(ctx context.Context, id string, interval time.Duration) (FineTuneJob, error)
+}
+
+// OpenAIFineTuning manages OpenAI fine-tuning jobs
+//
+//	ft := client.FineTuning()
+//	file, _ := ft.UploadTrainingFile(ctx, "train.jsonl", f)
+//	job, _ := ft.Create(ctx, ai.FineTuneParams{BaseModel: "gpt-4o-mini-2024-07-18", TrainingFile: file.ID})
+//	job, _ = ft.Wait(ctx, job.ID, time.Minute)
+//	tuned := client.WithModel(job.Model)
+type OpenAIFineTuning struct {
+	client *openai.Client
+}
+
+// FineTuning returns the fine-tuning API of the client
+func (o *OpenAI) FineTuning() *OpenAIFineTuning {
+	return &OpenAIFineTuning{client: o.client}
+}
+
+// WithModel returns a copy of the client using model, e.g. a fine-tuned model
+func (o *OpenAI) WithModel(model string) *OpenAI {
+	c := *o
+	c.model = model
+	return &c
+}
+
+// UploadTrainingFile uploads a JSONL training or validation file, such as the
+// output of Anonymizer.Export
+func (f *OpenAIFineTuning) UploadTrainingFile(ctx context.Context, name string, r io.Reader) (ProviderFile, error) {
+	files := &openAIFiles{client: f.client, purpose: openai.FilePurposeFineTune}
+	return files.Upload(ctx, name, r, "application/jsonl")
+}
+
+// Create starts a fine-tuning job
+func (f *OpenAIFineTuning) Create(ctx context.Context, params FineTuneParams) (FineTuneJob, error) {
+	req := openai.FineTuningJobNewParams{
+		Model:        openai.F(openai.FineTuningJobNewParamsModel(params.BaseModel)),
+		TrainingFile: openai.F(params.TrainingFile),
+	}
+	if params.ValidationFile != "" {
+		req.ValidationFile = openai.F(params.ValidationFile)
+	}
+	if params.Suffix != "" {
+		req.Suffix = openai.F(params.Suffix)
+	}
+	if params.Epochs > 0 {
+		req.Hyperparameters = openai.F(openai.FineTuningJobNewParamsHyperparameters{
+			NEpochs: openai.F[openai.FineTuningJobNewParamsHyperparametersNEpochsUnion](shared.UnionInt(params.Epochs)),
+		})
+	}
+	if params.Seed != 0 {
+		req.Seed = openai.F(params.Seed)
+	}
+	job, err := f.client.FineTuning.Jobs.New(ctx, req)
+	if err != nil {
+		return FineTuneJob{}, err
+	}
+	return openAIFineTuneJob(job), nil
+}
+
+// Get returns the current state of a job
+func (f *OpenAIFineTuning) Get(ctx context.Context, id string) (FineTuneJob, error) {
+	job, err := f.client.FineTuning.Jobs.Get(ctx, id)
+	if err != nil {
+		return FineTuneJob{}, err
+	}
+	return openAIFineTuneJob(job), nil
+}
+
+// List returns the jobs of the organization, most recent first
+func (f *OpenAIFineTuning) List(ctx context.Context) ([]FineTuneJob, error) {
+	var jobs []FineTuneJob
+	iter := f.client.FineTuning.Jobs.ListAutoPaging(ctx, openai.FineTuningJobListParams{})
+	for iter.Next() {
+		job := iter.Current()
+		jobs = append(jobs, openAIFineTuneJob(&job))
+	}
+	return jobs, iter.Err()
+}
+
+// Cancel cancels a job that is not done yet
+func (f *OpenAIFineTuning) Cancel(ctx context.Context, id string) (FineTuneJob, error) {
+	job, err := f.client.FineTuning.Jobs.Cancel(ctx, id)
+	if err != nil {
+		return FineTuneJob{}, err
+	}
+	return openAIFineTuneJob(job), nil
+}
+
+// Checkpoints returns the checkpoints of a job, most recent first
+func (f *OpenAIFineTuning) Checkpoints(ctx context.Context, id string) ([]FineTuneCheckpoint, error) {
+	var checkpoints []FineTuneCheckpoint
+	iter := f.client.FineTuning.Jobs.Checkpoints.ListAutoPaging(ctx, id, openai.FineTuningJobCheckpointListParams{})
+	for iter.Next() {
+		c := iter.Current()
+		checkpoints = append(checkpoints, FineTuneCheckpoint{
+			ID:        c.ID,
+			Model:     c.FineTunedModelCheckpoint,
+			Step:      c.StepNumber,
+			TrainLoss: c.Metrics.TrainLoss,
+			ValidLoss: c.Metrics.ValidLoss,
+			CreatedAt: time.Unix(c.CreatedAt, 0),
+		})
+	}
+	return checkpoints, iter.Err()
+}
+
+// Wait polls the job every interval until it is done. It returns the job with
+// an error if it failed or was cancelled.
+func (f *OpenAIFineTuning) Wait(ctx context.Context, id string, interval time.Duration) (FineTuneJob, error) {
+	return waitFineTune(ctx, id, interval, f.Get)
+}
+
+// waitFineTune polls a job with get until it is done
+func waitFineTune(ctx context.Context, id string, interval time.Duration, get func(ctx context.Context, id string) (FineTuneJob, error)) (FineTuneJob, error) {
+	for {
+		job, err := get(ctx, id)
+		if err != nil {
+			return job, err
+		}
+		switch job.Status {
+		case FineTuneSucceeded:
+			return job, nil
+		case FineTuneFailed:
+			return job, fmt.Errorf("fine-tuning job %s failed: %s", id, job.Error)
+		case FineTuneCancelled:
+			return job, fmt.Errorf("fine-tuning job %s was cancelled", id)
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func openAIFineTuneJob(job *openai.FineTuningJob) FineTuneJob {
+	res := FineTuneJob{
+		ID:            job.ID,
+		BaseModel:     job.Model,
+		Model:         job.FineTunedModel,
+		Status:        FineTuneStatus(job.Status),
+		TrainingFile:  job.TrainingFile,
+		Error:         job.Error.Message,
+		TrainedTokens: job.TrainedTokens,
+		CreatedAt:     time.Unix(job.CreatedAt, 0),
+	}
+	if job.FinishedAt != 0 {
+		res.FinishedAt = time.Unix(job.FinishedAt, 0)
+	}
+	return res
+}
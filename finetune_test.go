@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAIFineTuning(t *testing.T) {
+	var created map[string]interface{}
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		job := `{"id":"ftjob-1","object":"fine_tuning.job","model":"gpt-4o-mini","created_at":1,"status":"%s","fine_tuned_model":%s,"training_file":"file-1","error":null,"finished_at":%s}`
+		switch {
+		case r.URL.Path == "/files":
+			r.ParseMultipartForm(1 << 20)
+			if r.FormValue("purpose") != "fine-tune" {
+				t.Errorf("unexpected purpose %q", r.FormValue("purpose"))
+			}
+			fmt.Fprint(w, `{"id":"file-1","object":"file","filename":"train.jsonl","created_at":1,"bytes":2,"purpose":"fine-tune"}`)
+		case r.URL.Path == "/fine_tuning/jobs" && r.Method == http.MethodPost:
+			json.NewDecoder(r.Body).Decode(&created)
+			fmt.Fprintf(w, job, "queued", "null", "null")
+		case r.URL.Path == "/fine_tuning/jobs/ftjob-1":
+			polls++
+			if polls < 2 {
+				fmt.Fprintf(w, job, "running", "null", "null")
+				return
+			}
+			fmt.Fprintf(w, job, "succeeded", `"ft:gpt-4o-mini:org::abc"`, "2")
+		case r.URL.Path == "/fine_tuning/jobs/ftjob-1/checkpoints" && r.URL.Query().Get("after") != "":
+			fmt.Fprint(w, `{"object":"list","has_more":false,"data":[]}`)
+		case r.URL.Path == "/fine_tuning/jobs/ftjob-1/checkpoints":
+			fmt.Fprint(w, `{"object":"list","has_more":false,"data":[{"id":"ftckpt-1","object":"fine_tuning.job.checkpoint","created_at":1,
+				"fine_tuned_model_checkpoint":"ft:gpt-4o-mini:org::abc:ckpt-step-10","fine_tuning_job_id":"ftjob-1","step_number":10,"metrics":{"train_loss":0.5}}]}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatible(server.URL, "key", "gpt-4o-mini", 100, 0, false)
+	ft := client.FineTuning()
+	ctx := context.Background()
+	file, err := ft.UploadTrainingFile(ctx, "train.jsonl", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := ft.Create(ctx, FineTuneParams{BaseModel: "gpt-4o-mini", TrainingFile: file.ID, Epochs: 3, Suffix: "support"})
+	if err != nil || job.Status != FineTuneQueued {
+		t.Fatalf("unexpected job %+v, %v", job, err)
+	}
+	if created["training_file"] != "file-1" || created["suffix"] != "support" ||
+		created["hyperparameters"].(map[string]interface{})["n_epochs"] != 3.0 {
+		t.Errorf("unexpected create request %v", created)
+	}
+
+	job, err = ft.Wait(ctx, job.ID, time.Millisecond)
+	if err != nil || job.Model != "ft:gpt-4o-mini:org::abc" || job.FinishedAt.IsZero() {
+		t.Fatalf("unexpected job %+v, %v", job, err)
+	}
+	if tuned := client.WithModel(job.Model); tuned.GetModel() != job.Model || client.GetModel() != "gpt-4o-mini" {
+		t.Errorf("unexpected models %s, %s", tuned.GetModel(), client.GetModel())
+	}
+
+	checkpoints, err := ft.Checkpoints(ctx, job.ID)
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].Step != 10 || checkpoints[0].TrainLoss != 0.5 {
+		t.Fatalf("unexpected checkpoints %+v, %v", checkpoints, err)
+	}
+}
@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"github.com/openai/openai-go/option"
+)
+
+// FireworksParams are Fireworks AI specific output constraints
+type FireworksParams struct {
+	// JSONSchema constrains JSON mode output to the schema (optional)
+	JSONSchema *Schema
+	// Grammar constrains the output with a GBNF grammar (optional),
+	// see https://docs.fireworks.ai/structured-responses/structured-output-grammar-based
+	Grammar string
+}
+
+// https://docs.fireworks.ai/api-reference/post-chatcompletions
+// Function calling is available through GenerateWithTools.
+func NewFireworks(apiKey string, model string, maxTokens int64, temperature float64, isJson bool, params *FireworksParams) *OpenAI {
+	return NewOpenAICompatible("https://api.fireworks.ai/inference/v1/", apiKey, model, maxTokens, temperature, isJson, fireworksOptions(params)...)
+}
+
+// fireworksOptions sets the response format of params
+func fireworksOptions(params *FireworksParams) []option.RequestOption {
+	var opts []option.RequestOption
+	if params != nil {
+		switch {
+		case params.Grammar != "":
+			opts = append(opts, option.WithJSONSet("response_format", map[string]interface{}{
+				"type":    "grammar",
+				"grammar": params.Grammar,
+			}))
+		case params.JSONSchema != nil:
+			opts = append(opts, option.WithJSONSet("response_format", map[string]interface{}{
+				"type":   "json_object",
+				"schema": params.JSONSchema.Map(),
+			}))
+		}
+	}
+	return opts
+}
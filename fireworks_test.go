@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFireworksResponseFormat(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "accounts/fireworks/models/llama-v3p1-8b-instruct", 100, 0.5, false,
+		fireworksOptions(&FireworksParams{Grammar: `root ::= "yes" | "no"`})...)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	format, _ := body["response_format"].(map[string]interface{})
+	if format["type"] != "grammar" || format["grammar"] != `root ::= "yes" | "no"` {
+		t.Fatalf("grammar not set: %v", body)
+	}
+
+	// The schema replaces the plain JSON mode format
+	llm = NewOpenAICompatible(srv.URL+"/", "key", "accounts/fireworks/models/llama-v3p1-8b-instruct", 100, 0.5, true,
+		fireworksOptions(&FireworksParams{JSONSchema: Object().Prop("name", String()).Required("name")})...)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	format, _ = body["response_format"].(map[string]interface{})
+	if schema, _ := format["schema"].(map[string]interface{}); format["type"] != "json_object" || schema["type"] != "object" {
+		t.Fatalf("schema not set: %v", body)
+	}
+
+	if opts := fireworksOptions(nil); len(opts) != 0 {
+		t.Fatalf("unexpected options without params: %d", len(opts))
+	}
+}
+
+func TestFireworksGenerateWithTools(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`)
+	}))
+	defer srv.Close()
+
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "accounts/fireworks/models/firefunction-v2", 100, 0, false)
+	res, err := llm.GenerateWithTools(context.Background(), []Message{{Role: RoleUser, Content: "Weather in Paris?"}}, []Tool{weatherTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools, _ := body["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("tools not sent: %v", body)
+	}
+	tool, _ := tools[0].(map[string]interface{})
+	function, _ := tool["function"].(map[string]interface{})
+	if tool["type"] != "function" || function["name"] != "get_weather" || function["parameters"] == nil {
+		t.Fatalf("unexpected tool %v", tool)
+	}
+	if len(res.ToolCalls) != 1 || res.ToolCalls[0].ID != "call_1" || res.ToolCalls[0].Name != "get_weather" || string(res.ToolCalls[0].Arguments) != `{"city":"Paris"}` {
+		t.Fatalf("unexpected tool calls %+v", res.ToolCalls)
+	}
+}
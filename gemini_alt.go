@@ -2,9 +2,12 @@ package ai
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/google/generative-ai-go/genai"
@@ -20,6 +23,11 @@ type GoogleSimpleLLM struct {
 	maxTokens   int
 	isJSON      bool
 	temperature *float32
+	voice       string
+	baseURL     string
+	stop        []string
+	sampling    Sampling
+	seed        *int64
 }
 
 // Deprecated: use Open AI compatible client instead
@@ -40,10 +48,14 @@ func (g *GoogleSimpleLLM) Generate(ctx context.Context, systemPrompt, prompt str
 	}
 	defer client.Close()
 
-	model := client.GenerativeModel(g.model)
+	model := client.GenerativeModel(requestModel(ctx, g.model))
 	if g.temperature != nil {
 		model.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		model.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &model.GenerationConfig)
 	if g.isJSON {
 		model.ResponseMIMEType = "application/json"
 	}
@@ -82,10 +94,14 @@ func (g *GoogleSimpleLLM) GenerateStream(ctx context.Context, systemPrompt, prom
 	}
 	defer client.Close()
 
-	model := client.GenerativeModel(g.model)
+	model := client.GenerativeModel(requestModel(ctx, g.model))
 	if g.temperature != nil {
 		model.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		model.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &model.GenerationConfig)
 	model.GenerationConfig.SetMaxOutputTokens(int32(g.maxTokens))
 	model.SystemInstruction = &genai.Content{
 		Parts: []genai.Part{genai.Text(systemPrompt)},
@@ -132,10 +148,78 @@ func (g *GoogleSimpleLLM) GenerateStream(ctx context.Context, systemPrompt, prom
 	}()
 }
 
+// GenerateWithMessagesStream streams the reply to messages with the REST
+// API, keeping the roles of the conversation, see MessagesStreamer
+func (g *GoogleSimpleLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	messages, p := emulatePrefill(messages)
+	req, err := g.restRequest(ctx, messages)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	if !sendPrefill(ctx, p, resultCh) {
+		return
+	}
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = geminiAPIBaseURL
+	}
+	headers := map[string]string{"x-goog-api-key": g.apiKey}
+	resp, err := sendJSON(ctx, http.DefaultClient, http.MethodPost, baseURL+"models/"+requestModel(ctx, g.model)+":streamGenerateContent?alt=sse", headers, req)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		var chunk geminiGenerateResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("error in stream: %s", chunk.Error.Message)
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			select {
+			case resultCh <- part.Text:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
 func (g *GoogleSimpleLLM) GetModel() string {
 	return g.model
 }
 
+func (g *GoogleSimpleLLM) Close() error {
+	return nil
+}
+
 func (g *GoogleSimpleLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
 	return g.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
 }
@@ -170,16 +254,21 @@ func (g *GoogleSimpleLLM) GenerateWithImages(ctx context.Context, prompt string,
 }
 
 func (g *GoogleSimpleLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	messages, p := emulatePrefill(messages)
 	client, err := genai.NewClient(ctx, option.WithAPIKey(g.apiKey))
 	if err != nil {
 		return "", fmt.Errorf("failed to create Google client: %v", err)
 	}
 	defer client.Close()
 
-	model := client.GenerativeModel(g.model)
+	model := client.GenerativeModel(requestModel(ctx, g.model))
 	if g.temperature != nil {
 		model.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		model.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &model.GenerationConfig)
 	if g.isJSON {
 		model.ResponseMIMEType = "application/json"
 	}
@@ -189,17 +278,21 @@ func (g *GoogleSimpleLLM) GenerateWithMessages(ctx context.Context, messages []M
 	var parts []genai.Part
 
 	for _, msg := range messages {
-		if msg.Image != nil {
-			imageData, err := io.ReadAll(msg.Image)
-			if err != nil {
-				return "", fmt.Errorf("failed to read image: %v", err)
+		for _, part := range msg.ContentParts() {
+			switch part.Type {
+			case PartText:
+				if part.Text != "" {
+					parts = append(parts, genai.Text(part.Text))
+				}
+			case PartFile:
+				parts = append(parts, genai.FileData{MIMEType: string(part.File.MimeType), URI: part.File.URI})
+			default:
+				data, err := io.ReadAll(part.Data)
+				if err != nil {
+					return "", fmt.Errorf("failed to read %s: %v", part.Type, err)
+				}
+				parts = append(parts, genai.Blob{MIMEType: string(part.MimeType), Data: data})
 			}
-			parts = append(parts, genai.ImageData(string(msg.MimeType), imageData))
-		}
-
-		// Add text content
-		if msg.Content != "" {
-			parts = append(parts, genai.Text(msg.Content))
 		}
 	}
 
@@ -217,5 +310,347 @@ func (g *GoogleSimpleLLM) GenerateWithMessages(ctx context.Context, messages []M
 	for _, part := range resp.Candidates[0].Content.Parts {
 		res.WriteString(fmt.Sprintf("%v", part))
 	}
-	return res.String(), nil
+	return withPrefill(p, res.String()), nil
+}
+
+// SetAudioOutput makes GenerateResponse request spoken audio from Gemini native
+// audio (TTS) models, e.g. gemini-2.5-flash-preview-tts, using a prebuilt voice such as "Kore"
+func (g *GoogleSimpleLLM) SetAudioOutput(voice string) {
+	g.voice = voice
+}
+
+const geminiAPIBaseURL = "https://generativelanguage.googleapis.com/v1beta/"
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiInlineData       `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Parameters  *Schema `json:"parameters,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerateRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+}
+
+type geminiGenerateResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	ModelVersion string `json:"modelVersion"`
+	Error        *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+
+	// raw is the response body
+	raw []byte
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (g *GoogleSimpleLLM) SetStopSequences(stop ...string) {
+	g.stop = stop
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (g *GoogleSimpleLLM) SetSampling(s Sampling) {
+	g.sampling = s
+}
+
+// SetSeed sets the seed of every request made with the REST API
+// (GenerateResponse, GenerateWithTools and GenerateStructured), see WithSeed
+func (g *GoogleSimpleLLM) SetSeed(seed int64) {
+	g.seed = &seed
+}
+
+// generationConfig applies the stop sequences and sampling parameters to
+// SDK requests. The SDK has no penalties, unlike the REST API.
+func (g *GoogleSimpleLLM) generationConfig(ctx context.Context, config *genai.GenerationConfig) {
+	config.StopSequences = stopSequences(ctx, g.stop)
+	s := sampling(ctx, g.sampling)
+	if s.TopP != 0 {
+		config.SetTopP(float32(s.TopP))
+	}
+	if s.TopK != 0 {
+		config.SetTopK(int32(s.TopK))
+	}
+}
+
+// SetBaseURL overrides the Gemini REST API URL used by GenerateResponse,
+// GenerateWithTools and ListModels
+func (g *GoogleSimpleLLM) SetBaseURL(baseURL string) {
+	g.baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+}
+
+// GenerateResponse uses the Gemini REST API directly, since the Go SDK
+// does not support response modalities and speech config yet
+func (g *GoogleSimpleLLM) GenerateResponse(ctx context.Context, messages []Message) (*Response, error) {
+	req, err := g.restRequest(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if g.voice != "" {
+		req.GenerationConfig["responseModalities"] = []string{"AUDIO"}
+		req.GenerationConfig["speechConfig"] = map[string]interface{}{
+			"voiceConfig": map[string]interface{}{
+				"prebuiltVoiceConfig": map[string]string{"voiceName": g.voice},
+			},
+		}
+		delete(req.GenerationConfig, "responseMimeType")
+	}
+
+	provenance := newProvenance(ctx, requestModel(ctx, g.model), req, "contents", "systemInstruction")
+	resp, err := g.generateContent(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Response{Model: resp.ModelVersion}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.InlineData != nil {
+			audio, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode audio: %v", err)
+			}
+			res.Audio = append(res.Audio, audio...)
+			res.AudioMimeType = MimeType(part.InlineData.MimeType)
+		}
+		text.WriteString(part.Text)
+	}
+	res.Text = text.String()
+	attachProvenance(res, provenance)
+	attachRaw(ctx, res, resp.raw)
+	return res, nil
+}
+
+// GenerateWithTools lets the model call the given tools. Requested calls are
+// returned in Response.ToolCalls, to be executed by the caller. Gemini does
+// not always assign call IDs, in which case the tool name is used.
+func (g *GoogleSimpleLLM) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	req, err := g.restRequest(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	if len(tools) > 0 {
+		declarations := make([]geminiFunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			declarations[i] = geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			}
+		}
+		req.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+		// JSON mode cannot be combined with function calling
+		delete(req.GenerationConfig, "responseMimeType")
+	}
+
+	provenance := newProvenance(ctx, requestModel(ctx, g.model), req, "contents", "systemInstruction")
+	resp, err := g.generateContent(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Response{Model: resp.ModelVersion}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if call := part.FunctionCall; call != nil {
+			id := call.ID
+			if id == "" {
+				id = call.Name
+			}
+			args := call.Args
+			if len(args) == 0 {
+				args = json.RawMessage("{}")
+			}
+			res.ToolCalls = append(res.ToolCalls, ToolCall{ID: id, Name: call.Name, Arguments: args})
+			continue
+		}
+		text.WriteString(part.Text)
+	}
+	res.Text = text.String()
+	attachProvenance(res, provenance)
+	attachRaw(ctx, res, resp.raw)
+	return res, nil
+}
+
+// restRequest converts messages to a Gemini REST API request
+func (g *GoogleSimpleLLM) restRequest(ctx context.Context, messages []Message) (geminiGenerateRequest, error) {
+	req := geminiGenerateRequest{
+		GenerationConfig: map[string]interface{}{
+			"maxOutputTokens": g.maxTokens,
+		},
+	}
+	if g.temperature != nil {
+		req.GenerationConfig["temperature"] = *g.temperature
+	}
+	seedBody(ctx, req.GenerationConfig, "seed", g.seed)
+	if IsDeterministic(ctx) {
+		req.GenerationConfig["temperature"] = 0
+		if _, ok := req.GenerationConfig["seed"]; !ok {
+			req.GenerationConfig["seed"] = DeterministicSeed
+		}
+	}
+	if g.isJSON {
+		req.GenerationConfig["responseMimeType"] = "application/json"
+	}
+	stopBody(ctx, req.GenerationConfig, "stopSequences", g.stop)
+	s := sampling(ctx, g.sampling)
+	if s.TopP != 0 {
+		req.GenerationConfig["topP"] = s.TopP
+	}
+	if s.TopK != 0 {
+		req.GenerationConfig["topK"] = s.TopK
+	}
+	if s.FrequencyPenalty != 0 {
+		req.GenerationConfig["frequencyPenalty"] = s.FrequencyPenalty
+	}
+	if s.PresencePenalty != 0 {
+		req.GenerationConfig["presencePenalty"] = s.PresencePenalty
+	}
+
+	// callNames are the tool names by call ID, for results without a name
+	callNames := map[string]string{}
+	for _, msg := range messages {
+		parts, err := geminiParts(ctx, msg)
+		if err != nil {
+			return req, err
+		}
+		for _, call := range msg.ToolCalls {
+			callNames[call.ID] = call.Name
+			parts = append(parts, geminiPart{FunctionCall: &geminiFunctionCall{ID: geminiCallID(call.ID, call.Name), Name: call.Name, Args: call.Arguments}})
+		}
+		if msg.Role == RoleTool {
+			name := msg.ToolName
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+			// the response must be an object
+			response := map[string]interface{}{"content": msg.text()}
+			if msg.IsError {
+				response = map[string]interface{}{"error": msg.text()}
+			}
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{ID: geminiCallID(msg.ToolCallID, name), Name: name, Response: response}}
+			// results of parallel tool calls go in one content
+			if n := len(req.Contents); n > 0 && len(req.Contents[n-1].Parts) > 0 && req.Contents[n-1].Parts[0].FunctionResponse != nil {
+				req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, part)
+			} else {
+				req.Contents = append(req.Contents, geminiContent{Role: "user", Parts: []geminiPart{part}})
+			}
+			continue
+		}
+		if msg.Role == RoleSystem && !msg.hasMedia() {
+			req.SystemInstruction = &geminiContent{Parts: parts}
+			continue
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: convertRole(msg.Role), Parts: parts})
+	}
+	return req, nil
+}
+
+// geminiParts converts the content parts of msg, the text of tool results
+// goes in their function response
+func geminiParts(ctx context.Context, msg Message) ([]geminiPart, error) {
+	var parts []geminiPart
+	for _, part := range msg.ContentParts() {
+		switch part.Type {
+		case PartText:
+			if part.Text != "" && msg.Role != RoleTool {
+				parts = append(parts, geminiPart{Text: part.Text})
+			}
+		case PartFile:
+			parts = append(parts, geminiPart{FileData: &geminiFileData{MimeType: string(part.File.MimeType), FileURI: part.File.URI}})
+		default:
+			data, err := io.ReadAll(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", part.Type, err)
+			}
+			parts = append(parts, geminiPart{InlineData: &geminiInlineData{
+				MimeType: string(part.MimeType),
+				Data:     encodeBase64(ctx, data),
+			}})
+		}
+	}
+	return parts, nil
+}
+
+// geminiCallID returns the ID of a function call, empty for the names used
+// as IDs of calls without one
+func geminiCallID(id, name string) string {
+	if id == name {
+		return ""
+	}
+	return id
+}
+
+// generateContent posts req to the generateContent endpoint, the returned
+// response has at least one candidate. Non 2xx responses are an HTTPError.
+func (g *GoogleSimpleLLM) generateContent(ctx context.Context, req geminiGenerateRequest) (*geminiGenerateResponse, error) {
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = geminiAPIBaseURL
+	}
+	headers := map[string]string{"x-goog-api-key": g.apiKey}
+	httpResp, err := sendJSON(ctx, http.DefaultClient, http.MethodPost, baseURL+"models/"+requestModel(ctx, g.model)+":generateContent", headers, req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	var resp geminiGenerateResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response (status %d): %v", httpResp.StatusCode, err)
+	}
+	resp.raw = data
+	if resp.Error != nil {
+		return nil, fmt.Errorf("failed to generate content: %s", resp.Error.Message)
+	}
+	if len(resp.Candidates) == 0 {
+		return nil, fmt.Errorf("no content generated")
+	}
+	return &resp, nil
 }
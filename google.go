@@ -3,6 +3,7 @@ package ai
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -13,9 +14,12 @@ import (
 	"cloud.google.com/go/vertexai/genai"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
 )
 
 type Google struct {
+	projectID      string
+	clientOpts     []option.ClientOption
 	clients        []*genai.Client
 	locations      []string
 	clientIndex    int32
@@ -24,7 +28,13 @@ type Google struct {
 	maxTokens      int
 	temperature    *float32
 	isJson         bool
-	mu             sync.RWMutex
+	labels         map[string]string
+	stop           []string
+	sampling       Sampling
+	// shared is set on clients created by WithModel, whose connections are
+	// owned and closed by the client they were created from
+	shared bool
+	mu     sync.RWMutex
 }
 
 const maxImageSize = 4 * 1024 * 1024 // 4MB
@@ -45,6 +55,20 @@ func validateImageSize(image io.Reader) (io.Reader, error) {
 }
 
 func NewGoogle(projectID string, locations []string, model string, maxTokens int, temperature *float32, isJson bool, opts ...option.ClientOption) (*Google, error) {
+	g := &Google{
+		projectID:   projectID,
+		clientOpts:  opts,
+		locations:   locations,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		isJson:      isJson,
+	}
+	opts = append([]option.ClientOption{
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(g.labelsUnaryInterceptor)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(g.labelsStreamInterceptor)),
+	}, opts...)
+
 	var clients []*genai.Client
 	for _, location := range locations {
 		client, err := genai.NewClient(context.Background(), projectID, location, opts...)
@@ -62,14 +86,8 @@ func NewGoogle(projectID string, locations []string, model string, maxTokens int
 		return nil, fmt.Errorf("no clients created: empty locations list")
 	}
 
-	return &Google{
-		clients:     clients,
-		locations:   locations,
-		model:       model,
-		maxTokens:   maxTokens,
-		temperature: temperature,
-		isJson:      isJson,
-	}, nil
+	g.clients = clients
+	return g, nil
 }
 
 func (g *Google) SetSafetySettings(settings []*genai.SafetySetting) {
@@ -78,6 +96,42 @@ func (g *Google) SetSafetySettings(settings []*genai.SafetySetting) {
 	g.safetySettings = settings
 }
 
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (g *Google) SetStopSequences(stop ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stop = stop
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (g *Google) SetSampling(s Sampling) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sampling = s
+}
+
+// generationConfig applies the stop sequences and sampling parameters
+func (g *Google) generationConfig(ctx context.Context, config *genai.GenerationConfig) {
+	g.mu.RLock()
+	config.StopSequences = stopSequences(ctx, g.stop)
+	s := sampling(ctx, g.sampling)
+	g.mu.RUnlock()
+	if s.TopP != 0 {
+		config.SetTopP(float32(s.TopP))
+	}
+	if s.TopK != 0 {
+		config.SetTopK(int32(s.TopK))
+	}
+	if s.FrequencyPenalty != 0 {
+		v := float32(s.FrequencyPenalty)
+		config.FrequencyPenalty = &v
+	}
+	if s.PresencePenalty != 0 {
+		v := float32(s.PresencePenalty)
+		config.PresencePenalty = &v
+	}
+}
+
 func (g *Google) getNextClient() *genai.Client {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -104,7 +158,7 @@ func (g *Google) Generate(ctx context.Context, systemPrompt, prompt string) (str
 		return "", fmt.Errorf("no available client")
 	}
 
-	gModel := client.GenerativeModel(g.model)
+	gModel := client.GenerativeModel(requestModel(ctx, g.model))
 	if g.isJson {
 		gModel.ResponseMIMEType = "application/json"
 	}
@@ -112,6 +166,10 @@ func (g *Google) Generate(ctx context.Context, systemPrompt, prompt string) (str
 	if g.temperature != nil {
 		gModel.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		gModel.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &gModel.GenerationConfig)
 	gModel.GenerationConfig.SetMaxOutputTokens(int32(g.maxTokens))
 	gModel.SystemInstruction = &genai.Content{
 		Parts: []genai.Part{genai.Text(systemPrompt)},
@@ -119,7 +177,7 @@ func (g *Google) Generate(ctx context.Context, systemPrompt, prompt string) (str
 
 	resp, err := gModel.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %v", err)
+		return "", g.wrapError("failed to generate content", err)
 	}
 
 	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
@@ -137,7 +195,7 @@ func (g *Google) Generate(ctx context.Context, systemPrompt, prompt string) (str
 }
 
 func (g *Google) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
-	gModel := g.getNextClient().GenerativeModel(g.model)
+	gModel := g.getNextClient().GenerativeModel(requestModel(ctx, g.model))
 	gModel.SafetySettings = g.safetySettings
 	if g.isJson {
 		gModel.ResponseMIMEType = "application/json"
@@ -145,13 +203,37 @@ func (g *Google) GenerateStream(ctx context.Context, systemPrompt, prompt string
 	if g.temperature != nil {
 		gModel.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		gModel.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &gModel.GenerationConfig)
 	gModel.GenerationConfig.SetMaxOutputTokens(int32(g.maxTokens))
 	gModel.SystemInstruction = &genai.Content{
 		Parts: []genai.Part{genai.Text(systemPrompt)},
 	}
 
-	iter := gModel.GenerateContentStream(ctx, genai.Text(prompt))
+	g.stream(ctx, gModel.GenerateContentStream(ctx, genai.Text(prompt)), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (g *Google) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	messages, p := emulatePrefill(messages)
+	cs, last, err := g.chatSession(ctx, messages, nil, nil)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	if !sendPrefill(ctx, p, resultCh) {
+		return
+	}
+	g.stream(ctx, cs.SendMessageStream(ctx, last...), resultCh, doneCh, errCh)
+}
 
+// stream sends the text of the responses of iter in the background
+func (g *Google) stream(ctx context.Context, iter *genai.GenerateContentResponseIterator, resultCh chan string, doneCh chan bool, errCh chan error) {
 	go func() {
 		for {
 			select {
@@ -198,6 +280,24 @@ func (g *Google) GetModel() string {
 	return fmt.Sprintf("%s/%s", location, g.model)
 }
 
+// Close closes the clients of all locations, g must not be used afterwards.
+// Clients created by WithModel share the connections of their parent, closing
+// them does nothing.
+func (g *Google) Close() error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.shared {
+		return nil
+	}
+	var errs []error
+	for _, client := range g.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (g *Google) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
 	return g.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
 }
@@ -223,15 +323,105 @@ func (g *Google) GenerateWithImages(ctx context.Context, prompt string, images [
 }
 
 func (g *Google) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
-	gModel := g.getNextClient().GenerativeModel(g.model)
+	messages, p := emulatePrefill(messages)
+	res, err := g.generateMessages(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return withPrefill(p, res), nil
+}
+
+// generateMessages generates a reply to messages, constrained to
+// responseSchema if not nil
+func (g *Google) generateMessages(ctx context.Context, messages []Message, responseSchema *genai.Schema) (string, error) {
+	cs, last, err := g.chatSession(ctx, messages, responseSchema, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Generate response
+	resp, err := cs.SendMessage(ctx, last...)
+	if err != nil {
+		return "", g.wrapError("failed to generate chat content", err)
+	}
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return "", fmt.Errorf("no content generated")
+	}
+
+	var res strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		res.WriteString(fmt.Sprintf("%v", part))
+	}
+	return res.String(), nil
+}
+
+// GenerateWithTools lets the model call the given tools. Requested calls are
+// returned in Response.ToolCalls, to be executed by the caller. Vertex AI
+// does not assign call IDs, the tool name is used instead.
+func (g *Google) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	cs, last, err := g.chatSession(ctx, messages, nil, tools)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := cs.SendMessage(ctx, last...)
+	if err != nil {
+		return nil, g.wrapError("failed to generate chat content", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return nil, fmt.Errorf("no content generated")
+	}
+
+	res := &Response{}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		switch part := part.(type) {
+		case genai.FunctionCall:
+			args := json.RawMessage("{}")
+			if len(part.Args) > 0 {
+				if args, err = json.Marshal(part.Args); err != nil {
+					return nil, fmt.Errorf("invalid arguments of tool call %s: %v", part.Name, err)
+				}
+			}
+			res.ToolCalls = append(res.ToolCalls, ToolCall{ID: part.Name, Name: part.Name, Arguments: args})
+		case genai.Text:
+			text.WriteString(string(part))
+		}
+	}
+	res.Text = text.String()
+	return res, nil
+}
+
+// chatSession starts a chat with all but the last message as history and
+// returns the parts of the last message, to be sent as the prompt. The model
+// may call tools if not empty.
+func (g *Google) chatSession(ctx context.Context, messages []Message, responseSchema *genai.Schema, tools []Tool) (*genai.ChatSession, []genai.Part, error) {
+	gModel := g.getNextClient().GenerativeModel(requestModel(ctx, g.model))
 	gModel.SafetySettings = g.safetySettings
-	if g.isJson {
+	if g.isJson || responseSchema != nil {
 		gModel.ResponseMIMEType = "application/json"
 	}
+	gModel.ResponseSchema = responseSchema
 	if g.temperature != nil {
 		gModel.Temperature = g.temperature
 	}
+	if IsDeterministic(ctx) {
+		gModel.SetTemperature(0)
+	}
+	g.generationConfig(ctx, &gModel.GenerationConfig)
 	gModel.GenerationConfig.SetMaxOutputTokens(int32(g.maxTokens))
+	if len(tools) > 0 {
+		declarations := make([]*genai.FunctionDeclaration, len(tools))
+		for i, tool := range tools {
+			declarations[i] = &genai.FunctionDeclaration{Name: tool.Name, Description: tool.Description}
+			if tool.Parameters != nil {
+				declarations[i].Parameters = vertexSchema(tool.Parameters)
+			}
+		}
+		gModel.Tools = []*genai.Tool{{FunctionDeclarations: declarations}}
+		// JSON mode cannot be combined with function calling
+		gModel.ResponseMIMEType = ""
+	}
 	// Start chat and set history
 	cs := gModel.StartChat()
 
@@ -241,32 +431,40 @@ func (g *Google) GenerateWithMessages(ctx context.Context, messages []Message) (
 
 		if msg.Role == RoleSystem {
 			gModel.SystemInstruction = &genai.Content{
-				Parts: []genai.Part{genai.Text(msg.Content)},
+				Parts: []genai.Part{genai.Text(msg.text())},
 			}
 			continue
 		}
 
-		var parts []genai.Part
-
-		if msg.Image != nil {
-			// Validate and read image data
-			validatedImage, err := validateImageSize(msg.Image)
-			if err != nil {
-				return "", err
+		if msg.Role == RoleTool {
+			response := map[string]any{"content": msg.text()}
+			if msg.IsError {
+				response = map[string]any{"error": msg.text()}
 			}
-			imageData, err := io.ReadAll(validatedImage)
-			if err != nil {
-				return "", fmt.Errorf("failed to read image: %v", err)
+			part := genai.FunctionResponse{Name: msg.ToolName, Response: response}
+			// results of parallel tool calls go in one content
+			if n := len(history); n > 0 && len(history[n-1].Parts) > 0 {
+				if _, ok := history[n-1].Parts[0].(genai.FunctionResponse); ok {
+					history[n-1].Parts = append(history[n-1].Parts, part)
+					continue
+				}
 			}
-
-			// Get the correct format from MIME type
-			format := strings.TrimPrefix(string(msg.MimeType), "image/")
-			parts = append(parts, genai.ImageData(format, imageData))
+			history = append(history, &genai.Content{Parts: []genai.Part{part}, Role: "user"})
+			continue
 		}
 
-		// Add text content
-		if msg.Content != "" {
-			parts = append(parts, genai.Text(msg.Content))
+		parts, err := vertexParts(msg)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, call := range msg.ToolCalls {
+			var args map[string]any
+			if len(call.Arguments) > 0 {
+				if err := json.Unmarshal(call.Arguments, &args); err != nil {
+					return nil, nil, fmt.Errorf("invalid arguments of tool call %s: %v", call.Name, err)
+				}
+			}
+			parts = append(parts, genai.FunctionCall{Name: call.Name, Args: args})
 		}
 
 		// Create content with role
@@ -276,30 +474,57 @@ func (g *Google) GenerateWithMessages(ctx context.Context, messages []Message) (
 		})
 	}
 
-	// Set chat history
-	cs.History = history
-
 	// Send message (use the last message as the prompt)
-	if len(messages) == 0 {
-		return "", fmt.Errorf("no messages provided")
+	if len(history) == 0 {
+		return nil, nil, fmt.Errorf("no messages provided")
 	}
-	lastMessage := messages[len(messages)-1]
+	cs.History = history[:len(history)-1]
+	return cs, history[len(history)-1].Parts, nil
+}
 
-	// Generate response
-	resp, err := cs.SendMessage(ctx, genai.Text(lastMessage.Content))
-	if err != nil {
-		return "", fmt.Errorf("failed to generate chat content: %v", err)
-	}
+// vertexParts converts the content parts of msg
+func vertexParts(msg Message) ([]genai.Part, error) {
+	var parts []genai.Part
+	for _, part := range msg.ContentParts() {
+		switch part.Type {
+		case PartText:
+			if part.Text != "" {
+				parts = append(parts, genai.Text(part.Text))
+			}
+		case PartFile:
+			parts = append(parts, genai.FileData{MIMEType: string(part.File.MimeType), FileURI: part.File.URI})
+		case PartImage:
+			// Validate and read image data
+			validatedImage, err := validateImageSize(part.Data)
+			if err != nil {
+				return nil, err
+			}
+			imageData, err := io.ReadAll(validatedImage)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image: %v", err)
+			}
 
-	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
-		return "", fmt.Errorf("no content generated")
+			// Get the correct format from MIME type
+			format := strings.TrimPrefix(string(part.MimeType), "image/")
+			parts = append(parts, genai.ImageData(format, imageData))
+		default:
+			data, err := io.ReadAll(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %v", part.Type, err)
+			}
+			parts = append(parts, genai.Blob{MIMEType: string(part.MimeType), Data: data})
+		}
 	}
+	return parts, nil
+}
 
-	var res strings.Builder
-	for _, part := range resp.Candidates[0].Content.Parts {
-		res.WriteString(fmt.Sprintf("%v", part))
+// wrapError converts safety blocks to a RefusalError
+func (g *Google) wrapError(msg string, err error) error {
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) {
+		return &RefusalError{Model: g.model, Message: blocked.Error()}
 	}
-	return res.String(), nil
+	return fmt.Errorf("%s: %v", msg, err)
 }
 
 func convertRole(role Role) string {
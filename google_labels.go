@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"context"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/grpc"
+)
+
+type googleLabelsKey struct{}
+
+// WithGoogleLabels returns a context that tags Gemini requests made with it
+// with Vertex AI labels, so usage can be broken down by team or feature in
+// billing exports. Labels are merged with the ones set by SetLabels, request
+// labels win. Keys and values must be lowercase letters, digits, "-" or "_",
+// up to 63 characters. Labels require the default gRPC transport.
+func WithGoogleLabels(ctx context.Context, labels map[string]string) context.Context {
+	merged := make(map[string]string)
+	if parent, ok := ctx.Value(googleLabelsKey{}).(map[string]string); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, googleLabelsKey{}, merged)
+}
+
+// SetLabels sets Vertex AI labels sent with every request
+func (g *Google) SetLabels(labels map[string]string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.labels = labels
+}
+
+// applyLabels adds the client and context labels to GenerateContent requests
+func (g *Google) applyLabels(ctx context.Context, msg interface{}) {
+	req, ok := msg.(*aiplatformpb.GenerateContentRequest)
+	if !ok {
+		return
+	}
+	requestLabels, _ := ctx.Value(googleLabelsKey{}).(map[string]string)
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(g.labels) == 0 && len(requestLabels) == 0 {
+		return
+	}
+	if req.Labels == nil {
+		req.Labels = make(map[string]string)
+	}
+	for k, v := range g.labels {
+		req.Labels[k] = v
+	}
+	for k, v := range requestLabels {
+		req.Labels[k] = v
+	}
+}
+
+func (g *Google) labelsUnaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	g.applyLabels(ctx, req)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (g *Google) labelsStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &labelsClientStream{ClientStream: stream, ctx: ctx, g: g}, nil
+}
+
+// labelsClientStream labels requests sent on a streaming call
+type labelsClientStream struct {
+	grpc.ClientStream
+	ctx context.Context
+	g   *Google
+}
+
+func (s *labelsClientStream) SendMsg(m interface{}) error {
+	s.g.applyLabels(s.ctx, m)
+	return s.ClientStream.SendMsg(m)
+}
@@ -3,8 +3,15 @@ package ai
 import (
 	"bytes"
 	"context"
+	"net"
 	"os"
+	"reflect"
 	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func TestGoogleGenerateWithImage(t *testing.T) {
@@ -29,3 +36,70 @@ func TestGoogleGenerateWithImage(t *testing.T) {
 	t.Logf("AI %s response: %v", llm.GetModel(), res)
 
 }
+
+func TestGoogleLabels(t *testing.T) {
+	g := &Google{}
+	g.SetLabels(map[string]string{"team": "search", "env": "prod"})
+
+	ctx := WithGoogleLabels(context.Background(), map[string]string{"feature": "summary"})
+	ctx = WithGoogleLabels(ctx, map[string]string{"env": "staging"})
+
+	req := &aiplatformpb.GenerateContentRequest{}
+	err := g.labelsUnaryInterceptor(ctx, "/GenerateContent", req, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"team": "search", "env": "staging", "feature": "summary"}
+	if !reflect.DeepEqual(req.Labels, want) {
+		t.Fatalf("unexpected labels %v", req.Labels)
+	}
+}
+
+// fakePredictionServer records generate requests and answers with response
+type fakePredictionServer struct {
+	aiplatformpb.UnimplementedPredictionServiceServer
+	requests []*aiplatformpb.GenerateContentRequest
+	response func(req *aiplatformpb.GenerateContentRequest) *aiplatformpb.GenerateContentResponse
+}
+
+func (s *fakePredictionServer) GenerateContent(ctx context.Context, req *aiplatformpb.GenerateContentRequest) (*aiplatformpb.GenerateContentResponse, error) {
+	s.requests = append(s.requests, req)
+	return s.response(req), nil
+}
+
+func (s *fakePredictionServer) StreamGenerateContent(req *aiplatformpb.GenerateContentRequest, stream aiplatformpb.PredictionService_StreamGenerateContentServer) error {
+	s.requests = append(s.requests, req)
+	return stream.Send(s.response(req))
+}
+
+// newFakeVertex returns a Google client of model served by fake
+func newFakeVertex(t *testing.T, fake *fakePredictionServer, model string) *Google {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	aiplatformpb.RegisterPredictionServiceServer(server, fake)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	g, err := NewGoogle("p", []string{"us-central1"}, model, 100, nil, false,
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g
+}
+
+// vertexText returns a response with a text candidate
+func vertexText(text string) *aiplatformpb.GenerateContentResponse {
+	return &aiplatformpb.GenerateContentResponse{Candidates: []*aiplatformpb.Candidate{{
+		Content: &aiplatformpb.Content{Role: "model", Parts: []*aiplatformpb.Part{{Data: &aiplatformpb.Part_Text{Text: text}}}},
+	}}}
+}
@@ -0,0 +1,363 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Grok is a native client for the xAI API. Unlike the OpenAI compatible
+// NewXAI, it sends images together with the prompt as vision models expect,
+// returns Grok specific response metadata and supports deferred completions.
+// https://docs.x.ai/docs/api-reference
+type Grok struct {
+	baseURL     string
+	apiKey      string
+	model       string
+	maxTokens   int
+	temperature float32
+	isJson      bool
+	httpClient  *http.Client
+	stop        []string
+	sampling    Sampling
+	seed        *int64
+}
+
+func NewGrok(apiKey, model string, maxTokens int, temperature float32, isJson bool) *Grok {
+	return &Grok{
+		baseURL:     "https://api.x.ai/v1",
+		apiKey:      apiKey,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		isJson:      isJson,
+		httpClient:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (g *Grok) SetHTTPClient(client *http.Client) {
+	g.httpClient = client
+}
+
+// SetBaseURL changes the API base URL
+func (g *Grok) SetBaseURL(baseURL string) {
+	g.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+type grokMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of parts for messages with images
+	Content interface{} `json:"content"`
+}
+
+type grokPart struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	ImageURL *grokPartImageURL `json:"image_url,omitempty"`
+}
+
+type grokPartImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail"`
+}
+
+type grokResponse struct {
+	Model             string `json:"model"`
+	SystemFingerprint string `json:"system_fingerprint"`
+	Choices           []struct {
+		Message struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+			Refusal          string `json:"refusal"`
+		} `json:"message"`
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Citations []string `json:"citations"`
+}
+
+func (r *grokResponse) response() (*Response, error) {
+	if len(r.Choices) == 0 {
+		return nil, fmt.Errorf("no content generated")
+	}
+	msg := r.Choices[0].Message
+	return &Response{
+		Text:              msg.Content,
+		Model:             r.Model,
+		SystemFingerprint: r.SystemFingerprint,
+		Refusal:           msg.Refusal,
+		Reasoning:         msg.ReasoningContent,
+		Citations:         r.Citations,
+	}, nil
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (g *Grok) SetSampling(s Sampling) {
+	g.sampling = s
+}
+
+// SetSeed sets the seed of every request, see WithSeed
+func (g *Grok) SetSeed(seed int64) {
+	g.seed = &seed
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (g *Grok) SetStopSequences(stop ...string) {
+	g.stop = stop
+}
+
+func (g *Grok) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + g.apiKey}
+}
+
+// request builds a chat completion request. Images are sent in the same user
+// message as the text that follows them, or in a user message of their own if
+// the text is not from the user.
+func (g *Grok) request(ctx context.Context, messages []Message) (map[string]interface{}, error) {
+	var msgs []grokMessage
+	var images []grokPart
+	for _, msg := range messages {
+		msg = msg.flat()
+		role := msg.Role
+		if role == "" {
+			role = RoleUser
+		}
+		if msg.Image != nil {
+			data, err := io.ReadAll(msg.Image)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image: %v", err)
+			}
+			images = append(images, grokPart{
+				Type:     "image_url",
+				ImageURL: &grokPartImageURL{URL: "data:" + string(msg.MimeType) + ";base64," + encodeBase64(ctx, data), Detail: "high"},
+			})
+			if msg.Content == "" {
+				continue
+			}
+		}
+		if len(images) == 0 {
+			msgs = append(msgs, grokMessage{Role: string(role), Content: msg.Content})
+			continue
+		}
+		if role != RoleUser {
+			// only user messages have images, they are sent before
+			msgs = append(msgs, grokMessage{Role: string(RoleUser), Content: images})
+			msgs = append(msgs, grokMessage{Role: string(role), Content: msg.Content})
+			images = nil
+			continue
+		}
+		parts := append(images, grokPart{Type: "text", Text: msg.Content})
+		msgs = append(msgs, grokMessage{Role: string(RoleUser), Content: parts})
+		images = nil
+	}
+	if len(images) > 0 {
+		msgs = append(msgs, grokMessage{Role: string(RoleUser), Content: images})
+	}
+
+	body := map[string]interface{}{
+		"model":       requestModel(ctx, g.model),
+		"messages":    msgs,
+		"max_tokens":  g.maxTokens,
+		"temperature": g.temperature,
+	}
+	if g.isJson {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+	stopBody(ctx, body, "stop", g.stop)
+	samplingBody(ctx, body, g.sampling)
+	// xAI has no top_k
+	delete(body, "top_k")
+	seedBody(ctx, body, "seed", g.seed)
+	deterministicBody(ctx, body, "seed")
+	return body, nil
+}
+
+func (g *Grok) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return g.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (g *Grok) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	g.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (g *Grok) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	err := g.stream(ctx, messages, func(chunk *grokResponse) error {
+		if chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk.Choices[0].Delta.Content:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+// stream calls fn for every chunk with choices of a streamed reply to messages
+func (g *Grok) stream(ctx context.Context, messages []Message, fn func(chunk *grokResponse) error) error {
+	body, err := g.request(ctx, messages)
+	if err != nil {
+		return err
+	}
+	body["stream"] = true
+	resp, err := sendJSON(ctx, g.httpClient, http.MethodPost, g.baseURL+"/chat/completions", g.headers(), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		var chunk grokResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		return fn(&chunk)
+	})
+}
+
+// StreamWithReasoning streams the reasoning of reasoning models (grok-3-mini)
+// as reasoning events
+func (g *Grok) StreamWithReasoning(ctx context.Context, messages []Message, fn func(StreamEvent) error) error {
+	seq := 0
+	emit := func(typ, text string) error {
+		if text == "" {
+			return nil
+		}
+		seq++
+		return fn(StreamEvent{Type: typ, Seq: seq - 1, Text: text})
+	}
+	return g.stream(ctx, messages, func(chunk *grokResponse) error {
+		delta := chunk.Choices[0].Delta
+		if err := emit(StreamEventReasoning, delta.ReasoningContent); err != nil {
+			return err
+		}
+		return emit(StreamEventDelta, delta.Content)
+	})
+}
+
+func (g *Grok) GetModel() string {
+	return g.model
+}
+
+func (g *Grok) Close() error {
+	return nil
+}
+
+func (g *Grok) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return g.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
+}
+
+// GenerateWithImages requires a vision model, e.g. "grok-2-vision-latest".
+// xAI accepts JPEG and PNG images.
+func (g *Grok) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if len(images) != len(mimeTypes) {
+		return "", fmt.Errorf("number of images and mime types must match")
+	}
+	if prompt == "" {
+		return "", fmt.Errorf("prompt is required")
+	}
+	var msgs []Message
+	for i, image := range images {
+		msgs = append(msgs, Message{Role: RoleUser, Image: image, MimeType: mimeTypes[i]})
+	}
+	msgs = append(msgs, Message{Role: RoleUser, Content: prompt})
+	return g.GenerateWithMessages(ctx, msgs)
+}
+
+func (g *Grok) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	resp, err := g.GenerateResponse(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
+// GenerateResponse returns the response with the reasoning of reasoning
+// models and the citations of live search
+func (g *Grok) GenerateResponse(ctx context.Context, messages []Message) (*Response, error) {
+	body, err := g.request(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	provenance := newProvenance(ctx, requestModel(ctx, g.model), body, "messages")
+	var raw json.RawMessage
+	if err := doJSON(ctx, g.httpClient, http.MethodPost, g.baseURL+"/chat/completions", g.headers(), body, &raw); err != nil {
+		return nil, err
+	}
+	var resp grokResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	res, err := resp.response()
+	attachProvenance(res, provenance)
+	attachRaw(ctx, res, raw)
+	return res, err
+}
+
+// StartDeferred starts a deferred completion and returns its request id.
+// The result is kept by xAI for 24 hours, see DeferredResponse.
+func (g *Grok) StartDeferred(ctx context.Context, messages []Message) (string, error) {
+	body, err := g.request(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	body["deferred"] = true
+	var resp struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := doJSON(ctx, g.httpClient, http.MethodPost, g.baseURL+"/chat/completions", g.headers(), body, &resp); err != nil {
+		return "", err
+	}
+	if resp.RequestID == "" {
+		return "", fmt.Errorf("no request id returned")
+	}
+	return resp.RequestID, nil
+}
+
+// DeferredResponse returns the result of a deferred completion, or nil if it
+// is not ready yet
+func (g *Grok) DeferredResponse(ctx context.Context, requestID string) (*Response, error) {
+	resp, err := sendJSON(ctx, g.httpClient, http.MethodGet, g.baseURL+"/chat/deferred-completion/"+requestID, g.headers(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+	var res grokResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return res.response()
+}
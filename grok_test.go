@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGrok(t *testing.T) {
+	var body map[string]interface{}
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("missing auth header %v", r.Header)
+		}
+		switch r.URL.Path {
+		case "/chat/deferred-completion/req-1":
+			if polls++; polls == 1 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			io.WriteString(w, `{"model":"grok-3","choices":[{"index":0,"message":{"role":"assistant","content":"later"}}]}`)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["deferred"] == true {
+			io.WriteString(w, `{"request_id":"req-1"}`)
+			return
+		}
+		io.WriteString(w, `{"model":"grok-2-vision-1212","system_fingerprint":"fp_1","citations":["https://x.ai"],"choices":[{"index":0,"message":{"role":"assistant","content":"a cat","reasoning_content":"looked"}}]}`)
+	}))
+	defer server.Close()
+
+	llm := NewGrok("key", "grok-2-vision-latest", 100, 0, false)
+	llm.SetBaseURL(server.URL + "/")
+	res, err := llm.GenerateWithImages(context.Background(), "What is this?",
+		[]io.Reader{bytes.NewReader([]byte("png")), bytes.NewReader([]byte("jpg"))}, []MimeType{MimeTypePNG, MimeTypeJPEG})
+	if err != nil || res != "a cat" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	messages := body["messages"].([]interface{})
+	if len(messages) != 1 {
+		t.Fatalf("images should be sent with the prompt: %v", messages)
+	}
+	parts := messages[0].(map[string]interface{})["content"].([]interface{})
+	if len(parts) != 3 || parts[2].(map[string]interface{})["text"] != "What is this?" {
+		t.Fatalf("unexpected parts %v", parts)
+	}
+
+	// images before an assistant message are sent as a user message of their own
+	if _, err := llm.GenerateWithMessages(context.Background(), []Message{
+		{Role: RoleUser, Image: bytes.NewReader([]byte("png")), MimeType: MimeTypePNG},
+		{Role: RoleAssistant, Content: "a cat"},
+		{Role: RoleUser, Content: "What color?"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	messages = body["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+	first, second := messages[0].(map[string]interface{}), messages[1].(map[string]interface{})
+	if first["role"] != "user" || len(first["content"].([]interface{})) != 1 || second["role"] != "assistant" || second["content"] != "a cat" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+
+	resp, err := llm.GenerateResponse(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if err != nil || resp.Reasoning != "looked" || len(resp.Citations) != 1 || resp.SystemFingerprint != "fp_1" {
+		t.Fatalf("unexpected response %+v, %v", resp, err)
+	}
+
+	id, err := llm.StartDeferred(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	if err != nil || id != "req-1" {
+		t.Fatalf("unexpected request id %q, %v", id, err)
+	}
+	if resp, err := llm.DeferredResponse(context.Background(), id); resp != nil || err != nil {
+		t.Fatalf("expected pending result, got %v, %v", resp, err)
+	}
+	if resp, err := llm.DeferredResponse(context.Background(), id); err != nil || resp.Text != "later" {
+		t.Fatalf("unexpected deferred result %v, %v", resp, err)
+	}
+}
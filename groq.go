@@ -0,0 +1,41 @@
+package ai
+
+import (
+	"github.com/openai/openai-go/option"
+)
+
+// https://console.groq.com/docs/openai
+// Groq is mostly OpenAI compatible, but rejects some parameters and
+// does not support JSON mode together with streaming or stop sequences.
+func NewGroq(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	return NewOpenAICompatible("https://api.groq.com/openai/v1/", apiKey, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(rewriteJSONBody(groqCompat)))
+}
+
+var groqUnsupportedParams = []string{
+	"logprobs", "logit_bias", "top_logprobs", "n",
+	"store", "metadata", "modalities", "audio", "prediction", "service_tier",
+}
+
+func groqCompat(body map[string]interface{}) {
+	for _, key := range groqUnsupportedParams {
+		delete(body, key)
+	}
+
+	// messages[].name is not supported
+	if messages, ok := body["messages"].([]interface{}); ok {
+		for _, m := range messages {
+			if msg, ok := m.(map[string]interface{}); ok {
+				delete(msg, "name")
+			}
+		}
+	}
+
+	if format, ok := body["response_format"].(map[string]interface{}); ok && format["type"] == "json_object" {
+		if stream, _ := body["stream"].(bool); stream {
+			delete(body, "response_format")
+		} else {
+			delete(body, "stop")
+		}
+	}
+}
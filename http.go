@@ -0,0 +1,145 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// HTTPClientSetter is implemented by clients whose HTTP client can be
+// replaced, e.g. to route requests through a proxy or inspect responses
+type HTTPClientSetter interface {
+	SetHTTPClient(client *http.Client)
+}
+
+// HTTPError is returned by REST based clients for non 2xx responses
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// Retryable reports whether the status is transient: timeouts, rate limits
+// and server errors
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// newJSONRequest builds a request with a JSON encoded body (if not nil)
+func newJSONRequest(ctx context.Context, method, url string, headers map[string]string, body interface{}) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// doJSON sends a JSON request and decodes the JSON response into out (if not nil)
+func doJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out interface{}) error {
+	resp, err := sendJSON(ctx, client, method, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// sendJSON sends a JSON request and returns the response for 2xx statuses,
+// the caller must close the body
+func sendJSON(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	req, err := newJSONRequest(ctx, method, url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if err := compressRequest(req); err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if compressionFromContext(ctx) != "" {
+		if err := decompressResponse(resp); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(data)}
+	}
+	return resp, nil
+}
+
+// readSSE reads a server-sent events stream, calling fn for every event.
+// Reading stops when fn returns an error, which is returned (io.EOF is not).
+func readSSE(r io.Reader, fn func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 || event != "" {
+				if err := fn(event, strings.Join(data, "\n")); err != nil {
+					if err == io.EOF {
+						return nil
+					}
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		if err := fn(event, strings.Join(data, "\n")); err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingTransport counts the requests it sends
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestSetHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/messages":
+			io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+		default:
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	anthropic := NewAnthropic("key", "claude-3-5-haiku-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	grok := NewGrok("key", "grok-2", 100, 0, false)
+	grok.SetBaseURL(server.URL)
+	for _, llm := range []LLM{NewOpenAICompatible(server.URL, "key", "m", 100, 0, false), anthropic, grok} {
+		transport := &countingTransport{}
+		llm.(HTTPClientSetter).SetHTTPClient(&http.Client{Transport: transport})
+		if res, err := llm.Generate(context.Background(), "", "hi"); err != nil || res != "ok" {
+			t.Fatalf("unexpected result %q, %v from %T", res, err, llm)
+		}
+		if transport.requests != 1 {
+			t.Errorf("expected the request of %T to use the client, got %d requests", llm, transport.requests)
+		}
+	}
+}
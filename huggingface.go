@@ -0,0 +1,288 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	huggingFaceBaseURL = "https://api-inference.huggingface.co/models/"
+	// huggingFaceMaxLoadWait caps the total time spent waiting for a cold model
+	huggingFaceMaxLoadWait = 5 * time.Minute
+)
+
+// NewHuggingFace creates a chat-completion client for a model on the serverless Inference API.
+// Requests made while the model is loading (503) are retried once it is ready.
+// https://huggingface.co/docs/api-inference/tasks/chat-completion
+func NewHuggingFace(token, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	return NewHuggingFaceEndpoint(huggingFaceBaseURL+model, token, model, maxTokens, temperature, isJson)
+}
+
+// NewHuggingFaceEndpoint creates a chat-completion client for a dedicated
+// Inference Endpoint (or any TGI server) at endpointURL
+func NewHuggingFaceEndpoint(endpointURL, token, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	return NewOpenAICompatible(strings.TrimSuffix(endpointURL, "/")+"/v1/", token, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(huggingFaceLoadingMiddleware))
+}
+
+func huggingFaceLoadingMiddleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	deadline := time.Now().Add(huggingFaceMaxLoadWait)
+	for {
+		resp, err := next(req)
+		if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+			return resp, err
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		wait, loading := huggingFaceLoadingWait(data)
+		if !loading || time.Now().Add(wait).After(deadline) || req.GetBody == nil {
+			resp.Body = io.NopCloser(bytes.NewReader(data))
+			return resp, nil
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// huggingFaceLoadingWait parses a 503 body and reports whether the model is
+// loading and how long to wait before retrying
+func huggingFaceLoadingWait(body []byte) (time.Duration, bool) {
+	var loading struct {
+		Error         string  `json:"error"`
+		EstimatedTime float64 `json:"estimated_time"`
+	}
+	if err := json.Unmarshal(body, &loading); err != nil || !strings.Contains(loading.Error, "loading") {
+		return 0, false
+	}
+	wait := time.Duration(loading.EstimatedTime * float64(time.Second))
+	if wait < time.Second {
+		wait = time.Second
+	}
+	if wait > 30*time.Second {
+		wait = 30 * time.Second
+	}
+	return wait, true
+}
+
+// HuggingFaceTextGeneration uses the raw text-generation task, for models
+// without a chat template. Messages are flattened into a single prompt.
+// https://huggingface.co/docs/api-inference/tasks/text-generation
+type HuggingFaceTextGeneration struct {
+	token       string
+	model       string
+	url         string
+	maxTokens   int
+	temperature float32
+	httpClient  *http.Client
+	stop        []string
+	sampling    Sampling
+	seed        *int64
+}
+
+// NewHuggingFaceTextGeneration creates a client for a model name on the
+// serverless Inference API, or for a full Inference Endpoint URL
+func NewHuggingFaceTextGeneration(token, model string, maxTokens int, temperature float32) *HuggingFaceTextGeneration {
+	url := huggingFaceBaseURL + model
+	if strings.HasPrefix(model, "http://") || strings.HasPrefix(model, "https://") {
+		url = model
+	}
+	return &HuggingFaceTextGeneration{
+		token:       token,
+		model:       model,
+		url:         url,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		httpClient:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (h *HuggingFaceTextGeneration) SetHTTPClient(client *http.Client) {
+	h.httpClient = client
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (h *HuggingFaceTextGeneration) SetSampling(s Sampling) {
+	h.sampling = s
+}
+
+// SetSeed sets the seed of every request, see WithSeed
+func (h *HuggingFaceTextGeneration) SetSeed(seed int64) {
+	h.seed = &seed
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (h *HuggingFaceTextGeneration) SetStopSequences(stop ...string) {
+	h.stop = stop
+}
+
+func (h *HuggingFaceTextGeneration) request(ctx context.Context, systemPrompt, prompt string, stream bool) map[string]interface{} {
+	if systemPrompt != "" {
+		prompt = systemPrompt + "\n\n" + prompt
+	}
+	parameters := map[string]interface{}{
+		"max_new_tokens":   h.maxTokens,
+		"return_full_text": false,
+	}
+	if h.temperature > 0 {
+		parameters["temperature"] = h.temperature
+	}
+	stopBody(ctx, parameters, "stop", h.stop)
+	// Text generation has a repetition penalty instead of the OpenAI penalties
+	s := sampling(ctx, h.sampling)
+	if s.TopP != 0 {
+		parameters["top_p"] = s.TopP
+	}
+	if s.TopK != 0 {
+		parameters["top_k"] = s.TopK
+	}
+	seedBody(ctx, parameters, "seed", h.seed)
+	if IsDeterministic(ctx) {
+		// temperature must be positive, greedy decoding is requested instead
+		delete(parameters, "temperature")
+		parameters["do_sample"] = false
+		if _, ok := parameters["seed"]; !ok {
+			parameters["seed"] = DeterministicSeed
+		}
+	}
+	return map[string]interface{}{
+		"inputs":     prompt,
+		"parameters": parameters,
+		"stream":     stream,
+	}
+}
+
+// send posts the request, waiting and retrying while the model is loading
+func (h *HuggingFaceTextGeneration) send(ctx context.Context, body interface{}) (*http.Response, error) {
+	deadline := time.Now().Add(huggingFaceMaxLoadWait)
+	headers := map[string]string{"Authorization": "Bearer " + h.token}
+	for {
+		resp, err := sendJSON(ctx, h.httpClient, http.MethodPost, h.url, headers, body)
+		httpErr, ok := err.(*HTTPError)
+		if !ok || httpErr.StatusCode != http.StatusServiceUnavailable {
+			return resp, err
+		}
+		wait, loading := huggingFaceLoadingWait([]byte(httpErr.Body))
+		if !loading || time.Now().Add(wait).After(deadline) {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (h *HuggingFaceTextGeneration) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	resp, err := h.send(ctx, h.request(ctx, systemPrompt, prompt, false))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var results []struct {
+		GeneratedText string `json:"generated_text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return "", fmt.Errorf("failed to decode response: %v", err)
+	}
+	if len(results) == 0 {
+		return "", fmt.Errorf("no content generated")
+	}
+	return results[0].GeneratedText, nil
+}
+
+func (h *HuggingFaceTextGeneration) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	resp, err := h.send(ctx, h.request(ctx, systemPrompt, prompt, true))
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		var chunk struct {
+			Token struct {
+				Text    string `json:"text"`
+				Special bool   `json:"special"`
+			} `json:"token"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("error in stream: %s", chunk.Error)
+		}
+		if chunk.Token.Special || chunk.Token.Text == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk.Token.Text:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (h *HuggingFaceTextGeneration) GetModel() string {
+	return h.model
+}
+
+func (h *HuggingFaceTextGeneration) Close() error {
+	return nil
+}
+
+func (h *HuggingFaceTextGeneration) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return "", fmt.Errorf("text-generation does not support images")
+}
+
+func (h *HuggingFaceTextGeneration) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return "", fmt.Errorf("text-generation does not support images")
+}
+
+func (h *HuggingFaceTextGeneration) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	systemPrompt, prompt := messagesToPrompt(messages)
+	return h.Generate(ctx, systemPrompt, prompt)
+}
+
+// GenerateWithMessagesStream streams the reply to messages flattened into a
+// single prompt, see MessagesStreamer
+func (h *HuggingFaceTextGeneration) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	systemPrompt, prompt := messagesToPrompt(messages)
+	h.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+}
@@ -0,0 +1,32 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHuggingFaceModelLoading(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, `{"error":"Model gpt2 is currently loading","estimated_time":0.01}`)
+			return
+		}
+		io.WriteString(w, `[{"generated_text":"world"}]`)
+	}))
+	defer srv.Close()
+
+	llm := NewHuggingFaceTextGeneration("token", srv.URL, 10, 0)
+	res, err := llm.Generate(context.Background(), "", "hello")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if res != "world" || calls != 2 {
+		t.Fatalf("unexpected response %q after %d calls", res, calls)
+	}
+}
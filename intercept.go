@@ -0,0 +1,236 @@
+package ai
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// defaultInterceptBuffer is the default StreamInterceptor.MaxBuffer
+const defaultInterceptBuffer = 256
+
+// StreamInterceptor transforms or vetoes the output of a stream in flight,
+// see InterceptLLM. Chunks are buffered up to a boundary, so that a word or a
+// sentence is never split, and Transform is called with the buffered segment.
+type StreamInterceptor struct {
+	// Transform returns the text sent for segment, empty drops it. Returning
+	// an error vetoes the rest of the stream, which fails with the error.
+	Transform func(ctx context.Context, segment string) (string, error)
+	// Boundary returns the length of the leading part of buffered that is
+	// ready to be transformed, 0 to wait for more output. Defaults to
+	// WordBoundary.
+	Boundary func(buffered string) int
+	// MaxBuffer is the size in bytes past which the buffer is transformed
+	// without a boundary, bounding latency. Defaults to 256.
+	MaxBuffer int
+}
+
+// WordBoundary splits after the last whitespace
+func WordBoundary(buffered string) int {
+	return strings.LastIndexFunc(buffered, unicode.IsSpace) + 1
+}
+
+// sentenceEnd matches the end of a sentence followed by whitespace
+var sentenceEnd = regexp.MustCompile(`[.!?。！？]["')\]]*\s+`)
+
+// SentenceBoundary splits after the last complete sentence or line
+func SentenceBoundary(buffered string) int {
+	end := strings.LastIndexByte(buffered, '\n') + 1
+	if loc := sentenceEnd.FindAllStringIndex(buffered, -1); len(loc) > 0 {
+		end = max(end, loc[len(loc)-1][1])
+	}
+	return end
+}
+
+// MaskWords returns an interceptor replacing the given words, case
+// insensitively, with asterisks
+func MaskWords(words ...string) StreamInterceptor {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(word)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	return StreamInterceptor{
+		Transform: func(ctx context.Context, segment string) (string, error) {
+			return pattern.ReplaceAllStringFunc(segment, func(word string) string {
+				return strings.Repeat("*", len([]rune(word)))
+			}), nil
+		},
+	}
+}
+
+// Translate returns an interceptor translating the output sentence by
+// sentence to language with llm, see Rewrite
+func Translate(llm LLM, language string) StreamInterceptor {
+	return StreamInterceptor{
+		Transform: func(ctx context.Context, segment string) (string, error) {
+			if strings.TrimSpace(segment) == "" {
+				return segment, nil
+			}
+			res, err := Rewrite(ctx, llm, segment, "Translate to "+language+". Keep the formatting.")
+			if err != nil {
+				return "", err
+			}
+			// Keep the whitespace between sentences
+			trailing := segment[len(strings.TrimRightFunc(segment, unicode.IsSpace)):]
+			return strings.TrimSpace(res) + trailing, nil
+		},
+		Boundary: SentenceBoundary,
+	}
+}
+
+// interceptStage is the buffer of a StreamInterceptor in a stream
+type interceptStage struct {
+	StreamInterceptor
+	buf string
+}
+
+// write buffers chunk and returns the transformed segments that are ready
+func (s *interceptStage) write(ctx context.Context, chunk string) (string, error) {
+	s.buf += chunk
+	boundary := s.Boundary
+	if boundary == nil {
+		boundary = WordBoundary
+	}
+	maxBuffer := s.MaxBuffer
+	if maxBuffer <= 0 {
+		maxBuffer = defaultInterceptBuffer
+	}
+	n := boundary(s.buf)
+	if n == 0 && len(s.buf) >= maxBuffer {
+		n = len(s.buf)
+	}
+	if n == 0 {
+		return "", nil
+	}
+	segment := s.buf[:n]
+	s.buf = s.buf[n:]
+	return s.Transform(ctx, segment)
+}
+
+// flush transforms what is left at the end of the stream
+func (s *interceptStage) flush(ctx context.Context) (string, error) {
+	if s.buf == "" {
+		return "", nil
+	}
+	segment := s.buf
+	s.buf = ""
+	return s.Transform(ctx, segment)
+}
+
+// InterceptLLM passes the output of llm through interceptors, in order.
+// Streams are transformed in flight, other calls transform the whole output.
+//
+//	llm = ai.NewInterceptLLM(llm, ai.MaskWords("darn", "heck"), ai.Translate(fast, "French"))
+type InterceptLLM struct {
+	LLM
+	interceptors []StreamInterceptor
+}
+
+// NewInterceptLLM creates an InterceptLLM
+func NewInterceptLLM(llm LLM, interceptors ...StreamInterceptor) *InterceptLLM {
+	return &InterceptLLM{LLM: llm, interceptors: interceptors}
+}
+
+// transform passes a whole output through the interceptors
+func (i *InterceptLLM) transform(ctx context.Context, fn func() (string, error)) (string, error) {
+	res, err := fn()
+	if err != nil {
+		return "", err
+	}
+	for _, interceptor := range i.interceptors {
+		if res, err = interceptor.Transform(ctx, res); err != nil {
+			return "", err
+		}
+	}
+	return res, nil
+}
+
+func (i *InterceptLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return i.transform(ctx, func() (string, error) {
+		return i.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (i *InterceptLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	send := func(chunk string) error {
+		if chunk == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	stages := make([]*interceptStage, len(i.interceptors))
+	reset := func() {
+		for n, interceptor := range i.interceptors {
+			stages[n] = &interceptStage{StreamInterceptor: interceptor}
+		}
+	}
+	reset()
+	// pass writes chunk through the stages from the first one, flushing them
+	// at the end of the stream
+	pass := func(chunk string, flush bool) error {
+		for _, stage := range stages {
+			out, err := stage.write(ctx, chunk)
+			if err != nil {
+				return err
+			}
+			if flush {
+				rest, err := stage.flush(ctx)
+				if err != nil {
+					return err
+				}
+				out += rest
+			}
+			chunk = out
+		}
+		return send(chunk)
+	}
+
+	err := consumeStream(ctx, i.LLM, systemPrompt, prompt, func(chunk string) error {
+		if chunk == "[CLEAR]" {
+			// The output restarts, drop what was buffered
+			reset()
+			return send(chunk)
+		}
+		return pass(chunk, false)
+	})
+	if err == nil {
+		err = pass("", true)
+	}
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (i *InterceptLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return i.transform(ctx, func() (string, error) {
+		return i.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+	})
+}
+
+func (i *InterceptLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return i.transform(ctx, func() (string, error) {
+		return i.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+	})
+}
+
+func (i *InterceptLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	return i.transform(ctx, func() (string, error) {
+		return i.LLM.GenerateWithMessages(ctx, messages)
+	})
+}
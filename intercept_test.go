@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInterceptLLM(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMockLLM("mock", "Well darn, what the HECK. Second sentence!")
+	mock.SetFailureProfile(FailureProfile{ChunkSize: 3})
+	translator := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		return strings.ToUpper(prompt[strings.LastIndex(prompt, "\n")+1:]), nil
+	}}
+
+	var chunks []string
+	llm := NewInterceptLLM(mock, MaskWords("darn", "heck"), StreamInterceptor{
+		Transform: func(ctx context.Context, segment string) (string, error) { return "[" + segment + "]", nil },
+		Boundary:  SentenceBoundary,
+	})
+	err := consumeStream(ctx, llm, "", "hi", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil || strings.Join(chunks, "|") != "[Well ****, what the ****. ]|[Second sentence!]" {
+		t.Fatalf("unexpected chunks %q, %v", chunks, err)
+	}
+	if res, err := llm.Generate(ctx, "", "hi"); err != nil || res != "[Well ****, what the ****. Second sentence!]" {
+		t.Errorf("unexpected result %q, %v", res, err)
+	}
+
+	// Segments are sent once the buffer is full even without a boundary
+	stage := &interceptStage{StreamInterceptor: StreamInterceptor{
+		Transform: func(ctx context.Context, segment string) (string, error) { return segment, nil },
+		MaxBuffer: 4,
+	}}
+	if out, _ := stage.write(ctx, "abc"); out != "" {
+		t.Errorf("expected buffering, got %q", out)
+	}
+	if out, _ := stage.write(ctx, "def"); out != "abcdef" {
+		t.Errorf("expected the full buffer, got %q", out)
+	}
+
+	veto := errors.New("vetoed")
+	llm = NewInterceptLLM(mock, StreamInterceptor{Transform: func(ctx context.Context, segment string) (string, error) {
+		if strings.Contains(segment, "HECK") {
+			return "", veto
+		}
+		return segment, nil
+	}})
+	chunks = nil
+	err = consumeStream(ctx, llm, "", "hi", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if !errors.Is(err, veto) || strings.Join(chunks, "") != "Well darn, what the " {
+		t.Errorf("expected a veto after %q, got %v", chunks, err)
+	}
+
+	llm = NewInterceptLLM(mock, Translate(translator, "shouting"))
+	if res, err := llm.Generate(ctx, "", "hi"); err != nil || res != "WELL DARN, WHAT THE HECK. SECOND SENTENCE!" {
+		t.Errorf("unexpected translation %q, %v", res, err)
+	}
+}
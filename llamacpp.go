@@ -0,0 +1,211 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LlamaCpp uses the native API of llama.cpp's llama-server, for fully offline deployments.
+// Messages are formatted with the model's chat template on the server.
+// https://github.com/ggml-org/llama.cpp/tree/master/tools/server
+type LlamaCpp struct {
+	baseURL     string
+	model       string
+	maxTokens   int
+	temperature float32
+	grammar     string
+	cachePrompt bool
+	httpClient  *http.Client
+	stop        []string
+	sampling    Sampling
+	seed        *int64
+}
+
+// NewLlamaCpp creates a client for a llama-server at baseURL, e.g. "http://localhost:8080".
+// The server runs a single model, so model is only used as the reported name.
+func NewLlamaCpp(baseURL, model string, maxTokens int, temperature float32) *LlamaCpp {
+	return &LlamaCpp{
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		cachePrompt: true,
+		httpClient:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (l *LlamaCpp) SetHTTPClient(client *http.Client) {
+	l.httpClient = client
+}
+
+// SetGrammar constrains the output with a GBNF grammar, empty to disable
+func (l *LlamaCpp) SetGrammar(grammar string) {
+	l.grammar = grammar
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (l *LlamaCpp) SetSampling(s Sampling) {
+	l.sampling = s
+}
+
+// SetSeed sets the seed of every request, see WithSeed
+func (l *LlamaCpp) SetSeed(seed int64) {
+	l.seed = &seed
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (l *LlamaCpp) SetStopSequences(stop ...string) {
+	l.stop = stop
+}
+
+// SetCachePrompt sets whether the server reuses the KV cache of the previous
+// request for a common prompt prefix (enabled by default)
+func (l *LlamaCpp) SetCachePrompt(cachePrompt bool) {
+	l.cachePrompt = cachePrompt
+}
+
+type llamaCppMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type llamaCppCompletion struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+}
+
+// prompt applies the chat template of the loaded model to messages
+func (l *LlamaCpp) prompt(ctx context.Context, messages []Message) (string, error) {
+	var msgs []llamaCppMessage
+	for _, msg := range messages {
+		msg = msg.flat()
+		if msg.Image != nil {
+			return "", fmt.Errorf("llama.cpp client does not support images")
+		}
+		msgs = append(msgs, llamaCppMessage{Role: string(msg.Role), Content: msg.Content})
+	}
+
+	var resp struct {
+		Prompt string `json:"prompt"`
+	}
+	err := doJSON(ctx, l.httpClient, http.MethodPost, l.baseURL+"/apply-template", nil,
+		map[string]interface{}{"messages": msgs}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Prompt, nil
+}
+
+func (l *LlamaCpp) request(ctx context.Context, prompt string, stream bool) map[string]interface{} {
+	req := map[string]interface{}{
+		"prompt":       prompt,
+		"n_predict":    l.maxTokens,
+		"temperature":  l.temperature,
+		"cache_prompt": l.cachePrompt,
+		"stream":       stream,
+	}
+	if l.grammar != "" {
+		req["grammar"] = l.grammar
+	}
+	stopBody(ctx, req, "stop", l.stop)
+	samplingBody(ctx, req, l.sampling)
+	seedBody(ctx, req, "seed", l.seed)
+	if IsDeterministic(ctx) {
+		deterministicBody(ctx, req, "seed")
+		// the server applies a repeat penalty unless it is set to 1
+		req["repeat_penalty"] = 1
+	}
+	return req
+}
+
+func (l *LlamaCpp) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return l.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (l *LlamaCpp) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	l.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (l *LlamaCpp) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	templated, err := l.prompt(ctx, messages)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	resp, err := sendJSON(ctx, l.httpClient, http.MethodPost, l.baseURL+"/completion", nil, l.request(ctx, templated, true))
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		var chunk llamaCppCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if chunk.Content != "" {
+			select {
+			case resultCh <- chunk.Content:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if chunk.Stop {
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (l *LlamaCpp) GetModel() string {
+	return l.model
+}
+
+func (l *LlamaCpp) Close() error {
+	return nil
+}
+
+func (l *LlamaCpp) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return "", fmt.Errorf("llama.cpp client does not support images")
+}
+
+func (l *LlamaCpp) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return "", fmt.Errorf("llama.cpp client does not support images")
+}
+
+func (l *LlamaCpp) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	prompt, err := l.prompt(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	var resp llamaCppCompletion
+	err = doJSON(ctx, l.httpClient, http.MethodPost, l.baseURL+"/completion", nil, l.request(ctx, prompt, false), &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLlamaCpp(t *testing.T) {
+	var completion map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apply-template":
+			var req struct {
+				Messages []llamaCppMessage `json:"messages"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			var sb strings.Builder
+			for _, m := range req.Messages {
+				sb.WriteString("<" + m.Role + ">" + m.Content)
+			}
+			json.NewEncoder(w).Encode(map[string]string{"prompt": sb.String()})
+		case "/completion":
+			json.NewDecoder(r.Body).Decode(&completion)
+			if completion["stream"] == true {
+				w.Write([]byte("data: {\"content\":\"he\",\"stop\":false}\n\ndata: {\"content\":\"llo\",\"stop\":false}\n\ndata: {\"content\":\"\",\"stop\":true}\n\n"))
+				return
+			}
+			w.Write([]byte(`{"content":"hello","stop":true}`))
+		}
+	}))
+	defer server.Close()
+
+	llm := NewLlamaCpp(server.URL+"/", "qwen2.5", 64, 0.5)
+	llm.SetGrammar(`root ::= "hello"`)
+
+	res, err := llm.Generate(context.Background(), "Be brief", "Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "hello" {
+		t.Errorf("unexpected response %q", res)
+	}
+	if completion["prompt"] != "<system>Be brief<user>Hi" || completion["n_predict"] != 64.0 ||
+		completion["grammar"] != `root ::= "hello"` || completion["cache_prompt"] != true {
+		t.Errorf("unexpected request %v", completion)
+	}
+
+	var out strings.Builder
+	err = consumeStream(context.Background(), llm, "", "Hi", func(chunk string) error {
+		out.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "hello" {
+		t.Errorf("unexpected stream output %q", out.String())
+	}
+}
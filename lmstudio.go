@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ModelNotLoadedError is returned when a local server does not have the
+// requested model loaded
+type ModelNotLoadedError struct {
+	Model string
+	// Available are the models the server reported
+	Available []string
+}
+
+func (e *ModelNotLoadedError) Error() string {
+	if len(e.Available) == 0 {
+		return fmt.Sprintf("model %s is not loaded, no models are loaded", e.Model)
+	}
+	return fmt.Sprintf("model %s is not loaded, available: %s", e.Model, strings.Join(e.Available, ", "))
+}
+
+// lmStudioTimeout bounds the model check done by NewLMStudio
+const lmStudioTimeout = 10 * time.Second
+
+// NewLMStudio creates a client for the LM Studio local server, baseURL is
+// e.g. "http://localhost:1234/v1/". It checks /v1/models first and returns a
+// ModelNotLoadedError if the model is not available.
+// https://lmstudio.ai/docs/app/api/endpoints/openai
+func NewLMStudio(baseURL, model string, maxTokens int64, temperature float64, isJson bool) (*OpenAI, error) {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lmStudioTimeout)
+	defer cancel()
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, nil, http.MethodGet, baseURL+"models", nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list LM Studio models: %w", err)
+	}
+
+	var available []string
+	for _, m := range resp.Data {
+		if m.ID == model {
+			return NewOpenAICompatible(baseURL, "lm-studio", model, maxTokens, temperature, isJson), nil
+		}
+		available = append(available, m.ID)
+	}
+	return nil, &ModelNotLoadedError{Model: model, Available: available}
+}
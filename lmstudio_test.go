@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLMStudio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			io.WriteString(w, `{"object":"list","data":[{"id":"qwen2.5-7b-instruct","object":"model"}]}`)
+		case "/v1/chat/completions":
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewLMStudio(server.URL+"/v1", "llama-3.2-1b", 100, 0, false)
+	var notLoaded *ModelNotLoadedError
+	if !errors.As(err, &notLoaded) || len(notLoaded.Available) != 1 {
+		t.Fatalf("expected ModelNotLoadedError, got %v", err)
+	}
+
+	llm, err := NewLMStudio(server.URL+"/v1", "qwen2.5-7b-instruct", 100, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := llm.Generate(context.Background(), "", "hi")
+	if err != nil || res != "ok" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+}
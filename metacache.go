@@ -0,0 +1,226 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetadataCache stores responses of provider metadata requests, such as
+// model lists, which change rarely but may be consulted on every request by
+// routers and validators. Implementations must be safe for concurrent use,
+// e.g. backed by Redis to share the cache between instances.
+type MetadataCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+// CachedResponse is a cached metadata response
+type CachedResponse struct {
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	Expires      time.Time `json:"expires"`
+}
+
+var metadataCache = struct {
+	sync.RWMutex
+	cache MetadataCache
+	ttl   time.Duration
+}{}
+
+// SetMetadataCache caches metadata requests in cache: ListModels and
+// ModelStatusChecker.Refresh. Responses are fresh for the max-age of their
+// Cache-Control header, or ttl without one. Stale responses with an ETag or
+// Last-Modified header are revalidated with a conditional request. A nil
+// cache disables caching, the default.
+func SetMetadataCache(cache MetadataCache, ttl time.Duration) {
+	metadataCache.Lock()
+	defer metadataCache.Unlock()
+	metadataCache.cache, metadataCache.ttl = cache, ttl
+}
+
+// getMetadata sends a GET request for metadata and decodes the JSON response
+// into out, using the metadata cache if set
+func getMetadata(ctx context.Context, client *http.Client, url string, headers map[string]string, out interface{}) error {
+	req, err := newJSONRequest(ctx, http.MethodGet, url, headers, nil)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := cacheMetadata(headers)(req, func(req *http.Request) (*http.Response, error) {
+		return compressionMiddleware(req, client.Do)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: string(body)}
+	}
+	return decodeMetadata(body, out)
+}
+
+// cacheMetadata returns a middleware serving GET requests from the metadata
+// cache if set. Responses are cached by URL and credentials, the headers of
+// the request are not used as they may change on every request, e.g. signed
+// tokens.
+func cacheMetadata(credentials map[string]string) func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	return func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		metadataCache.RLock()
+		cache, ttl := metadataCache.cache, metadataCache.ttl
+		metadataCache.RUnlock()
+		if cache == nil || req.Method != http.MethodGet {
+			return next(req)
+		}
+
+		key := metadataKey(req.URL.String(), credentials)
+		cached, ok := cache.Get(key)
+		if ok && time.Now().Before(cached.Expires) {
+			return cachedMetadataResponse(req, cached.Body), nil
+		}
+		if ok && cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if ok && cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+		resp, err := next(req)
+		if err != nil {
+			return nil, err
+		}
+		if ok && resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			if maxAge, store := cacheMaxAge(resp.Header, ttl); store {
+				cached.Expires = time.Now().Add(maxAge)
+				cache.Set(key, cached)
+			}
+			return cachedMetadataResponse(req, cached.Body), nil
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if maxAge, store := cacheMaxAge(resp.Header, ttl); store {
+			cache.Set(key, &CachedResponse{
+				Body:         body,
+				ETag:         resp.Header.Get("ETag"),
+				LastModified: resp.Header.Get("Last-Modified"),
+				Expires:      time.Now().Add(maxAge),
+			})
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+}
+
+// cachedMetadataResponse returns a response to req with a cached body
+func cachedMetadataResponse(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func decodeMetadata(body []byte, out interface{}) error {
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
+
+// metadataKey identifies a request by URL and a hash of its headers, so
+// responses for different API keys are not shared
+func metadataKey(url string, headers map[string]string) string {
+	lines := make([]string, 0, len(headers))
+	for name, value := range headers {
+		lines = append(lines, strings.ToLower(name)+": "+value+"\n")
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, line := range lines {
+		io.WriteString(h, line)
+	}
+	return url + "#" + hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// cacheMaxAge returns how long a response may be cached and whether it may
+// be stored at all, from its Cache-Control header
+func cacheMaxAge(header http.Header, ttl time.Duration) (time.Duration, bool) {
+	maxAge := ttl
+	var noCache bool
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			// stored, but revalidated every time
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	return maxAge, true
+}
+
+// MemoryMetadataCache is a MetadataCache in memory
+type MemoryMetadataCache struct {
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+}
+
+// NewMemoryMetadataCache creates a MemoryMetadataCache
+func NewMemoryMetadataCache() *MemoryMetadataCache {
+	return &MemoryMetadataCache{entries: make(map[string]*CachedResponse)}
+}
+
+func (c *MemoryMetadataCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	copied := *resp
+	return &copied, true
+}
+
+func (c *MemoryMetadataCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copied := *resp
+	c.entries[key] = &copied
+}
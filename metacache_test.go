@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetadataCache(t *testing.T) {
+	var requests, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"data":[{"id":"model-%s"}]}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	cache := NewMemoryMetadataCache()
+	SetMetadataCache(cache, time.Hour)
+	defer SetMetadataCache(nil, 0)
+	ctx := context.Background()
+
+	nim := NewNIM(server.URL, "", "m", 100, 0, false)
+	for i := 0; i < 3; i++ {
+		models, err := nim.ListModels(ctx)
+		if err != nil || len(models) != 1 || models[0].ID != "model-Bearer not-used" {
+			t.Fatalf("unexpected models %v, %v", models, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected a single request, got %d", requests)
+	}
+
+	// Responses for other API keys are not shared
+	keyed := NewNIM(server.URL, "key", "m", 100, 0, false)
+	if models, _ := keyed.ListModels(ctx); len(models) != 1 || models[0].ID != "model-Bearer key" || requests != 2 {
+		t.Errorf("unexpected models %v after %d requests", models, requests)
+	}
+
+	// Stale responses are revalidated
+	for _, entry := range cache.entries {
+		entry.Expires = time.Now()
+	}
+	models, err := nim.ListModels(ctx)
+	if err != nil || len(models) != 1 || models[0].ID != "model-Bearer not-used" || notModified != 1 {
+		t.Errorf("unexpected models %v, %v, %d not modified", models, err, notModified)
+	}
+
+	header := http.Header{"Cache-Control": {"public, max-age=60"}}
+	if maxAge, store := cacheMaxAge(header, time.Hour); maxAge != time.Minute || !store {
+		t.Errorf("unexpected max age %v, %v", maxAge, store)
+	}
+	if _, store := cacheMaxAge(http.Header{"Cache-Control": {"no-store"}}, time.Hour); store {
+		t.Error("expected no-store responses not to be stored")
+	}
+}
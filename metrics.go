@@ -0,0 +1,255 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names reported to Metrics
+const (
+	// MetricRequests counts requests by model and status ("ok" or "error")
+	MetricRequests = "ai_requests_total"
+	// MetricRequestDuration observes request durations in seconds by model
+	MetricRequestDuration = "ai_request_duration_seconds"
+	// MetricTokens counts estimated tokens by model and direction ("input" or "output")
+	MetricTokens = "ai_tokens_total"
+	// MetricCacheRequests counts cache lookups by cache and result ("hit" or "miss")
+	MetricCacheRequests = "ai_cache_requests_total"
+	// MetricRouterDecisions counts the arms tried by a router, by router, arm
+	// (the model) and outcome ("success" or "error")
+	MetricRouterDecisions = "ai_router_decisions_total"
+	// MetricQueueDepth is the number of requests waiting in a queue, by queue
+	MetricQueueDepth = "ai_queue_depth"
+	// MetricQueueEvents counts queue events by queue and event ("throttled",
+	// "rejected" or "timeout")
+	MetricQueueEvents = "ai_queue_events_total"
+	// MetricDegradedResponses counts degraded responses returned by routers
+	// when every model failed, by router
+	MetricDegradedResponses = "ai_degraded_responses_total"
+)
+
+// Metrics receives the instrumentation of MetricsLLM and of the subsystems
+// given one with SetMetrics. Implementations must be safe for concurrent use
+// and fast, as they may be called while locks are held. Adapting it to
+// Prometheus or OpenTelemetry instruments takes a few lines, or use
+// PrometheusMetrics.
+type Metrics interface {
+	// AddCounter adds delta to a counter
+	AddCounter(name string, delta float64, labels map[string]string)
+	// SetGauge sets the current value of a gauge
+	SetGauge(name string, value float64, labels map[string]string)
+	// Observe records a value in a histogram
+	Observe(name string, value float64, labels map[string]string)
+}
+
+// MetricsLLM reports request counts, durations and token estimates of llm
+type MetricsLLM struct {
+	LLM
+	metrics Metrics
+}
+
+// NewMetricsLLM creates a MetricsLLM
+func NewMetricsLLM(llm LLM, metrics Metrics) *MetricsLLM {
+	return &MetricsLLM{LLM: llm, metrics: metrics}
+}
+
+func (m *MetricsLLM) do(input string, fn func() (string, error)) (string, error) {
+	start := time.Now()
+	res, err := fn()
+	// Read after the call, routers report the model that answered
+	model := m.LLM.GetModel()
+	labels := map[string]string{"model": model}
+	m.metrics.Observe(MetricRequestDuration, time.Since(start).Seconds(), labels)
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.metrics.AddCounter(MetricRequests, 1, map[string]string{"model": model, "status": status})
+	m.metrics.AddCounter(MetricTokens, float64(CountTokens(model, input)), map[string]string{"model": model, "direction": "input"})
+	if err == nil {
+		m.metrics.AddCounter(MetricTokens, float64(CountTokens(model, res)), map[string]string{"model": model, "direction": "output"})
+	}
+	return res, err
+}
+
+func (m *MetricsLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return m.do(systemPrompt+prompt, func() (string, error) {
+		return m.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (m *MetricsLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	_, err := m.do(systemPrompt+prompt, func() (string, error) {
+		var out strings.Builder
+		err := consumeStream(ctx, m.LLM, systemPrompt, prompt, func(chunk string) error {
+			out.WriteString(chunk)
+			select {
+			case resultCh <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		return out.String(), err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (m *MetricsLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return m.do(prompt, func() (string, error) {
+		return m.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+	})
+}
+
+func (m *MetricsLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return m.do(prompt, func() (string, error) {
+		return m.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+	})
+}
+
+func (m *MetricsLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	var input strings.Builder
+	for _, msg := range messages {
+		input.WriteString(msg.Content)
+	}
+	return m.do(input.String(), func() (string, error) {
+		return m.LLM.GenerateWithMessages(ctx, messages)
+	})
+}
+
+// PrometheusMetrics collects metrics in memory and serves them in the
+// Prometheus text format, without depending on the Prometheus client.
+// Observed values are exported as summaries (sum and count).
+//
+//	metrics := ai.NewPrometheusMetrics()
+//	http.Handle("/metrics", metrics)
+type PrometheusMetrics struct {
+	mu     sync.Mutex
+	series map[string]*promSeries
+}
+
+type promSeries struct {
+	name   string
+	kind   string
+	labels string
+	value  float64
+	count  uint64
+}
+
+// NewPrometheusMetrics creates a PrometheusMetrics
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{series: make(map[string]*promSeries)}
+}
+
+func (p *PrometheusMetrics) get(name, kind string, labels map[string]string) *promSeries {
+	formatted := formatPromLabels(labels)
+	key := name + formatted
+	s, ok := p.series[key]
+	if !ok {
+		s = &promSeries{name: name, kind: kind, labels: formatted}
+		p.series[key] = s
+	}
+	return s
+}
+
+func (p *PrometheusMetrics) AddCounter(name string, delta float64, labels map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.get(name, "counter", labels).value += delta
+}
+
+func (p *PrometheusMetrics) SetGauge(name string, value float64, labels map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.get(name, "gauge", labels).value = value
+}
+
+func (p *PrometheusMetrics) Observe(name string, value float64, labels map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.get(name, "summary", labels)
+	s.value += value
+	s.count++
+}
+
+// Value returns the current value of a counter or gauge, or the sum of an
+// observed metric, with exactly the given labels
+func (p *PrometheusMetrics) Value(name string, labels map[string]string) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.series[name+formatPromLabels(labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (p *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	series := make([]*promSeries, 0, len(p.series))
+	for _, s := range p.series {
+		series = append(series, &promSeries{name: s.name, kind: s.kind, labels: s.labels, value: s.value, count: s.count})
+	}
+	p.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].name != series[j].name {
+			return series[i].name < series[j].name
+		}
+		return series[i].labels < series[j].labels
+	})
+
+	var b strings.Builder
+	for i, s := range series {
+		if i == 0 || series[i-1].name != s.name {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", s.name, s.kind)
+		}
+		value := strconv.FormatFloat(s.value, 'g', -1, 64)
+		if s.kind == "summary" {
+			fmt.Fprintf(&b, "%s_sum%s %s\n%s_count%s %d\n", s.name, s.labels, value, s.name, s.labels, s.count)
+			continue
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", s.name, s.labels, value)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (p *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	p.WriteTo(w)
+}
+
+// formatPromLabels formats labels as {a="1",b="2"} sorted by name
+func formatPromLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	ctx := context.Background()
+
+	failing := &stubLLM{model: "primary", response: func(systemPrompt, prompt string) (string, error) {
+		return "", errors.New("down")
+	}}
+	backup := &stubLLM{model: "backup", response: func(systemPrompt, prompt string) (string, error) {
+		return "fine", nil
+	}}
+	router := NewFallbackLLM([]LLM{failing, backup}, nil)
+	router.SetMetrics(metrics, "main")
+	llm := NewMetricsLLM(router, metrics)
+	if _, err := llm.Generate(ctx, "", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumeStream(ctx, llm, "", "hello", func(string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewAttachmentCache(1 << 20)
+	cache.SetMetrics(metrics, "images")
+	cache.Base64([]byte("image"))
+	cache.Base64([]byte("image"))
+
+	for _, c := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{MetricRouterDecisions, map[string]string{"router": "main", "arm": "primary", "outcome": "error"}, 2},
+		{MetricRouterDecisions, map[string]string{"router": "main", "arm": "backup", "outcome": "success"}, 2},
+		{MetricRequests, map[string]string{"model": "backup", "status": "ok"}, 2},
+		{MetricTokens, map[string]string{"model": "backup", "direction": "input"}, 4},
+		{MetricCacheRequests, map[string]string{"cache": "images", "result": "hit"}, 1},
+		{MetricCacheRequests, map[string]string{"cache": "images", "result": "miss"}, 1},
+	} {
+		if got := metrics.Value(c.name, c.labels); got != c.want {
+			t.Errorf("%s%v = %v, want %v", c.name, c.labels, got, c.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE ai_cache_requests_total counter\n",
+		`ai_cache_requests_total{cache="images",result="hit"} 1` + "\n",
+		"# TYPE ai_request_duration_seconds summary\n",
+		`ai_request_duration_seconds_count{model="backup"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MiniMax calls the MiniMax chat completion v2 API, e.g. with model
+// "MiniMax-Text-01". Requests are authenticated with an API key and the
+// group id of the account.
+// https://www.minimax.io/platform/document/ChatCompletion%20v2
+type MiniMax struct {
+	baseURL     string
+	apiKey      string
+	groupID     string
+	model       string
+	maxTokens   int
+	temperature float32
+	httpClient  *http.Client
+}
+
+// MiniMaxError is returned when the API reports an error in base_resp,
+// which it does with status 200
+type MiniMaxError struct {
+	Code    int
+	Message string
+}
+
+func (e *MiniMaxError) Error() string {
+	return fmt.Sprintf("minimax error %d: %s", e.Code, e.Message)
+}
+
+// Retryable reports whether the error is a timeout, rate limit or internal error
+func (e *MiniMaxError) Retryable() bool {
+	return e.Code == 1000 || e.Code == 1001 || e.Code == 1002 || e.Code == 1013
+}
+
+// NewMiniMax creates a client for the international API, use SetBaseURL for
+// the mainland China API at https://api.minimax.chat/v1
+func NewMiniMax(apiKey, groupID, model string, maxTokens int, temperature float32) *MiniMax {
+	return &MiniMax{
+		baseURL:     "https://api.minimaxi.chat/v1",
+		apiKey:      apiKey,
+		groupID:     groupID,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		httpClient:  http.DefaultClient,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (m *MiniMax) SetHTTPClient(client *http.Client) {
+	m.httpClient = client
+}
+
+// SetBaseURL changes the API base URL
+func (m *MiniMax) SetBaseURL(baseURL string) {
+	m.baseURL = baseURL
+}
+
+type miniMaxMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or a list of parts for messages with images
+	Content interface{} `json:"content"`
+}
+
+type miniMaxPart struct {
+	Type     string               `json:"type"`
+	Text     string               `json:"text,omitempty"`
+	ImageURL *miniMaxPartImageURL `json:"image_url,omitempty"`
+}
+
+type miniMaxPartImageURL struct {
+	URL string `json:"url"`
+}
+
+// miniMaxResponse is a response or a stream chunk. Stream chunks carry
+// deltas, the last one repeats the whole output in message instead.
+type miniMaxResponse struct {
+	Object  string `json:"object"`
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	BaseResp *struct {
+		StatusCode int    `json:"status_code"`
+		StatusMsg  string `json:"status_msg"`
+	} `json:"base_resp"`
+}
+
+func (r *miniMaxResponse) err() error {
+	if r.BaseResp != nil && r.BaseResp.StatusCode != 0 {
+		return &MiniMaxError{Code: r.BaseResp.StatusCode, Message: r.BaseResp.StatusMsg}
+	}
+	return nil
+}
+
+func (m *MiniMax) url() string {
+	u := m.baseURL + "/text/chatcompletion_v2"
+	if m.groupID != "" {
+		u += "?GroupId=" + url.QueryEscape(m.groupID)
+	}
+	return u
+}
+
+func (m *MiniMax) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + m.apiKey}
+}
+
+func (m *MiniMax) request(ctx context.Context, messages []Message, stream bool) (map[string]interface{}, error) {
+	var msgs []miniMaxMessage
+	for _, msg := range messages {
+		msg = msg.flat()
+		role := msg.Role
+		if role == "" {
+			role = RoleUser
+		}
+		if msg.Image == nil {
+			msgs = append(msgs, miniMaxMessage{Role: string(role), Content: msg.Content})
+			continue
+		}
+
+		data, err := io.ReadAll(msg.Image)
+		if err != nil {
+			return nil, err
+		}
+		parts := []miniMaxPart{{
+			Type:     "image_url",
+			ImageURL: &miniMaxPartImageURL{URL: "data:" + string(msg.MimeType) + ";base64," + encodeBase64(ctx, data)},
+		}}
+		if msg.Content != "" {
+			parts = append(parts, miniMaxPart{Type: "text", Text: msg.Content})
+		}
+		msgs = append(msgs, miniMaxMessage{Role: string(role), Content: parts})
+	}
+	body := map[string]interface{}{
+		"model":      requestModel(ctx, m.model),
+		"messages":   msgs,
+		"max_tokens": m.maxTokens,
+		"stream":     stream,
+	}
+	if m.temperature > 0 {
+		body["temperature"] = m.temperature
+	}
+	if IsDeterministic(ctx) {
+		// temperature must be positive, a tiny top_p makes sampling greedy
+		delete(body, "temperature")
+		body["top_p"] = 0.01
+	}
+	return body, nil
+}
+
+func (m *MiniMax) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return m.GenerateWithMessages(ctx, promptMessages(systemPrompt, prompt))
+}
+
+func (m *MiniMax) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	m.GenerateWithMessagesStream(ctx, promptMessages(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (m *MiniMax) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	body, err := m.request(ctx, messages, true)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	resp, err := sendJSON(ctx, m.httpClient, http.MethodPost, m.url(), m.headers(), body)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// errors are sent as a plain JSON body instead of an event stream
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var res miniMaxResponse
+		if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+			sendErr(fmt.Errorf("failed to decode response: %v", err))
+			return
+		}
+		if err := res.err(); err != nil {
+			sendErr(err)
+			return
+		}
+		sendErr(fmt.Errorf("unexpected non-stream response"))
+		return
+	}
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		var chunk miniMaxResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if err := chunk.err(); err != nil {
+			return err
+		}
+		// the final chunk repeats the output
+		if chunk.Object == "chat.completion" {
+			return io.EOF
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		select {
+		case resultCh <- chunk.Choices[0].Delta.Content:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (m *MiniMax) GetModel() string {
+	return m.model
+}
+
+func (m *MiniMax) Close() error {
+	return nil
+}
+
+func (m *MiniMax) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return m.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
+}
+
+// GenerateWithImages requires a vision model, e.g. "MiniMax-VL-01"
+func (m *MiniMax) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if len(images) != len(mimeTypes) {
+		return "", fmt.Errorf("number of images and mime types must match")
+	}
+	var msgs []Message
+	for i, image := range images {
+		msgs = append(msgs, Message{Role: RoleUser, Image: image, MimeType: mimeTypes[i]})
+	}
+	msgs = append(msgs, Message{Role: RoleUser, Content: prompt})
+	return m.GenerateWithMessages(ctx, msgs)
+}
+
+func (m *MiniMax) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	body, err := m.request(ctx, messages, false)
+	if err != nil {
+		return "", err
+	}
+	var resp miniMaxResponse
+	if err := doJSON(ctx, m.httpClient, http.MethodPost, m.url(), m.headers(), body, &resp); err != nil {
+		return "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no content generated")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
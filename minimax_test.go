@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiniMax(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/text/chatcompletion_v2" || r.URL.Query().Get("GroupId") != "g1" || r.Header.Get("Authorization") != "Bearer key" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case body["stream"] == true:
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"he\"}}],\"object\":\"chat.completion.chunk\"}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"llo\"}}],\"object\":\"chat.completion.chunk\"}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"finish_reason\":\"stop\",\"message\":{\"role\":\"assistant\",\"content\":\"hello\"}}],\"object\":\"chat.completion\",\"base_resp\":{\"status_code\":0,\"status_msg\":\"\"}}\n\n")
+		case body["max_tokens"] == float64(1):
+			io.WriteString(w, `{"base_resp":{"status_code":1002,"status_msg":"rate limit exceeded"}}`)
+		default:
+			io.WriteString(w, `{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}],"base_resp":{"status_code":0,"status_msg":"success"}}`)
+		}
+	}))
+	defer server.Close()
+
+	llm := NewMiniMax("key", "g1", "MiniMax-Text-01", 100, 0.5)
+	llm.SetBaseURL(server.URL)
+	res, err := llm.Generate(context.Background(), "Be brief", "hi")
+	if err != nil || res != "hello" {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+
+	var streamed string
+	err = consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		streamed += chunk
+		return nil
+	})
+	if err != nil || streamed != "hello" {
+		t.Fatalf("unexpected stream %q, %v", streamed, err)
+	}
+
+	limited := NewMiniMax("key", "g1", "MiniMax-Text-01", 1, 0.5)
+	limited.SetBaseURL(server.URL)
+	_, err = limited.Generate(context.Background(), "", "hi")
+	var miniMaxErr *MiniMaxError
+	if !errors.As(err, &miniMaxErr) || miniMaxErr.Code != 1002 || !IsRetryable(err) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrMockDisconnected is returned by MockLLM streams that are cut off by
+// FailureProfile.DisconnectAfter
+var ErrMockDisconnected = errors.New("mock: connection lost mid-stream")
+
+// FailureProfile makes MockLLM fail like real providers do, to test fallback
+// and retry setups. Random failures use Seed, so runs are reproducible.
+type FailureProfile struct {
+	// RateLimitAfter fails every call after this many with status 429, 0 disables
+	RateLimitAfter int
+	// RetryAfter is sent as Retry-After with rate limit errors
+	RetryAfter time.Duration
+	// ServerErrorRate is the share of calls (0 to 1) that fail with status 503
+	ServerErrorRate float64
+	Seed            int64
+
+	// Latency delays every response
+	Latency time.Duration
+	// ChunkSize splits streams into chunks of this many runes, 0 sends the
+	// response at once
+	ChunkSize int
+	// ChunkDelay is the pause between stream chunks
+	ChunkDelay time.Duration
+	// DisconnectAfter fails streams with ErrMockDisconnected after this many
+	// chunks, 0 disables
+	DisconnectAfter int
+}
+
+// MockLLM is an offline LLM for tests
+type MockLLM struct {
+	model   string
+	respond func(systemPrompt, prompt string) (string, error)
+
+	mu      sync.Mutex
+	profile FailureProfile
+	rand    *rand.Rand
+	calls   int
+}
+
+// NewMockLLM creates a MockLLM that always answers with response
+func NewMockLLM(model, response string) *MockLLM {
+	return NewMockLLMFunc(model, func(systemPrompt, prompt string) (string, error) {
+		return response, nil
+	})
+}
+
+// NewMockLLMFunc creates a MockLLM that answers with respond. Requests with
+// messages pass the content of the last message as prompt.
+func NewMockLLMFunc(model string, respond func(systemPrompt, prompt string) (string, error)) *MockLLM {
+	return &MockLLM{model: model, respond: respond, rand: rand.New(rand.NewSource(0))}
+}
+
+// SetFailureProfile sets the failures to inject and resets the call count
+func (m *MockLLM) SetFailureProfile(profile FailureProfile) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.profile = profile
+	m.rand = rand.New(rand.NewSource(profile.Seed))
+	m.calls = 0
+}
+
+// Calls returns the number of calls made
+func (m *MockLLM) Calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// call counts a call and returns the injected failure, if any
+func (m *MockLLM) call(ctx context.Context) (FailureProfile, error) {
+	m.mu.Lock()
+	m.calls++
+	profile := m.profile
+	var err error
+	switch {
+	case profile.RateLimitAfter > 0 && m.calls > profile.RateLimitAfter:
+		header := http.Header{}
+		if profile.RetryAfter > 0 {
+			header.Set("Retry-After", strconv.FormatFloat(profile.RetryAfter.Seconds(), 'f', -1, 64))
+		}
+		err = &HTTPError{StatusCode: http.StatusTooManyRequests, Header: header, Body: "mock: rate limit exceeded"}
+	case profile.ServerErrorRate > 0 && m.rand.Float64() < profile.ServerErrorRate:
+		err = &HTTPError{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: "mock: service unavailable"}
+	}
+	m.mu.Unlock()
+
+	if profile.Latency > 0 {
+		select {
+		case <-time.After(profile.Latency):
+		case <-ctx.Done():
+			return profile, ctx.Err()
+		}
+	}
+	return profile, err
+}
+
+func (m *MockLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	if _, err := m.call(ctx); err != nil {
+		return "", err
+	}
+	return m.respond(systemPrompt, prompt)
+}
+
+func (m *MockLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	profile, err := m.call(ctx)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	res, err := m.respond(systemPrompt, prompt)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+
+	chunks := []string{res}
+	if runes := []rune(res); profile.ChunkSize > 0 && len(runes) > profile.ChunkSize {
+		chunks = nil
+		for i := 0; i < len(runes); i += profile.ChunkSize {
+			chunks = append(chunks, string(runes[i:min(i+profile.ChunkSize, len(runes))]))
+		}
+	}
+	for i, chunk := range chunks {
+		if profile.DisconnectAfter > 0 && i >= profile.DisconnectAfter {
+			sendErr(ErrMockDisconnected)
+			return
+		}
+		if i > 0 && profile.ChunkDelay > 0 {
+			select {
+			case <-time.After(profile.ChunkDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case resultCh <- chunk:
+		case <-ctx.Done():
+			return
+		}
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+// GenerateWithMessagesStream streams like GenerateStream, with the content of
+// the last message as prompt
+func (m *MockLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	var prompt string
+	if len(messages) > 0 {
+		prompt = messages[len(messages)-1].Content
+	}
+	m.GenerateStream(ctx, "", prompt, resultCh, doneCh, errCh)
+}
+
+func (m *MockLLM) GetModel() string {
+	return m.model
+}
+
+func (m *MockLLM) Close() error {
+	return nil
+}
+
+func (m *MockLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return m.Generate(ctx, "", prompt)
+}
+
+func (m *MockLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return m.Generate(ctx, "", prompt)
+}
+
+func (m *MockLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	var prompt string
+	if len(messages) > 0 {
+		prompt = messages[len(messages)-1].Content
+	}
+	return m.Generate(ctx, "", prompt)
+}
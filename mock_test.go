@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockLLMFailures(t *testing.T) {
+	ctx := context.Background()
+	llm := NewMockLLM("mock", "hello world")
+	llm.SetFailureProfile(FailureProfile{RateLimitAfter: 2, RetryAfter: time.Second})
+	for i := 0; i < 2; i++ {
+		if res, err := llm.Generate(ctx, "", "hi"); err != nil || res != "hello world" {
+			t.Fatalf("unexpected result %q, %v", res, err)
+		}
+	}
+	_, err := llm.Generate(ctx, "", "hi")
+	if limited, wait := rateLimited(err); !limited || wait != time.Second || !IsRetryable(err) {
+		t.Fatalf("expected rate limit error, got %v", err)
+	}
+
+	failures := func() string {
+		llm.SetFailureProfile(FailureProfile{ServerErrorRate: 0.5, Seed: 7})
+		var pattern strings.Builder
+		for i := 0; i < 20; i++ {
+			_, err := llm.Generate(ctx, "", "hi")
+			var httpErr *HTTPError
+			if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusServiceUnavailable {
+				pattern.WriteString("x")
+			} else {
+				pattern.WriteString(".")
+			}
+		}
+		return pattern.String()
+	}
+	first := failures()
+	if !strings.Contains(first, "x") || !strings.Contains(first, ".") || failures() != first {
+		t.Fatalf("random failures should be reproducible, got %s", first)
+	}
+
+	llm.SetFailureProfile(FailureProfile{ChunkSize: 3, ChunkDelay: time.Millisecond, DisconnectAfter: 2})
+	var chunks []string
+	err = consumeStream(ctx, llm, "", "hi", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if !errors.Is(err, ErrMockDisconnected) || strings.Join(chunks, "|") != "hel|lo " {
+		t.Fatalf("expected disconnect after 2 chunks, got %q, %v", chunks, err)
+	}
+
+	backup := NewMockLLM("backup", "backup answer")
+	var out string
+	err = consumeStream(ctx, NewFallbackLLM([]LLM{llm, backup}, nil), "", "hi", func(chunk string) error {
+		if chunk == "[CLEAR]" {
+			out = ""
+		} else {
+			out += chunk
+		}
+		return nil
+	})
+	if err != nil || out != "backup answer" {
+		t.Fatalf("fallback should recover from the disconnect, got %q, %v", out, err)
+	}
+}
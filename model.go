@@ -3,6 +3,7 @@ package ai
 import (
 	"context"
 	"io"
+	"strings"
 )
 
 type MimeType string
@@ -13,6 +14,15 @@ const (
 	MimeTypeWEBP MimeType = "image/webp"
 	MimeTypeHEIC MimeType = "image/heic"
 	MimeTypeHEIF MimeType = "image/heif"
+
+	MimeTypeWAV  MimeType = "audio/wav"
+	MimeTypeMP3  MimeType = "audio/mpeg"
+	MimeTypeFLAC MimeType = "audio/flac"
+	MimeTypeOpus MimeType = "audio/opus"
+	MimeTypeOGG  MimeType = "audio/ogg"
+	MimeTypePCM  MimeType = "audio/pcm"
+
+	MimeTypePDF MimeType = "application/pdf"
 )
 
 type Role string
@@ -21,14 +31,53 @@ const (
 	RoleSystem    Role = "system"
 	RoleUser      Role = "user"
 	RoleAssistant Role = "assistant"
+	// RoleTool messages hold the result of a tool call, see ToolResult.Message
+	RoleTool Role = "tool"
 )
 
-// LLMChat defines the interface for chat (multi-message)
+// Message is a message of a conversation. Its content is either Parts, or
+// the Content, Image, Audio, Document and File fields, which are sent as
+// parts in this order: file, document, image, audio and text (see
+// ContentParts). OpenAI, Claude and Gemini keep the order of Parts, other
+// providers get the text parts joined and the first image.
 type Message struct {
 	Role     Role
 	Image    io.Reader // optional
 	MimeType MimeType  // optional
 	Content  string    // optional
+
+	// Parts are interleaved text, images, audio, documents and files
+	// (optional), e.g. to refer to images by their position
+	Parts []ContentPart
+
+	// Audio is a recording sent to models with audio input, such as Gemini
+	// and OpenAI's gpt-4o-audio-preview (optional)
+	Audio         io.Reader
+	AudioMimeType MimeType
+
+	// Document is a file such as a PDF sent to models that read documents,
+	// such as Claude and Gemini (optional)
+	Document         io.Reader
+	DocumentMimeType MimeType
+
+	// CacheControl caches the conversation up to and including the message
+	// with Claude, e.g. long documents or few-shot examples reused across
+	// requests. Claude allows 4 cache breakpoints per request, counting the
+	// ones of the client's cachePrompt. Other providers ignore it.
+	CacheControl bool
+
+	// File is a file uploaded with a FileStore, referenced instead of sent
+	// with the request (optional)
+	File *ProviderFile
+
+	// ToolCalls are the tool calls requested in an assistant message, see
+	// Response.Message (optional)
+	ToolCalls []ToolCall
+	// ToolCallID, ToolName and IsError describe the call answered by a
+	// RoleTool message
+	ToolCallID string
+	ToolName   string
+	IsError    bool
 }
 
 // LLM defines the interface for language model generators
@@ -48,5 +97,67 @@ type LLM interface {
 	// GenerateWithImages generates text from multiple images
 	GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error)
 
+	// GenerateWithMessages generates a reply to a conversation. A final
+	// assistant message is a prefill the reply starts with, e.g. "{" to force
+	// JSON: Claude continues it natively, OpenAI and Gemini are instructed to
+	// start with it. The reply includes the prefill.
 	GenerateWithMessages(ctx context.Context, messages []Message) (string, error)
+
+	// Close releases the resources of the client, such as gRPC connections.
+	// Wrappers close the clients they wrap.
+	Close() error
+}
+
+// messagesToPrompt flattens text messages into a system prompt and a chat
+// transcript, for backends that only accept a single prompt string. Tool
+// calls and results are written as text.
+func messagesToPrompt(messages []Message) (systemPrompt, prompt string) {
+	var system []string
+	var chat []Message
+	for _, msg := range messages {
+		msg = msg.flat()
+		if msg.Content == "" && len(msg.ToolCalls) == 0 {
+			continue
+		}
+		if msg.Role == RoleSystem {
+			system = append(system, msg.Content)
+		} else {
+			chat = append(chat, msg)
+		}
+	}
+	systemPrompt = strings.Join(system, "\n\n")
+
+	// A single user message is sent as is
+	if len(chat) == 1 && chat[0].Role == RoleUser {
+		return systemPrompt, chat[0].Content
+	}
+
+	var sb strings.Builder
+	for _, msg := range chat {
+		switch {
+		case msg.Role == RoleAssistant && len(msg.ToolCalls) > 0:
+			sb.WriteString("Assistant: " + msg.Content)
+			for _, call := range msg.ToolCalls {
+				sb.WriteString("\n[call " + call.Name + " " + string(call.Arguments) + "]")
+			}
+			sb.WriteString("\n\n")
+		case msg.Role == RoleAssistant:
+			sb.WriteString("Assistant: " + msg.Content + "\n\n")
+		case msg.Role == RoleTool:
+			sb.WriteString("Tool result (" + msg.ToolName + "): " + msg.Content + "\n\n")
+		default:
+			sb.WriteString("User: " + msg.Content + "\n\n")
+		}
+	}
+	sb.WriteString("Assistant:")
+	return systemPrompt, sb.String()
+}
+
+// promptMessages converts a system prompt and a prompt to messages
+func promptMessages(systemPrompt, prompt string) []Message {
+	var messages []Message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: systemPrompt})
+	}
+	return append(messages, Message{Role: RoleUser, Content: prompt})
 }
@@ -0,0 +1,27 @@
+package ai
+
+import "context"
+
+type modelKey struct{}
+
+// WithModel returns a context whose requests use model instead of the
+// client's, e.g. to escalate a hard question to a bigger model of the same
+// provider without creating a new client:
+//
+//	res, err := llm.Generate(ai.WithModel(ctx, "gpt-4o"), systemPrompt, prompt)
+//
+// A FallbackLLM applies the override to its primary model only, the models it
+// falls back to use their own. Clients bound to a single model endpoint
+// (llama.cpp and Hugging Face text generation) ignore it, and GetModel still
+// returns the client's model.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey{}, model)
+}
+
+// requestModel returns the model of ctx, or the client's if ctx has none
+func requestModel(ctx context.Context, client string) string {
+	if model, ok := ctx.Value(modelKey{}).(string); ok && model != "" {
+		return model
+	}
+	return client
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithModel(t *testing.T) {
+	var model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		model, _ = body["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/chat/completions":
+			fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		case r.URL.Path == "/messages":
+			fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+		default:
+			model = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/models/"), ":generateContent")
+			fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o-mini", 100, 0, false)
+	anthropic := NewAnthropic("key", "claude-3-5-haiku-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+	for _, tc := range []struct {
+		llm      LLM
+		override string
+	}{
+		{openAI, "gpt-4o"},
+		{anthropic, "claude-3-5-sonnet-latest"},
+	} {
+		if _, err := tc.llm.GenerateWithMessages(context.Background(), messages); err != nil {
+			t.Fatal(err)
+		}
+		if model != tc.llm.GetModel() {
+			t.Errorf("expected the client model %s, got %s", tc.llm.GetModel(), model)
+		}
+		if _, err := tc.llm.GenerateWithMessages(WithModel(context.Background(), tc.override), messages); err != nil {
+			t.Fatal(err)
+		}
+		if model != tc.override || tc.llm.GetModel() == tc.override {
+			t.Errorf("expected the request model %s, got %s", tc.override, model)
+		}
+	}
+
+	if _, err := gemini.GenerateResponse(WithModel(context.Background(), "gemini-2.5-pro"), messages); err != nil {
+		t.Fatal(err)
+	}
+	if model != "gemini-2.5-pro" {
+		t.Errorf("expected the request model in the URL, got %s", model)
+	}
+}
+
+func TestWithModelStatus(t *testing.T) {
+	checker := NewModelStatusChecker([]ModelStatus{{Model: "old", State: ModelRetired}})
+	llm := NewModelStatusLLM(&stubLLM{model: "new", response: func(string, string) (string, error) { return "ok", nil }}, checker)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	var statusErr *ModelStatusError
+	if _, err := llm.Generate(WithModel(context.Background(), "old"), "", "hi"); !errors.As(err, &statusErr) {
+		t.Errorf("expected the requested model to be checked, got %v", err)
+	}
+}
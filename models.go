@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// ModelInfo is the metadata of a model available from a provider
+type ModelInfo struct {
+	// ID is the model name used to create clients
+	ID          string
+	DisplayName string
+	Description string
+	// OwnedBy is the organization that owns the model (OpenAI only)
+	OwnedBy   string
+	CreatedAt time.Time
+	// InputTokenLimit and OutputTokenLimit are 0 if the provider does not
+	// report them (Gemini only)
+	InputTokenLimit  int
+	OutputTokenLimit int
+}
+
+// ModelLister is implemented by clients whose provider lists its models
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// ListModels returns the models available from the provider of llm, e.g. to
+// build a model picker. Responses are cached with SetMetadataCache.
+func ListModels(ctx context.Context, llm LLM) ([]ModelInfo, error) {
+	if l, ok := llm.(ModelLister); ok {
+		return l.ListModels(ctx)
+	}
+	return nil, fmt.Errorf("%s cannot list models", llm.GetModel())
+}
+
+// ListModels returns the models of the /models endpoint, which most
+// OpenAI-compatible servers implement, e.g. the models served by a NIM
+// endpoint
+func (o *OpenAI) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	cache := cacheMetadata(map[string]string{"Authorization": "Bearer " + o.apiKey})
+	page, err := o.client.Models.List(ctx, option.WithMiddleware(cache))
+	if err != nil {
+		return nil, err
+	}
+	models := make([]ModelInfo, len(page.Data))
+	for i, m := range page.Data {
+		models[i] = ModelInfo{ID: m.ID, OwnedBy: m.OwnedBy}
+		if m.Created != 0 {
+			models[i].CreatedAt = time.Unix(m.Created, 0)
+		}
+	}
+	return models, nil
+}
+
+// ListModels returns the Claude models, newest first. Clients on Bedrock
+// cannot list models.
+func (a *Anthropic) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if a.bedrock != nil {
+		return nil, fmt.Errorf("%s on Bedrock cannot list models", a.model)
+	}
+	headers := map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+	var models []ModelInfo
+	after := ""
+	for {
+		query := url.Values{"limit": {"1000"}}
+		if after != "" {
+			query.Set("after_id", after)
+		}
+		var resp struct {
+			Data []struct {
+				ID          string    `json:"id"`
+				DisplayName string    `json:"display_name"`
+				CreatedAt   time.Time `json:"created_at"`
+			} `json:"data"`
+			HasMore bool   `json:"has_more"`
+			LastID  string `json:"last_id"`
+		}
+		if err := getMetadata(ctx, a.httpClient, a.baseURL+"/models?"+query.Encode(), headers, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Data {
+			models = append(models, ModelInfo{ID: m.ID, DisplayName: m.DisplayName, CreatedAt: m.CreatedAt})
+		}
+		if !resp.HasMore || resp.LastID == "" {
+			return models, nil
+		}
+		after = resp.LastID
+	}
+}
+
+// ListModels returns the Gemini models that generate content
+func (g *GoogleSimpleLLM) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	baseURL := g.baseURL
+	if baseURL == "" {
+		baseURL = geminiAPIBaseURL
+	}
+	headers := map[string]string{"x-goog-api-key": g.apiKey}
+	var models []ModelInfo
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		var resp struct {
+			Models []struct {
+				Name                       string   `json:"name"`
+				DisplayName                string   `json:"displayName"`
+				Description                string   `json:"description"`
+				InputTokenLimit            int      `json:"inputTokenLimit"`
+				OutputTokenLimit           int      `json:"outputTokenLimit"`
+				SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := getMetadata(ctx, nil, baseURL+"models?"+query.Encode(), headers, &resp); err != nil {
+			return nil, err
+		}
+		for _, m := range resp.Models {
+			generates := false
+			for _, method := range m.SupportedGenerationMethods {
+				generates = generates || method == "generateContent"
+			}
+			if !generates {
+				continue
+			}
+			models = append(models, ModelInfo{
+				ID:               strings.TrimPrefix(m.Name, "models/"),
+				DisplayName:      m.DisplayName,
+				Description:      m.Description,
+				InputTokenLimit:  m.InputTokenLimit,
+				OutputTokenLimit: m.OutputTokenLimit,
+			})
+		}
+		if resp.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = resp.NextPageToken
+	}
+}
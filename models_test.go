@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/models" && r.Header.Get("Authorization") == "Bearer key":
+			fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-4o","object":"model","created":1715367049,"owned_by":"system"}]}`)
+		case r.URL.Path == "/v1/models" && r.Header.Get("x-api-key") == "key" && r.Header.Get("anthropic-version") != "":
+			if r.URL.Query().Get("after_id") == "" {
+				fmt.Fprint(w, `{"data":[{"type":"model","id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5","created_at":"2025-09-29T00:00:00Z"}],"has_more":true,"last_id":"claude-sonnet-4-5"}`)
+			} else {
+				fmt.Fprint(w, `{"data":[{"type":"model","id":"claude-3-5-haiku-latest","display_name":"Claude Haiku 3.5","created_at":"2024-10-22T00:00:00Z"}],"has_more":false,"last_id":"claude-3-5-haiku-latest"}`)
+			}
+		case r.URL.Path == "/gemini/models" && r.Header.Get("x-goog-api-key") == "key":
+			if r.URL.Query().Get("pageToken") == "" {
+				fmt.Fprint(w, `{"models":[{"name":"models/gemini-2.0-flash","displayName":"Gemini 2.0 Flash","inputTokenLimit":1048576,"outputTokenLimit":8192,"supportedGenerationMethods":["generateContent","countTokens"]}],"nextPageToken":"next"}`)
+			} else {
+				fmt.Fprint(w, `{"models":[{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}]}`)
+			}
+		default:
+			http.Error(w, `{"error":"unexpected request"}`, http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	openAI := NewOpenAICompatible(server.URL+"/", "key", "gpt-4o", 100, 0, false)
+	models, err := ListModels(context.Background(), openAI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "gpt-4o" || models[0].OwnedBy != "system" || !models[0].CreatedAt.Equal(time.Unix(1715367049, 0)) {
+		t.Errorf("unexpected OpenAI models %+v", models)
+	}
+
+	anthropic := NewAnthropic("key", "claude-sonnet-4-5", 100, 0, false)
+	anthropic.SetBaseURL(server.URL + "/v1")
+	models, err = ListModels(context.Background(), anthropic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].DisplayName != "Claude Sonnet 4.5" || models[1].ID != "claude-3-5-haiku-latest" || models[1].CreatedAt.Year() != 2024 {
+		t.Errorf("unexpected Anthropic models %+v", models)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL + "/gemini")
+	models, err = ListModels(context.Background(), gemini)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "gemini-2.0-flash" || models[0].InputTokenLimit != 1048576 {
+		t.Errorf("unexpected Gemini models %+v", models)
+	}
+
+	if _, err := ListModels(context.Background(), NewOpenAICompatible(server.URL, "wrong", "m", 100, 0, false)); err == nil {
+		t.Error("expected error for unauthorized request")
+	}
+	if _, err := ListModels(context.Background(), &stubLLM{model: "stub"}); err == nil {
+		t.Error("expected error for a client that cannot list models")
+	}
+}
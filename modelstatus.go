@@ -0,0 +1,257 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelState is the lifecycle state of a model
+type ModelState string
+
+const (
+	ModelActive ModelState = "active"
+	// ModelDeprecated models still work but will be retired
+	ModelDeprecated ModelState = "deprecated"
+	// ModelRetired models no longer work
+	ModelRetired ModelState = "retired"
+	// ModelMaintenance models are temporarily unavailable
+	ModelMaintenance ModelState = "maintenance"
+)
+
+// ModelStatus is the state of a model during a period, e.g. a deprecation
+// announced until the retirement date, or a maintenance window
+type ModelStatus struct {
+	// Model is the model name, matched with or without a provider prefix
+	// such as "us-central1/"
+	Model string     `json:"model"`
+	State ModelState `json:"state"`
+	// Replacement is the model to use instead
+	Replacement string `json:"replacement,omitempty"`
+	Message     string `json:"message,omitempty"`
+	// Start and End bound the period the status applies to, zero values
+	// leave it open
+	Start time.Time `json:"start,omitempty"`
+	End   time.Time `json:"end,omitempty"`
+}
+
+func (s ModelStatus) active(now time.Time) bool {
+	return (s.Start.IsZero() || !now.Before(s.Start)) && (s.End.IsZero() || now.Before(s.End))
+}
+
+// ModelStatusError is returned for requests to a model that is refused by a
+// ModelStatusChecker
+type ModelStatusError struct {
+	Status ModelStatus
+}
+
+func (e *ModelStatusError) Error() string {
+	msg := fmt.Sprintf("model %s is %s", e.Status.Model, e.Status.State)
+	if e.Status.Replacement != "" {
+		msg += ", use " + e.Status.Replacement
+	}
+	if e.Status.Message != "" {
+		msg += ": " + e.Status.Message
+	}
+	return msg
+}
+
+// ModelStatusChecker knows the deprecations, retirements and maintenance
+// windows of models, from a static table and optionally a remote feed.
+// Retired models and models under maintenance are refused, deprecated
+// models are refused only if SetRefuseDeprecated is enabled and are
+// otherwise reported to the warning callback, once per model.
+//
+// Wrap a client with NewModelStatusLLM, or give the checker to a FallbackLLM
+// with SetModelStatus to route around refused models.
+type ModelStatusChecker struct {
+	mu               sync.Mutex
+	static           []ModelStatus
+	remote           []ModelStatus
+	refuseDeprecated bool
+	warn             func(ModelStatus)
+	warned           map[string]bool
+	now              func() time.Time
+}
+
+// NewModelStatusChecker creates a ModelStatusChecker with a static table
+func NewModelStatusChecker(statuses []ModelStatus) *ModelStatusChecker {
+	return &ModelStatusChecker{static: statuses, warned: make(map[string]bool), now: time.Now}
+}
+
+// SetWarningCallback sets the function called the first time a deprecated
+// model is used
+func (c *ModelStatusChecker) SetWarningCallback(fn func(ModelStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warn = fn
+}
+
+// SetRefuseDeprecated makes deprecated models refused like retired ones
+func (c *ModelStatusChecker) SetRefuseDeprecated(refuse bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refuseDeprecated = refuse
+}
+
+// LoadModelStatuses reads a JSON array of ModelStatus
+func LoadModelStatuses(r io.Reader) ([]ModelStatus, error) {
+	var statuses []ModelStatus
+	if err := json.NewDecoder(r).Decode(&statuses); err != nil {
+		return nil, fmt.Errorf("failed to decode model statuses: %v", err)
+	}
+	return statuses, nil
+}
+
+// Refresh loads the remote feed at url, a JSON array of ModelStatus. Remote
+// statuses replace the static table for the models they list, an "active"
+// entry clears a static status.
+func (c *ModelStatusChecker) Refresh(ctx context.Context, url string) error {
+	var statuses []ModelStatus
+	if err := getMetadata(ctx, nil, url, nil, &statuses); err != nil {
+		return fmt.Errorf("failed to fetch model statuses: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remote = statuses
+	return nil
+}
+
+// RefreshEvery calls Refresh every interval until ctx is canceled, reporting
+// errors to errorCallback (optional)
+func (c *ModelStatusChecker) RefreshEvery(ctx context.Context, url string, interval time.Duration, errorCallback func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Refresh(ctx, url); err != nil && errorCallback != nil && ctx.Err() == nil {
+			errorCallback(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Status returns the current status of model, ModelActive if none applies.
+// The most severe applicable status of the remote feed wins, or of the static
+// table if the feed does not list the model.
+func (c *ModelStatusChecker) Status(model string) ModelStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status(model)
+}
+
+var modelStateSeverity = map[ModelState]int{ModelDeprecated: 1, ModelMaintenance: 2, ModelRetired: 3}
+
+func (c *ModelStatusChecker) status(model string) ModelStatus {
+	res := ModelStatus{Model: model, State: ModelActive}
+	now := c.now()
+	statuses := c.static
+	for _, s := range c.remote {
+		if matchModel(model, s.Model) {
+			// the feed knows the model, e.g. it is active again
+			statuses = c.remote
+			break
+		}
+	}
+	for _, s := range statuses {
+		if matchModel(model, s.Model) && s.active(now) && modelStateSeverity[s.State] > modelStateSeverity[res.State] {
+			res = s
+		}
+	}
+	return res
+}
+
+// Check returns a ModelStatusError if model is refused, and warns about
+// deprecated models otherwise
+func (c *ModelStatusChecker) Check(model string) error {
+	c.mu.Lock()
+	s := c.status(model)
+	refused := s.State == ModelRetired || s.State == ModelMaintenance || s.State == ModelDeprecated && c.refuseDeprecated
+	var warn func(ModelStatus)
+	if s.State == ModelDeprecated && !refused && !c.warned[s.Model] {
+		c.warned[s.Model] = true
+		warn = c.warn
+	}
+	c.mu.Unlock()
+
+	if refused {
+		return &ModelStatusError{Status: s}
+	}
+	if warn != nil {
+		warn(s)
+	}
+	return nil
+}
+
+// matchModel reports whether model is name, ignoring a provider prefix
+func matchModel(model, name string) bool {
+	if model == name {
+		return true
+	}
+	_, base, ok := strings.Cut(model, "/")
+	for ok {
+		if base == name {
+			return true
+		}
+		_, base, ok = strings.Cut(base, "/")
+	}
+	return false
+}
+
+// ModelStatusLLM refuses requests to llm while a ModelStatusChecker refuses
+// its model
+type ModelStatusLLM struct {
+	LLM
+	checker *ModelStatusChecker
+}
+
+// NewModelStatusLLM creates a ModelStatusLLM
+func NewModelStatusLLM(llm LLM, checker *ModelStatusChecker) *ModelStatusLLM {
+	return &ModelStatusLLM{LLM: llm, checker: checker}
+}
+
+func (m *ModelStatusLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	if err := m.checker.Check(requestModel(ctx, m.LLM.GetModel())); err != nil {
+		return "", err
+	}
+	return m.LLM.Generate(ctx, systemPrompt, prompt)
+}
+
+func (m *ModelStatusLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	if err := m.checker.Check(requestModel(ctx, m.LLM.GetModel())); err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	m.LLM.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+}
+
+func (m *ModelStatusLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	if err := m.checker.Check(requestModel(ctx, m.LLM.GetModel())); err != nil {
+		return "", err
+	}
+	return m.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+}
+
+func (m *ModelStatusLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if err := m.checker.Check(requestModel(ctx, m.LLM.GetModel())); err != nil {
+		return "", err
+	}
+	return m.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+}
+
+func (m *ModelStatusLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	if err := m.checker.Check(requestModel(ctx, m.LLM.GetModel())); err != nil {
+		return "", err
+	}
+	return m.LLM.GenerateWithMessages(ctx, messages)
+}
@@ -0,0 +1,99 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestModelStatusChecker(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	statuses, err := LoadModelStatuses(strings.NewReader(`[
+		{"model": "old", "state": "deprecated", "replacement": "new", "end": "2025-07-01T00:00:00Z"},
+		{"model": "old", "state": "retired", "replacement": "new", "start": "2025-07-01T00:00:00Z"},
+		{"model": "busy", "state": "maintenance", "start": "2025-06-01T10:00:00Z", "end": "2025-06-01T14:00:00Z"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := NewModelStatusChecker(statuses)
+	checker.now = func() time.Time { return now }
+	var warnings []string
+	checker.SetWarningCallback(func(s ModelStatus) { warnings = append(warnings, s.Model) })
+
+	if err := checker.Check("us-central1/old"); err != nil {
+		t.Fatal(err)
+	}
+	checker.Check("old")
+	var statusErr *ModelStatusError
+	if err := checker.Check("busy"); !errors.As(err, &statusErr) || statusErr.Status.State != ModelMaintenance {
+		t.Errorf("expected maintenance error, got %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "old" {
+		t.Errorf("expected a single deprecation warning, got %q", warnings)
+	}
+
+	now = now.AddDate(0, 1, 0)
+	if err := checker.Check("old"); err == nil || err.Error() != "model old is retired, use new" {
+		t.Errorf("expected retired error, got %v", err)
+	}
+	if err := checker.Check("busy"); err != nil {
+		t.Errorf("expected the maintenance window to be over, got %v", err)
+	}
+
+	// The remote feed takes precedence
+	feed := `[{"model": "old", "state": "deprecated"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, feed)
+	}))
+	defer server.Close()
+	if err := checker.Refresh(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if s := checker.Status("old"); s.State != ModelDeprecated {
+		t.Errorf("unexpected status %+v", s)
+	}
+	checker.SetRefuseDeprecated(true)
+	if _, err := NewModelStatusLLM(&stubLLM{model: "old"}, checker).Generate(context.Background(), "", "hi"); !errors.As(err, &statusErr) {
+		t.Errorf("expected deprecated model to be refused, got %v", err)
+	}
+
+	// even when it reactivates a model
+	feed = `[{"model": "old", "state": "active"}]`
+	if err := checker.Refresh(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Check("old"); err != nil {
+		t.Errorf("expected the feed to reactivate the model, got %v", err)
+	}
+}
+
+func TestFallbackModelStatus(t *testing.T) {
+	var calls []string
+	llm := func(model string) LLM {
+		return &stubLLM{model: model, response: func(systemPrompt, prompt string) (string, error) {
+			calls = append(calls, model)
+			return model, nil
+		}}
+	}
+	checker := NewModelStatusChecker([]ModelStatus{
+		{Model: "old", State: ModelRetired, Replacement: "new"},
+		{Model: "gone", State: ModelRetired},
+	})
+	var errs []error
+	f := NewFallbackLLM([]LLM{llm("gone"), llm("old"), llm("other"), llm("new")}, func(err error) { errs = append(errs, err) })
+	f.SetModelStatus(checker)
+
+	res, err := f.Generate(context.Background(), "", "hi")
+	if err != nil || res != "new" || len(calls) != 1 || len(errs) != 2 {
+		t.Fatalf("expected the replacement to be tried first, got %q, %v, calls %q, errors %v", res, err, calls, errs)
+	}
+	if chain, _ := f.chain(); len(chain) != 2 || chain[1].GetModel() != "other" {
+		t.Errorf("unexpected chain %v", chain)
+	}
+}
@@ -0,0 +1,248 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// ModerationCategory is a normalized category of harmful content. Provider
+// subcategories map to their category, e.g. "hate/threatening" to hate.
+type ModerationCategory string
+
+const (
+	ModerationHarassment   ModerationCategory = "harassment"
+	ModerationHate         ModerationCategory = "hate"
+	ModerationSelfHarm     ModerationCategory = "self-harm"
+	ModerationSexual       ModerationCategory = "sexual"
+	ModerationSexualMinors ModerationCategory = "sexual/minors"
+	ModerationViolence     ModerationCategory = "violence"
+	ModerationIllicit      ModerationCategory = "illicit"
+	// ModerationDangerous is dangerous content as rated by Gemini, which has
+	// no separate violence, self-harm and illicit categories
+	ModerationDangerous ModerationCategory = "dangerous"
+)
+
+// ModerationResult is the classification of content
+type ModerationResult struct {
+	// Flagged reports whether the content is harmful in any category
+	Flagged bool
+	// Categories are the categories the content is flagged for
+	Categories []ModerationCategory
+	// Scores are the likelihoods of the categories, from 0 to 1
+	Scores map[ModerationCategory]float64
+}
+
+// setScore keeps the highest score of a category
+func (r *ModerationResult) setScore(category ModerationCategory, score float64) {
+	if r.Scores == nil {
+		r.Scores = map[ModerationCategory]float64{}
+	}
+	if current, ok := r.Scores[category]; !ok || score > current {
+		r.Scores[category] = score
+	}
+}
+
+// flag adds category to the flagged categories
+func (r *ModerationResult) flag(category ModerationCategory) {
+	r.Flagged = true
+	for _, c := range r.Categories {
+		if c == category {
+			return
+		}
+	}
+	r.Categories = append(r.Categories, category)
+	sort.Slice(r.Categories, func(i, j int) bool { return r.Categories[i] < r.Categories[j] })
+}
+
+// Moderator classifies text and images as harmful, e.g. to screen user input
+// before it is sent to a model
+type Moderator interface {
+	// Moderate classifies text and an optional image (nil if none)
+	Moderate(ctx context.Context, text string, image io.Reader, mimeType MimeType) (*ModerationResult, error)
+}
+
+// openAIModerationCategory normalizes an OpenAI category
+func openAIModerationCategory(category string) ModerationCategory {
+	switch {
+	case category == "sexual/minors":
+		return ModerationSexualMinors
+	case strings.Contains(category, "/"):
+		return ModerationCategory(category[:strings.Index(category, "/")])
+	}
+	return ModerationCategory(category)
+}
+
+// OpenAIModerator classifies content with the OpenAI moderation API
+type OpenAIModerator struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAIModerator creates a moderator for model, e.g.
+// "omni-moderation-latest", which also classifies images
+func NewOpenAIModerator(apiKey, model string) *OpenAIModerator {
+	return NewOpenAI(apiKey, "", 0, 0, false).Moderator(model)
+}
+
+// Moderator returns a moderator for model sharing the client
+func (o *OpenAI) Moderator(model string) *OpenAIModerator {
+	return &OpenAIModerator{client: o.client, model: model}
+}
+
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string, image io.Reader, mimeType MimeType) (*ModerationResult, error) {
+	var inputs []openai.ModerationMultiModalInputUnionParam
+	if text != "" {
+		inputs = append(inputs, openai.ModerationTextInputParam{
+			Type: openai.F(openai.ModerationTextInputTypeText),
+			Text: openai.F(text),
+		})
+	}
+	if image != nil {
+		data, err := io.ReadAll(image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %v", err)
+		}
+		inputs = append(inputs, openai.ModerationImageURLInputParam{
+			Type: openai.F(openai.ModerationImageURLInputTypeImageURL),
+			ImageURL: openai.F(openai.ModerationImageURLInputImageURLParam{
+				URL: openai.F("data:" + string(mimeType) + ";base64," + encodeBase64(ctx, data)),
+			}),
+		})
+	}
+	resp, err := m.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.F[openai.ModerationNewParamsInputUnion](openai.ModerationNewParamsInputModerationMultiModalArray(inputs)),
+		Model: openai.F(openai.ModerationModel(m.model)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// categories are decoded as maps to keep the ones the SDK does not know
+	var raw struct {
+		Results []struct {
+			Flagged        bool               `json:"flagged"`
+			Categories     map[string]bool    `json:"categories"`
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(resp.JSON.RawJSON()), &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode moderation: %v", err)
+	}
+	res := &ModerationResult{Scores: map[ModerationCategory]float64{}}
+	for _, result := range raw.Results {
+		for category, score := range result.CategoryScores {
+			res.setScore(openAIModerationCategory(category), score)
+		}
+		for category, flagged := range result.Categories {
+			if flagged {
+				res.flag(openAIModerationCategory(category))
+			}
+		}
+		res.Flagged = res.Flagged || result.Flagged
+	}
+	return res, nil
+}
+
+// geminiHarmCategories are the normalized Gemini harm categories
+var geminiHarmCategories = map[string]ModerationCategory{
+	"HARM_CATEGORY_HARASSMENT":        ModerationHarassment,
+	"HARM_CATEGORY_HATE_SPEECH":       ModerationHate,
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT": ModerationSexual,
+	"HARM_CATEGORY_DANGEROUS_CONTENT": ModerationDangerous,
+}
+
+// geminiHarmScores are the scores of Gemini harm probabilities
+var geminiHarmScores = map[string]float64{
+	"NEGLIGIBLE": 0,
+	"LOW":        0.25,
+	"MEDIUM":     0.6,
+	"HIGH":       0.9,
+}
+
+// geminiFlagScore is the score from which content is flagged, Gemini blocks
+// medium and high probabilities by default
+const geminiFlagScore = 0.6
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// GeminiModerator classifies content with the safety ratings of the Gemini
+// API. Ratings come with a generation, which is limited to one token.
+type GeminiModerator struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// NewGeminiModerator creates a moderator using model, e.g. "gemini-2.0-flash-lite"
+func NewGeminiModerator(apiKey, model string) *GeminiModerator {
+	return &GeminiModerator{apiKey: apiKey, model: model, baseURL: geminiAPIBaseURL}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (m *GeminiModerator) SetBaseURL(baseURL string) {
+	m.baseURL = baseURL
+}
+
+func (m *GeminiModerator) Moderate(ctx context.Context, text string, image io.Reader, mimeType MimeType) (*ModerationResult, error) {
+	msg := Message{Role: RoleUser, Content: text, Image: image, MimeType: mimeType}
+	parts, err := geminiParts(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	// content is rated without being blocked, to get the ratings of all
+	// categories
+	var safetySettings []map[string]string
+	for category := range geminiHarmCategories {
+		safetySettings = append(safetySettings, map[string]string{"category": category, "threshold": "BLOCK_NONE"})
+	}
+	body := map[string]interface{}{
+		"contents":         []geminiContent{{Role: "user", Parts: parts}},
+		"safetySettings":   safetySettings,
+		"generationConfig": map[string]interface{}{"maxOutputTokens": 1},
+	}
+	headers := map[string]string{"x-goog-api-key": m.apiKey}
+	var resp struct {
+		PromptFeedback struct {
+			BlockReason   string               `json:"blockReason"`
+			SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+		} `json:"promptFeedback"`
+		Candidates []struct {
+			SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+		} `json:"candidates"`
+	}
+	if err := doJSON(ctx, nil, http.MethodPost, m.baseURL+"models/"+m.model+":generateContent", headers, body, &resp); err != nil {
+		return nil, err
+	}
+
+	res := &ModerationResult{Scores: map[ModerationCategory]float64{}}
+	ratings := resp.PromptFeedback.SafetyRatings
+	for _, candidate := range resp.Candidates {
+		ratings = append(ratings, candidate.SafetyRatings...)
+	}
+	for _, rating := range ratings {
+		category, ok := geminiHarmCategories[rating.Category]
+		if !ok {
+			category = ModerationCategory(strings.ToLower(strings.TrimPrefix(rating.Category, "HARM_CATEGORY_")))
+		}
+		score := geminiHarmScores[rating.Probability]
+		res.setScore(category, score)
+		if rating.Blocked || score >= geminiFlagScore {
+			res.flag(category)
+		}
+	}
+	if resp.PromptFeedback.BlockReason != "" {
+		res.Flagged = true
+	}
+	return res, nil
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModerators(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/moderations":
+			w.Write([]byte(`{"id": "modr-1", "model": "omni-moderation-latest", "results": [{
+				"flagged": true,
+				"categories": {"hate": false, "hate/threatening": true, "sexual/minors": false, "violence": false},
+				"category_scores": {"hate": 0.2, "hate/threatening": 0.7, "sexual/minors": 0.01, "violence": 0.1}}]}`))
+		case strings.HasSuffix(r.URL.Path, ":generateContent"):
+			w.Write([]byte(`{"candidates": [{"safetyRatings": [
+				{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH"},
+				{"category": "HARM_CATEGORY_HATE_SPEECH", "probability": "LOW"},
+				{"category": "HARM_CATEGORY_CIVIC_INTEGRITY", "probability": "NEGLIGIBLE"}]}]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "", 0, 0, false).Moderator("omni-moderation-latest")
+	res, err := openAI.Moderate(ctx, "text", strings.NewReader("image"), MimeTypePNG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inputs, _ := body["input"].([]interface{}); len(inputs) != 2 {
+		t.Errorf("expected text and image inputs, got %v", body["input"])
+	}
+	if !res.Flagged || len(res.Categories) != 1 || res.Categories[0] != ModerationHate || res.Scores[ModerationHate] != 0.7 || res.Scores[ModerationSexualMinors] != 0.01 {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	gemini := NewGeminiModerator("key", "gemini-2.0-flash-lite")
+	gemini.SetBaseURL(server.URL + "/")
+	res, err = gemini.Moderate(ctx, "text", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Flagged || len(res.Categories) != 1 || res.Categories[0] != ModerationHarassment || res.Scores[ModerationHate] != 0.25 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if _, ok := res.Scores["civic_integrity"]; !ok {
+		t.Errorf("unknown categories should be kept: %v", res.Scores)
+	}
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// NIMBaseURL is the hosted NVIDIA API catalog (build.nvidia.com)
+const NIMBaseURL = "https://integrate.api.nvidia.com/v1/"
+
+// https://docs.api.nvidia.com/nim/reference/llm-apis
+// NewNIM creates a client for NVIDIA NIM, either hosted (baseURL empty or
+// NIMBaseURL) or a self-hosted container, e.g. "http://gpu-host:8000/v1/"
+// where apiKey may be empty. NIM expects the Accept header to match the
+// stream parameter, so it is set per request. ListModels returns the models
+// served by the endpoint.
+func NewNIM(baseURL, apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	if baseURL == "" {
+		baseURL = NIMBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	if apiKey == "" {
+		apiKey = "not-used"
+	}
+	return NewOpenAICompatible(baseURL, apiKey, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(nimAcceptHeader))
+}
+
+func nimAcceptHeader(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	if req.Body == nil || req.Method != http.MethodPost {
+		return next(req)
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+
+	var body struct {
+		Stream bool `json:"stream"`
+	}
+	json.Unmarshal(data, &body)
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	return next(req)
+}
@@ -0,0 +1,396 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Locale describes how numbers, dates and units are written
+type Locale struct {
+	DecimalSeparator rune
+	GroupSeparator   rune   // 0 disables digit grouping
+	DateLayout       string // time layout, e.g. "02.01.2006"
+	DayFirst         bool   // reading ambiguous dates like 03/04/2024
+	Metric           bool
+}
+
+var (
+	LocaleUS  = Locale{DecimalSeparator: '.', GroupSeparator: ',', DateLayout: "01/02/2006"}
+	LocaleUK  = Locale{DecimalSeparator: '.', GroupSeparator: ',', DateLayout: "02/01/2006", DayFirst: true, Metric: true}
+	LocaleDE  = Locale{DecimalSeparator: ',', GroupSeparator: '.', DateLayout: "02.01.2006", DayFirst: true, Metric: true}
+	LocaleFR  = Locale{DecimalSeparator: ',', GroupSeparator: '\u202f', DateLayout: "02/01/2006", DayFirst: true, Metric: true}
+	LocaleISO = Locale{DecimalSeparator: '.', DateLayout: "2006-01-02", Metric: true}
+)
+
+// Normalizer rewrites numbers, dates and units in generated text to a locale,
+// so the text can be fed into strict parsers. Ambiguous input, like "1,234" or
+// "03/04/2024", is read according to From (To if From is nil).
+// If LLM is set, a second pass asks the model to fix anything the rules missed.
+type Normalizer struct {
+	To   Locale
+	From *Locale
+	LLM  LLM
+}
+
+func NewNormalizer(to Locale) *Normalizer {
+	return &Normalizer{To: to}
+}
+
+var (
+	isoDateRe     = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	numericDateRe = regexp.MustCompile(`\b(\d{1,2})([./])(\d{1,2})[./](\d{4})\b`)
+	monthNames    = `(January|February|March|April|May|June|July|August|September|October|November|December|Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sep|Sept|Oct|Nov|Dec)\.?`
+	monthDayRe    = regexp.MustCompile(`\b` + monthNames + ` (\d{1,2})(?:st|nd|rd|th)?,? (\d{4})\b`)
+	dayMonthRe    = regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)? ` + monthNames + `,? (\d{4})\b`)
+
+	numberPattern = `[-+]?\d+(?:[.,'\x{00a0}\x{202f}]\d+)*`
+	// numberSeparators are the decimal and group separators accepted in input
+	numberSeparators = ".,'\u00a0\u202f"
+	quantityRe       = regexp.MustCompile(`(` + numberPattern + `)( ?)(miles|mi|kilometers|kilometres|km|lbs|lb|kilograms|kg|feet|ft|°F|°C)\b`)
+	numberRe         = regexp.MustCompile(numberPattern)
+)
+
+// Normalize rewrites text to the target locale
+func (n *Normalizer) Normalize(ctx context.Context, text string) (string, error) {
+	from := n.To
+	if n.From != nil {
+		from = *n.From
+	}
+
+	// Dates are replaced by placeholders first, so their digits are not
+	// treated as numbers
+	var protected []string
+	protect := func(s string) string {
+		protected = append(protected, s)
+		return fmt.Sprintf("\x00%d\x00", len(protected)-1)
+	}
+	text = replaceDates(text, from, func(t time.Time) string {
+		return protect(t.Format(n.To.DateLayout))
+	})
+
+	text = replaceStandalone(text, quantityRe, func(sub []string) string {
+		return protect(n.convertQuantity(sub[1], sub[2], sub[3], from))
+	})
+
+	text = replaceStandalone(text, numberRe, func(sub []string) string {
+		s := sub[0]
+		v, decimals, ok := parseLocaleNumber(s, from)
+		if !ok {
+			return s
+		}
+		if decimals == 0 && !strings.ContainsAny(s, numberSeparators) && len(strings.TrimLeft(s, "-+")) < 5 {
+			// Plain short integers (years, counts) are kept as is
+			return s
+		}
+		return formatLocaleNumber(v, decimals, n.To)
+	})
+
+	for i, s := range protected {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), s, 1)
+	}
+
+	if n.LLM != nil {
+		return n.llmPass(ctx, text)
+	}
+	return text, nil
+}
+
+func (n *Normalizer) llmPass(ctx context.Context, text string) (string, error) {
+	units := "imperial"
+	if n.To.Metric {
+		units = "metric"
+	}
+	example := formatLocaleNumber(1234567.89, 2, n.To)
+	date := time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC).Format(n.To.DateLayout)
+	systemPrompt := fmt.Sprintf(`You normalize text for machine parsing.
+Rewrite every number, date and unit in the text to this format:
+- numbers like %s
+- dates like %s (March 31, 2024)
+- %s units
+Do not change anything else. Reply with the rewritten text only.`, example, date, units)
+
+	res, err := n.LLM.Generate(ctx, systemPrompt, text)
+	if err != nil {
+		return "", err
+	}
+	res = strings.TrimSpace(res)
+	// Guard against the model answering or summarizing instead of rewriting
+	if l, r := utf8.RuneCountInString(text), utf8.RuneCountInString(res); r < l/2 || r > l*2 {
+		return "", fmt.Errorf("normalization changed the text too much")
+	}
+	return res, nil
+}
+
+func replaceDates(text string, from Locale, fn func(time.Time) string) string {
+	text = isoDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := isoDateRe.FindStringSubmatch(m)
+		return dateOrKeep(m, sub[1], sub[2], sub[3], fn)
+	})
+	text = numericDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := numericDateRe.FindStringSubmatch(m)
+		a, _ := strconv.Atoi(sub[1])
+		b, _ := strconv.Atoi(sub[3])
+		dayFirst := from.DayFirst || sub[2] == "."
+		if a > 12 {
+			dayFirst = true
+		} else if b > 12 {
+			dayFirst = false
+		}
+		if dayFirst {
+			return dateOrKeep(m, sub[4], sub[3], sub[1], fn)
+		}
+		return dateOrKeep(m, sub[4], sub[1], sub[3], fn)
+	})
+	text = monthDayRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := monthDayRe.FindStringSubmatch(m)
+		return dateOrKeep(m, sub[3], monthNumber(sub[1]), sub[2], fn)
+	})
+	return dayMonthRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := dayMonthRe.FindStringSubmatch(m)
+		return dateOrKeep(m, sub[3], monthNumber(sub[2]), sub[1], fn)
+	})
+}
+
+// dateOrKeep calls fn for a valid date, otherwise returns the original text
+func dateOrKeep(original, year, month, day string, fn func(time.Time) string) string {
+	y, _ := strconv.Atoi(year)
+	m, _ := strconv.Atoi(month)
+	d, _ := strconv.Atoi(day)
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Year() != y || int(t.Month()) != m || t.Day() != d {
+		return original
+	}
+	return fn(t)
+}
+
+func monthNumber(name string) string {
+	for m := time.January; m <= time.December; m++ {
+		if strings.HasPrefix(m.String(), name[:3]) {
+			return strconv.Itoa(int(m))
+		}
+	}
+	return "0"
+}
+
+// replaceStandalone calls fn with the submatches of every match of re that is
+// not part of a longer token, skipping version strings, times and identifiers
+func replaceStandalone(text string, re *regexp.Regexp, fn func(sub []string) string) string {
+	var sb strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		sb.WriteString(text[last:loc[0]])
+		if standalone(text, loc[0], loc[1]) {
+			sub := make([]string, len(loc)/2)
+			for i := range sub {
+				if loc[2*i] >= 0 {
+					sub[i] = text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			sb.WriteString(fn(sub))
+		} else {
+			sb.WriteString(text[loc[0]:loc[1]])
+		}
+		last = loc[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// standalone reports whether text[start:end] is not part of a longer token
+func standalone(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isWordRune(r) || strings.ContainsRune(".,:/\x00", r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if r == '\x00' || r == ':' || r == '/' || isWordRune(r) {
+			return false
+		}
+		if r == '.' || r == ',' {
+			next, _ := utf8.DecodeRuneInString(text[end+size:])
+			if next >= '0' && next <= '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// parseLocaleNumber parses a number with unknown separators. It returns the
+// value and the number of decimals written.
+func parseLocaleNumber(s string, from Locale) (float64, int, bool) {
+	sign := ""
+	if s[0] == '-' || s[0] == '+' {
+		sign, s = s[:1], s[1:]
+	}
+
+	decimalSep := rune(0)
+	lastDot := strings.LastIndexByte(s, '.')
+	lastComma := strings.LastIndexByte(s, ',')
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		decimalSep = '.'
+		if lastComma > lastDot {
+			decimalSep = ','
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		sep := byte('.')
+		idx := lastDot
+		if lastComma >= 0 {
+			sep, idx = ',', lastComma
+		}
+		switch {
+		case strings.Count(s, string(sep)) > 1:
+			// repeated separator is grouping
+		case len(s)-idx-1 != 3 || strings.HasPrefix(s, "0"+string(sep)):
+			decimalSep = rune(sep)
+		case rune(sep) == from.DecimalSeparator:
+			decimalSep = rune(sep)
+		}
+	}
+
+	intPart, fracPart := s, ""
+	if decimalSep != 0 {
+		i := strings.LastIndex(s, string(decimalSep))
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	groups := strings.FieldsFunc(intPart, func(r rune) bool {
+		return strings.ContainsRune(".,'  ", r)
+	})
+	for i, g := range groups {
+		if i > 0 && len(g) != 3 || i == 0 && len(groups) > 1 && len(g) > 3 {
+			return 0, 0, false
+		}
+	}
+	if strings.ContainsAny(fracPart, ".,'  ") {
+		return 0, 0, false
+	}
+
+	v, err := strconv.ParseFloat(sign+strings.Join(groups, "")+"."+fracPart+"0", 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return v, len(fracPart), true
+}
+
+func formatLocaleNumber(v float64, decimals int, to Locale) string {
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	var sb strings.Builder
+	if v < 0 {
+		sb.WriteByte('-')
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 && to.GroupSeparator != 0 {
+			sb.WriteRune(to.GroupSeparator)
+		}
+		sb.WriteRune(c)
+	}
+	if fracPart != "" {
+		sb.WriteRune(to.DecimalSeparator)
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}
+
+type unitConversion struct {
+	symbol string
+	metric bool
+	to     string
+	factor float64
+	offset float64
+}
+
+var unitConversions = map[string]unitConversion{
+	"mi":         {"mi", false, "km", 1.609344, 0},
+	"miles":      {"mi", false, "km", 1.609344, 0},
+	"km":         {"km", true, "mi", 1 / 1.609344, 0},
+	"kilometers": {"km", true, "mi", 1 / 1.609344, 0},
+	"kilometres": {"km", true, "mi", 1 / 1.609344, 0},
+	"lb":         {"lb", false, "kg", 0.45359237, 0},
+	"lbs":        {"lb", false, "kg", 0.45359237, 0},
+	"kg":         {"kg", true, "lb", 1 / 0.45359237, 0},
+	"kilograms":  {"kg", true, "lb", 1 / 0.45359237, 0},
+	"ft":         {"ft", false, "m", 0.3048, 0},
+	"feet":       {"ft", false, "m", 0.3048, 0},
+	"°F":         {"°F", false, "°C", 5.0 / 9, -32 * 5.0 / 9},
+	"°C":         {"°C", true, "°F", 9.0 / 5, 32},
+}
+
+// convertQuantity converts a quantity to the unit system of the target
+// locale and writes the unit as a symbol
+func (n *Normalizer) convertQuantity(number, space, unit string, from Locale) string {
+	v, decimals, ok := parseLocaleNumber(number, from)
+	if !ok {
+		return number + space + unit
+	}
+	conv := unitConversions[unit]
+	symbol := conv.symbol
+	if conv.metric != n.To.Metric && (conv.to != "m" || n.To.Metric) {
+		v = v*conv.factor + conv.offset
+		symbol = conv.to
+		decimals = 1
+		if v == math.Trunc(v*10)/10 && v == math.Trunc(v) {
+			decimals = 0
+		}
+	}
+	if strings.HasPrefix(symbol, "°") {
+		space = ""
+	} else {
+		space = " "
+	}
+	return formatLocaleNumber(v, decimals, n.To) + space + symbol
+}
+
+// PostProcessLLM applies a post-processor, e.g. Normalizer.Normalize, to every
+// response. Streamed responses are passed through unchanged.
+type PostProcessLLM struct {
+	LLM
+	process func(ctx context.Context, text string) (string, error)
+}
+
+func NewPostProcessLLM(llm LLM, process func(ctx context.Context, text string) (string, error)) *PostProcessLLM {
+	return &PostProcessLLM{LLM: llm, process: process}
+}
+
+func (p *PostProcessLLM) apply(ctx context.Context, res string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return p.process(ctx, res)
+}
+
+func (p *PostProcessLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	res, err := p.LLM.Generate(ctx, systemPrompt, prompt)
+	return p.apply(ctx, res, err)
+}
+
+func (p *PostProcessLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	res, err := p.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+	return p.apply(ctx, res, err)
+}
+
+func (p *PostProcessLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	res, err := p.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+	return p.apply(ctx, res, err)
+}
+
+func (p *PostProcessLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	res, err := p.LLM.GenerateWithMessages(ctx, messages)
+	return p.apply(ctx, res, err)
+}
@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		name string
+		n    *Normalizer
+		in   string
+		want string
+	}{
+		{"us to de", NewNormalizer(LocaleDE), "Revenue was 1,234,567.89 USD on March 5, 2024.", "Revenue was 1.234.567,89 USD on 05.03.2024."},
+		{"de to iso", &Normalizer{To: LocaleISO, From: &LocaleDE}, "Am 31.12.2023 kostete es 1.234,5 Euro", "Am 2023-12-31 kostete es 1234.5 Euro"},
+		{"ambiguous date", &Normalizer{To: LocaleISO, From: &LocaleUS}, "Due 03/04/2024, paid 13/04/2024", "Due 2024-03-04, paid 2024-04-13"},
+		{"ambiguous number", &Normalizer{To: LocaleISO, From: &LocaleDE}, "It weighs 1,234 g", "It weighs 1.234 g"},
+		{"keeps years versions and times", NewNormalizer(LocaleDE), "In 2024 v1.2.3 shipped at 10:30, build 3.5.", "In 2024 v1.2.3 shipped at 10:30, build 3,5."},
+		{"units to metric", NewNormalizer(LocaleUK), "A 10 miles run at 50°F carrying 20 lbs", "A 16.1 km run at 10°C carrying 9.1 kg"},
+		{"units to imperial", NewNormalizer(LocaleUS), "It is 100 km away and 20°C", "It is 62.1 mi away and 68°F"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.n.Normalize(context.Background(), tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostProcessLLM(t *testing.T) {
+	llm := NewPostProcessLLM(&stubLLM{response: func(systemPrompt, prompt string) (string, error) {
+		return "Total: 12,500.5", nil
+	}}, NewNormalizer(LocaleDE).Normalize)
+
+	res, err := llm.Generate(context.Background(), "", "total?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Total: 12.500,5" {
+		t.Errorf("unexpected response %q", res)
+	}
+}
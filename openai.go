@@ -3,8 +3,11 @@ package ai
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
@@ -16,6 +19,21 @@ type OpenAI struct {
 	maxTokens   int64
 	temperature float64
 	isJson      bool
+	audioVoice  string
+	audioFormat string
+	stop        []string
+	sampling    Sampling
+	seed        *int64
+
+	reasoningEffort     ReasoningEffort
+	maxCompletionTokens int64
+
+	// apiKey keys the metadata cache of ListModels, baseURL identifies the
+	// provider
+	apiKey  string
+	baseURL string
+	// opts are the request options of client, kept to rebuild it
+	opts []option.RequestOption
 }
 
 func NewOpenAI(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
@@ -32,31 +50,41 @@ func NewLambdaLab(apiKey string, model string, maxTokens int64, temperature floa
 	return NewOpenAICompatible("https://api.lambdalabs.com/v1/", apiKey, model, maxTokens, temperature, isJson)
 }
 
-// https://console.groq.com/docs/
-// Not working, not fully compatible with OpenAI API
-// func NewGroqClient(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAIClient {
-// return NewOpenAICompatibleClient("https://api.groq.com/openai/v1/", apiKey, model, maxTokens, temperature, isJson)
-// }
-
 // https://docs.x.ai/docs/api-reference
+// Images are sent as separate messages, use NewGrok for vision models.
 func NewXAI(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
 	return NewOpenAICompatible("https://api.x.ai/v1/", apiKey, model, maxTokens, temperature, isJson)
 }
 
-func NewOpenAICompatible(baseURL, apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
-	client := openai.NewClient(
+// NewOpenAICompatible creates a client for any OpenAI-compatible API.
+// Extra request options are applied to every request.
+func NewOpenAICompatible(baseURL, apiKey string, model string, maxTokens int64, temperature float64, isJson bool, opts ...option.RequestOption) *OpenAI {
+	opts = append([]option.RequestOption{
 		option.WithAPIKey(apiKey),
 		option.WithBaseURL(baseURL),
-	)
+	}, opts...)
 	return &OpenAI{
-		client:      client,
+		client:      newOpenAIClient(opts),
 		model:       model,
 		maxTokens:   maxTokens,
 		temperature: temperature,
 		isJson:      isJson,
+		apiKey:      apiKey,
+		baseURL:     baseURL,
+		opts:        opts,
 	}
 }
 
+func newOpenAIClient(opts []option.RequestOption) *openai.Client {
+	// Compression goes last so that other middlewares see the plain body
+	return openai.NewClient(append(opts[:len(opts):len(opts)], option.WithMiddleware(compressionMiddleware))...)
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (o *OpenAI) SetHTTPClient(client *http.Client) {
+	o.client = newOpenAIClient(append(o.opts[:len(o.opts):len(o.opts)], option.WithHTTPClient(client)))
+}
+
 func (o *OpenAI) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
 	params := openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
@@ -76,31 +104,60 @@ func (o *OpenAI) Generate(ctx context.Context, systemPrompt, prompt string) (str
 		)
 	}
 
-	completion, err := o.client.Chat.Completions.New(ctx, params)
+	opts := o.requestParams(ctx, &params)
+	completion, err := o.client.Chat.Completions.New(ctx, params, opts...)
 	if err != nil {
 		return "", err
 	}
-	return completion.Choices[0].Message.Content, nil
+	return o.content(completion)
 }
 
 func (o *OpenAI) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
-	stream := o.client.Chat.Completions.NewStreaming(ctx, openai.ChatCompletionNewParams{
+	params := openai.ChatCompletionNewParams{
 		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage(systemPrompt),
 			openai.UserMessage(prompt),
 		}),
 		Model: openai.F(o.model),
-	})
+	}
+	o.stream(ctx, params, resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (o *OpenAI) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	messages, p := emulatePrefill(messages)
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	if !sendPrefill(ctx, p, resultCh) {
+		return
+	}
+	o.stream(ctx, params, resultCh, doneCh, errCh)
+}
+
+func (o *OpenAI) stream(ctx context.Context, params openai.ChatCompletionNewParams, resultCh chan string, doneCh chan bool, errCh chan error) {
+	opts := o.requestParams(ctx, &params)
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params, opts...)
 
 	go func() {
 		defer close(resultCh)
 		defer close(doneCh)
 		defer close(errCh)
 
+		// The thinking of open reasoning models is not part of the answer
+		var think thinkSplitter
 		for stream.Next() {
 			chunk := stream.Current()
-			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-				resultCh <- chunk.Choices[0].Delta.Content
+			if len(chunk.Choices) == 0 {
+				continue
+			}
+			if _, answer := think.write(chunk.Choices[0].Delta.Content); answer != "" {
+				resultCh <- answer
 			}
 		}
 
@@ -108,14 +165,37 @@ func (o *OpenAI) GenerateStream(ctx context.Context, systemPrompt, prompt string
 			errCh <- err
 			return
 		}
+		if _, answer := think.flush(); answer != "" {
+			resultCh <- answer
+		}
 		doneCh <- true
 	}()
 }
 
+// StreamWithReasoning streams the reasoning of reasoning models, returned in
+// a separate field or in a leading <think> block, as reasoning events. OpenAI
+// itself does not return the reasoning of o-series models in chat
+// completions, only reasoning-capable compatible servers do.
+func (o *OpenAI) StreamWithReasoning(ctx context.Context, messages []Message, fn func(StreamEvent) error) error {
+	seq := 0
+	return o.StreamEvents(ctx, messages, nil, func(event StreamEvent) error {
+		if event.Type != StreamEventReasoning && event.Type != StreamEventDelta {
+			return nil
+		}
+		event.Seq = seq
+		seq++
+		return fn(event)
+	})
+}
+
 func (o *OpenAI) GetModel() string {
 	return o.model
 }
 
+func (o *OpenAI) Close() error {
+	return nil
+}
+
 func (o *OpenAI) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
 	return o.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
 }
@@ -150,30 +230,420 @@ func (o *OpenAI) GenerateWithImages(ctx context.Context, prompt string, images [
 }
 
 func (o *OpenAI) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	messages, p := emulatePrefill(messages)
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	opts := o.requestParams(ctx, &params)
+	resp, err := o.client.Chat.Completions.New(ctx, params, opts...)
+	if err != nil {
+		return "", err
+	}
+	res, err := o.content(resp)
+	if err != nil {
+		return "", err
+	}
+	return withPrefill(p, res), nil
+}
+
+// content returns the message text without reasoning, or a RefusalError if
+// the model refused
+func (o *OpenAI) content(completion *openai.ChatCompletion) (string, error) {
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("no choices returned")
+	}
+	msg := completion.Choices[0].Message
+	if msg.Refusal != "" {
+		return "", &RefusalError{Model: o.model, Message: msg.Refusal}
+	}
+	_, answer := splitThinking(msg.Content)
+	return answer, nil
+}
+
+// extraReasoning returns the reasoning of a message or delta, which
+// compatible servers return in a reasoning_content (DeepSeek, vLLM) or
+// reasoning (Groq, OpenRouter) field
+func extraReasoning[F interface{ Raw() string }](fields map[string]F) string {
+	for _, key := range []string{"reasoning_content", "reasoning"} {
+		var reasoning string
+		if f, ok := fields[key]; ok && json.Unmarshal([]byte(f.Raw()), &reasoning) == nil && reasoning != "" {
+			return reasoning
+		}
+	}
+	return ""
+}
+
+// SetAudioOutput makes GenerateResponse request spoken audio in the given
+// voice and format (wav, mp3, flac, opus or pcm16) from audio capable models
+func (o *OpenAI) SetAudioOutput(voice, format string) {
+	o.audioVoice = voice
+	o.audioFormat = format
+}
+
+func (o *OpenAI) GenerateResponse(ctx context.Context, messages []Message) (*Response, error) {
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.audioVoice != "" {
+		params.Modalities = openai.F([]openai.ChatCompletionModality{
+			openai.ChatCompletionModalityText,
+			openai.ChatCompletionModalityAudio,
+		})
+		params.Audio = openai.F(openai.ChatCompletionAudioParam{
+			Voice:  openai.F(openai.ChatCompletionAudioParamVoice(o.audioVoice)),
+			Format: openai.F(openai.ChatCompletionAudioParamFormat(o.audioFormat)),
+		})
+		// Audio output does not support JSON mode, the field is omitted
+		params.ResponseFormat = openai.ChatCompletionNewParams{}.ResponseFormat
+	}
+
+	opts := o.requestParams(ctx, &params)
+	provenance := newProvenance(ctx, params.Model.Value, params, "messages")
+	resp, err := o.client.Chat.Completions.New(ctx, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned")
+	}
+
+	msg := resp.Choices[0].Message
+	reasoning, text := splitThinking(msg.Content)
+	if extra := extraReasoning(msg.JSON.ExtraFields); extra != "" {
+		reasoning = extra
+	}
+	res := &Response{Text: text, Reasoning: reasoning, Refusal: msg.Refusal, Model: resp.Model, SystemFingerprint: resp.SystemFingerprint}
+	if msg.Audio.Data != "" {
+		audio, err := base64.StdEncoding.DecodeString(msg.Audio.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode audio: %v", err)
+		}
+		res.Audio = audio
+		res.AudioMimeType = openAIAudioMimeType(o.audioFormat)
+		res.AudioTranscript = msg.Audio.Transcript
+	}
+	attachProvenance(res, provenance)
+	attachRaw(ctx, res, resp.JSON.RawJSON())
+	return res, nil
+}
+
+// GenerateWithTools lets the model call the given tools. Requested calls are
+// returned in Response.ToolCalls, to be executed by the caller.
+func (o *OpenAI) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error) {
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tools) > 0 {
+		params.Tools = openai.F(openAITools(tools))
+	}
+
+	opts := o.requestParams(ctx, &params)
+	provenance := newProvenance(ctx, params.Model.Value, params, "messages")
+	resp, err := o.client.Chat.Completions.New(ctx, params, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices returned")
+	}
+
+	msg := resp.Choices[0].Message
+	reasoning, text := splitThinking(msg.Content)
+	if extra := extraReasoning(msg.JSON.ExtraFields); extra != "" {
+		reasoning = extra
+	}
+	res := &Response{Text: text, Reasoning: reasoning, Refusal: msg.Refusal, Model: resp.Model, SystemFingerprint: resp.SystemFingerprint}
+	for _, call := range msg.ToolCalls {
+		res.ToolCalls = append(res.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: json.RawMessage(call.Function.Arguments),
+		})
+	}
+	attachProvenance(res, provenance)
+	attachRaw(ctx, res, resp.JSON.RawJSON())
+	return res, nil
+}
+
+// openAIAudioFormats are the input audio formats of OpenAI
+var openAIAudioFormats = map[MimeType]openai.ChatCompletionContentPartInputAudioInputAudioFormat{
+	MimeTypeWAV: openai.ChatCompletionContentPartInputAudioInputAudioFormatWAV,
+	MimeTypeMP3: openai.ChatCompletionContentPartInputAudioInputAudioFormatMP3,
+}
+
+// openAIAudioPart converts audio to an input audio part
+func openAIAudioPart(ctx context.Context, audio io.Reader, mimeType MimeType) (openai.ChatCompletionContentPartInputAudioParam, error) {
+	format, ok := openAIAudioFormats[mimeType]
+	if !ok {
+		return openai.ChatCompletionContentPartInputAudioParam{}, fmt.Errorf("unsupported audio format %s, OpenAI accepts WAV and MP3", mimeType)
+	}
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return openai.ChatCompletionContentPartInputAudioParam{}, fmt.Errorf("failed to read audio: %v", err)
+	}
+	return openai.ChatCompletionContentPartInputAudioParam{
+		Type: openai.F(openai.ChatCompletionContentPartInputAudioTypeInputAudio),
+		InputAudio: openai.F(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+			Data:   openai.F(encodeBase64(ctx, data)),
+			Format: openai.F(format),
+		}),
+	}, nil
+}
+
+// openAIContentParts converts parts to user message parts, OpenAI chat
+// completions do not accept documents
+func openAIContentParts(ctx context.Context, parts []ContentPart) ([]openai.ChatCompletionContentPartUnionParam, error) {
+	var res []openai.ChatCompletionContentPartUnionParam
+	for _, part := range parts {
+		switch part.Type {
+		case PartText:
+			res = append(res, openai.TextPart(part.Text))
+		case PartImage:
+			data, err := io.ReadAll(part.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read image: %v", err)
+			}
+			res = append(res, openai.ImagePart("data:"+string(part.MimeType)+";base64,"+encodeBase64(ctx, data)))
+		case PartAudio:
+			audio, err := openAIAudioPart(ctx, part.Data, part.MimeType)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, audio)
+		case PartFile:
+			res = append(res, openAIFilePart{FileID: part.File.ID})
+		default:
+			return nil, fmt.Errorf("%s input is not supported by OpenAI chat completions", part.Type)
+		}
+	}
+	return res, nil
+}
+
+// openAIFilePart references an uploaded file, the SDK has no file parts
+type openAIFilePart struct {
+	openai.ChatCompletionContentPartParam
+	FileID string
+}
+
+func (p openAIFilePart) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type": "file",
+		"file": map[string]string{"file_id": p.FileID},
+	})
+}
+
+// openAIAssistantMessage converts an assistant message with its tool calls
+func openAIAssistantMessage(msg Message) openai.ChatCompletionAssistantMessageParam {
+	if len(msg.ToolCalls) == 0 {
+		return openai.AssistantMessage(msg.text())
+	}
+	param := openai.ChatCompletionAssistantMessageParam{Role: openai.F(openai.ChatCompletionAssistantMessageParamRoleAssistant)}
+	if text := msg.text(); text != "" {
+		param.Content = openai.F([]openai.ChatCompletionAssistantMessageParamContentUnion{openai.TextPart(text)})
+	}
+	calls := make([]openai.ChatCompletionMessageToolCallParam, len(msg.ToolCalls))
+	for i, call := range msg.ToolCalls {
+		calls[i] = openai.ChatCompletionMessageToolCallParam{
+			ID:   openai.F(call.ID),
+			Type: openai.F(openai.ChatCompletionMessageToolCallTypeFunction),
+			Function: openai.F(openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      openai.F(call.Name),
+				Arguments: openai.F(string(call.Arguments)),
+			}),
+		}
+	}
+	param.ToolCalls = openai.F(calls)
+	return param
+}
+
+// openAITools converts tools to function tool definitions
+func openAITools(tools []Tool) []openai.ChatCompletionToolParam {
+	params := make([]openai.ChatCompletionToolParam, len(tools))
+	for i, tool := range tools {
+		function := openai.FunctionDefinitionParam{
+			Name:        openai.F(tool.Name),
+			Description: openai.F(tool.Description),
+		}
+		if tool.Parameters != nil {
+			function.Parameters = openai.F(openai.FunctionParameters(tool.Parameters.Map()))
+		}
+		params[i] = openai.ChatCompletionToolParam{
+			Type:     openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(function),
+		}
+	}
+	return params
+}
+
+// Prewarm sends messages with a one token limit, so that OpenAI's automatic
+// prompt caching serves a following request continuing the conversation.
+// Only prefixes of 1024 tokens or more are cached, see Prefetcher.
+func (o *OpenAI) Prewarm(ctx context.Context, messages []Message) error {
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return err
+	}
+	opts := o.requestParams(ctx, &params)
+	if params.MaxCompletionTokens.Present {
+		params.MaxCompletionTokens = openai.F(int64(1))
+	} else {
+		params.MaxTokens = openai.F(int64(1))
+	}
+	_, err = o.client.Chat.Completions.New(ctx, params, opts...)
+	return err
+}
+
+func openAIAudioMimeType(format string) MimeType {
+	switch format {
+	case "wav":
+		return MimeTypeWAV
+	case "mp3":
+		return MimeTypeMP3
+	case "flac":
+		return MimeTypeFLAC
+	case "opus":
+		return MimeTypeOpus
+	}
+	return MimeTypePCM
+}
+
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (o *OpenAI) SetStopSequences(stop ...string) {
+	o.stop = stop
+}
+
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (o *OpenAI) SetSampling(s Sampling) {
+	o.sampling = s
+}
+
+// SetSeed sets the seed of every request, see WithSeed
+func (o *OpenAI) SetSeed(seed int64) {
+	o.seed = &seed
+}
+
+// SetReasoningEffort sets the reasoning effort of every request, see
+// WithReasoningEffort. It is sent as is, also to models that are not
+// recognized as reasoning models.
+func (o *OpenAI) SetReasoningEffort(effort ReasoningEffort) {
+	o.reasoningEffort = effort
+}
+
+// SetMaxCompletionTokens limits the tokens generated by every request,
+// reasoning tokens included, with max_completion_tokens instead of the
+// max_tokens given to the constructor. It is used with reasoning models
+// (o-series and GPT-5), which reject max_tokens, and defaults to the
+// constructor's limit for them.
+func (o *OpenAI) SetMaxCompletionTokens(maxTokens int64) {
+	o.maxCompletionTokens = maxTokens
+}
+
+// requestParams applies the request options of ctx to params, returning
+// request options to send with it
+func (o *OpenAI) requestParams(ctx context.Context, params *openai.ChatCompletionNewParams) []option.RequestOption {
+	params.Model = openai.F(requestModel(ctx, o.model))
+	if stop := stopSequences(ctx, o.stop); len(stop) > 0 {
+		params.Stop = openai.F[openai.ChatCompletionNewParamsStopUnion](openai.ChatCompletionNewParamsStopArray(stop))
+	}
+	if seed, ok := requestSeed(ctx, o.seed); ok {
+		params.Seed = openai.F(seed)
+	}
+	var opts []option.RequestOption
+	s := sampling(ctx, o.sampling)
+	if s.TopP != 0 {
+		params.TopP = openai.F(s.TopP)
+	}
+	if s.FrequencyPenalty != 0 {
+		params.FrequencyPenalty = openai.F(s.FrequencyPenalty)
+	}
+	if s.PresencePenalty != 0 {
+		params.PresencePenalty = openai.F(s.PresencePenalty)
+	}
+	if s.TopK != 0 && !o.isOpenAIHosted() {
+		// not part of the OpenAI API, which rejects it, but accepted by most
+		// compatible servers
+		opts = append(opts, option.WithJSONSet("top_k", s.TopK))
+	}
+	opts = append(opts, o.deterministic(ctx, params)...)
+	return append(opts, o.reasoning(ctx, params)...)
+}
+
+// isOpenAIHosted returns whether o calls the OpenAI API itself rather than a
+// compatible server
+func (o *OpenAI) isOpenAIHosted() bool {
+	u, err := url.Parse(o.baseURL)
+	return err == nil && u.Hostname() == "api.openai.com"
+}
+
+// reasoning applies the reasoning effort and completion limit to params.
+// Sampling parameters are removed for reasoning models, which reject them.
+func (o *OpenAI) reasoning(ctx context.Context, params *openai.ChatCompletionNewParams) []option.RequestOption {
+	if effort := reasoningEffort(ctx, o.reasoningEffort); effort != "" {
+		params.ReasoningEffort = openai.F(openai.ChatCompletionReasoningEffort(effort))
+	}
+	if o.maxCompletionTokens != 0 {
+		params.MaxCompletionTokens = openai.F(o.maxCompletionTokens)
+		params.MaxTokens.Present = false
+	}
+	if !isOpenAIReasoningModel(params.Model.Value) {
+		return nil
+	}
+	if params.MaxTokens.Present {
+		params.MaxCompletionTokens = params.MaxTokens
+		params.MaxTokens.Present = false
+	}
+	params.Temperature.Present = false
+	params.TopP.Present = false
+	params.FrequencyPenalty.Present = false
+	params.PresencePenalty.Present = false
+	return []option.RequestOption{option.WithJSONDel("top_k")}
+}
+
+// deterministic applies deterministic mode (see WithDeterministic) to params,
+// returning request options that remove penalties set on the client
+func (o *OpenAI) deterministic(ctx context.Context, params *openai.ChatCompletionNewParams) []option.RequestOption {
+	if !IsDeterministic(ctx) {
+		return nil
+	}
+	params.Temperature = openai.F(0.0)
+	params.TopP = openai.F(1.0)
+	if !params.Seed.Present {
+		params.Seed = openai.F(int64(DeterministicSeed))
+	}
+	var opts []option.RequestOption
+	for _, key := range samplingPenalties {
+		opts = append(opts, option.WithJSONDel(key))
+	}
+	return opts
+}
+
+func (o *OpenAI) messagesParams(ctx context.Context, messages []Message) (openai.ChatCompletionNewParams, error) {
 	chatMessages := make([]openai.ChatCompletionMessageParamUnion, len(messages))
 
 	for i, msg := range messages {
-		if msg.Image != nil {
-			// Convert image to base64
-			imageData, err := io.ReadAll(msg.Image)
+		if msg.hasMedia() {
+			parts, err := openAIContentParts(ctx, msg.ContentParts())
 			if err != nil {
-				return "", err
+				return openai.ChatCompletionNewParams{}, err
 			}
-			base64Image := base64.StdEncoding.EncodeToString(imageData)
-
-			// Create message with both text and image
-			chatMessages[i] = openai.UserMessageParts(
-				openai.ImagePart("data:" + string(msg.MimeType) + ";base64," + base64Image),
-			)
+			chatMessages[i] = openai.UserMessageParts(parts...)
 		} else {
 			// Regular text message
 			switch msg.Role {
 			case RoleUser:
-				chatMessages[i] = openai.UserMessage(msg.Content)
+				chatMessages[i] = openai.UserMessage(msg.text())
 			case RoleAssistant:
-				chatMessages[i] = openai.AssistantMessage(msg.Content)
+				chatMessages[i] = openAIAssistantMessage(msg)
 			case RoleSystem:
-				chatMessages[i] = openai.SystemMessage(msg.Content)
+				chatMessages[i] = openai.SystemMessage(msg.text())
+			case RoleTool:
+				chatMessages[i] = openai.ToolMessage(msg.ToolCallID, msg.text())
 			}
 		}
 	}
@@ -192,10 +662,5 @@ func (o *OpenAI) GenerateWithMessages(ctx context.Context, messages []Message) (
 			},
 		)
 	}
-
-	resp, err := o.client.Chat.Completions.New(ctx, params)
-	if err != nil {
-		return "", err
-	}
-	return resp.Choices[0].Message.Content, nil
+	return params, nil
 }
@@ -2,10 +2,10 @@ package ai
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -18,6 +18,9 @@ type OpenAIAlt struct {
 	maxTokens   int
 	temperature float32
 	isJson      bool
+	stop        []string
+	sampling    Sampling
+	seed        *int64
 }
 
 func NewOpenAIAlt(apiKey, model string, maxTokens int, temperature float32, isJson bool) *OpenAIAlt {
@@ -48,11 +51,12 @@ func (o *OpenAIAlt) Generate(ctx context.Context, systemPrompt, prompt string) (
 	}
 
 	req := openai.ChatCompletionRequest{
-		Model:       o.model,
+		Model:       requestModel(ctx, o.model),
 		Messages:    messages,
 		MaxTokens:   o.maxTokens,
 		Temperature: o.temperature,
 	}
+	o.deterministic(ctx, &req)
 
 	if o.isJson {
 		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
@@ -95,16 +99,32 @@ func (o *OpenAIAlt) GenerateStream(ctx context.Context, systemPrompt, prompt str
 		})
 	}
 
-	stream, err := o.client.CreateChatCompletionStream(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model:       o.model,
-			Messages:    messages,
-			MaxTokens:   o.maxTokens,
-			Temperature: o.temperature,
-			Stream:      true,
-		},
-	)
+	o.stream(ctx, messages, resultCh, doneCh, errCh)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, see MessagesStreamer
+func (o *OpenAIAlt) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	chatMessages, err := o.chatMessages(ctx, messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	o.stream(ctx, chatMessages, resultCh, doneCh, errCh)
+}
+
+func (o *OpenAIAlt) stream(ctx context.Context, messages []openai.ChatCompletionMessage, resultCh chan string, doneCh chan bool, errCh chan error) {
+	req := openai.ChatCompletionRequest{
+		Model:       requestModel(ctx, o.model),
+		Messages:    messages,
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+		Stream:      true,
+	}
+	o.deterministic(ctx, &req)
+	stream, err := o.client.CreateChatCompletionStream(ctx, req)
 
 	if err != nil {
 		select {
@@ -150,6 +170,10 @@ func (o *OpenAIAlt) GetModel() string {
 	return o.model
 }
 
+func (o *OpenAIAlt) Close() error {
+	return nil
+}
+
 func (o *OpenAIAlt) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
 	return o.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
 }
@@ -173,9 +197,42 @@ func (o *OpenAIAlt) GenerateWithImages(ctx context.Context, prompt string, image
 }
 
 func (o *OpenAIAlt) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	chatMessages, err := o.chatMessages(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model:       requestModel(ctx, o.model),
+		Messages:    chatMessages,
+		MaxTokens:   o.maxTokens,
+		Temperature: o.temperature,
+	}
+	o.deterministic(ctx, &req)
+
+	if o.isJson {
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+		}
+	}
+
+	resp, err := o.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", errors.New("no choices returned")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (o *OpenAIAlt) chatMessages(ctx context.Context, messages []Message) ([]openai.ChatCompletionMessage, error) {
 	var chatMessages []openai.ChatCompletionMessage
 
 	for _, msg := range messages {
+		msg = msg.flat()
 		message := openai.ChatCompletionMessage{
 			Role: string(msg.Role),
 		}
@@ -183,9 +240,9 @@ func (o *OpenAIAlt) GenerateWithMessages(ctx context.Context, messages []Message
 		if msg.Image != nil {
 			imageBytes, err := io.ReadAll(msg.Image)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
-			base64Image := base64.StdEncoding.EncodeToString(imageBytes)
+			base64Image := encodeBase64(ctx, imageBytes)
 
 			message.MultiContent = []openai.ChatMessagePart{
 				{
@@ -202,31 +259,57 @@ func (o *OpenAIAlt) GenerateWithMessages(ctx context.Context, messages []Message
 		} else {
 			message.Content = msg.Content
 		}
+		message.ToolCallID = msg.ToolCallID
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+				ID:       call.ID,
+				Type:     openai.ToolTypeFunction,
+				Function: openai.FunctionCall{Name: call.Name, Arguments: string(call.Arguments)},
+			})
+		}
 
 		chatMessages = append(chatMessages, message)
 	}
+	return chatMessages, nil
+}
 
-	req := openai.ChatCompletionRequest{
-		Model:       o.model,
-		Messages:    chatMessages,
-		MaxTokens:   o.maxTokens,
-		Temperature: o.temperature,
-	}
+// SetStopSequences sets the stop sequences of every request, see WithStopSequences
+func (o *OpenAIAlt) SetStopSequences(stop ...string) {
+	o.stop = stop
+}
 
-	if o.isJson {
-		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONObject,
-		}
-	}
+// SetSampling sets the sampling parameters of every request, see WithSampling
+func (o *OpenAIAlt) SetSampling(s Sampling) {
+	o.sampling = s
+}
 
-	resp, err := o.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", err
-	}
+// SetSeed sets the seed of every request, see WithSeed
+func (o *OpenAIAlt) SetSeed(seed int64) {
+	o.seed = &seed
+}
 
-	if len(resp.Choices) == 0 {
-		return "", errors.New("no choices returned")
+// deterministic applies the stop sequences, sampling parameters, seed and
+// deterministic mode (see WithDeterministic) to req. The API has no top_k.
+func (o *OpenAIAlt) deterministic(ctx context.Context, req *openai.ChatCompletionRequest) {
+	req.Stop = stopSequences(ctx, o.stop)
+	s := sampling(ctx, o.sampling)
+	req.TopP = float32(s.TopP)
+	req.FrequencyPenalty = float32(s.FrequencyPenalty)
+	req.PresencePenalty = float32(s.PresencePenalty)
+	if seed, ok := requestSeed(ctx, o.seed); ok {
+		n := int(seed)
+		req.Seed = &n
+	}
+	if !IsDeterministic(ctx) {
+		return
+	}
+	// a zero temperature is omitted from the request, which means 1
+	req.Temperature = math.SmallestNonzeroFloat32
+	req.TopP = 1
+	req.FrequencyPenalty = 0
+	req.PresencePenalty = 0
+	if req.Seed == nil {
+		seed := DeterministicSeed
+		req.Seed = &seed
 	}
-
-	return resp.Choices[0].Message.Content, nil
 }
@@ -77,7 +77,7 @@ func TestGenerateWithMessages(t *testing.T) {
 	}
 
 	llmGenOpenAI := NewOpenAI(cfg.APIKey, cfg.Model, int64(cfg.DefaultTokesLimit), 1.0, false)
-	// llmGenOpenAI := NewGroqClient(cfg.APIKey, cfg.Model, int64(cfg.DefaultTokesLimit), 1.0, false)
+	// llmGenOpenAI := NewGroq(cfg.APIKey, cfg.Model, int64(cfg.DefaultTokesLimit), 1.0, false)
 	// llmGenOpenAI := NewLambdaLabClient(cfg.APIKey, cfg.Model, int64(cfg.DefaultTokesLimit), 1.0, false)
 	// llmGenOpenAI := NewGoogleSimple(cfg.APIKey, cfg.Model, int64(cfg.DefaultTokesLimit), 1.0, false)
 
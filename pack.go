@@ -0,0 +1,151 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TrimMode is how PackContext trims a segment that does not fit
+type TrimMode int
+
+const (
+	// TrimWhole drops the segment entirely, e.g. for tool definitions or few-shot examples
+	TrimWhole TrimMode = iota
+	// TrimOldest drops messages from the start one at a time, e.g. for the history
+	TrimOldest
+	// TrimNewest drops messages from the end one at a time, e.g. for retrieved
+	// documents sorted by relevance
+	TrimNewest
+)
+
+// ContextSegment is a part of a prompt packed by PackContext
+type ContextSegment struct {
+	// Name identifies the segment in the PackReport, e.g. "history"
+	Name string
+	// Priority orders trimming, segments with lower priorities are trimmed
+	// first and equal priorities are trimmed from the last segment
+	Priority int
+	// Required segments are never trimmed
+	Required bool
+	Trim     TrimMode
+	Messages []Message
+}
+
+// PackOptions configures PackContext
+type PackOptions struct {
+	// MaxTokens is the context window of the model
+	MaxTokens int
+	// ReserveTokens are kept free for the response
+	ReserveTokens int
+	// MessageOverhead is the number of tokens added to each message for its
+	// role and formatting, 4 if zero
+	MessageOverhead int
+}
+
+// DroppedContext is what PackContext removed from a segment
+type DroppedContext struct {
+	Segment string
+	// Messages are the removed messages in their original order
+	Messages []Message
+	Tokens   int
+	// Whole is true if nothing is left of the segment
+	Whole bool
+}
+
+// PackReport describes the result of PackContext
+type PackReport struct {
+	// Budget is MaxTokens minus ReserveTokens
+	Budget int
+	// Tokens is the estimated size of the packed messages
+	Tokens int
+	// Dropped lists the trimmed segments in the order they were trimmed
+	Dropped []DroppedContext
+}
+
+// PackContext fits segments into the context window of model, counting
+// tokens with TokenizerFor. Segments that don't fit are trimmed by priority
+// until the rest does. The packed messages keep the order of segments.
+// An error is returned with the report if the required segments alone
+// exceed the budget.
+//
+//	messages, report, err := ai.PackContext(llm.GetModel(), []ai.ContextSegment{
+//		{Name: "system", Required: true, Messages: system},
+//		{Name: "examples", Priority: 1, Messages: examples},
+//		{Name: "documents", Priority: 2, Trim: ai.TrimNewest, Messages: docs},
+//		{Name: "history", Priority: 3, Trim: ai.TrimOldest, Messages: history},
+//		{Name: "question", Required: true, Messages: question},
+//	}, ai.PackOptions{MaxTokens: 128000, ReserveTokens: 4000})
+func PackContext(model string, segments []ContextSegment, opts PackOptions) ([]Message, *PackReport, error) {
+	overhead := opts.MessageOverhead
+	if overhead == 0 {
+		overhead = messageTokenOverhead
+	}
+	tokenizer := TokenizerFor(model)
+	report := &PackReport{Budget: opts.MaxTokens - opts.ReserveTokens}
+
+	// kept[i] are the messages left of segments[i] and tokens[i] their sizes
+	kept := make([][]Message, len(segments))
+	tokens := make([][]int, len(segments))
+	for i, s := range segments {
+		kept[i] = s.Messages
+		for _, msg := range s.Messages {
+			n := tokenizer.CountTokens(msg.text()) + overhead
+			tokens[i] = append(tokens[i], n)
+			report.Tokens += n
+		}
+	}
+
+	var order []int
+	for i, s := range segments {
+		if !s.Required && len(s.Messages) > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		pa, pb := segments[order[a]].Priority, segments[order[b]].Priority
+		if pa != pb {
+			return pa < pb
+		}
+		return order[a] > order[b]
+	})
+
+	for _, i := range order {
+		if report.Tokens <= report.Budget {
+			break
+		}
+		dropped := DroppedContext{Segment: segments[i].Name}
+		for len(kept[i]) > 0 && report.Tokens > report.Budget {
+			if segments[i].Trim == TrimWhole {
+				for _, n := range tokens[i] {
+					dropped.Tokens += n
+					report.Tokens -= n
+				}
+				dropped.Messages = kept[i]
+				kept[i], tokens[i] = nil, nil
+				break
+			}
+			j := len(kept[i]) - 1
+			if segments[i].Trim == TrimOldest {
+				j = 0
+				dropped.Messages = append(dropped.Messages, kept[i][j])
+			} else {
+				dropped.Messages = append([]Message{kept[i][j]}, dropped.Messages...)
+			}
+			dropped.Tokens += tokens[i][j]
+			report.Tokens -= tokens[i][j]
+			kept[i] = append(kept[i][:j:j], kept[i][j+1:]...)
+			tokens[i] = append(tokens[i][:j:j], tokens[i][j+1:]...)
+		}
+		dropped.Whole = len(kept[i]) == 0
+		report.Dropped = append(report.Dropped, dropped)
+	}
+
+	if report.Tokens > report.Budget {
+		return nil, report, fmt.Errorf("required context of %d tokens exceeds the budget of %d tokens", report.Tokens, report.Budget)
+	}
+	var messages []Message
+	for _, msgs := range kept {
+		messages = append(messages, msgs...)
+	}
+	return messages, report, nil
+}
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackContext(t *testing.T) {
+	// 10 tokens each with the 4 characters per token heuristic, 14 with overhead
+	msg := func(role Role, name string) Message {
+		return Message{Role: role, Content: name + strings.Repeat(".", 40-len(name))}
+	}
+	segments := []ContextSegment{
+		{Name: "system", Required: true, Messages: []Message{msg(RoleSystem, "system")}},
+		{Name: "examples", Priority: 1, Messages: []Message{msg(RoleUser, "q"), msg(RoleAssistant, "a")}},
+		{Name: "documents", Priority: 2, Trim: TrimNewest, Messages: []Message{msg(RoleUser, "doc1"), msg(RoleUser, "doc2")}},
+		{Name: "history", Priority: 3, Trim: TrimOldest, Messages: []Message{msg(RoleUser, "h1"), msg(RoleAssistant, "h2"), msg(RoleUser, "h3")}},
+		{Name: "question", Required: true, Messages: []Message{msg(RoleUser, "question")}},
+	}
+	names := func(messages []Message) string {
+		var res []string
+		for _, m := range messages {
+			res = append(res, strings.TrimRight(m.Content, "."))
+		}
+		return strings.Join(res, " ")
+	}
+
+	messages, report, err := PackContext("gpt-4", segments, PackOptions{MaxTokens: 200})
+	if err != nil || len(messages) != 9 || report.Tokens != 126 || len(report.Dropped) != 0 {
+		t.Fatalf("unexpected packing %q, %+v, %v", names(messages), report, err)
+	}
+
+	// 5 messages fit: examples go first, then the last document, then the oldest history
+	messages, report, err = PackContext("gpt-4", segments, PackOptions{MaxTokens: 100, ReserveTokens: 31})
+	if err != nil || names(messages) != "system h2 h3 question" || report.Tokens != 56 || report.Budget != 69 {
+		t.Fatalf("unexpected packing %q, %+v, %v", names(messages), report, err)
+	}
+	if len(report.Dropped) != 3 ||
+		report.Dropped[0].Segment != "examples" || !report.Dropped[0].Whole || report.Dropped[0].Tokens != 28 ||
+		names(report.Dropped[1].Messages) != "doc1 doc2" || !report.Dropped[1].Whole ||
+		names(report.Dropped[2].Messages) != "h1" || report.Dropped[2].Whole {
+		t.Errorf("unexpected dropped context %+v", report.Dropped)
+	}
+	if len(segments[3].Messages) != 3 {
+		t.Error("segments must not be modified")
+	}
+
+	if _, report, err = PackContext("gpt-4", segments, PackOptions{MaxTokens: 20}); err == nil || report.Tokens != 28 {
+		t.Errorf("expected an error for required segments over the budget, got %+v", report)
+	}
+}
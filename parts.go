@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"io"
+	"strings"
+)
+
+// PartType is the kind of a ContentPart
+type PartType string
+
+const (
+	PartText     PartType = "text"
+	PartImage    PartType = "image"
+	PartAudio    PartType = "audio"
+	PartDocument PartType = "document"
+	PartFile     PartType = "file"
+)
+
+// ContentPart is a part of a message, see Message.Parts
+type ContentPart struct {
+	Type PartType
+	Text string
+	// Data and MimeType hold images, audio and documents
+	Data     io.Reader
+	MimeType MimeType
+	// File is an uploaded file, see FileStore
+	File *ProviderFile
+}
+
+// TextPart creates a text part
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: PartText, Text: text}
+}
+
+// ImagePart creates an image part
+func ImagePart(image io.Reader, mimeType MimeType) ContentPart {
+	return ContentPart{Type: PartImage, Data: image, MimeType: mimeType}
+}
+
+// AudioPart creates an audio part
+func AudioPart(audio io.Reader, mimeType MimeType) ContentPart {
+	return ContentPart{Type: PartAudio, Data: audio, MimeType: mimeType}
+}
+
+// DocumentPart creates a document part, e.g. a PDF
+func DocumentPart(document io.Reader, mimeType MimeType) ContentPart {
+	return ContentPart{Type: PartDocument, Data: document, MimeType: mimeType}
+}
+
+// FilePart creates a part referencing an uploaded file
+func FilePart(file ProviderFile) ContentPart {
+	return ContentPart{Type: PartFile, File: &file, MimeType: file.MimeType}
+}
+
+// ContentParts returns the parts of the message: Parts if set, otherwise the
+// file, document, image, audio and text fields in this order
+func (m Message) ContentParts() []ContentPart {
+	if len(m.Parts) > 0 {
+		return m.Parts
+	}
+	var parts []ContentPart
+	if m.File != nil {
+		parts = append(parts, FilePart(*m.File))
+	}
+	if m.Document != nil {
+		parts = append(parts, DocumentPart(m.Document, m.DocumentMimeType))
+	}
+	if m.Image != nil {
+		parts = append(parts, ImagePart(m.Image, m.MimeType))
+	}
+	if m.Audio != nil {
+		parts = append(parts, AudioPart(m.Audio, m.AudioMimeType))
+	}
+	if m.Content != "" {
+		parts = append(parts, TextPart(m.Content))
+	}
+	return parts
+}
+
+// hasMedia reports whether the message has parts other than its text
+func (m Message) hasMedia() bool {
+	for _, part := range m.ContentParts() {
+		if part.Type != PartText {
+			return true
+		}
+	}
+	return false
+}
+
+// flat returns the message with the text of its parts as Content and its
+// first image as Image, for providers without ordered parts
+func (m Message) flat() Message {
+	if len(m.Parts) == 0 {
+		return m
+	}
+	m.Content = m.text()
+	for _, part := range m.Parts {
+		if part.Type == PartImage {
+			m.Image, m.MimeType = part.Data, part.MimeType
+			break
+		}
+	}
+	m.Parts = nil
+	return m
+}
+
+// text returns the text of the message, joining text parts with newlines
+func (m Message) text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	var texts []string
+	for _, part := range m.Parts {
+		if part.Type == PartText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContentParts(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		case "/messages":
+			io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+		default:
+			io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	messages := func() []Message {
+		return Chat{}.UserParts(
+			TextPart("Before:"), ImagePart(strings.NewReader("a"), MimeTypePNG),
+			TextPart("After:"), ImagePart(strings.NewReader("b"), MimeTypePNG),
+		).Messages()
+	}
+	// kinds returns the part types of the first message, with images as
+	// their data
+	kinds := func(key, partsKey string, kind func(map[string]interface{}) string) string {
+		msg := body[key].([]interface{})[0].(map[string]interface{})
+		var res []string
+		for _, part := range msg[partsKey].([]interface{}) {
+			res = append(res, kind(part.(map[string]interface{})))
+		}
+		return strings.Join(res, " ")
+	}
+	want := "Before: YQ== After: Yg=="
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o", 100, 0, false)
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages()); err != nil {
+		t.Fatal(err)
+	}
+	got := kinds("messages", "content", func(p map[string]interface{}) string {
+		if url, ok := p["image_url"].(map[string]interface{}); ok {
+			return strings.TrimPrefix(url["url"].(string), "data:image/png;base64,")
+		}
+		return p["text"].(string)
+	})
+	if got != want {
+		t.Errorf("unexpected OpenAI parts %q", got)
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	if _, err := anthropic.GenerateWithMessages(context.Background(), messages()); err != nil {
+		t.Fatal(err)
+	}
+	got = kinds("messages", "content", func(p map[string]interface{}) string {
+		if source, ok := p["source"].(map[string]interface{}); ok {
+			return source["data"].(string)
+		}
+		return p["text"].(string)
+	})
+	if got != want {
+		t.Errorf("unexpected Anthropic parts %q", got)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if _, err := gemini.GenerateResponse(context.Background(), messages()); err != nil {
+		t.Fatal(err)
+	}
+	got = kinds("contents", "parts", func(p map[string]interface{}) string {
+		if data, ok := p["inlineData"].(map[string]interface{}); ok {
+			return data["data"].(string)
+		}
+		return p["text"].(string)
+	})
+	if got != want {
+		t.Errorf("unexpected Gemini parts %q", got)
+	}
+
+	// providers without parts get the text and the first image
+	flat := messages()[0].flat()
+	if flat.Content != "Before:\nAfter:" || flat.Image == nil || flat.Parts != nil {
+		t.Errorf("unexpected flat message %+v", flat)
+	}
+}
+
+func TestContentPartsBuffered(t *testing.T) {
+	newMessages, err := bufferMessages([]Message{{Role: RoleUser, Parts: []ContentPart{
+		TextPart("Describe"), ImagePart(strings.NewReader("png"), MimeTypePNG),
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data, _ := io.ReadAll(newMessages()[0].Parts[1].Data)
+		if string(data) != "png" {
+			t.Fatalf("attempt %d read %q", i, data)
+		}
+	}
+}
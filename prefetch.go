@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prewarmer is implemented by clients that can warm provider side caches
+// with a conversation, lowering the time to first token of the next request
+type Prewarmer interface {
+	Prewarm(ctx context.Context, messages []Message) error
+}
+
+// Prefetcher speculatively prewarms the provider with the history of a chat
+// while the user is typing the next message. Call Update with the current
+// history when the user starts typing: the history is prewarmed after a
+// delay, unless it changes first. Editing the history cancels a prewarm in
+// flight and schedules the new one. Histories are prewarmed at most once.
+//
+// Clients that do not implement Prewarmer are not prewarmed. Histories with
+// images are skipped, as prewarming would consume the image readers.
+type Prefetcher struct {
+	llm   LLM
+	delay time.Duration
+	// errorCallback receives prewarm errors other than cancellations (optional)
+	errorCallback func(error)
+
+	mu      sync.Mutex
+	pending string
+	warmed  string
+	cancel  context.CancelFunc
+}
+
+// NewPrefetcher creates a Prefetcher for llm, delay avoids prewarming
+// histories that are about to change
+func NewPrefetcher(llm LLM, delay time.Duration, errorCallback func(error)) *Prefetcher {
+	return &Prefetcher{llm: llm, delay: delay, errorCallback: errorCallback}
+}
+
+// Update schedules a prewarm of history. ctx bounds the prewarm, e.g. the
+// lifetime of the chat session.
+func (p *Prefetcher) Update(ctx context.Context, history []Message) {
+	prewarmer, ok := p.llm.(Prewarmer)
+	if !ok || len(history) == 0 {
+		return
+	}
+	for _, msg := range history {
+		if msg.Image != nil {
+			return
+		}
+	}
+	key := historyKey(history)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key == p.pending || key == p.warmed {
+		return
+	}
+	if p.cancel != nil {
+		p.cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.pending, p.cancel = key, cancel
+	// Copy the history, the caller may append to it
+	history = append([]Message(nil), history...)
+
+	go func() {
+		defer cancel()
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return
+		}
+		err := prewarmer.Prewarm(ctx, history)
+
+		p.mu.Lock()
+		if p.pending == key {
+			p.pending, p.cancel = "", nil
+			if err == nil {
+				p.warmed = key
+			}
+		}
+		p.mu.Unlock()
+		if err != nil && !errors.Is(err, context.Canceled) && p.errorCallback != nil {
+			p.errorCallback(err)
+		}
+	}()
+}
+
+// Cancel stops a scheduled or running prewarm, e.g. when the message is sent
+func (p *Prefetcher) Cancel() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+		p.pending, p.cancel = "", nil
+	}
+}
+
+// historyKey identifies a history by the roles and hashed contents of its messages
+func historyKey(history []Message) string {
+	var b strings.Builder
+	for _, msg := range history {
+		b.WriteString(string(msg.Role))
+		b.WriteByte(':')
+		b.WriteString(ContentHash([]byte(msg.Content)))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// prewarmLLM records prewarmed histories, prewarms block until release is closed
+type prewarmLLM struct {
+	stubLLM
+	release  chan struct{}
+	started  chan string
+	finished chan string
+}
+
+func (p *prewarmLLM) Prewarm(ctx context.Context, messages []Message) error {
+	last := messages[len(messages)-1].Content
+	p.started <- last
+	select {
+	case <-p.release:
+		p.finished <- last
+		return nil
+	case <-ctx.Done():
+		p.finished <- "canceled " + last
+		return ctx.Err()
+	}
+}
+
+func TestPrefetcher(t *testing.T) {
+	llm := &prewarmLLM{release: make(chan struct{}), started: make(chan string, 10), finished: make(chan string, 10)}
+	var errs []error
+	p := NewPrefetcher(llm, time.Millisecond, func(err error) { errs = append(errs, err) })
+	ctx := context.Background()
+
+	history := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}
+	p.Update(ctx, history)
+	if got := <-llm.started; got != "hello" {
+		t.Fatalf("unexpected prewarm %q", got)
+	}
+
+	// Editing the history cancels the prewarm in flight
+	edited := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello!"}}
+	p.Update(ctx, edited)
+	p.Update(ctx, edited)
+	if got := <-llm.finished; got != "canceled hello" {
+		t.Fatalf("expected cancellation, got %q", got)
+	}
+	if got := <-llm.started; got != "hello!" {
+		t.Fatalf("unexpected prewarm %q", got)
+	}
+	close(llm.release)
+	if got := <-llm.finished; got != "hello!" {
+		t.Fatalf("unexpected prewarm %q", got)
+	}
+
+	// Warmed histories are not prewarmed again
+	time.Sleep(10 * time.Millisecond)
+	p.Update(ctx, edited)
+	time.Sleep(10 * time.Millisecond)
+	if len(llm.started) != 0 || len(errs) != 0 {
+		t.Errorf("unexpected prewarms %d, errors %v", len(llm.started), errs)
+	}
+}
+
+func TestAnthropicPrewarm(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, true)
+	llm.SetBaseURL(server.URL)
+
+	history := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}}
+	if err := llm.Prewarm(context.Background(), history); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llm.GenerateWithMessages(context.Background(), append(history, Message{Role: RoleUser, Content: "how are you?"})); err != nil {
+		t.Fatal(err)
+	}
+
+	cached := func(body map[string]interface{}, i int) bool {
+		msg := body["messages"].([]interface{})[i].(map[string]interface{})
+		content := msg["content"].([]interface{})
+		return content[len(content)-1].(map[string]interface{})["cache_control"] != nil
+	}
+	if bodies[0]["max_tokens"] != 1.0 || !cached(bodies[0], 1) {
+		t.Errorf("unexpected prewarm request %v", bodies[0])
+	}
+	if !cached(bodies[1], 1) || cached(bodies[1], 2) {
+		t.Errorf("expected the history to be cached in %v", bodies[1])
+	}
+}
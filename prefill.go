@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// prefill returns the content of the final assistant message of messages, or
+// "" if there is none. Trailing whitespace is trimmed, Claude rejects it.
+func prefill(messages []Message) string {
+	if len(messages) == 0 {
+		return ""
+	}
+	last := messages[len(messages)-1]
+	if last.Role != RoleAssistant || len(last.ToolCalls) > 0 {
+		return ""
+	}
+	return strings.TrimRightFunc(last.text(), unicode.IsSpace)
+}
+
+const prefillPrompt = `Start your reply with exactly this text and continue it, without repeating or explaining it:
+%s`
+
+// emulatePrefill replaces the prefill of messages with an instruction to
+// start the reply with it, for clients without native prefill
+func emulatePrefill(messages []Message) ([]Message, string) {
+	p := prefill(messages)
+	if p == "" {
+		return messages, ""
+	}
+	msgs := make([]Message, len(messages))
+	copy(msgs, messages)
+	msgs[len(msgs)-1] = Message{Role: RoleUser, Content: fmt.Sprintf(prefillPrompt, p)}
+	return msgs, p
+}
+
+// withPrefill returns the reply to an emulated prefill p starting with it.
+// The model may repeat the prefill, so it is only added if missing.
+func withPrefill(p, res string) string {
+	if p == "" {
+		return res
+	}
+	if trimmed := strings.TrimLeftFunc(res, unicode.IsSpace); strings.HasPrefix(trimmed, p) {
+		return trimmed
+	}
+	return p + res
+}
+
+// sendPrefill sends an emulated prefill p as the first chunk of a streamed
+// reply, it returns false if ctx is done first
+func sendPrefill(ctx context.Context, p string, resultCh chan string) bool {
+	if p == "" {
+		return true
+	}
+	select {
+	case resultCh <- p:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,122 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+)
+
+func TestPrefill(t *testing.T) {
+	var body map[string]interface{}
+	reply := `"red", "green"]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/messages" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id": "msg_1", "type": "message", "role": "assistant", "stop_reason": "end_turn",
+				"content": []map[string]string{{"type": "text", "text": reply}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "1", "object": "chat.completion", "model": "m",
+			"choices": []map[string]interface{}{{"index": 0, "finish_reason": "stop", "message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	defer server.Close()
+	messages := Chat{}.User("Two colors as a JSON array").Assistant("[ \n").Messages()
+	last := func() map[string]interface{} {
+		list := body["messages"].([]interface{})
+		return list[len(list)-1].(map[string]interface{})
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	res, err := anthropic.GenerateWithMessages(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != `["red", "green"]` {
+		t.Errorf("expected the reply to start with the prefill, got %q", res)
+	}
+	if content := last()["content"].([]interface{})[0].(map[string]interface{}); last()["role"] != "assistant" || content["text"] != "[" {
+		t.Errorf("expected the trimmed prefill as the last message, got %v", last())
+	}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	if res, err = openAI.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if res != `["red", "green"]` {
+		t.Errorf("expected the reply to start with the prefill, got %q", res)
+	}
+	if content, _ := json.Marshal(last()["content"]); last()["role"] != "user" || !strings.Contains(string(content), `:\n[`) {
+		t.Errorf("expected an instruction to start with the prefill, got %v", last())
+	}
+
+	reply = `["red", "green"]`
+	if res, err = openAI.GenerateWithMessages(context.Background(), messages); err != nil || res != reply {
+		t.Errorf("expected a repeated prefill to be kept once, got %q, %v", res, err)
+	}
+}
+
+func TestPrefillIgnored(t *testing.T) {
+	messages := Chat{}.User("Hi").Messages()
+	if got, p := emulatePrefill(messages); p != "" || len(got) != 1 {
+		t.Errorf("unexpected prefill %q", p)
+	}
+	if res := withPrefill("", " hello"); res != " hello" {
+		t.Errorf("reply without prefill changed to %q", res)
+	}
+	if p := prefill([]Message{{Role: RoleAssistant, ToolCalls: []ToolCall{{Name: "f"}}}}); p != "" {
+		t.Errorf("tool calls are not a prefill, got %q", p)
+	}
+}
+
+func TestPrefillStream(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\\\"red\\\"]\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+	messages := Chat{}.User("A color as a JSON array").Assistant("[").Messages()
+	collect := func(llm LLM) string {
+		var out strings.Builder
+		if err := consumeMessagesStream(context.Background(), llm, messages, func(chunk string) error {
+			out.WriteString(chunk)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	if got := collect(NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)); got != `["red"]` {
+		t.Errorf("expected the stream to start with the prefill, got %q", got)
+	}
+	list := body["messages"].([]interface{})
+	if last := list[len(list)-1].(map[string]interface{}); last["role"] != "user" {
+		t.Errorf("expected an instruction to start with the prefill, got %v", last)
+	}
+
+	fake := &fakePredictionServer{response: func(req *aiplatformpb.GenerateContentRequest) *aiplatformpb.GenerateContentResponse {
+		return vertexText(`"red"]`)
+	}}
+	if got := collect(newFakeVertex(t, fake, "gemini-2.0-flash-001")); got != `["red"]` {
+		t.Errorf("expected the stream to start with the prefill, got %q", got)
+	}
+	contents := fake.requests[0].Contents
+	if last := contents[len(contents)-1]; last.Role != "user" || !strings.Contains(last.Parts[0].GetText(), "\n[") {
+		t.Errorf("expected an instruction to start with the prefill, got %v", last)
+	}
+}
@@ -0,0 +1,164 @@
+package ai
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Names of the built-in prompts used by Summarize, Extract, Classify, Rewrite,
+// Judge and DescribeImage
+const (
+	PromptSummarize     = "summarize"
+	PromptExtract       = "extract"
+	PromptClassify      = "classify"
+	PromptRewrite       = "rewrite"
+	PromptJudge         = "judge"
+	PromptDescribeImage = "describe_image"
+)
+
+//go:embed prompts/*.tmpl
+var promptFiles embed.FS
+
+// PromptTemplate is a versioned pair of system and user prompt templates.
+// Its text defines both with text/template:
+//
+//	{{define "system"}}You summarize texts.{{end}}
+//	{{define "user"}}Summarize:\n{{.Text}}{{end}}
+type PromptTemplate struct {
+	Name    string
+	Version int
+
+	tmpl *template.Template
+}
+
+// ParsePrompt parses a prompt template, which must define "system" and "user"
+func ParsePrompt(name string, version int, text string) (*PromptTemplate, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("prompt %s: %w", name, err)
+	}
+	for _, part := range []string{"system", "user"} {
+		if tmpl.Lookup(part) == nil {
+			return nil, fmt.Errorf("prompt %s: missing %q template", name, part)
+		}
+	}
+	return &PromptTemplate{Name: name, Version: version, tmpl: tmpl}, nil
+}
+
+// Render executes the templates with data
+func (p *PromptTemplate) Render(data any) (systemPrompt, prompt string, err error) {
+	var buf strings.Builder
+	if err := p.tmpl.ExecuteTemplate(&buf, "system", data); err != nil {
+		return "", "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	systemPrompt = buf.String()
+	buf.Reset()
+	if err := p.tmpl.ExecuteTemplate(&buf, "user", data); err != nil {
+		return "", "", fmt.Errorf("prompt %s: %w", p.Name, err)
+	}
+	return systemPrompt, buf.String(), nil
+}
+
+var (
+	promptsOnce sync.Once
+	// builtinPrompts holds the embedded prompts by name, sorted by version
+	builtinPrompts map[string][]*PromptTemplate
+
+	promptsMu       sync.RWMutex
+	promptOverrides = map[string]*PromptTemplate{}
+)
+
+// loadPrompts parses the embedded prompts/<name>.v<version>.tmpl files
+func loadPrompts() {
+	builtinPrompts = map[string][]*PromptTemplate{}
+	files, _ := fs.Glob(promptFiles, "prompts/*.tmpl")
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".tmpl")
+		i := strings.LastIndex(base, ".v")
+		if i < 0 {
+			panic(fmt.Sprintf("ai: prompt file %s has no version", file))
+		}
+		version, err := strconv.Atoi(base[i+2:])
+		if err != nil {
+			panic(fmt.Sprintf("ai: prompt file %s: invalid version", file))
+		}
+		text, _ := promptFiles.ReadFile(file)
+		p, err := ParsePrompt(base[:i], version, string(text))
+		if err != nil {
+			panic("ai: " + err.Error())
+		}
+		builtinPrompts[p.Name] = append(builtinPrompts[p.Name], p)
+	}
+	for _, versions := range builtinPrompts {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	}
+}
+
+// Prompt returns the prompt used for name: the one set with SetPrompt, or
+// the latest built-in version. It returns nil for unknown names.
+func Prompt(name string) *PromptTemplate {
+	promptsMu.RLock()
+	p, ok := promptOverrides[name]
+	promptsMu.RUnlock()
+	if ok {
+		return p
+	}
+	promptsOnce.Do(loadPrompts)
+	versions := builtinPrompts[name]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
+}
+
+// PromptVersion returns a specific built-in version of a prompt, e.g. to pin
+// it with SetPrompt when upgrading the library, or nil if it does not exist
+func PromptVersion(name string, version int) *PromptTemplate {
+	promptsOnce.Do(loadPrompts)
+	for _, p := range builtinPrompts[name] {
+		if p.Version == version {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetPrompt replaces the prompt used for name, nil restores the built-in one
+func SetPrompt(name string, p *PromptTemplate) {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	if p == nil {
+		delete(promptOverrides, name)
+		return
+	}
+	promptOverrides[name] = p
+}
+
+// renderPrompt renders the prompt used for name: the one set with SetPrompt,
+// the one of the prompt store or the latest built-in version
+func renderPrompt(ctx context.Context, name string, data any) (systemPrompt, prompt string, err error) {
+	promptsMu.RLock()
+	p, ok := promptOverrides[name]
+	store := promptStore
+	promptsMu.RUnlock()
+	if !ok && store != nil {
+		if p, err = store.Resolve(ctx, name); err != nil {
+			return "", "", err
+		}
+	}
+	if p == nil {
+		p = Prompt(name)
+	}
+	if p == nil {
+		return "", "", fmt.Errorf("unknown prompt %s", name)
+	}
+	return p.Render(data)
+}
@@ -0,0 +1,213 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PromptStore resolves prompts at call time, so prompt changes can be shipped
+// and rolled back without a code deploy. Set one with SetPromptStore for the
+// built-in prompts, or call it directly for your own.
+type PromptStore interface {
+	// Resolve returns the prompt to use for name in ctx, or nil if the store
+	// does not know the prompt
+	Resolve(ctx context.Context, name string) (*PromptTemplate, error)
+}
+
+type promptEnvironmentKey struct{}
+
+// WithPromptEnvironment returns a context whose prompts are resolved for env,
+// e.g. "dev", "staging" or "prod", instead of the store default
+func WithPromptEnvironment(ctx context.Context, env string) context.Context {
+	return context.WithValue(ctx, promptEnvironmentKey{}, env)
+}
+
+type rolloutKeyKey struct{}
+
+// WithRolloutKey returns a context whose prompts are resolved for key, e.g. a
+// user or conversation ID, so that rollouts serve the same version to the
+// same key. Without a key, versions are picked at random for each call.
+func WithRolloutKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, rolloutKeyKey{}, key)
+}
+
+// PromptRelease is the version of a prompt served in an environment
+type PromptRelease struct {
+	Version int `json:"version" yaml:"version"`
+	// RolloutVersion is served instead of Version to RolloutPercent percent
+	// of the rollout keys, see WithRolloutKey
+	RolloutVersion int     `json:"rollout_version,omitempty" yaml:"rollout_version"`
+	RolloutPercent float64 `json:"rollout_percent,omitempty" yaml:"rollout_percent"`
+}
+
+// PromptConfig describes the versions and releases of a prompt, see
+// LoadPromptRegistry
+type PromptConfig struct {
+	Name string `json:"name" yaml:"name"`
+	// Versions are prompt template texts by version, see ParsePrompt
+	Versions map[int]string `json:"versions" yaml:"versions"`
+	// Environments are the releases by environment
+	Environments map[string]PromptRelease `json:"environments" yaml:"environments"`
+}
+
+// PromptRegistry is an in-memory PromptStore of versioned prompts released
+// per environment, with percentage rollouts
+type PromptRegistry struct {
+	defaultEnv string
+
+	mu       sync.RWMutex
+	versions map[string]map[int]*PromptTemplate
+	releases map[string]map[string]PromptRelease
+}
+
+// NewPromptRegistry creates a PromptRegistry resolving prompts for defaultEnv
+// unless the context has another environment, see WithPromptEnvironment
+func NewPromptRegistry(defaultEnv string) *PromptRegistry {
+	return &PromptRegistry{
+		defaultEnv: defaultEnv,
+		versions:   map[string]map[int]*PromptTemplate{},
+		releases:   map[string]map[string]PromptRelease{},
+	}
+}
+
+// LoadPromptRegistry loads prompts from YAML or JSON:
+//
+//	prompts:
+//	  - name: summarize
+//	    versions:
+//	      1: '{{define "system"}}...{{end}}{{define "user"}}...{{end}}'
+//	      2: '{{define "system"}}...{{end}}{{define "user"}}...{{end}}'
+//	    environments:
+//	      staging: {version: 2}
+//	      prod: {version: 1, rollout_version: 2, rollout_percent: 10}
+//
+// To update the prompts of a running process, load a new registry and swap
+// it in with SetPromptStore.
+func LoadPromptRegistry(r io.Reader, defaultEnv string) (*PromptRegistry, error) {
+	var file struct {
+		Prompts []PromptConfig `json:"prompts" yaml:"prompts"`
+	}
+	if err := yaml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode prompts: %w", err)
+	}
+	reg := NewPromptRegistry(defaultEnv)
+	for _, c := range file.Prompts {
+		for version, text := range c.Versions {
+			p, err := ParsePrompt(c.Name, version, text)
+			if err != nil {
+				return nil, err
+			}
+			if err := reg.Add(p); err != nil {
+				return nil, err
+			}
+		}
+		for env, release := range c.Environments {
+			if err := reg.Release(c.Name, env, release); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return reg, nil
+}
+
+// Add adds a prompt version. Versions are immutable, adding one twice fails.
+func (r *PromptRegistry) Add(p *PromptTemplate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.versions[p.Name] == nil {
+		r.versions[p.Name] = map[int]*PromptTemplate{}
+	}
+	if _, ok := r.versions[p.Name][p.Version]; ok {
+		return fmt.Errorf("prompt %s version %d already exists", p.Name, p.Version)
+	}
+	r.versions[p.Name][p.Version] = p
+	return nil
+}
+
+// Release sets the versions of name served in env. Rolling back is releasing
+// the previous version again.
+func (r *PromptRegistry) Release(name, env string, release PromptRelease) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, version := range []int{release.Version, release.RolloutVersion} {
+		if _, ok := r.versions[name][version]; version != 0 && !ok {
+			return fmt.Errorf("prompt %s has no version %d", name, version)
+		}
+	}
+	if release.Version == 0 {
+		return fmt.Errorf("prompt %s: release without version", name)
+	}
+	if release.RolloutPercent < 0 || release.RolloutPercent > 100 {
+		return fmt.Errorf("prompt %s: invalid rollout percent %v", name, release.RolloutPercent)
+	}
+	if r.releases[name] == nil {
+		r.releases[name] = map[string]PromptRelease{}
+	}
+	r.releases[name][env] = release
+	return nil
+}
+
+// Versions returns the versions of name, in increasing order
+func (r *PromptRegistry) Versions(name string) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var versions []int
+	for version := range r.versions[name] {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Resolve returns the version of name released in the environment of ctx,
+// picking the rollout version for the rollout key of ctx if it falls in the
+// rollout percentage. It fails if the prompt is not released there.
+func (r *PromptRegistry) Resolve(ctx context.Context, name string) (*PromptTemplate, error) {
+	env, ok := ctx.Value(promptEnvironmentKey{}).(string)
+	if !ok {
+		env = r.defaultEnv
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.versions[name] == nil {
+		return nil, nil
+	}
+	release, ok := r.releases[name][env]
+	if !ok {
+		return nil, fmt.Errorf("prompt %s is not released in %q", name, env)
+	}
+	version := release.Version
+	if release.RolloutVersion != 0 && rolloutBucket(ctx, name) < release.RolloutPercent {
+		version = release.RolloutVersion
+	}
+	return r.versions[name][version], nil
+}
+
+// rolloutBucket returns a number in [0, 100), stable for the rollout key of
+// ctx and name
+func rolloutBucket(ctx context.Context, name string) float64 {
+	key, ok := ctx.Value(rolloutKeyKey{}).(string)
+	if !ok {
+		return rand.Float64() * 100
+	}
+	h := fnv.New32a()
+	io.WriteString(h, name+"\x00"+key)
+	return float64(h.Sum32()%10000) / 100
+}
+
+var promptStore PromptStore
+
+// SetPromptStore makes the built-in prompts resolve through store, after the
+// prompts set with SetPrompt and before the built-in versions. Nil removes it.
+func SetPromptStore(store PromptStore) {
+	promptsMu.Lock()
+	defer promptsMu.Unlock()
+	promptStore = store
+}
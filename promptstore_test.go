@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const promptsYAML = `
+prompts:
+  - name: summarize
+    versions:
+      1: '{{define "system"}}v1{{end}}{{define "user"}}{{.Text}}{{end}}'
+      2: '{{define "system"}}v2{{end}}{{define "user"}}{{.Text}}{{end}}'
+    environments:
+      staging: {version: 2}
+      prod: {version: 1, rollout_version: 2, rollout_percent: 30}
+`
+
+func TestPromptRegistry(t *testing.T) {
+	reg, err := LoadPromptRegistry(strings.NewReader(promptsYAML), "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPromptStore(reg)
+	defer SetPromptStore(nil)
+
+	var systemPrompts []string
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		systemPrompts = append(systemPrompts, systemPrompt)
+		return "ok", nil
+	}}
+	ctx := WithPromptEnvironment(context.Background(), "staging")
+	if _, err := Summarize(ctx, llm, "text", 10); err != nil || systemPrompts[0] != "v2" {
+		t.Fatalf("expected the staging version, got %q, %v", systemPrompts, err)
+	}
+
+	// Rollouts are sticky per key and serve about the given share of keys
+	rollout := 0
+	for i := 0; i < 1000; i++ {
+		ctx := WithRolloutKey(context.Background(), fmt.Sprint("user", i))
+		p, err := reg.Resolve(ctx, PromptSummarize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := reg.Resolve(ctx, PromptSummarize)
+		if p != again {
+			t.Fatalf("rollout is not sticky for user%d", i)
+		}
+		if p.Version == 2 {
+			rollout++
+		}
+	}
+	if rollout < 250 || rollout > 350 {
+		t.Errorf("unexpected rollout share %d/1000", rollout)
+	}
+
+	// Rolling back
+	if err := reg.Release(PromptSummarize, "prod", PromptRelease{Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := reg.Resolve(WithRolloutKey(context.Background(), "user1"), PromptSummarize); p.Version != 1 {
+		t.Errorf("expected version 1 after rollback, got %d", p.Version)
+	}
+	if err := reg.Release(PromptSummarize, "prod", PromptRelease{Version: 3}); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+	if _, err := reg.Resolve(WithPromptEnvironment(context.Background(), "dev"), PromptSummarize); err == nil {
+		t.Error("expected an error for an unreleased environment")
+	}
+
+	// Unknown prompts fall back to the built-in ones
+	if p, err := reg.Resolve(context.Background(), PromptClassify); p != nil || err != nil {
+		t.Errorf("unexpected prompt %v, %v", p, err)
+	}
+	if _, err := Classify(context.Background(), llm, "text", []string{"ok", "ko"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Provenance records what produced a response, so it can be stored with the
+// output and traced later. It is set on Response by clients implementing
+// ResponseGenerator when requested with WithProvenance.
+type Provenance struct {
+	// RequestedModel is the model the client was configured with
+	RequestedModel string `json:"requested_model"`
+	// Model is the model version that answered, if reported
+	Model             string `json:"model,omitempty"`
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// Params are the request parameters as sent, without the prompt
+	Params map[string]interface{} `json:"params"`
+	// PromptHash is the SHA-256 of the prompt as sent, including images
+	PromptHash string    `json:"prompt_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Reformulations are the rewrites of the prompt retried by ReformulateLLM
+	Reformulations []Reformulation `json:"reformulations,omitempty"`
+}
+
+type provenanceKey struct{}
+
+// WithProvenance returns a context that makes clients attach a Provenance to
+// responses
+func WithProvenance(ctx context.Context) context.Context {
+	return context.WithValue(ctx, provenanceKey{}, true)
+}
+
+// newProvenance records the request body of a call made with a context from
+// WithProvenance, nil otherwise. The fields of the body named by promptKeys
+// make up the prompt, the other fields the parameters.
+func newProvenance(ctx context.Context, requestedModel string, body interface{}, promptKeys ...string) *Provenance {
+	if on, _ := ctx.Value(provenanceKey{}).(bool); !on {
+		return nil
+	}
+	p := &Provenance{RequestedModel: requestedModel, Params: map[string]interface{}{}, CreatedAt: time.Now().UTC()}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return p
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return p
+	}
+
+	prompt := make(map[string]json.RawMessage)
+	for _, key := range promptKeys {
+		if v, ok := fields[key]; ok {
+			prompt[key] = v
+			delete(fields, key)
+		}
+	}
+	promptData, _ := json.Marshal(prompt)
+	p.PromptHash = ContentHash(promptData)
+	for key, v := range fields {
+		var value interface{}
+		json.Unmarshal(v, &value)
+		p.Params[key] = value
+	}
+	return p
+}
+
+// attachProvenance completes p with the response metadata and sets it on res
+func attachProvenance(res *Response, p *Provenance) {
+	if p == nil || res == nil {
+		return
+	}
+	p.Model = res.Model
+	p.SystemFingerprint = res.SystemFingerprint
+	res.Provenance = p
+}
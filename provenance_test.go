@@ -0,0 +1,40 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProvenance(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "gpt-4o", 100, 0.7, false)
+	msgs := []Message{{Role: RoleUser, Content: "hi"}}
+
+	res, err := llm.GenerateResponse(context.Background(), msgs)
+	if err != nil || res.Provenance != nil {
+		t.Fatalf("provenance should only be recorded on request: %+v, %v", res, err)
+	}
+
+	ctx := WithDeterministic(WithProvenance(context.Background()))
+	res, err = llm.GenerateResponse(ctx, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := res.Provenance
+	if p == nil || p.RequestedModel != "gpt-4o" || p.Model != "m" || p.PromptHash == "" {
+		t.Fatalf("unexpected provenance %+v", p)
+	}
+	if p.Params["temperature"] != float64(0) || p.Params["seed"] != float64(DeterministicSeed) || p.Params["max_tokens"] != float64(100) {
+		t.Fatalf("resolved params not recorded: %v", p.Params)
+	}
+	if _, ok := p.Params["messages"]; ok {
+		t.Fatalf("prompt should not be in params: %v", p.Params)
+	}
+
+	again, _ := llm.GenerateResponse(ctx, msgs)
+	other, _ := llm.GenerateResponse(ctx, []Message{{Role: RoleUser, Content: "hello"}})
+	if again.Provenance.PromptHash != p.PromptHash || other.Provenance.PromptHash == p.PromptHash {
+		t.Fatalf("prompt hash should identify the prompt")
+	}
+}
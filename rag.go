@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Chunker splits the text of a document into chunks that are embedded and
+// retrieved separately
+type Chunker func(text string) []string
+
+// NewTokenChunker returns a Chunker splitting text into chunks of about
+// maxTokens tokens of model at paragraph breaks, as AskWithSources does
+func NewTokenChunker(model string, maxTokens int) Chunker {
+	tokenizer := TokenizerFor(model)
+	return func(text string) []string {
+		return chunkText(tokenizer, text, maxTokens)
+	}
+}
+
+// Chunk is an embedded chunk of a document
+type Chunk struct {
+	DocumentID string
+	Title      string
+	Text       string
+	Vector     []float32
+	// Score is the similarity to the query of chunks returned by Search, or
+	// the relevance score of reranked chunks
+	Score float64
+}
+
+// VectorStore stores chunks and finds the ones nearest to a vector
+type VectorStore interface {
+	Add(ctx context.Context, chunks []Chunk) error
+	// Search returns the k chunks most similar to vector, most similar first
+	Search(ctx context.Context, vector []float32, k int) ([]Chunk, error)
+}
+
+// MemoryVectorStore is an in-memory VectorStore ranking chunks by cosine
+// similarity, for small corpora and tests
+type MemoryVectorStore struct {
+	mu     sync.RWMutex
+	chunks []Chunk
+}
+
+// NewMemoryVectorStore creates an empty MemoryVectorStore
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{}
+}
+
+func (m *MemoryVectorStore) Add(ctx context.Context, chunks []Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chunks = append(m.chunks, chunks...)
+	return nil
+}
+
+func (m *MemoryVectorStore) Search(ctx context.Context, vector []float32, k int) ([]Chunk, error) {
+	m.mu.RLock()
+	res := make([]Chunk, len(m.chunks))
+	for i, c := range m.chunks {
+		res[i] = c
+		res[i].Score = cosineSimilarity(vector, c.Vector)
+	}
+	m.mu.RUnlock()
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	if k < len(res) {
+		res = res[:k]
+	}
+	return res, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, 0 if
+// they differ in length or one is zero
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// RAG answers questions from indexed documents: documents are split by a
+// Chunker, embedded and stored in a VectorStore, and the chunks nearest to a
+// question are given to the LLM as numbered sources, see AskWithSources.
+//
+//	rag := ai.NewRAG(llm, ai.NewOpenAIEmbedder(key, "text-embedding-3-small"), ai.NewMemoryVectorStore())
+//	if err := rag.Index(ctx, docs); err != nil {
+//		return err
+//	}
+//	answer, err := rag.Ask(ctx, "How do I reset the device?")
+type RAG struct {
+	llm           LLM
+	embedder      Embedder
+	queryEmbedder Embedder
+	store         VectorStore
+	chunker       Chunker
+	topK          int
+	reranker      Reranker
+	candidates    int
+}
+
+// Defaults of NewRAG
+const (
+	defaultRAGChunkTokens = 300
+	defaultRAGTopK        = 5
+)
+
+// NewRAG creates a RAG retrieving 5 chunks of about 300 tokens
+func NewRAG(llm LLM, embedder Embedder, store VectorStore) *RAG {
+	return &RAG{
+		llm:      llm,
+		embedder: embedder,
+		store:    store,
+		chunker:  NewTokenChunker(llm.GetModel(), defaultRAGChunkTokens),
+		topK:     defaultRAGTopK,
+	}
+}
+
+// SetChunker sets how documents are split, for documents indexed afterwards
+func (r *RAG) SetChunker(chunker Chunker) {
+	r.chunker = chunker
+}
+
+// SetTopK sets the number of chunks given to the LLM
+func (r *RAG) SetTopK(k int) {
+	r.topK = k
+}
+
+// SetQueryEmbedder sets the embedder of questions, for models that embed
+// queries differently than documents (see EmbedInputType). It must return
+// vectors comparable to the documents'.
+func (r *RAG) SetQueryEmbedder(embedder Embedder) {
+	r.queryEmbedder = embedder
+}
+
+// SetReranker reranks the candidates nearest chunks with reranker and keeps
+// the top K of them
+func (r *RAG) SetReranker(reranker Reranker, candidates int) {
+	r.reranker = reranker
+	r.candidates = candidates
+}
+
+// Index chunks, embeds and stores docs
+func (r *RAG) Index(ctx context.Context, docs []Document) error {
+	var chunks []Chunk
+	var texts []string
+	for _, doc := range docs {
+		for _, text := range r.chunker(doc.Text) {
+			chunks = append(chunks, Chunk{DocumentID: doc.ID, Title: doc.Title, Text: text})
+			texts = append(texts, text)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil
+	}
+	vectors, err := r.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(vectors) != len(chunks) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(chunks), len(vectors))
+	}
+	for i := range chunks {
+		chunks[i].Vector = vectors[i]
+	}
+	return r.store.Add(ctx, chunks)
+}
+
+// Retrieve returns the chunks most relevant to question, most relevant first
+func (r *RAG) Retrieve(ctx context.Context, question string) ([]Chunk, error) {
+	if r.reranker == nil {
+		return r.search(ctx, question, r.topK)
+	}
+	candidates := r.candidates
+	if candidates < r.topK {
+		candidates = r.topK
+	}
+	chunks, err := r.search(ctx, question, candidates)
+	if err != nil || len(chunks) == 0 {
+		return chunks, err
+	}
+	texts := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.Text
+	}
+	results, err := r.reranker.Rerank(ctx, question, texts, r.topK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rerank chunks: %w", err)
+	}
+	if len(results) > r.topK {
+		results = results[:r.topK]
+	}
+	reranked := make([]Chunk, len(results))
+	for i, result := range results {
+		if result.Index < 0 || result.Index >= len(chunks) {
+			return nil, fmt.Errorf("rerank index %d out of range", result.Index)
+		}
+		reranked[i] = chunks[result.Index]
+		reranked[i].Score = result.Score
+	}
+	return reranked, nil
+}
+
+// search returns the k chunks nearest to question
+func (r *RAG) search(ctx context.Context, question string, k int) ([]Chunk, error) {
+	embedder := r.embedder
+	if r.queryEmbedder != nil {
+		embedder = r.queryEmbedder
+	}
+	vectors, err := embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed question: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(vectors))
+	}
+	return r.store.Search(ctx, vectors[0], k)
+}
+
+// Ask answers question from the retrieved chunks. The answer cites them by
+// number, Sources are the retrieved chunks in order of similarity.
+func (r *RAG) Ask(ctx context.Context, question string) (*SourcedAnswer, error) {
+	chunks, err := r.Retrieve(ctx, question)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no indexed content to answer from")
+	}
+	sources := make([]Source, len(chunks))
+	for i, c := range chunks {
+		sources[i] = Source{Index: i + 1, DocumentID: c.DocumentID, Title: c.Title, Text: c.Text}
+	}
+	return askSources(ctx, r.llm, question, sources)
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// keywordEmbedder embeds texts by the keywords they contain
+type keywordEmbedder []string
+
+func (k keywordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(k))
+		for j, keyword := range k {
+			if strings.Contains(strings.ToLower(text), keyword) {
+				vectors[i][j] = 1
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestRAG(t *testing.T) {
+	var prompt string
+	stub := &stubLLM{model: "m", response: func(systemPrompt, p string) (string, error) {
+		prompt = p
+		return "The capital of France is Paris [1].", nil
+	}}
+	rag := NewRAG(stub, keywordEmbedder{"france", "rain", "paris"}, NewMemoryVectorStore())
+	rag.SetChunker(func(text string) []string { return strings.Split(text, "\n\n") })
+	rag.SetTopK(2)
+
+	docs := []Document{
+		{ID: "weather", Title: "Weather", Text: "Rain is common in autumn.\n\nSnow falls in winter."},
+		{ID: "france", Title: "France", Text: "France is a country in Europe.\n\nThe capital of France is Paris."},
+	}
+	ctx := context.Background()
+	if err := rag.Index(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := rag.Ask(ctx, "What is the capital of France, Paris?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Sources) != 2 || res.Sources[0].DocumentID != "france" || res.Sources[0].Text != "The capital of France is Paris." {
+		t.Fatalf("nearest chunks should come first: %+v", res.Sources)
+	}
+	if !strings.Contains(prompt, "[1] France\nThe capital of France is Paris.") || strings.Contains(prompt, "Rain") {
+		t.Fatalf("unexpected prompt:\n%s", prompt)
+	}
+	if len(res.Citations) != 1 || res.Citations[0].Index != 1 {
+		t.Fatalf("unexpected citations %+v", res.Citations)
+	}
+
+	if _, err := NewRAG(stub, keywordEmbedder{"france"}, NewMemoryVectorStore()).Ask(ctx, "France?"); err == nil {
+		t.Fatal("expected an error without indexed content")
+	}
+}
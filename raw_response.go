@@ -0,0 +1,39 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type rawResponseKey struct{}
+
+// WithRawResponse returns a context that makes clients set Response.Raw to
+// the provider response, to read fields the package does not model
+//
+//	res, err := llm.GenerateWithTools(ai.WithRawResponse(ctx), messages, tools)
+//	...
+//	var usage struct {
+//		Usage map[string]interface{} `json:"usage"`
+//	}
+//	json.Unmarshal(res.Raw, &usage)
+func WithRawResponse(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rawResponseKey{}, true)
+}
+
+// attachRaw sets raw on res if requested with WithRawResponse. raw is the
+// response body as string or bytes, or an SDK response marshaled to JSON.
+func attachRaw(ctx context.Context, res *Response, raw interface{}) {
+	if on, _ := ctx.Value(rawResponseKey{}).(bool); !on || res == nil {
+		return
+	}
+	switch raw := raw.(type) {
+	case string:
+		res.Raw = json.RawMessage(raw)
+	case []byte:
+		res.Raw = json.RawMessage(raw)
+	case json.RawMessage:
+		res.Raw = raw
+	default:
+		res.Raw, _ = json.Marshal(raw)
+	}
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRawResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"m","service_tier":"flex","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		case "/messages":
+			io.WriteString(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`)
+		default:
+			io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]},"avgLogprobs":-0.5}]}`)
+		}
+	}))
+	defer server.Close()
+	messages := Chat{}.User("Hi").Messages()
+	field := func(raw json.RawMessage, path ...string) interface{} {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			t.Fatalf("invalid raw response %q: %v", raw, err)
+		}
+		for _, key := range path {
+			switch m := v.(type) {
+			case map[string]interface{}:
+				v = m[key]
+			case []interface{}:
+				v = m[0]
+			}
+		}
+		return v
+	}
+	ctx := WithRawResponse(context.Background())
+
+	openAI := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	res, err := openAI.GenerateResponse(ctx, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field(res.Raw, "service_tier") != "flex" {
+		t.Errorf("unexpected OpenAI raw response %s", res.Raw)
+	}
+	if res, err = openAI.GenerateResponse(context.Background(), messages); err != nil || res.Raw != nil {
+		t.Errorf("raw response set without WithRawResponse: %s, %v", res.Raw, err)
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	if res, err = anthropic.GenerateWithTools(ctx, messages, nil); err != nil {
+		t.Fatal(err)
+	}
+	if field(res.Raw, "usage", "input_tokens") != 3.0 {
+		t.Errorf("unexpected Anthropic raw response %s", res.Raw)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if res, err = gemini.GenerateResponse(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	if field(res.Raw, "candidates", "0", "avgLogprobs") != -0.5 {
+		t.Errorf("unexpected Gemini raw response %s", res.Raw)
+	}
+}
@@ -0,0 +1,445 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/websocket"
+)
+
+// RealtimeEventType is the type of a RealtimeEvent
+type RealtimeEventType string
+
+const (
+	// RealtimeText is a text delta of the reply
+	RealtimeText RealtimeEventType = "text"
+	// RealtimeAudio is a chunk of reply audio, 16-bit PCM at 24kHz
+	RealtimeAudio RealtimeEventType = "audio"
+	// RealtimeTranscript is a transcript delta of the reply audio
+	RealtimeTranscript RealtimeEventType = "transcript"
+	// RealtimeInputTranscript is a transcript of the user's speech
+	RealtimeInputTranscript RealtimeEventType = "input_transcript"
+	// RealtimeSpeechStarted means the user started speaking, playback of the
+	// current reply should stop
+	RealtimeSpeechStarted RealtimeEventType = "speech_started"
+	// RealtimeTurnDone ends a reply
+	RealtimeTurnDone RealtimeEventType = "turn_done"
+	// RealtimeError is an error reported by the provider, the session stays
+	// open
+	RealtimeError RealtimeEventType = "error"
+)
+
+// RealtimeEvent is an event received in a realtime session
+type RealtimeEvent struct {
+	Type  RealtimeEventType
+	Text  string
+	Audio []byte
+	Error string
+	// Raw is the provider message the event comes from
+	Raw json.RawMessage
+}
+
+// RealtimeConfig configures a realtime session
+type RealtimeConfig struct {
+	// Instructions is the system prompt
+	Instructions string
+	// AudioOutput requests spoken replies, otherwise replies are text
+	AudioOutput bool
+	// Voice is the provider voice of spoken replies (optional)
+	Voice string
+	// InputTranscription requests transcripts of the user's speech
+	InputTranscription bool
+}
+
+// Realtime connects bidirectional streaming sessions for low-latency voice
+// and text conversations. Turns of user audio are detected by the provider.
+type Realtime interface {
+	// Connect opens a session calling onEvent with the received events, one
+	// at a time. The session is closed when ctx is done.
+	Connect(ctx context.Context, config RealtimeConfig, onEvent func(RealtimeEvent)) (*RealtimeSession, error)
+}
+
+// realtimeProtocol converts between the events of a session and provider
+// messages
+type realtimeProtocol interface {
+	setup(config RealtimeConfig) []interface{}
+	text(text string) []interface{}
+	audio(pcm []byte) []interface{}
+	events(data []byte) ([]RealtimeEvent, error)
+}
+
+// errRealtimeClosed is returned by sends on a closed session
+var errRealtimeClosed = errors.New("realtime session closed")
+
+// RealtimeSession is an open realtime session
+//
+//	session, err := ai.NewOpenAIRealtime(key, "gpt-4o-realtime-preview").Connect(ctx, ai.RealtimeConfig{
+//		Instructions: "You are a helpful assistant.",
+//		AudioOutput:  true,
+//	}, func(e ai.RealtimeEvent) {
+//		if e.Type == ai.RealtimeAudio {
+//			speaker.Write(e.Audio)
+//		}
+//	})
+//	...
+//	session.SendAudio(ctx, microphoneChunk)
+type RealtimeSession struct {
+	conn     *websocket.Conn
+	protocol realtimeProtocol
+	onEvent  func(RealtimeEvent)
+
+	writeMu sync.Mutex
+	closed  atomic.Bool
+	done    chan struct{}
+	err     error
+}
+
+// connectRealtime dials a realtime endpoint and sends the session setup
+func connectRealtime(ctx context.Context, endpoint string, headers map[string]string, protocol realtimeProtocol, config RealtimeConfig, onEvent func(RealtimeEvent)) (*RealtimeSession, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	origin := "https://" + u.Host
+	if u.Scheme == "ws" {
+		origin = "http://" + u.Host
+	}
+	wsConfig, err := websocket.NewConfig(endpoint, origin)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		wsConfig.Header.Set(k, v)
+	}
+	conn, err := wsConfig.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	s := &RealtimeSession{conn: conn, protocol: protocol, onEvent: onEvent, done: make(chan struct{})}
+	if err := s.send(ctx, protocol.setup(config)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go s.read()
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Close()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// read calls onEvent with the received events until the connection closes
+func (s *RealtimeSession) read() {
+	defer close(s.done)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.conn, &data); err != nil {
+			if !s.closed.Load() {
+				s.err = err
+			}
+			return
+		}
+		events, err := s.protocol.events(data)
+		if err != nil {
+			s.err = err
+			s.Close()
+			return
+		}
+		for _, event := range events {
+			event.Raw = data
+			s.onEvent(event)
+		}
+	}
+}
+
+// send writes messages, within the deadline of ctx if any
+func (s *RealtimeSession) send(ctx context.Context, messages []interface{}) error {
+	if s.closed.Load() {
+		return errRealtimeClosed
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	deadline, _ := ctx.Deadline()
+	s.conn.SetWriteDeadline(deadline)
+	for _, msg := range messages {
+		if err := websocket.JSON.Send(s.conn, msg); err != nil {
+			return fmt.Errorf("failed to send: %w", err)
+		}
+	}
+	return nil
+}
+
+// SendText adds a user message and requests a reply
+func (s *RealtimeSession) SendText(ctx context.Context, text string) error {
+	return s.send(ctx, s.protocol.text(text))
+}
+
+// SendAudio streams a chunk of user speech, 16-bit PCM mono at 24kHz for
+// OpenAI and 16kHz for Gemini
+func (s *RealtimeSession) SendAudio(ctx context.Context, pcm []byte) error {
+	return s.send(ctx, s.protocol.audio(pcm))
+}
+
+// Close closes the session
+func (s *RealtimeSession) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// Wait blocks until the session is closed. It returns the error that closed
+// it, nil if it was closed with Close or by its context.
+func (s *RealtimeSession) Wait() error {
+	<-s.done
+	return s.err
+}
+
+const (
+	openAIRealtimeURL = "wss://api.openai.com/v1/realtime"
+	geminiLiveURL     = "wss://generativelanguage.googleapis.com/ws/google.ai.generativelanguage.v1beta.GenerativeService.BidiGenerateContent"
+)
+
+// OpenAIRealtime connects to the OpenAI Realtime API
+type OpenAIRealtime struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// NewOpenAIRealtime creates a client for model, e.g. "gpt-4o-realtime-preview"
+func NewOpenAIRealtime(apiKey, model string) *OpenAIRealtime {
+	return &OpenAIRealtime{apiKey: apiKey, model: model, baseURL: openAIRealtimeURL}
+}
+
+// SetBaseURL sets the WebSocket URL of the API, e.g. for a proxy
+func (r *OpenAIRealtime) SetBaseURL(baseURL string) {
+	r.baseURL = baseURL
+}
+
+func (r *OpenAIRealtime) Connect(ctx context.Context, config RealtimeConfig, onEvent func(RealtimeEvent)) (*RealtimeSession, error) {
+	headers := map[string]string{
+		"Authorization": "Bearer " + r.apiKey,
+		"OpenAI-Beta":   "realtime=v1",
+	}
+	endpoint := r.baseURL + "?model=" + url.QueryEscape(r.model)
+	return connectRealtime(ctx, endpoint, headers, openAIRealtimeProtocol{}, config, onEvent)
+}
+
+type openAIRealtimeProtocol struct{}
+
+func (openAIRealtimeProtocol) setup(config RealtimeConfig) []interface{} {
+	session := map[string]interface{}{
+		"modalities":          []string{"text"},
+		"input_audio_format":  "pcm16",
+		"output_audio_format": "pcm16",
+		"turn_detection":      map[string]string{"type": "server_vad"},
+	}
+	if config.AudioOutput {
+		session["modalities"] = []string{"text", "audio"}
+	}
+	if config.Instructions != "" {
+		session["instructions"] = config.Instructions
+	}
+	if config.Voice != "" {
+		session["voice"] = config.Voice
+	}
+	if config.InputTranscription {
+		session["input_audio_transcription"] = map[string]string{"model": "whisper-1"}
+	}
+	return []interface{}{map[string]interface{}{"type": "session.update", "session": session}}
+}
+
+func (openAIRealtimeProtocol) text(text string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"type": "conversation.item.create",
+			"item": map[string]interface{}{
+				"type":    "message",
+				"role":    "user",
+				"content": []map[string]string{{"type": "input_text", "text": text}},
+			},
+		},
+		map[string]string{"type": "response.create"},
+	}
+}
+
+func (openAIRealtimeProtocol) audio(pcm []byte) []interface{} {
+	return []interface{}{map[string]string{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(pcm),
+	}}
+}
+
+func (openAIRealtimeProtocol) events(data []byte) ([]RealtimeEvent, error) {
+	var msg struct {
+		Type       string `json:"type"`
+		Delta      string `json:"delta"`
+		Transcript string `json:"transcript"`
+		Error      struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %v", err)
+	}
+	switch msg.Type {
+	case "response.text.delta":
+		return []RealtimeEvent{{Type: RealtimeText, Text: msg.Delta}}, nil
+	case "response.audio.delta":
+		audio, err := base64.StdEncoding.DecodeString(msg.Delta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode audio: %v", err)
+		}
+		return []RealtimeEvent{{Type: RealtimeAudio, Audio: audio}}, nil
+	case "response.audio_transcript.delta":
+		return []RealtimeEvent{{Type: RealtimeTranscript, Text: msg.Delta}}, nil
+	case "conversation.item.input_audio_transcription.completed":
+		return []RealtimeEvent{{Type: RealtimeInputTranscript, Text: msg.Transcript}}, nil
+	case "input_audio_buffer.speech_started":
+		return []RealtimeEvent{{Type: RealtimeSpeechStarted}}, nil
+	case "response.done":
+		return []RealtimeEvent{{Type: RealtimeTurnDone}}, nil
+	case "error":
+		return []RealtimeEvent{{Type: RealtimeError, Error: msg.Error.Message}}, nil
+	}
+	return nil, nil
+}
+
+// GeminiLive connects to the Gemini Live API
+type GeminiLive struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// NewGeminiLive creates a client for model, e.g. "gemini-2.0-flash-live-001"
+func NewGeminiLive(apiKey, model string) *GeminiLive {
+	return &GeminiLive{apiKey: apiKey, model: model, baseURL: geminiLiveURL}
+}
+
+// SetBaseURL sets the WebSocket URL of the API, e.g. for a proxy
+func (g *GeminiLive) SetBaseURL(baseURL string) {
+	g.baseURL = baseURL
+}
+
+func (g *GeminiLive) Connect(ctx context.Context, config RealtimeConfig, onEvent func(RealtimeEvent)) (*RealtimeSession, error) {
+	endpoint := g.baseURL + "?key=" + url.QueryEscape(g.apiKey)
+	return connectRealtime(ctx, endpoint, nil, geminiLiveProtocol{model: g.model}, config, onEvent)
+}
+
+type geminiLiveProtocol struct {
+	model string
+}
+
+func (p geminiLiveProtocol) setup(config RealtimeConfig) []interface{} {
+	generationConfig := map[string]interface{}{"responseModalities": []string{"TEXT"}}
+	setup := map[string]interface{}{
+		"model":            "models/" + p.model,
+		"generationConfig": generationConfig,
+	}
+	if config.AudioOutput {
+		generationConfig["responseModalities"] = []string{"AUDIO"}
+		// transcripts of the reply audio, like OpenAI sends
+		setup["outputAudioTranscription"] = map[string]interface{}{}
+	}
+	if config.Voice != "" {
+		generationConfig["speechConfig"] = map[string]interface{}{
+			"voiceConfig": map[string]interface{}{
+				"prebuiltVoiceConfig": map[string]string{"voiceName": config.Voice},
+			},
+		}
+	}
+	if config.Instructions != "" {
+		setup["systemInstruction"] = geminiContent{Parts: []geminiPart{{Text: config.Instructions}}}
+	}
+	if config.InputTranscription {
+		setup["inputAudioTranscription"] = map[string]interface{}{}
+	}
+	return []interface{}{map[string]interface{}{"setup": setup}}
+}
+
+func (geminiLiveProtocol) text(text string) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"clientContent": map[string]interface{}{
+			"turns":        []geminiContent{{Role: "user", Parts: []geminiPart{{Text: text}}}},
+			"turnComplete": true,
+		},
+	}}
+}
+
+func (geminiLiveProtocol) audio(pcm []byte) []interface{} {
+	return []interface{}{map[string]interface{}{
+		"realtimeInput": map[string]interface{}{
+			"audio": geminiInlineData{MimeType: "audio/pcm;rate=16000", Data: base64.StdEncoding.EncodeToString(pcm)},
+		},
+	}}
+}
+
+func (geminiLiveProtocol) events(data []byte) ([]RealtimeEvent, error) {
+	var msg struct {
+		ServerContent *struct {
+			ModelTurn struct {
+				Parts []geminiPart `json:"parts"`
+			} `json:"modelTurn"`
+			InputTranscription struct {
+				Text string `json:"text"`
+			} `json:"inputTranscription"`
+			OutputTranscription struct {
+				Text string `json:"text"`
+			} `json:"outputTranscription"`
+			Interrupted  bool `json:"interrupted"`
+			TurnComplete bool `json:"turnComplete"`
+		} `json:"serverContent"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %v", err)
+	}
+	if msg.Error != nil {
+		return []RealtimeEvent{{Type: RealtimeError, Error: msg.Error.Message}}, nil
+	}
+	content := msg.ServerContent
+	if content == nil {
+		return nil, nil
+	}
+	var events []RealtimeEvent
+	if content.Interrupted {
+		events = append(events, RealtimeEvent{Type: RealtimeSpeechStarted})
+	}
+	if text := content.InputTranscription.Text; text != "" {
+		events = append(events, RealtimeEvent{Type: RealtimeInputTranscript, Text: text})
+	}
+	for _, part := range content.ModelTurn.Parts {
+		if part.Text != "" {
+			events = append(events, RealtimeEvent{Type: RealtimeText, Text: part.Text})
+		}
+		if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+			audio, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode audio: %v", err)
+			}
+			events = append(events, RealtimeEvent{Type: RealtimeAudio, Audio: audio})
+		}
+	}
+	if text := content.OutputTranscription.Text; text != "" {
+		events = append(events, RealtimeEvent{Type: RealtimeTranscript, Text: text})
+	}
+	if content.TurnComplete {
+		events = append(events, RealtimeEvent{Type: RealtimeTurnDone})
+	}
+	return events, nil
+}
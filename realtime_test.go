@@ -0,0 +1,123 @@
+package ai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+// realtimeServer replies to the messages after the setup with events
+func realtimeServer(t *testing.T, messages chan<- map[string]interface{}, events ...string) *httptest.Server {
+	return httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		for i := 0; ; i++ {
+			var msg map[string]interface{}
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				return
+			}
+			messages <- msg
+			if i == 0 {
+				continue
+			}
+			for _, event := range events {
+				if err := websocket.Message.Send(conn, event); err != nil {
+					t.Error(err)
+				}
+			}
+			events = nil
+		}
+	}))
+}
+
+// collectRealtime returns the events of a session until its turn is done
+func collectRealtime(t *testing.T, r Realtime, config RealtimeConfig, send func(s *RealtimeSession) error) []RealtimeEvent {
+	var events []RealtimeEvent
+	turnDone := make(chan struct{})
+	session, err := r.Connect(context.Background(), config, func(e RealtimeEvent) {
+		events = append(events, e)
+		if e.Type == RealtimeTurnDone {
+			close(turnDone)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := send(session); err != nil {
+		t.Fatal(err)
+	}
+	<-turnDone
+	session.Close()
+	if err := session.Wait(); err != nil {
+		t.Fatalf("unexpected error after Close: %v", err)
+	}
+	if err := session.SendText(context.Background(), "again"); err == nil {
+		t.Fatal("expected an error sending on a closed session")
+	}
+	return events
+}
+
+func TestOpenAIRealtime(t *testing.T) {
+	audio := base64.StdEncoding.EncodeToString([]byte{1, 2})
+	messages := make(chan map[string]interface{}, 10)
+	server := realtimeServer(t, messages,
+		`{"type": "session.created"}`,
+		`{"type": "response.audio.delta", "delta": "`+audio+`"}`,
+		`{"type": "response.audio_transcript.delta", "delta": "Hi"}`,
+		`{"type": "response.done"}`,
+	)
+	defer server.Close()
+
+	r := NewOpenAIRealtime("key", "gpt-4o-realtime-preview")
+	r.SetBaseURL("ws" + strings.TrimPrefix(server.URL, "http"))
+	events := collectRealtime(t, r, RealtimeConfig{Instructions: "Be brief.", AudioOutput: true, Voice: "alloy"}, func(s *RealtimeSession) error {
+		return s.SendText(context.Background(), "Hello")
+	})
+
+	setup := <-messages
+	session, _ := setup["session"].(map[string]interface{})
+	if setup["type"] != "session.update" || session["instructions"] != "Be brief." || session["voice"] != "alloy" || len(session["modalities"].([]interface{})) != 2 {
+		t.Errorf("unexpected setup %v", setup)
+	}
+	if item := <-messages; item["type"] != "conversation.item.create" || !strings.Contains(toJSON(item), `"text":"Hello"`) {
+		t.Errorf("unexpected item %v", item)
+	}
+	if len(events) != 3 || events[0].Type != RealtimeAudio || string(events[0].Audio) != "\x01\x02" || events[1].Text != "Hi" || len(events[2].Raw) == 0 {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func TestGeminiLive(t *testing.T) {
+	messages := make(chan map[string]interface{}, 10)
+	server := realtimeServer(t, messages,
+		`{"setupComplete": {}}`,
+		`{"serverContent": {"inputTranscription": {"text": "Hello"}}}`,
+		`{"serverContent": {"modelTurn": {"parts": [{"text": "Hi"}]}, "turnComplete": true}}`,
+	)
+	defer server.Close()
+
+	g := NewGeminiLive("key", "gemini-2.0-flash-live-001")
+	g.SetBaseURL("ws" + strings.TrimPrefix(server.URL, "http"))
+	events := collectRealtime(t, g, RealtimeConfig{Instructions: "Be brief.", InputTranscription: true}, func(s *RealtimeSession) error {
+		return s.SendAudio(context.Background(), []byte{1, 2})
+	})
+
+	setup := <-messages
+	if s := toJSON(setup); !strings.Contains(s, `"model":"models/gemini-2.0-flash-live-001"`) || !strings.Contains(s, `"inputAudioTranscription":{}`) || !strings.Contains(s, `"TEXT"`) {
+		t.Errorf("unexpected setup %s", s)
+	}
+	if input := toJSON(<-messages); !strings.Contains(input, `"realtimeInput":{"audio":{"data":"AQI=","mimeType":"audio/pcm;rate=16000"}}`) {
+		t.Errorf("unexpected input %s", input)
+	}
+	if len(events) != 3 || events[0].Type != RealtimeInputTranscript || events[1].Type != RealtimeText || events[1].Text != "Hi" || events[2].Type != RealtimeTurnDone {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
+
+func toJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
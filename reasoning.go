@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// ReasoningEffort is how much reasoning models think before answering, less
+// effort is faster and uses fewer reasoning tokens
+type ReasoningEffort string
+
+const (
+	ReasoningEffortLow    ReasoningEffort = "low"
+	ReasoningEffortMedium ReasoningEffort = "medium"
+	ReasoningEffortHigh   ReasoningEffort = "high"
+)
+
+type reasoningEffortKey struct{}
+
+// WithReasoningEffort returns a context whose requests to reasoning models
+// use effort instead of the one set on the client with SetReasoningEffort
+func WithReasoningEffort(ctx context.Context, effort ReasoningEffort) context.Context {
+	return context.WithValue(ctx, reasoningEffortKey{}, effort)
+}
+
+// reasoningEffort returns the effort of ctx, or the client's
+func reasoningEffort(ctx context.Context, client ReasoningEffort) ReasoningEffort {
+	if effort, ok := ctx.Value(reasoningEffortKey{}).(ReasoningEffort); ok {
+		return effort
+	}
+	return client
+}
+
+// openAIReasoningModelPattern matches the o-series and GPT-5 models, except
+// the non reasoning GPT-5 chat models
+var openAIReasoningModelPattern = regexp.MustCompile(`^(o\d+(-|$)|gpt-5)`)
+
+// isOpenAIReasoningModel reports whether model is an OpenAI reasoning model,
+// which takes max_completion_tokens and rejects sampling parameters
+func isOpenAIReasoningModel(model string) bool {
+	model = model[strings.LastIndex(model, "/")+1:]
+	return openAIReasoningModelPattern.MatchString(model) && !strings.HasPrefix(model, "gpt-5-chat")
+}
+
+// ReasoningStreamer is implemented by clients that stream the reasoning of
+// reasoning models apart from the answer
+type ReasoningStreamer interface {
+	// StreamWithReasoning streams a reply to messages as reasoning and delta
+	// events, numbered from 0. The stream ends when it returns. Returning an
+	// error from fn stops the stream and returns the error.
+	StreamWithReasoning(ctx context.Context, messages []Message, fn func(StreamEvent) error) error
+}
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// thinkSplitter separates the <think> block that open reasoning models such
+// as DeepSeek R1 put at the start of their output from the answer, across
+// stream chunks
+type thinkSplitter struct {
+	state int
+	buf   string
+}
+
+const (
+	thinkUndecided = iota
+	thinkThinking
+	thinkAnswerStart
+	thinkAnswer
+)
+
+// write returns the reasoning and the answer in chunk that are complete,
+// holding back what could be part of a tag
+func (s *thinkSplitter) write(chunk string) (reasoning, answer string) {
+	s.buf += chunk
+	switch s.state {
+	case thinkUndecided:
+		trimmed := strings.TrimLeft(s.buf, " \t\r\n")
+		if len(trimmed) < len(thinkOpenTag) && strings.HasPrefix(thinkOpenTag, trimmed) {
+			return "", ""
+		}
+		if !strings.HasPrefix(trimmed, thinkOpenTag) {
+			s.state = thinkAnswer
+			answer, s.buf = s.buf, ""
+			return "", answer
+		}
+		s.state = thinkThinking
+		s.buf = trimmed[len(thinkOpenTag):]
+		return s.write("")
+	case thinkThinking:
+		if i := strings.Index(s.buf, thinkCloseTag); i >= 0 {
+			reasoning, s.buf = s.buf[:i], s.buf[i+len(thinkCloseTag):]
+			s.state = thinkAnswerStart
+			_, answer = s.write("")
+			return reasoning, answer
+		}
+		// Hold back a partial closing tag
+		keep := 0
+		for n := min(len(s.buf), len(thinkCloseTag)-1); n > 0; n-- {
+			if strings.HasSuffix(s.buf, thinkCloseTag[:n]) {
+				keep = n
+				break
+			}
+		}
+		reasoning, s.buf = s.buf[:len(s.buf)-keep], s.buf[len(s.buf)-keep:]
+		return reasoning, ""
+	case thinkAnswerStart:
+		s.buf = strings.TrimLeft(s.buf, " \t\r\n")
+		if s.buf == "" {
+			return "", ""
+		}
+		s.state = thinkAnswer
+	}
+	answer, s.buf = s.buf, ""
+	return "", answer
+}
+
+// flush returns what was held back at the end of the output
+func (s *thinkSplitter) flush() (reasoning, answer string) {
+	buf := s.buf
+	s.buf = ""
+	switch s.state {
+	case thinkThinking:
+		return buf, ""
+	case thinkAnswerStart:
+		return "", ""
+	}
+	return "", buf
+}
+
+// splitThinking separates a leading <think> block from the answer in text
+func splitThinking(text string) (reasoning, answer string) {
+	var s thinkSplitter
+	reasoning, answer = s.write(text)
+	r, a := s.flush()
+	return reasoning + r, answer + a
+}
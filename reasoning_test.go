@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReasoningEffort(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	llm := NewOpenAICompatible(server.URL, "key", "o3-mini", 100, 0.7, false)
+	llm.SetReasoningEffort(ReasoningEffortLow)
+	llm.SetSampling(Sampling{TopP: 0.9, TopK: 40})
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["reasoning_effort"] != "low" || body["max_completion_tokens"] != 100.0 || body["max_tokens"] != nil ||
+		body["temperature"] != nil || body["top_p"] != nil || body["top_k"] != nil {
+		t.Errorf("unexpected reasoning request %v", body)
+	}
+
+	ctx := WithReasoningEffort(context.Background(), ReasoningEffortHigh)
+	if _, err := llm.Generate(ctx, "", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if body["reasoning_effort"] != "high" {
+		t.Errorf("expected the context effort, got %v", body["reasoning_effort"])
+	}
+
+	// Other models keep max_tokens unless a completion limit is set
+	llm = NewOpenAICompatible(server.URL, "key", "gpt-4o", 100, 0.7, false)
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["max_tokens"] != 100.0 || body["temperature"] != 0.7 || body["reasoning_effort"] != nil {
+		t.Errorf("unexpected request %v", body)
+	}
+	llm.SetMaxCompletionTokens(2000)
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["max_completion_tokens"] != 2000.0 || body["max_tokens"] != nil {
+		t.Errorf("unexpected completion limit %v", body)
+	}
+
+	for model, want := range map[string]bool{"o1": true, "o4-mini": true, "openai/gpt-5-mini": true, "gpt-5-chat-latest": false, "gpt-4o": false, "omni": false} {
+		if isOpenAIReasoningModel(model) != want {
+			t.Errorf("isOpenAIReasoningModel(%q) != %v", model, want)
+		}
+	}
+}
+
+func TestThinkSplitter(t *testing.T) {
+	for _, c := range []struct {
+		chunks            []string
+		reasoning, answer string
+	}{
+		{[]string{"\n<thi", "nk>Let me", " think.</th", "ink>\n", "\nThe answer", "."}, "Let me think.", "The answer."},
+		{[]string{"<", "b>bold</b>"}, "", "<b>bold</b>"},
+		{[]string{"<think>unfinished"}, "unfinished", ""},
+		{[]string{"no thinking <think>x</think>"}, "", "no thinking <think>x</think>"},
+	} {
+		var s thinkSplitter
+		var reasoning, answer strings.Builder
+		for _, chunk := range append(c.chunks, "") {
+			r, a := s.write(chunk)
+			reasoning.WriteString(r)
+			answer.WriteString(a)
+		}
+		r, a := s.flush()
+		reasoning.WriteString(r)
+		answer.WriteString(a)
+		if reasoning.String() != c.reasoning || answer.String() != c.answer {
+			t.Errorf("%q: unexpected reasoning %q and answer %q", c.chunks, reasoning.String(), answer.String())
+		}
+	}
+}
+
+func TestStreamWithReasoning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if r.URL.Path == "/grok/chat/completions" {
+			for _, delta := range []string{`{"reasoning_content":"Hmm."}`, `{"content":"42"}`} {
+				fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":%s}]}\n\n", delta)
+			}
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		for _, delta := range []string{`{"reasoning_content":"Hmm."}`, `{"content":"<think>Well.</think>"}`, `{"content":"\n42"}`} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":%s}]}\n\n", delta)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	collect := func(s ReasoningStreamer) string {
+		var events []string
+		err := s.StreamWithReasoning(context.Background(), messages, func(e StreamEvent) error {
+			events = append(events, fmt.Sprintf("%d:%s:%s", e.Seq, e.Type, e.Text))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(events, "|")
+	}
+	llm := NewOpenAICompatible(server.URL, "key", "deepseek-r1", 100, 0, false)
+	if got := collect(llm); got != "0:reasoning:Hmm.|1:reasoning:Well.|2:delta:42" {
+		t.Errorf("unexpected events %s", got)
+	}
+	var answer strings.Builder
+	if err := consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		answer.WriteString(chunk)
+		return nil
+	}); err != nil || answer.String() != "42" {
+		t.Errorf("expected the answer without reasoning, got %q, %v", answer.String(), err)
+	}
+
+	grok := NewGrok("key", "grok-3-mini", 100, 0, false)
+	grok.SetBaseURL(server.URL + "/grok")
+	if got := collect(grok); got != "0:reasoning:Hmm.|1:delta:42" {
+		t.Errorf("unexpected events %s", got)
+	}
+}
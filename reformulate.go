@@ -0,0 +1,244 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// reformulateInstructions are the Rewrite instructions of ReformulateLLM
+const reformulateInstructions = "Rephrase this request to a language model so it is clear, neutral and unambiguous, " +
+	"preserving its intent and all its details. Reply with the rephrased request only."
+
+// Reformulation records a retry of ReformulateLLM
+type Reformulation struct {
+	// Prompt is the rewritten prompt that was sent
+	Prompt string `json:"prompt"`
+	// Reason is why the previous attempt was rejected: "empty", "refusal"
+	// (including blocked responses) or "evasive" (see DetectRefusal)
+	Reason string `json:"reason"`
+}
+
+// ReformulateLLM retries empty, refused, blocked or evasive responses with
+// the prompt rephrased by an auxiliary model, typically a cheap one, up to
+// maxRetries times. The last user message is rephrased for conversations.
+// The rewrites are recorded in Provenance.Reformulations of responses from
+// GenerateResponse.
+type ReformulateLLM struct {
+	LLM
+	rewriter   LLM
+	maxRetries int
+}
+
+// NewReformulateLLM creates a ReformulateLLM
+func NewReformulateLLM(llm, rewriter LLM, maxRetries int) *ReformulateLLM {
+	return &ReformulateLLM{LLM: llm, rewriter: rewriter, maxRetries: maxRetries}
+}
+
+// Close closes the wrapped model and the rewriter
+func (r *ReformulateLLM) Close() error {
+	return errors.Join(r.LLM.Close(), r.rewriter.Close())
+}
+
+// reformulationReason returns why a response should be retried, "" if it
+// should not
+func reformulationReason(text string, err error) string {
+	switch {
+	case IsRefusal(err):
+		return "refusal"
+	case err != nil:
+		return ""
+	case strings.TrimSpace(text) == "":
+		return "empty"
+	case DetectRefusal(text):
+		return "evasive"
+	}
+	return ""
+}
+
+// reformulate calls fn with prompt, then with rewrites of the original prompt
+// while check returns a reason to retry. The last response is returned if the
+// retries are exhausted or rewriting fails.
+func reformulate[T any](ctx context.Context, r *ReformulateLLM, prompt string, fn func(prompt string) (T, error), check func(T, error) string) (T, []Reformulation, error) {
+	var reformulations []Reformulation
+	res, err := fn(prompt)
+	for attempt := 0; attempt < r.maxRetries; attempt++ {
+		reason := check(res, err)
+		if reason == "" {
+			break
+		}
+		rewritten, rewriteErr := Rewrite(ctx, r.rewriter, prompt, reformulateInstructions)
+		if rewriteErr != nil || rewritten == "" {
+			break
+		}
+		reformulations = append(reformulations, Reformulation{Prompt: rewritten, Reason: reason})
+		res, err = fn(rewritten)
+	}
+	return res, reformulations, err
+}
+
+func (r *ReformulateLLM) text(ctx context.Context, prompt string, fn func(prompt string) (string, error)) (string, error) {
+	res, _, err := reformulate(ctx, r, prompt, fn, reformulationReason)
+	return res, err
+}
+
+func (r *ReformulateLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return r.text(ctx, prompt, func(prompt string) (string, error) {
+		return r.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+// GenerateStream retries when a stream ends empty, refused or evasive. If
+// chunks were already sent, "[CLEAR]" is sent before the retry.
+func (r *ReformulateLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	send := func(chunk string) error {
+		select {
+		case resultCh <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var sent bool
+	_, err := r.text(ctx, prompt, func(prompt string) (string, error) {
+		if sent {
+			if err := send("[CLEAR]"); err != nil {
+				return "", err
+			}
+			sent = false
+		}
+		var out strings.Builder
+		err := consumeStream(ctx, r.LLM, systemPrompt, prompt, func(chunk string) error {
+			sent = true
+			out.WriteString(chunk)
+			return send(chunk)
+		})
+		return out.String(), err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (r *ReformulateLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	imageBuf, err := bufferImage(image)
+	if err != nil {
+		return "", err
+	}
+	return r.text(ctx, prompt, func(prompt string) (string, error) {
+		var imageReader io.Reader
+		if imageBuf != nil {
+			imageReader = bytes.NewReader(imageBuf.Bytes())
+		}
+		return r.LLM.GenerateWithImage(ctx, prompt, imageReader, mimeType)
+	})
+}
+
+func (r *ReformulateLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	imageBufs, err := bufferImages(images)
+	if err != nil {
+		return "", err
+	}
+	return r.text(ctx, prompt, func(prompt string) (string, error) {
+		return r.LLM.GenerateWithImages(ctx, prompt, newReadersFromBuffers(imageBufs), mimeTypes)
+	})
+}
+
+// withLastUserPrompt returns a function sending messages with the content of
+// the last user message replaced by a prompt
+func withLastUserPrompt(messages []Message, fn func(messages []Message) error) (string, func(prompt string) error, error) {
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return "", nil, err
+	}
+	last := -1
+	for i := len(messages) - 1; i >= 0 && last < 0; i-- {
+		if messages[i].Role == RoleUser {
+			last = i
+		}
+	}
+	var prompt string
+	if last >= 0 {
+		prompt = messages[last].Content
+	}
+	return prompt, func(prompt string) error {
+		msgs := replay()
+		if last >= 0 {
+			msgs[last].Content = prompt
+		}
+		return fn(msgs)
+	}, nil
+}
+
+func (r *ReformulateLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	var res string
+	prompt, send, err := withLastUserPrompt(messages, func(messages []Message) (err error) {
+		res, err = r.LLM.GenerateWithMessages(ctx, messages)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return r.text(ctx, prompt, func(prompt string) (string, error) {
+		err := send(prompt)
+		return res, err
+	})
+}
+
+// GenerateResponse retries like GenerateWithMessages, also on responses with
+// a refusal, and records the rewrites in the provenance when requested with
+// WithProvenance
+func (r *ReformulateLLM) GenerateResponse(ctx context.Context, messages []Message) (*Response, error) {
+	var res *Response
+	prompt, send, err := withLastUserPrompt(messages, func(messages []Message) (err error) {
+		if g, ok := r.LLM.(ResponseGenerator); ok {
+			res, err = g.GenerateResponse(ctx, messages)
+			return err
+		}
+		text, err := r.LLM.GenerateWithMessages(ctx, messages)
+		res = &Response{Text: text}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, reformulations, err := reformulate(ctx, r, prompt, func(prompt string) (*Response, error) {
+		err := send(prompt)
+		return res, err
+	}, func(res *Response, err error) string {
+		if err == nil && res.Refusal != "" {
+			return "refusal"
+		}
+		var text string
+		if res != nil {
+			text = res.Text
+		}
+		return reformulationReason(text, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if on, _ := ctx.Value(provenanceKey{}).(bool); on && len(reformulations) > 0 {
+		if res.Provenance == nil {
+			res.Provenance = &Provenance{
+				RequestedModel:    r.LLM.GetModel(),
+				Model:             res.Model,
+				SystemFingerprint: res.SystemFingerprint,
+				CreatedAt:         time.Now().UTC(),
+			}
+		}
+		res.Provenance.Reformulations = reformulations
+	}
+	return res, nil
+}
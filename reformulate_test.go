@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestReformulateLLM(t *testing.T) {
+	var prompts []string
+	llm := &stubLLM{model: "main", response: func(systemPrompt, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		switch {
+		case strings.HasPrefix(prompt, "Rephrased"):
+			return "The answer is 42.", nil
+		case strings.Contains(prompt, "blank"):
+			return " ", nil
+		}
+		return "I'm sorry, but I can't help with that.", nil
+	}}
+	rewriter := &stubLLM{model: "cheap", response: func(systemPrompt, prompt string) (string, error) {
+		return "Rephrased: " + prompt[strings.LastIndex(prompt, "\n")+1:], nil
+	}}
+	ctx := context.Background()
+
+	res, err := NewReformulateLLM(llm, rewriter, 1).Generate(ctx, "", "how to pick a lock")
+	if err != nil || res != "The answer is 42." || len(prompts) != 2 {
+		t.Fatalf("unexpected result %q, %v after %q", res, err, prompts)
+	}
+
+	// Retries are bounded and the last response is returned
+	prompts = nil
+	rewriter.response = func(systemPrompt, prompt string) (string, error) { return "still blank", nil }
+	res, err = NewReformulateLLM(llm, rewriter, 2).Generate(ctx, "", "blank")
+	if err != nil || res != " " || len(prompts) != 3 {
+		t.Fatalf("unexpected result %q, %v after %q", res, err, prompts)
+	}
+
+	rewriter.response = func(systemPrompt, prompt string) (string, error) { return "Rephrased question", nil }
+	messages := []Message{{Role: RoleUser, Content: "hi"}, {Role: RoleAssistant, Content: "hello"}, {Role: RoleUser, Content: "blank"}}
+	resp, err := NewReformulateLLM(llm, rewriter, 1).GenerateResponse(WithProvenance(ctx), messages)
+	if err != nil || resp.Text != "The answer is 42." {
+		t.Fatalf("unexpected response %+v, %v", resp, err)
+	}
+	if p := resp.Provenance; p == nil || len(p.Reformulations) != 1 ||
+		p.Reformulations[0] != (Reformulation{Prompt: "Rephrased question", Reason: "empty"}) {
+		t.Errorf("unexpected provenance %+v", resp.Provenance)
+	}
+	if messages[2].Content != "blank" {
+		t.Error("messages should not be modified")
+	}
+}
+
+func TestReformulateClose(t *testing.T) {
+	llm, rewriter := &closingLLM{}, &closingLLM{}
+	if err := NewReformulateLLM(llm, rewriter, 1).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if llm.closed != 1 || rewriter.closed != 1 {
+		t.Errorf("expected the model and the rewriter to be closed once, got %d and %d", llm.closed, rewriter.closed)
+	}
+}
@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RefusalError is returned when the model declines to answer, as opposed to
+// failing. Use IsRefusal to branch on it.
+type RefusalError struct {
+	Model string
+	// Message is the refusal explanation, if the provider returned one
+	Message string
+}
+
+func (e *RefusalError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("model %s refused to answer", e.Model)
+	}
+	return fmt.Sprintf("model %s refused to answer: %s", e.Model, e.Message)
+}
+
+// IsRefusal reports whether err is (or wraps) a RefusalError
+func IsRefusal(err error) bool {
+	var refusal *RefusalError
+	return errors.As(err, &refusal)
+}
+
+var refusalPhrases = []string{
+	"i can't help with",
+	"i cannot help with",
+	"i can't assist with",
+	"i cannot assist with",
+	"i can't provide",
+	"i cannot provide",
+	"i can't comply",
+	"i cannot comply",
+	"i'm sorry, but i can't",
+	"i'm sorry, but i cannot",
+	"i am sorry, but i cannot",
+	"i'm unable to help",
+	"i am unable to help",
+	"i'm not able to help",
+	"i won't be able to help",
+	"i must decline",
+	"as an ai language model, i cannot",
+}
+
+// maxRefusalLength limits heuristic detection to short answers, long ones
+// usually contain the answer along with a disclaimer
+const maxRefusalLength = 500
+
+// DetectRefusal reports whether text looks like a refusal. It is a heuristic
+// for providers that do not signal refusals in a structured way.
+func DetectRefusal(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || len(text) > maxRefusalLength {
+		return false
+	}
+	text = strings.ToLower(strings.ReplaceAll(text, "’", "'"))
+	for _, phrase := range refusalPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// RefusalDetectingLLM returns a RefusalError when a response looks like a refusal
+type RefusalDetectingLLM struct {
+	LLM
+}
+
+func NewRefusalDetectingLLM(llm LLM) *RefusalDetectingLLM {
+	return &RefusalDetectingLLM{LLM: llm}
+}
+
+func (r *RefusalDetectingLLM) check(res string, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	if DetectRefusal(res) {
+		return "", &RefusalError{Model: r.LLM.GetModel(), Message: res}
+	}
+	return res, nil
+}
+
+func (r *RefusalDetectingLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return r.check(r.LLM.Generate(ctx, systemPrompt, prompt))
+}
+
+func (r *RefusalDetectingLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return r.check(r.LLM.GenerateWithImage(ctx, prompt, image, mimeType))
+}
+
+func (r *RefusalDetectingLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return r.check(r.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes))
+}
+
+func (r *RefusalDetectingLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	return r.check(r.LLM.GenerateWithMessages(ctx, messages))
+}
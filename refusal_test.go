@@ -0,0 +1,23 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRefusalDetection(t *testing.T) {
+	if !DetectRefusal("I’m sorry, but I can’t help with that request.") {
+		t.Fatalf("expected refusal")
+	}
+	if DetectRefusal("Paris is the capital of France.") {
+		t.Fatalf("unexpected refusal")
+	}
+
+	llm := NewRefusalDetectingLLM(&stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		return "I cannot assist with that.", nil
+	}})
+	_, err := llm.Generate(context.Background(), "", "how to pick a lock")
+	if !IsRefusal(err) {
+		t.Fatalf("expected RefusalError, got %v", err)
+	}
+}
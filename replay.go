@@ -0,0 +1,167 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ReplayTurn is an assistant turn of a stored conversation replayed against
+// another model
+type ReplayTurn struct {
+	// Index is the index of the assistant message in the conversation
+	Index    int
+	Expected string
+	Output   string
+	// Similarity is the word overlap of Output and Expected, from 0 to 1
+	Similarity float64
+	Diverged   bool
+	// Reason explains the divergence
+	Reason   string
+	Err      error
+	Duration time.Duration
+}
+
+// ReplayReport is the result of Replay
+type ReplayReport struct {
+	Model string
+	Turns []ReplayTurn
+}
+
+// Diverged returns the number of turns that diverged or failed
+func (r *ReplayReport) Diverged() int {
+	diverged := 0
+	for _, turn := range r.Turns {
+		if turn.Diverged {
+			diverged++
+		}
+	}
+	return diverged
+}
+
+// ReplayOptions configures ReplayWithOptions
+type ReplayOptions struct {
+	// Threshold is the minimum similarity of a turn that does not diverge
+	Threshold float64
+	// Judge decides whether a turn diverges instead of the similarity, by
+	// comparing the meaning of the replies with the judge prompt (optional)
+	Judge LLM
+}
+
+// DefaultReplayOptions are used by Replay
+var DefaultReplayOptions = ReplayOptions{Threshold: 0.5}
+
+// Replay re-runs a stored conversation turn by turn against llm, e.g. before
+// switching the default model. Every assistant message is generated again
+// from the stored messages before it, so a divergence does not affect the
+// following turns, and compared with the stored reply.
+func Replay(ctx context.Context, llm LLM, messages []Message) (*ReplayReport, error) {
+	return ReplayWithOptions(ctx, llm, messages, DefaultReplayOptions)
+}
+
+// ReplayWithOptions is Replay with options
+func ReplayWithOptions(ctx context.Context, llm LLM, messages []Message, opts ReplayOptions) (*ReplayReport, error) {
+	// images are sent again for every turn
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReplayReport{Model: llm.GetModel()}
+	for i, msg := range messages {
+		if msg.Role != RoleAssistant || i == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		turn := ReplayTurn{Index: i, Expected: msg.Content}
+		start := time.Now()
+		turn.Output, turn.Err = llm.GenerateWithMessages(ctx, replay()[:i])
+		turn.Duration = time.Since(start)
+		if turn.Err != nil {
+			turn.Diverged = true
+			turn.Reason = fmt.Sprintf("generation failed: %v", turn.Err)
+			report.Turns = append(report.Turns, turn)
+			continue
+		}
+		turn.Similarity = wordOverlap(turn.Expected, turn.Output)
+		if opts.Judge != nil {
+			verdict, err := Judge(ctx, opts.Judge, "The response has the same meaning as this reference response:\n"+turn.Expected, lastUserContent(messages[:i]), turn.Output)
+			if err != nil {
+				turn.Err = fmt.Errorf("judge failed: %w", err)
+				turn.Diverged = true
+				turn.Reason = turn.Err.Error()
+			} else if !verdict.Pass {
+				turn.Diverged = true
+				turn.Reason = verdict.Reason
+			}
+		} else if turn.Similarity < opts.Threshold {
+			turn.Diverged = true
+			turn.Reason = fmt.Sprintf("similarity %.2f below %.2f", turn.Similarity, opts.Threshold)
+		}
+		report.Turns = append(report.Turns, turn)
+	}
+	if len(report.Turns) == 0 {
+		return nil, errors.New("no assistant turns to replay")
+	}
+	return report, nil
+}
+
+// lastUserContent returns the content of the last user message
+func lastUserContent(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// wordOverlap returns the Dice coefficient of the lowercase words of a and b
+func wordOverlap(a, b string) float64 {
+	words := func(s string) map[string]int {
+		counts := map[string]int{}
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			counts[w]++
+		}
+		return counts
+	}
+	wa, wb := words(a), words(b)
+	total, common := 0, 0
+	for w, n := range wa {
+		total += n
+		common += min(n, wb[w])
+	}
+	for _, n := range wb {
+		total += n
+	}
+	if total == 0 {
+		return 1
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// WriteMarkdown writes the report as a markdown table
+func (r *ReplayReport) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Replay report: %s\n\n", r.Model)
+	fmt.Fprintf(&sb, "%d/%d turns diverged\n\n", r.Diverged(), len(r.Turns))
+	sb.WriteString("| Turn | Result | Similarity | Duration | Details |\n")
+	sb.WriteString("|------|--------|------------|----------|---------|\n")
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+	for _, turn := range r.Turns {
+		status := "SAME"
+		if turn.Diverged {
+			status = "DIVERGED"
+		}
+		fmt.Fprintf(&sb, "| %d | %s | %.2f | %s | %s |\n", turn.Index, status, turn.Similarity, turn.Duration.Round(time.Millisecond), escape.Replace(turn.Reason))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Capital of France?"},
+		{Role: RoleAssistant, Content: "The capital of France is Paris."},
+		{Role: RoleUser, Content: "And of Italy?"},
+		{Role: RoleAssistant, Content: "Rome."},
+		{Role: RoleUser, Content: "And of Spain?"},
+		{Role: RoleAssistant, Content: "Madrid."},
+	}
+	llm := &stubLLM{model: "new", response: func(_, prompt string) (string, error) {
+		switch prompt {
+		case "Capital of France?":
+			return "Paris is the capital of France.", nil
+		case "And of Italy?":
+			return "I don't know.", nil
+		}
+		return "", errors.New("overloaded")
+	}}
+	report, err := Replay(context.Background(), llm, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Model != "new" || len(report.Turns) != 3 || report.Diverged() != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if turn := report.Turns[0]; turn.Index != 2 || turn.Diverged || turn.Similarity != 1 {
+		t.Errorf("expected the same words to match: %+v", turn)
+	}
+	if turn := report.Turns[1]; !turn.Diverged || turn.Similarity != 0 || turn.Output != "I don't know." {
+		t.Errorf("expected divergence: %+v", turn)
+	}
+	if turn := report.Turns[2]; !turn.Diverged || turn.Err == nil {
+		t.Errorf("expected failure: %+v", turn)
+	}
+
+	var sb strings.Builder
+	if err := report.WriteMarkdown(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "2/3 turns diverged") || !strings.Contains(sb.String(), "| 4 | DIVERGED | 0.00 |") {
+		t.Errorf("unexpected markdown:\n%s", sb.String())
+	}
+
+	judge := &stubLLM{model: "judge", response: func(_, prompt string) (string, error) {
+		if strings.Contains(prompt, "Rome.") {
+			return "FAIL\nDoes not name Rome", nil
+		}
+		return "PASS", nil
+	}}
+	report, err = ReplayWithOptions(context.Background(), llm, messages[:5], ReplayOptions{Judge: judge})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Turns[0].Diverged || !report.Turns[1].Diverged || !strings.Contains(report.Turns[1].Reason, "Does not name Rome") {
+		t.Errorf("unexpected judged turns %+v", report.Turns)
+	}
+
+	if _, err := Replay(context.Background(), llm, messages[:2]); err == nil {
+		t.Error("expected error without assistant turns")
+	}
+}
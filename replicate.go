@@ -0,0 +1,326 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Replicate runs models hosted on Replicate. Predictions are asynchronous,
+// so Generate creates a prediction and polls it until it completes.
+// https://replicate.com/docs/reference/http
+type Replicate struct {
+	apiToken     string
+	model        string
+	maxTokens    int
+	temperature  float32
+	baseURL      string
+	httpClient   *http.Client
+	pollInterval time.Duration
+}
+
+// NewReplicate creates a client for an official model ("owner/name")
+// or a specific version ("owner/name:version")
+func NewReplicate(apiToken, model string, maxTokens int, temperature float32) *Replicate {
+	return &Replicate{
+		apiToken:     apiToken,
+		model:        model,
+		maxTokens:    maxTokens,
+		temperature:  temperature,
+		baseURL:      "https://api.replicate.com/v1/",
+		httpClient:   http.DefaultClient,
+		pollInterval: time.Second,
+	}
+}
+
+// SetHTTPClient sets the HTTP client of requests, http.DefaultClient by default
+func (r *Replicate) SetHTTPClient(client *http.Client) {
+	r.httpClient = client
+}
+
+type replicatePrediction struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"`
+	Output json.RawMessage `json:"output"`
+	Error  interface{}     `json:"error"`
+	URLs   struct {
+		Get    string `json:"get"`
+		Stream string `json:"stream"`
+		Cancel string `json:"cancel"`
+	} `json:"urls"`
+}
+
+func (p *replicatePrediction) done() bool {
+	return p.Status == "succeeded" || p.Status == "failed" || p.Status == "canceled"
+}
+
+// text returns the output, which is either a string or a list of streamed tokens
+func (p *replicatePrediction) text() (string, error) {
+	if len(p.Output) == 0 || string(p.Output) == "null" {
+		return "", nil
+	}
+	var tokens []string
+	if err := json.Unmarshal(p.Output, &tokens); err == nil {
+		return strings.Join(tokens, ""), nil
+	}
+	var s string
+	if err := json.Unmarshal(p.Output, &s); err == nil {
+		return s, nil
+	}
+	return "", fmt.Errorf("unexpected output: %s", p.Output)
+}
+
+func (r *Replicate) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + r.apiToken}
+}
+
+func (r *Replicate) createPrediction(ctx context.Context, input map[string]interface{}, stream bool) (*replicatePrediction, error) {
+	body := map[string]interface{}{"input": input}
+	if stream {
+		body["stream"] = true
+	}
+
+	model := requestModel(ctx, r.model)
+	url := r.baseURL + "models/" + model + "/predictions"
+	if _, version, ok := strings.Cut(model, ":"); ok {
+		url = r.baseURL + "predictions"
+		body["version"] = version
+	}
+
+	headers := r.headers()
+	if !stream {
+		// Block for up to 60 seconds, polling afterwards if still running
+		headers["Prefer"] = "wait"
+	}
+
+	var prediction replicatePrediction
+	if err := doJSON(ctx, r.httpClient, http.MethodPost, url, headers, body, &prediction); err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %v", err)
+	}
+	return &prediction, nil
+}
+
+// wait polls the prediction until it is done, canceling it if ctx is done
+func (r *Replicate) wait(ctx context.Context, prediction *replicatePrediction) (*replicatePrediction, error) {
+	for !prediction.done() {
+		select {
+		case <-ctx.Done():
+			r.cancel(prediction)
+			return nil, ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+
+		var next replicatePrediction
+		if err := doJSON(ctx, r.httpClient, http.MethodGet, prediction.URLs.Get, r.headers(), nil, &next); err != nil {
+			return nil, fmt.Errorf("failed to get prediction: %v", err)
+		}
+		prediction = &next
+	}
+
+	if prediction.Status != "succeeded" {
+		if prediction.Error != nil {
+			return nil, fmt.Errorf("prediction %s: %v", prediction.Status, prediction.Error)
+		}
+		return nil, fmt.Errorf("prediction %s", prediction.Status)
+	}
+	return prediction, nil
+}
+
+// cancel stops a running prediction so it is not billed further (best effort)
+func (r *Replicate) cancel(prediction *replicatePrediction) {
+	if prediction.URLs.Cancel == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = doJSON(ctx, r.httpClient, http.MethodPost, prediction.URLs.Cancel, r.headers(), nil, nil)
+}
+
+func (r *Replicate) input(systemPrompt, prompt string) map[string]interface{} {
+	input := map[string]interface{}{
+		"prompt":      prompt,
+		"temperature": r.temperature,
+		// Newer models use max_tokens, older ones max_new_tokens
+		"max_tokens":     r.maxTokens,
+		"max_new_tokens": r.maxTokens,
+	}
+	if systemPrompt != "" {
+		input["system_prompt"] = systemPrompt
+	}
+	return input
+}
+
+func (r *Replicate) run(ctx context.Context, input map[string]interface{}) (string, error) {
+	prediction, err := r.createPrediction(ctx, input, false)
+	if err != nil {
+		return "", err
+	}
+	prediction, err = r.wait(ctx, prediction)
+	if err != nil {
+		return "", err
+	}
+	return prediction.text()
+}
+
+func (r *Replicate) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return r.run(ctx, r.input(systemPrompt, prompt))
+}
+
+func (r *Replicate) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	r.stream(ctx, r.input(systemPrompt, prompt), resultCh, doneCh, errCh)
+}
+
+func (r *Replicate) stream(ctx context.Context, input map[string]interface{}, resultCh chan string, doneCh chan bool, errCh chan error) {
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+	}
+
+	prediction, err := r.createPrediction(ctx, input, true)
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	if prediction.URLs.Stream == "" {
+		sendErr(fmt.Errorf("model %s does not support streaming", r.model))
+		return
+	}
+
+	headers := r.headers()
+	headers["Accept"] = "text/event-stream"
+	headers["Cache-Control"] = "no-store"
+	resp, err := sendJSON(ctx, r.httpClient, http.MethodGet, prediction.URLs.Stream, headers, nil)
+	if err != nil {
+		sendErr(fmt.Errorf("failed to stream prediction: %v", err))
+		return
+	}
+	defer resp.Body.Close()
+
+	err = readSSE(resp.Body, func(event, data string) error {
+		switch event {
+		case "output":
+			select {
+			case resultCh <- data:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case "error":
+			return fmt.Errorf("prediction failed: %s", data)
+		case "done":
+			if strings.Contains(data, "canceled") {
+				return errors.New("prediction canceled")
+			}
+			return io.EOF
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			r.cancel(prediction)
+		}
+		sendErr(err)
+		return
+	}
+
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (r *Replicate) GetModel() string {
+	return r.model
+}
+
+func (r *Replicate) Close() error {
+	return nil
+}
+
+func (r *Replicate) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return r.GenerateWithImages(ctx, prompt, []io.Reader{image}, []MimeType{mimeType})
+}
+
+func (r *Replicate) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	if len(images) != len(mimeTypes) {
+		return "", fmt.Errorf("number of images and mime types must match")
+	}
+
+	if prompt == "" {
+		return "", fmt.Errorf("prompt is required")
+	}
+
+	msgs := []Message{}
+
+	// Add images to the message
+	for i, image := range images {
+		msgs = append(msgs, Message{
+			Role:     RoleUser,
+			Image:    image,
+			MimeType: mimeTypes[i],
+		})
+	}
+
+	msgs = append(msgs, Message{
+		Role:    RoleUser,
+		Content: prompt,
+	})
+
+	return r.GenerateWithMessages(ctx, msgs)
+}
+
+// GenerateWithMessages flattens the conversation into a single prompt.
+// Vision models on Replicate accept a single image, passed as a data URI.
+func (r *Replicate) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	input, err := r.messagesInput(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return r.run(ctx, input)
+}
+
+// GenerateWithMessagesStream streams the reply to messages, flattened like
+// GenerateWithMessages does, see MessagesStreamer
+func (r *Replicate) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	input, err := r.messagesInput(ctx, messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	r.stream(ctx, input, resultCh, doneCh, errCh)
+}
+
+// messagesInput flattens messages into a prediction input
+func (r *Replicate) messagesInput(ctx context.Context, messages []Message) (map[string]interface{}, error) {
+	var image string
+	for _, msg := range messages {
+		msg = msg.flat()
+		if msg.Image == nil {
+			continue
+		}
+		if image != "" {
+			return nil, fmt.Errorf("replicate models accept a single image")
+		}
+		data, err := io.ReadAll(msg.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %v", err)
+		}
+		image = "data:" + string(msg.MimeType) + ";base64," + encodeBase64(ctx, data)
+	}
+
+	systemPrompt, prompt := messagesToPrompt(messages)
+	input := r.input(systemPrompt, prompt)
+	if image != "" {
+		input["image"] = image
+	}
+	return input, nil
+}
@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReplicateGenerate(t *testing.T) {
+	var srv *httptest.Server
+	polls := 0
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("missing auth header")
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/models/meta/llama/predictions":
+			var body struct {
+				Input map[string]interface{} `json:"input"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Input["prompt"] != "hi" || body.Input["system_prompt"] != "be brief" {
+				t.Errorf("unexpected input: %v", body.Input)
+			}
+			io.WriteString(w, `{"id":"p1","status":"starting","urls":{"get":"`+srv.URL+`/predictions/p1"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/predictions/p1":
+			polls++
+			if polls < 2 {
+				io.WriteString(w, `{"id":"p1","status":"processing","urls":{"get":"`+srv.URL+`/predictions/p1"}}`)
+				return
+			}
+			io.WriteString(w, `{"id":"p1","status":"succeeded","output":["Hel","lo"]}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	llm := NewReplicate("token", "meta/llama", 100, 0.5)
+	llm.baseURL = srv.URL + "/"
+	llm.pollInterval = time.Millisecond
+
+	res, err := llm.Generate(context.Background(), "be brief", "hi")
+	if err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if res != "Hello" {
+		t.Fatalf("unexpected response: %q", res)
+	}
+	if polls != 2 {
+		t.Fatalf("expected 2 polls, got %d", polls)
+	}
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// RerankResult is the relevance of a document to a query
+type RerankResult struct {
+	// Index is the position of the document in the reranked documents
+	Index int
+	Score float64
+}
+
+// Reranker scores the relevance of documents to a query with a cross-encoder
+// model, more accurately than embedding similarity
+type Reranker interface {
+	// Rerank returns the topN most relevant documents, most relevant first.
+	// topN <= 0 returns all documents.
+	Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error)
+}
+
+// rerankResponse is the response of the Cohere and Voyage rerank APIs, which
+// name the results differently
+type rerankResponse struct {
+	Results []rerankResult `json:"results"`
+	Data    []rerankResult `json:"data"`
+}
+
+type rerankResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+}
+
+// results returns the results sorted by relevance, checking their indexes
+func (r rerankResponse) results(documents int) ([]RerankResult, error) {
+	raw := r.Results
+	if len(raw) == 0 {
+		raw = r.Data
+	}
+	res := make([]RerankResult, len(raw))
+	for i, result := range raw {
+		if result.Index < 0 || result.Index >= documents {
+			return nil, fmt.Errorf("rerank index %d out of range", result.Index)
+		}
+		res[i] = RerankResult{Index: result.Index, Score: result.RelevanceScore}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	return res, nil
+}
+
+// CohereReranker reranks documents with the Cohere rerank API
+type CohereReranker struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// NewCohereReranker creates a reranker for model, e.g. "rerank-v3.5"
+func NewCohereReranker(apiKey, model string) *CohereReranker {
+	return &CohereReranker{apiKey: apiKey, model: model, baseURL: cohereBaseURL}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (r *CohereReranker) SetBaseURL(baseURL string) {
+	r.baseURL = baseURL
+}
+
+func (r *CohereReranker) Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+	body := map[string]interface{}{
+		"model":     r.model,
+		"query":     query,
+		"documents": documents,
+	}
+	if topN > 0 {
+		body["top_n"] = topN
+	}
+	headers := map[string]string{"Authorization": "Bearer " + r.apiKey}
+	var resp rerankResponse
+	if err := doJSON(ctx, nil, http.MethodPost, r.baseURL+"/rerank", headers, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.results(len(documents))
+}
+
+// VoyageReranker reranks documents with the Voyage AI rerank API
+type VoyageReranker struct {
+	apiKey  string
+	model   string
+	baseURL string
+}
+
+// NewVoyageReranker creates a reranker for model, e.g. "rerank-2"
+func NewVoyageReranker(apiKey, model string) *VoyageReranker {
+	return &VoyageReranker{apiKey: apiKey, model: model, baseURL: voyageBaseURL}
+}
+
+// SetBaseURL sets the base URL of the API, e.g. for a proxy
+func (r *VoyageReranker) SetBaseURL(baseURL string) {
+	r.baseURL = baseURL
+}
+
+func (r *VoyageReranker) Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error) {
+	if len(documents) == 0 {
+		return nil, nil
+	}
+	body := map[string]interface{}{
+		"model":     r.model,
+		"query":     query,
+		"documents": documents,
+	}
+	if topN > 0 {
+		body["top_k"] = topN
+	}
+	headers := map[string]string{"Authorization": "Bearer " + r.apiKey}
+	var resp rerankResponse
+	if err := doJSON(ctx, nil, http.MethodPost, r.baseURL+"/rerank", headers, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.results(len(documents))
+}
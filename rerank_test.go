@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRerankers(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rerank" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		results := `[{"index": 0, "relevance_score": 0.1}, {"index": 2, "relevance_score": 0.9}]`
+		if _, ok := body["top_k"]; ok {
+			w.Write([]byte(`{"data": ` + results + `}`))
+			return
+		}
+		w.Write([]byte(`{"results": ` + results + `}`))
+	}))
+	defer server.Close()
+
+	cohere := NewCohereReranker("key", "rerank-v3.5")
+	cohere.SetBaseURL(server.URL)
+	voyage := NewVoyageReranker("key", "rerank-2")
+	voyage.SetBaseURL(server.URL)
+
+	for _, tc := range []struct {
+		name     string
+		reranker Reranker
+		topField string
+	}{
+		{"cohere", cohere, "top_n"},
+		{"voyage", voyage, "top_k"},
+	} {
+		results, err := tc.reranker.Rerank(context.Background(), "q", []string{"a", "b", "c"}, 2)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(results) != 2 || results[0].Index != 2 || results[0].Score != 0.9 {
+			t.Errorf("%s: results should be sorted by score: %+v", tc.name, results)
+		}
+		if body[tc.topField] != float64(2) || body["query"] != "q" {
+			t.Errorf("%s: unexpected request %v", tc.name, body)
+		}
+	}
+}
+
+// reverseReranker ranks documents in reverse order
+type reverseReranker struct{}
+
+func (reverseReranker) Rerank(ctx context.Context, query string, documents []string, topN int) ([]RerankResult, error) {
+	var res []RerankResult
+	for i := len(documents) - 1; i >= 0 && len(res) < topN; i-- {
+		res = append(res, RerankResult{Index: i, Score: float64(i)})
+	}
+	return res, nil
+}
+
+func TestRAGReranker(t *testing.T) {
+	stub := &stubLLM{model: "m", response: func(systemPrompt, p string) (string, error) {
+		return "ok", nil
+	}}
+	rag := NewRAG(stub, keywordEmbedder{"france", "paris"}, NewMemoryVectorStore())
+	rag.SetChunker(func(text string) []string { return strings.Split(text, "\n\n") })
+	rag.SetTopK(1)
+	rag.SetReranker(reverseReranker{}, 2)
+
+	ctx := context.Background()
+	docs := []Document{{ID: "france", Text: "France and Paris.\n\nFrance.\n\nRain."}}
+	if err := rag.Index(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := rag.Retrieve(ctx, "France, Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0].Text != "France." {
+		t.Fatalf("expected the second candidate after reranking, got %+v", chunks)
+	}
+}
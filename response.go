@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Response is a generation result that may carry more than text
+type Response struct {
+	Text string
+
+	// Model is the model version that generated the response, if reported
+	Model string
+	// SystemFingerprint identifies the backend configuration, if reported.
+	// Deterministic requests are only reproducible while it stays the same.
+	SystemFingerprint string
+
+	// Refusal is set when the model declined to answer, with its explanation if any
+	Refusal string
+
+	// Reasoning is the thinking of reasoning models, if returned
+	Reasoning string
+	// Citations are the sources used by search enabled models
+	Citations []string
+
+	// ToolCalls requested by the model, see GenerateWithTools
+	ToolCalls []ToolCall
+
+	// Audio output, set only when the client is configured for audio responses
+	Audio           []byte
+	AudioMimeType   MimeType
+	AudioTranscript string
+
+	// Provenance is set when requested with WithProvenance
+	Provenance *Provenance
+	// Raw is the provider response JSON, set when requested with
+	// WithRawResponse. Claude responses are the SDK response re-encoded,
+	// without the fields it drops.
+	Raw json.RawMessage
+}
+
+// Message returns the assistant message of the response with its tool calls,
+// to continue the conversation
+//
+//	res, err := llm.GenerateWithTools(ctx, messages, tools)
+//	...
+//	messages = append(messages, res.Message())
+//	messages = append(messages, ai.ToolResultMessages(executor.Execute(ctx, res.ToolCalls))...)
+func (r *Response) Message() Message {
+	return Message{Role: RoleAssistant, Content: r.Text, ToolCalls: r.ToolCalls}
+}
+
+// ResponseGenerator is implemented by clients that can return multimodal responses
+type ResponseGenerator interface {
+	GenerateResponse(ctx context.Context, messages []Message) (*Response, error)
+}
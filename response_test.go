@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAudioOutput(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			io.WriteString(w, `{"id":"1","object":"chat.completion","model":"gpt-4o-audio-preview","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"","audio":{"id":"a1","data":"UklGRg==","transcript":"Hello there","expires_at":1}}}]}`)
+		default:
+			io.WriteString(w, `{"candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AAEC"}},{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AwQ="}}]}}]}`)
+		}
+	}))
+	defer server.Close()
+	messages := Chat{}.User("Say hello").Messages()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "gpt-4o-audio-preview", 100, 0, true)
+	openAI.SetAudioOutput("alloy", "wav")
+	res, err := openAI.GenerateResponse(context.Background(), messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modalities, _ := body["modalities"].([]interface{}); len(modalities) != 2 || modalities[0] != "text" || modalities[1] != "audio" {
+		t.Errorf("unexpected modalities %v", body["modalities"])
+	}
+	if audio, _ := body["audio"].(map[string]interface{}); audio["voice"] != "alloy" || audio["format"] != "wav" {
+		t.Errorf("unexpected audio params %v", body["audio"])
+	}
+	if _, ok := body["response_format"]; ok {
+		t.Errorf("JSON mode should be disabled for audio output: %v", body)
+	}
+	if string(res.Audio) != "RIFF" || res.AudioMimeType != MimeTypeWAV || res.AudioTranscript != "Hello there" {
+		t.Errorf("unexpected OpenAI audio %q %q %q", res.Audio, res.AudioMimeType, res.AudioTranscript)
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.5-flash-preview-tts", 100, true, nil)
+	gemini.SetBaseURL(server.URL)
+	gemini.SetAudioOutput("Kore")
+	if res, err = gemini.GenerateResponse(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	config, _ := body["generationConfig"].(map[string]interface{})
+	if modalities, _ := config["responseModalities"].([]interface{}); len(modalities) != 1 || modalities[0] != "AUDIO" {
+		t.Errorf("unexpected response modalities %v", config)
+	}
+	voice, _ := json.Marshal(config["speechConfig"])
+	if string(voice) != `{"voiceConfig":{"prebuiltVoiceConfig":{"voiceName":"Kore"}}}` {
+		t.Errorf("unexpected speech config %s", voice)
+	}
+	if _, ok := config["responseMimeType"]; ok {
+		t.Errorf("JSON mode should be disabled for audio output: %v", config)
+	}
+	// Audio parts are concatenated
+	if string(res.Audio) != "\x00\x01\x02\x03\x04" || res.AudioMimeType != "audio/L16;codec=pcm;rate=24000" || res.Text != "" {
+		t.Errorf("unexpected Gemini audio %q %q %q", res.Audio, res.AudioMimeType, res.Text)
+	}
+}
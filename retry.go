@@ -0,0 +1,209 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// IsRetryable reports whether err is (or wraps) a transient error that is
+// likely to succeed when retried, such as an overloaded provider. Errors opt
+// in by implementing Retryable() bool.
+func IsRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// RetryLLM retries retryable errors with exponential backoff, other errors are
+// returned right away. A stream that fails after sending output is restarted
+// with a [CLEAR] message, as FallbackLLM does when switching models.
+type RetryLLM struct {
+	LLM
+	maxRetries int
+	delay      time.Duration
+}
+
+// NewRetryLLM creates a RetryLLM, delay is doubled after every attempt
+func NewRetryLLM(llm LLM, maxRetries int, delay time.Duration) *RetryLLM {
+	return &RetryLLM{LLM: llm, maxRetries: maxRetries, delay: delay}
+}
+
+// retry calls fn until it succeeds, fails with a non-retryable error or runs
+// out of attempts
+func (r *RetryLLM) retry(ctx context.Context, fn func() (string, error)) (string, error) {
+	delay := r.delay
+	for attempt := 0; ; attempt++ {
+		res, err := fn()
+		if err == nil || attempt >= r.maxRetries || !IsRetryable(err) {
+			return res, err
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+func (r *RetryLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return r.retry(ctx, func() (string, error) {
+		return r.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (r *RetryLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	send := func(chunk string) error {
+		select {
+		case resultCh <- chunk:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	_, err := r.retry(ctx, func() (string, error) {
+		var sent bool
+		err := consumeStream(ctx, r.LLM, systemPrompt, prompt, func(chunk string) error {
+			sent = true
+			return send(chunk)
+		})
+		if err != nil && sent && IsRetryable(err) {
+			if clearErr := send("[CLEAR]"); clearErr != nil {
+				return "", clearErr
+			}
+		}
+		return "", err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (r *RetryLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	imageBuf, err := bufferImage(image)
+	if err != nil {
+		return "", err
+	}
+
+	return r.retry(ctx, func() (string, error) {
+		var imageReader io.Reader
+		if imageBuf != nil {
+			imageReader = bytes.NewReader(imageBuf.Bytes())
+		}
+		return r.LLM.GenerateWithImage(ctx, prompt, imageReader, mimeType)
+	})
+}
+
+func (r *RetryLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	imageBufs, err := bufferImages(images)
+	if err != nil {
+		return "", err
+	}
+
+	return r.retry(ctx, func() (string, error) {
+		return r.LLM.GenerateWithImages(ctx, prompt, newReadersFromBuffers(imageBufs), mimeTypes)
+	})
+}
+
+func (r *RetryLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return "", err
+	}
+
+	return r.retry(ctx, func() (string, error) {
+		return r.LLM.GenerateWithMessages(ctx, replay())
+	})
+}
+
+func bufferImages(images []io.Reader) ([]*bytes.Buffer, error) {
+	imageBufs := make([]*bytes.Buffer, len(images))
+	for i, img := range images {
+		buf, err := bufferImage(img)
+		if err != nil {
+			return nil, err
+		}
+		imageBufs[i] = buf
+	}
+	return imageBufs, nil
+}
+
+// bufferMessages buffers message images, audio, documents and parts and
+// returns a function that creates copies of messages with fresh readers, so
+// they can be sent again
+func bufferMessages(messages []Message) (func() []Message, error) {
+	images := make([]io.Reader, len(messages))
+	audio := make([]io.Reader, len(messages))
+	documents := make([]io.Reader, len(messages))
+	for i, msg := range messages {
+		images[i] = msg.Image
+		audio[i] = msg.Audio
+		documents[i] = msg.Document
+	}
+	imageBufs, err := bufferImages(images)
+	if err != nil {
+		return nil, err
+	}
+	audioBufs, err := bufferImages(audio)
+	if err != nil {
+		return nil, err
+	}
+	documentBufs, err := bufferImages(documents)
+	if err != nil {
+		return nil, err
+	}
+	partBufs := make([][]*bytes.Buffer, len(messages))
+	for i, msg := range messages {
+		data := make([]io.Reader, len(msg.Parts))
+		for j, part := range msg.Parts {
+			data[j] = part.Data
+		}
+		if partBufs[i], err = bufferImages(data); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() []Message {
+		msgs := make([]Message, len(messages))
+		copy(msgs, messages)
+		for i, reader := range newReadersFromBuffers(imageBufs) {
+			if reader != nil {
+				msgs[i].Image = reader
+			}
+		}
+		for i, reader := range newReadersFromBuffers(audioBufs) {
+			if reader != nil {
+				msgs[i].Audio = reader
+			}
+		}
+		for i, reader := range newReadersFromBuffers(documentBufs) {
+			if reader != nil {
+				msgs[i].Document = reader
+			}
+		}
+		for i, bufs := range partBufs {
+			if len(bufs) == 0 {
+				continue
+			}
+			msgs[i].Parts = make([]ContentPart, len(bufs))
+			copy(msgs[i].Parts, messages[i].Parts)
+			for j, reader := range newReadersFromBuffers(bufs) {
+				if reader != nil {
+					msgs[i].Parts[j].Data = reader
+				}
+			}
+		}
+		return msgs
+	}, nil
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+func TestAnthropicStreamOverloaded(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hel\"}}\n\n")
+		if requests == 1 {
+			fmt.Fprint(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+			return
+		}
+		fmt.Fprint(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"lo\"}}\n\n")
+		fmt.Fprint(w, "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-haiku-20240307", 100, 0, false)
+	llm.client = anthropic.NewClient("key", anthropic.WithBaseURL(server.URL))
+
+	var chunks []string
+	err := consumeStream(context.Background(), llm, "", "Say hello", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	var anthropicErr *AnthropicError
+	if !errors.As(err, &anthropicErr) || anthropicErr.Type != "overloaded_error" || !IsRetryable(err) {
+		t.Fatalf("expected retryable overloaded error, got %v", err)
+	}
+
+	requests = 0
+	chunks = nil
+	err = consumeStream(context.Background(), NewRetryLLM(llm, 2, time.Millisecond), "", "Say hello", func(chunk string) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(chunks) != "[hel [CLEAR] hel lo]" || requests != 2 {
+		t.Errorf("unexpected chunks %q after %d requests", chunks, requests)
+	}
+}
+
+func TestGeminiRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`)
+	}))
+	defer server.Close()
+
+	llm := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	llm.SetBaseURL(server.URL)
+	_, err := llm.GenerateResponse(context.Background(), []Message{{Role: RoleUser, Content: "hi"}})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests || !IsRetryable(err) {
+		t.Fatalf("expected a retryable HTTP error, got %v", err)
+	}
+	if limited, wait := rateLimited(err); !limited || wait != 3*time.Second {
+		t.Errorf("expected a rate limit of 3s, got %v %v", limited, wait)
+	}
+}
+
+func TestRetryLLM(t *testing.T) {
+	var calls int
+	llm := NewRetryLLM(&stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		calls++
+		if calls < 3 {
+			return "", &HTTPError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return "ok", nil
+	}}, 3, time.Millisecond)
+	res, err := llm.Generate(context.Background(), "", "hi")
+	if err != nil || res != "ok" || calls != 3 {
+		t.Fatalf("unexpected result %q, %v after %d calls", res, err, calls)
+	}
+
+	calls = 0
+	llm = NewRetryLLM(&stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		calls++
+		return "", &AnthropicError{Type: "invalid_request_error", Message: "bad"}
+	}}, 3, time.Millisecond)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err == nil || calls != 1 {
+		t.Fatalf("non-retryable error was retried %d times: %v", calls, err)
+	}
+
+	fallback := NewFallbackLLM([]LLM{llm}, func(error) {})
+	if _, err := fallback.Generate(context.Background(), "", "hi"); IsRetryable(err) || !errors.As(err, new(*AnthropicError)) {
+		t.Fatalf("fallback did not wrap the error: %v", err)
+	}
+}
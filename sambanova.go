@@ -0,0 +1,74 @@
+package ai
+
+import (
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// https://docs.sambanova.ai/cloud/docs/get-started/api-reference
+// SambaNova Cloud is OpenAI compatible, but rejects requests with any field
+// it does not know, so the body is reduced to the supported parameters.
+func NewSambaNova(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	return NewOpenAICompatible("https://api.sambanova.ai/v1/", apiKey, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(rewriteJSONBody(sambaNovaCompat)))
+}
+
+var sambaNovaParams = map[string]bool{
+	"model": true, "messages": true, "max_tokens": true, "temperature": true,
+	"top_p": true, "top_k": true, "stop": true, "stream": true, "stream_options": true,
+	"response_format": true, "tools": true, "tool_choice": true, "parallel_tool_calls": true,
+}
+
+var sambaNovaMessageFields = map[string]bool{
+	"role": true, "content": true, "tool_calls": true, "tool_call_id": true,
+}
+
+func sambaNovaCompat(body map[string]interface{}) {
+	// max_completion_tokens is only known by its old name
+	if v, ok := body["max_completion_tokens"]; ok {
+		if _, set := body["max_tokens"]; !set {
+			body["max_tokens"] = v
+		}
+	}
+	for key := range body {
+		if !sambaNovaParams[key] {
+			delete(body, key)
+		}
+	}
+
+	messages, _ := body["messages"].([]interface{})
+	for _, m := range messages {
+		msg, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range msg {
+			if !sambaNovaMessageFields[key] {
+				delete(msg, key)
+			}
+		}
+		// text models expect plain string content
+		if text, ok := textOnlyContent(msg["content"]); ok {
+			msg["content"] = text
+		}
+	}
+}
+
+// textOnlyContent joins content parts that are all text
+func textOnlyContent(content interface{}) (string, bool) {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return "", false
+	}
+	var texts []string
+	for _, p := range parts {
+		part, ok := p.(map[string]interface{})
+		if !ok || part["type"] != "text" {
+			return "", false
+		}
+		text, _ := part["text"].(string)
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, "\n"), true
+}
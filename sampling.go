@@ -0,0 +1,72 @@
+package ai
+
+import "context"
+
+// Sampling are sampling parameters, zero values leave the provider defaults.
+// Parameters a provider does not support are not sent: OpenAI has no TopK
+// (it is sent to other OpenAI-compatible servers though), Claude has no penalties
+// and neither do the Gemini SDK methods of GoogleSimpleLLM.
+type Sampling struct {
+	// TopP samples from the most likely tokens whose probabilities add up to TopP
+	TopP float64
+	// TopK samples from the TopK most likely tokens
+	TopK int
+	// FrequencyPenalty penalizes tokens by how often they appeared so far,
+	// reducing verbatim repetition in long outputs
+	FrequencyPenalty float64
+	// PresencePenalty penalizes tokens that appeared at all, encouraging new
+	// topics
+	PresencePenalty float64
+}
+
+// merge returns s with the non zero parameters of o
+func (s Sampling) merge(o Sampling) Sampling {
+	if o.TopP != 0 {
+		s.TopP = o.TopP
+	}
+	if o.TopK != 0 {
+		s.TopK = o.TopK
+	}
+	if o.FrequencyPenalty != 0 {
+		s.FrequencyPenalty = o.FrequencyPenalty
+	}
+	if o.PresencePenalty != 0 {
+		s.PresencePenalty = o.PresencePenalty
+	}
+	return s
+}
+
+type samplingKey struct{}
+
+// WithSampling returns a context whose requests use the non zero parameters
+// of s instead of the ones set on the client with SetSampling. Deterministic
+// mode (see WithDeterministic) takes precedence.
+func WithSampling(ctx context.Context, s Sampling) context.Context {
+	return context.WithValue(ctx, samplingKey{}, sampling(ctx, Sampling{}).merge(s))
+}
+
+// sampling returns the client's parameters overridden by those of ctx
+func sampling(ctx context.Context, client Sampling) Sampling {
+	if s, ok := ctx.Value(samplingKey{}).(Sampling); ok {
+		return client.merge(s)
+	}
+	return client
+}
+
+// samplingBody adds the sampling parameters to a JSON request body with the
+// usual top_p, top_k, frequency_penalty and presence_penalty keys
+func samplingBody(ctx context.Context, body map[string]interface{}, client Sampling) {
+	s := sampling(ctx, client)
+	if s.TopP != 0 {
+		body["top_p"] = s.TopP
+	}
+	if s.TopK != 0 {
+		body["top_k"] = s.TopK
+	}
+	if s.FrequencyPenalty != 0 {
+		body["frequency_penalty"] = s.FrequencyPenalty
+	}
+	if s.PresencePenalty != 0 {
+		body["presence_penalty"] = s.PresencePenalty
+	}
+}
@@ -0,0 +1,67 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+func TestSampling(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/messages" {
+			fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+	}))
+	defer server.Close()
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "m", 100, 0.7, false)
+	openAI.SetSampling(Sampling{TopP: 0.9, FrequencyPenalty: 0.5})
+	ctx := WithSampling(context.Background(), Sampling{TopK: 40, PresencePenalty: 0.3})
+	if _, err := openAI.GenerateWithMessages(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["top_p"] != 0.9 || body["top_k"] != 40.0 || body["frequency_penalty"] != 0.5 || body["presence_penalty"] != 0.3 {
+		t.Errorf("unexpected sampling parameters %v", body)
+	}
+
+	// api.openai.com rejects top_k
+	hosted := NewOpenAI("key", "m", 100, 0.7, false)
+	hosted.client = openai.NewClient(option.WithAPIKey("key"), option.WithBaseURL(server.URL))
+	if _, err := hosted.GenerateWithMessages(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["top_k"]; ok || body["presence_penalty"] != 0.3 {
+		t.Errorf("unexpected OpenAI sampling parameters %v", body)
+	}
+
+	// Deterministic mode removes the penalties
+	if _, err := openAI.GenerateWithMessages(WithDeterministic(ctx), messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["top_p"] != 1.0 || body["frequency_penalty"] != nil || body["presence_penalty"] != nil {
+		t.Errorf("unexpected deterministic parameters %v", body)
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	anthropic.SetSampling(Sampling{TopP: 0.9, TopK: 10, FrequencyPenalty: 0.5})
+	if _, err := anthropic.GenerateWithMessages(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	if body["top_p"] != 0.9 || body["top_k"] != 40.0 || body["frequency_penalty"] != nil {
+		t.Errorf("unexpected sampling parameters %v", body)
+	}
+}
@@ -0,0 +1,294 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema built with a fluent API, e.g.
+//
+//	Object().
+//		Prop("name", String().Desc("Full name")).
+//		Prop("tags", Array(String())).
+//		Required("name")
+//
+// It is shared by tool definitions and structured output.
+type Schema struct {
+	typ         string
+	description string
+	format      string
+	enum        []string
+	properties  map[string]*Schema
+	order       []string
+	required    []string
+	items       *Schema
+}
+
+func Object() *Schema  { return &Schema{typ: "object"} }
+func String() *Schema  { return &Schema{typ: "string"} }
+func Number() *Schema  { return &Schema{typ: "number"} }
+func Integer() *Schema { return &Schema{typ: "integer"} }
+func Boolean() *Schema { return &Schema{typ: "boolean"} }
+
+func Array(items *Schema) *Schema {
+	return &Schema{typ: "array", items: items}
+}
+
+// Desc sets the description
+func (s *Schema) Desc(description string) *Schema {
+	s.description = description
+	return s
+}
+
+// Format sets the string format, e.g. "date-time"
+func (s *Schema) Format(format string) *Schema {
+	s.format = format
+	return s
+}
+
+// Enum restricts the value to the given options
+func (s *Schema) Enum(values ...string) *Schema {
+	s.enum = append(s.enum, values...)
+	return s
+}
+
+// Prop adds an object property. Properties keep their insertion order.
+func (s *Schema) Prop(name string, prop *Schema) *Schema {
+	if s.properties == nil {
+		s.properties = map[string]*Schema{}
+	}
+	if _, ok := s.properties[name]; !ok {
+		s.order = append(s.order, name)
+	}
+	s.properties[name] = prop
+	return s
+}
+
+// Required marks object properties as required
+func (s *Schema) Required(names ...string) *Schema {
+	for _, name := range names {
+		if !containsString(s.required, name) {
+			s.required = append(s.required, name)
+		}
+	}
+	return s
+}
+
+func (s *Schema) Type() string {
+	return s.typ
+}
+
+func (s *Schema) Description() string {
+	return s.description
+}
+
+// Properties returns object property names in insertion order
+func (s *Schema) Properties() []string {
+	return s.order
+}
+
+// Property returns a property schema by name, or nil
+func (s *Schema) Property(name string) *Schema {
+	return s.properties[name]
+}
+
+func (s *Schema) RequiredProperties() []string {
+	return s.required
+}
+
+func (s *Schema) Items() *Schema {
+	return s.items
+}
+
+func (s *Schema) EnumValues() []string {
+	return s.enum
+}
+
+// Map returns the schema as a JSON Schema map, as expected by provider SDKs
+func (s *Schema) Map() map[string]interface{} {
+	if s == nil {
+		return nil
+	}
+	m := map[string]interface{}{}
+	if s.typ != "" {
+		m["type"] = s.typ
+	}
+	if s.description != "" {
+		m["description"] = s.description
+	}
+	if s.format != "" {
+		m["format"] = s.format
+	}
+	if len(s.enum) > 0 {
+		enum := make([]interface{}, len(s.enum))
+		for i, e := range s.enum {
+			enum[i] = e
+		}
+		m["enum"] = enum
+	}
+	if s.typ == "object" {
+		props := map[string]interface{}{}
+		for _, name := range s.order {
+			props[name] = s.properties[name].Map()
+		}
+		m["properties"] = props
+		if len(s.required) > 0 {
+			required := make([]interface{}, len(s.required))
+			for i, r := range s.required {
+				required[i] = r
+			}
+			m["required"] = required
+		}
+	}
+	if s.items != nil {
+		m["items"] = s.items.Map()
+	}
+	return m
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Map())
+}
+
+// Validate checks a decoded JSON value against the schema
+func (s *Schema) Validate(v interface{}) error {
+	return validateJSONSchema(s.Map(), v, "$")
+}
+
+// ValidateJSON checks raw JSON data against the schema
+func (s *Schema) ValidateJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid JSON: %v", err)
+	}
+	return s.Validate(v)
+}
+
+// SchemaFrom derives a schema from a Go value or type using struct tags:
+//
+//	type Args struct {
+//		City  string `json:"city" jsonschema:"description=City name"`
+//		Units string `json:"units,omitempty" jsonschema:"enum=metric,enum=imperial"`
+//	}
+//
+// Fields are required unless tagged with omitempty or jsonschema:"optional".
+// A jsonschema_description tag can be used for descriptions containing commas.
+func SchemaFrom(v interface{}) (*Schema, error) {
+	var t reflect.Type
+	if rt, ok := v.(reflect.Type); ok {
+		t = rt
+	} else {
+		t = reflect.TypeOf(v)
+	}
+	if t == nil {
+		return nil, fmt.Errorf("cannot derive schema from nil")
+	}
+	return schemaFromType(t, map[reflect.Type]bool{})
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaFromType(t reflect.Type, seen map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return String().Format("date-time"), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String(), nil
+	case reflect.Bool:
+		return Boolean(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer(), nil
+	case reflect.Float32, reflect.Float64:
+		return Number(), nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return String(), nil
+		}
+		items, err := schemaFromType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return Array(items), nil
+	case reflect.Map:
+		return Object(), nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Struct:
+		if seen[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		s := Object()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			prop, err := schemaFromType(field.Type, seen)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", field.Name, err)
+			}
+
+			required := !strings.Contains(opts, "omitempty")
+			for _, tag := range splitSchemaTag(field.Tag.Get("jsonschema")) {
+				key, value, _ := strings.Cut(tag, "=")
+				switch key {
+				case "description":
+					prop.Desc(value)
+				case "enum":
+					prop.Enum(value)
+				case "format":
+					prop.Format(value)
+				case "required":
+					required = true
+				case "optional":
+					required = false
+				}
+			}
+			if desc := field.Tag.Get("jsonschema_description"); desc != "" {
+				prop.Desc(desc)
+			}
+
+			s.Prop(name, prop)
+			if required {
+				s.Required(name)
+			}
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func splitSchemaTag(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
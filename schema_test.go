@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaBuilder(t *testing.T) {
+	s := Object().
+		Prop("name", String().Desc("Full name")).
+		Prop("age", Integer()).
+		Prop("tags", Array(String().Enum("a", "b"))).
+		Required("name")
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"properties":{"age":{"type":"integer"},"name":{"description":"Full name","type":"string"},"tags":{"items":{"enum":["a","b"],"type":"string"},"type":"array"}},"required":["name"],"type":"object"}`
+	if string(data) != expected {
+		t.Fatalf("unexpected schema:\n%s\nexpected:\n%s", data, expected)
+	}
+
+	if err := s.ValidateJSON([]byte(`{"name":"Bob","tags":["a"]}`)); err != nil {
+		t.Fatalf("expected valid: %v", err)
+	}
+	if err := s.ValidateJSON([]byte(`{"tags":["c"]}`)); err == nil {
+		t.Fatalf("expected missing name to fail")
+	}
+}
+
+func TestSchemaFrom(t *testing.T) {
+	type Address struct {
+		City string `json:"city" jsonschema:"description=City name"`
+	}
+	type Person struct {
+		Name    string   `json:"name"`
+		Units   string   `json:"units,omitempty" jsonschema:"enum=metric,enum=imperial"`
+		Address *Address `json:"address" jsonschema_description:"Home address, if known"`
+		Scores  []float64
+		secret  string
+	}
+
+	s, err := SchemaFrom(Person{})
+	if err != nil {
+		t.Fatalf("Error deriving schema: %v", err)
+	}
+	if got := s.Properties(); len(got) != 4 || got[0] != "name" || got[3] != "Scores" {
+		t.Fatalf("unexpected properties: %v", got)
+	}
+	if got := s.RequiredProperties(); len(got) != 3 {
+		t.Fatalf("unexpected required: %v", got)
+	}
+	if got := s.Property("units").EnumValues(); len(got) != 2 {
+		t.Fatalf("unexpected enum: %v", got)
+	}
+	if got := s.Property("address").Property("city").Description(); got != "City name" {
+		t.Fatalf("unexpected description: %q", got)
+	}
+	if got := s.Property("Scores").Items().Type(); got != "number" {
+		t.Fatalf("unexpected items type: %q", got)
+	}
+}
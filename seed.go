@@ -0,0 +1,34 @@
+package ai
+
+import "context"
+
+type seedKey struct{}
+
+// WithSeed returns a context whose requests sample with seed, replacing the
+// seed set on the client with SetSeed and DeterministicSeed in deterministic
+// mode. Providers that accept a seed (OpenAI and most OpenAI-compatible
+// backends, Grok, Gemini, llama.cpp, Cloudflare and Hugging Face text
+// generation) then make a best effort to return the same output for the same
+// request. Compare Response.SystemFingerprint across runs: outputs may change
+// when it does.
+func WithSeed(ctx context.Context, seed int64) context.Context {
+	return context.WithValue(ctx, seedKey{}, seed)
+}
+
+// requestSeed returns the seed of ctx, or the client's if any
+func requestSeed(ctx context.Context, client *int64) (int64, bool) {
+	if seed, ok := ctx.Value(seedKey{}).(int64); ok {
+		return seed, true
+	}
+	if client != nil {
+		return *client, true
+	}
+	return 0, false
+}
+
+// seedBody adds the seed to a JSON request body under key
+func seedBody(ctx context.Context, body map[string]interface{}, key string, client *int64) {
+	if seed, ok := requestSeed(ctx, client); ok {
+		body[key] = seed
+	}
+}
@@ -0,0 +1,231 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Document is a source for AskWithSources
+type Document struct {
+	ID    string
+	Title string
+	Text  string
+}
+
+// Source is a chunk of a document that was given to the model, Index is the
+// number it is cited by
+type Source struct {
+	Index      int
+	DocumentID string
+	Title      string
+	Text       string
+}
+
+// SourcedAnswer is an answer with the sources it cites. The answer keeps the
+// citation markers, e.g. "Paris is the capital [1]."
+type SourcedAnswer struct {
+	Answer string
+	// Citations are the cited sources in order of first citation
+	Citations []Source
+	// Sources are all sources given to the model
+	Sources []Source
+}
+
+// AskOptions configures AskWithSourcesOptions
+type AskOptions struct {
+	// MaxContextTokens is the budget for the sources in the prompt
+	MaxContextTokens int
+	// ChunkTokens is the target size of document chunks
+	ChunkTokens int
+}
+
+// DefaultAskOptions are used by AskWithSources
+var DefaultAskOptions = AskOptions{MaxContextTokens: 3000, ChunkTokens: 300}
+
+const askSystemPrompt = `Answer the question using only the numbered sources below.
+Cite the sources that support each statement with their numbers in square brackets, e.g. [1] or [1][3].
+If the sources do not contain the answer, say that you don't know.`
+
+// AskWithSources answers a question from docs with DefaultAskOptions
+func AskWithSources(ctx context.Context, llm LLM, question string, docs []Document) (*SourcedAnswer, error) {
+	return AskWithSourcesOptions(ctx, llm, question, docs, DefaultAskOptions)
+}
+
+// AskWithSourcesOptions splits docs into chunks, packs the chunks most
+// relevant to the question into the token budget, asks the model to answer
+// with numbered citations and parses them. Relevance is word overlap with the
+// question, chunks that don't fit or share no words with it are left out.
+func AskWithSourcesOptions(ctx context.Context, llm LLM, question string, docs []Document, opts AskOptions) (*SourcedAnswer, error) {
+	if opts.MaxContextTokens <= 0 {
+		opts.MaxContextTokens = DefaultAskOptions.MaxContextTokens
+	}
+	if opts.ChunkTokens <= 0 {
+		opts.ChunkTokens = DefaultAskOptions.ChunkTokens
+	}
+
+	sources := packSources(TokenizerFor(llm.GetModel()), question, docs, opts)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no document content to answer from")
+	}
+	return askSources(ctx, llm, question, sources)
+}
+
+// askSources asks the model to answer question from the numbered sources
+// and parses the citations of the answer
+func askSources(ctx context.Context, llm LLM, question string, sources []Source) (*SourcedAnswer, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Sources:\n\n")
+	for _, s := range sources {
+		fmt.Fprintf(&prompt, "[%d]", s.Index)
+		if s.Title != "" {
+			prompt.WriteString(" " + s.Title)
+		}
+		prompt.WriteString("\n" + s.Text + "\n\n")
+	}
+	prompt.WriteString("Question: " + question)
+
+	answer, err := llm.Generate(ctx, askSystemPrompt, prompt.String())
+	if err != nil {
+		return nil, err
+	}
+	return &SourcedAnswer{Answer: answer, Citations: parseCitations(answer, sources), Sources: sources}, nil
+}
+
+// packSources chunks the documents and keeps the most relevant chunks that
+// fit into the budget, numbered in document order
+func packSources(tokenizer Tokenizer, question string, docs []Document, opts AskOptions) []Source {
+	type candidate struct {
+		source Source
+		order  int
+		score  float64
+		tokens int
+	}
+	terms := wordSet(question)
+	var candidates []candidate
+	for _, doc := range docs {
+		for _, text := range chunkText(tokenizer, doc.Text, opts.ChunkTokens) {
+			words := wordSet(text)
+			var overlap float64
+			for w := range words {
+				if terms[w] {
+					overlap++
+				}
+			}
+			candidates = append(candidates, candidate{
+				source: Source{DocumentID: doc.ID, Title: doc.Title, Text: text},
+				order:  len(candidates),
+				score:  overlap / float64(len(words)+1),
+				tokens: tokenizer.CountTokens(text),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	// unrelated chunks are only used if nothing matches the question
+	relevant := len(candidates) > 0 && candidates[0].score > 0
+	var packed []candidate
+	budget := opts.MaxContextTokens
+	for _, c := range candidates {
+		if relevant && c.score == 0 {
+			break
+		}
+		if c.tokens <= budget {
+			packed = append(packed, c)
+			budget -= c.tokens
+		}
+	}
+	sort.Slice(packed, func(i, j int) bool {
+		return packed[i].order < packed[j].order
+	})
+
+	sources := make([]Source, len(packed))
+	for i, c := range packed {
+		sources[i] = c.source
+		sources[i].Index = i + 1
+	}
+	return sources
+}
+
+// chunkText splits text into chunks of about maxTokens at paragraph breaks,
+// or at word breaks for longer paragraphs
+func chunkText(tokenizer Tokenizer, text string, maxTokens int) []string {
+	var pieces []string
+	for _, para := range strings.Split(text, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		if tokenizer.CountTokens(para) <= maxTokens {
+			pieces = append(pieces, para)
+			continue
+		}
+		var current []string
+		for _, word := range strings.Fields(para) {
+			current = append(current, word)
+			if tokenizer.CountTokens(strings.Join(current, " ")) >= maxTokens {
+				pieces = append(pieces, strings.Join(current, " "))
+				current = nil
+			}
+		}
+		if len(current) > 0 {
+			pieces = append(pieces, strings.Join(current, " "))
+		}
+	}
+
+	var chunks []string
+	var current string
+	for _, piece := range pieces {
+		if current != "" && tokenizer.CountTokens(current+"\n\n"+piece) > maxTokens {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		if current == "" {
+			current = piece
+		} else {
+			current += "\n\n" + piece
+		}
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// wordSet returns the lower case words of text longer than two letters
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len([]rune(w)) > 2 {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+var citationRe = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// parseCitations returns the sources cited in answer, ignoring unknown numbers
+func parseCitations(answer string, sources []Source) []Source {
+	var cited []Source
+	seen := make(map[int]bool)
+	for _, m := range citationRe.FindAllStringSubmatch(answer, -1) {
+		for _, n := range strings.Split(m[1], ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(n))
+			if err != nil || i < 1 || i > len(sources) || seen[i] {
+				continue
+			}
+			seen[i] = true
+			cited = append(cited, sources[i-1])
+		}
+	}
+	return cited
+}
@@ -0,0 +1,33 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAskWithSources(t *testing.T) {
+	var prompt string
+	stub := &stubLLM{model: "m", response: func(systemPrompt, p string) (string, error) {
+		prompt = p
+		return "Paris is the capital of France [2], it is on the Seine [2, 7][1].", nil
+	}}
+	docs := []Document{
+		{ID: "weather", Title: "Weather", Text: strings.Repeat("Rain is common in autumn. ", 40)},
+		{ID: "france", Title: "France", Text: "France is a country in Europe.\n\nThe capital of France is Paris, on the Seine."},
+	}
+
+	res, err := AskWithSourcesOptions(context.Background(), stub, "What is the capital of France?", docs, AskOptions{MaxContextTokens: 25, ChunkTokens: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Sources) != 2 || res.Sources[0].DocumentID != "france" || !strings.Contains(res.Sources[1].Text, "Paris") {
+		t.Fatalf("relevant chunks should be packed in document order: %+v", res.Sources)
+	}
+	if !strings.Contains(prompt, "[2] France\nThe capital of France is Paris") || !strings.HasSuffix(prompt, "Question: What is the capital of France?") {
+		t.Fatalf("unexpected prompt:\n%s", prompt)
+	}
+	if len(res.Citations) != 2 || res.Citations[0].Index != 2 || res.Citations[1].Index != 1 {
+		t.Fatalf("unexpected citations %+v", res.Citations)
+	}
+}
@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const defaultSpecMaxTokens = 4000
+
+// NewLLMFromSpec creates a client from a "provider:model" spec,
+// reading the API key from the <PROVIDER>_API_KEY environment variable.
+// If the model is omitted, <PROVIDER>_MODEL is used.
+func NewLLMFromSpec(spec string) (LLM, error) {
+	provider, model, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	provider = strings.ToLower(provider)
+	envPrefix := strings.ToUpper(provider)
+	if model == "" {
+		model = os.Getenv(envPrefix + "_MODEL")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no model for provider %s: use provider:model or set %s_MODEL", provider, envPrefix)
+	}
+	apiKey := os.Getenv(envPrefix + "_API_KEY")
+
+	switch provider {
+	case "openai":
+		return NewOpenAI(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "anthropic":
+		return NewAnthropic(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "bedrock":
+		return NewAnthropicBedrock(os.Getenv("AWS_REGION"), AWSCredentialsFromEnv(), model, defaultSpecMaxTokens, 1.0, false), nil
+	case "google":
+		return NewGoogleSimple(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "xai":
+		return NewGrok(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "groq":
+		return NewGroq(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "together":
+		return NewTogether(apiKey, model, defaultSpecMaxTokens, 1.0, false, nil), nil
+	case "fireworks":
+		return NewFireworks(apiKey, model, defaultSpecMaxTokens, 1.0, false, nil), nil
+	case "replicate":
+		return NewReplicate(apiKey, model, defaultSpecMaxTokens, 1.0), nil
+	case "huggingface":
+		return NewHuggingFace(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	case "cloudflare":
+		return NewCloudflare(os.Getenv("CLOUDFLARE_ACCOUNT_ID"), apiKey, model, defaultSpecMaxTokens, 1.0), nil
+	case "llamacpp":
+		baseURL := os.Getenv("LLAMACPP_URL")
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		return NewLlamaCpp(baseURL, model, defaultSpecMaxTokens, 1.0), nil
+	case "lambda_lab":
+		return NewLambdaLab(apiKey, model, defaultSpecMaxTokens, 1.0, false), nil
+	}
+	return nil, fmt.Errorf("unknown provider: %s", provider)
+}
+
+// NewLLMChainFromSpecs creates a FallbackLLM from comma separated specs,
+// e.g. "openai:gpt-4o-mini,anthropic:claude-3-5-haiku-latest".
+// A single spec returns the client itself.
+func NewLLMChainFromSpecs(specs string, errorCallback func(error)) (LLM, error) {
+	var llms []LLM
+	for _, spec := range strings.Split(specs, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		llm, err := NewLLMFromSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		llms = append(llms, llm)
+	}
+	if len(llms) == 0 {
+		return nil, fmt.Errorf("no providers specified")
+	}
+	if len(llms) == 1 {
+		return llms[0], nil
+	}
+	return NewFallbackLLM(llms, errorCallback), nil
+}
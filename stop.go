@@ -0,0 +1,33 @@
+package ai
+
+import "context"
+
+type stopSequencesKey struct{}
+
+// WithStopSequences returns a context whose requests end the generation when
+// the model generates any of the stop sequences, replacing the ones set on the
+// client with SetStopSequences. Without arguments it disables the client's
+// stop sequences. The stop sequence itself is not included in the output.
+// Providers without stop sequences ignore them.
+func WithStopSequences(ctx context.Context, stop ...string) context.Context {
+	if stop == nil {
+		stop = []string{}
+	}
+	return context.WithValue(ctx, stopSequencesKey{}, stop)
+}
+
+// stopSequences returns the stop sequences of ctx, or the client's if ctx
+// has none
+func stopSequences(ctx context.Context, client []string) []string {
+	if stop, ok := ctx.Value(stopSequencesKey{}).([]string); ok {
+		return stop
+	}
+	return client
+}
+
+// stopBody adds the stop sequences to a JSON request body under key
+func stopBody(ctx context.Context, body map[string]interface{}, key string, client []string) {
+	if stop := stopSequences(ctx, client); len(stop) > 0 {
+		body[key] = stop
+	}
+}
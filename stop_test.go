@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestStopSequences(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`)
+		case "/messages":
+			fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"stop_sequence"}`)
+		default:
+			fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"ok"}]}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	openAI.SetStopSequences("END")
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	anthropic.SetStopSequences("END")
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	gemini.SetStopSequences("END")
+
+	stop := func(name string) interface{} {
+		switch name {
+		case "openai":
+			return body["stop"]
+		case "anthropic":
+			return body["stop_sequences"]
+		}
+		config, _ := body["generationConfig"].(map[string]interface{})
+		return config["stopSequences"]
+	}
+	messages := []Message{{Role: RoleUser, Content: "hi"}}
+	for name, generate := range map[string]func(ctx context.Context) error{
+		"openai": func(ctx context.Context) error {
+			_, err := openAI.GenerateWithMessages(ctx, messages)
+			return err
+		},
+		"anthropic": func(ctx context.Context) error {
+			_, err := anthropic.GenerateWithMessages(ctx, messages)
+			return err
+		},
+		"gemini": func(ctx context.Context) error {
+			_, err := gemini.GenerateResponse(ctx, messages)
+			return err
+		},
+	} {
+		if err := generate(context.Background()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := stop(name); !reflect.DeepEqual(got, []interface{}{"END"}) {
+			t.Errorf("%s: expected the client stop sequences, got %v", name, got)
+		}
+
+		if err := generate(WithStopSequences(context.Background(), "\n\n", "###")); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := stop(name); !reflect.DeepEqual(got, []interface{}{"\n\n", "###"}) {
+			t.Errorf("%s: expected the request stop sequences, got %v", name, got)
+		}
+
+		if err := generate(WithStopSequences(context.Background())); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := stop(name); got != nil {
+			t.Errorf("%s: expected no stop sequences, got %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MessagesStreamer is implemented by clients that stream replies to
+// conversations, the streaming variant of LLM.GenerateWithMessages. All
+// provider clients implement it.
+type MessagesStreamer interface {
+	// GenerateWithMessagesStream streams the reply to messages like
+	// GenerateStream does
+	GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error)
+}
+
+// GenerateWithMessagesStream streams the reply of llm to messages. Clients
+// that are not a MessagesStreamer, such as wrappers, get the conversation
+// flattened into a single prompt, which fails if it has images or other media.
+func GenerateWithMessagesStream(ctx context.Context, llm LLM, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	if s, ok := llm.(MessagesStreamer); ok {
+		s.GenerateWithMessagesStream(ctx, messages, resultCh, doneCh, errCh)
+		return
+	}
+	for _, msg := range messages {
+		if msg.hasMedia() {
+			select {
+			case errCh <- fmt.Errorf("%s cannot stream messages with media", llm.GetModel()):
+			case <-ctx.Done():
+			}
+			return
+		}
+	}
+	systemPrompt, prompt := messagesToPrompt(messages)
+	llm.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+}
+
+// GenerateStreamFunc streams the reply of llm to prompt, calling fn for
+// every chunk, including "[CLEAR]" chunks. It returns once the stream
+// completes. If fn returns an error, generation is canceled and the error
+// is returned.
+func GenerateStreamFunc(ctx context.Context, llm LLM, systemPrompt, prompt string, fn func(chunk string) error) error {
+	return consumeStream(ctx, llm, systemPrompt, prompt, fn)
+}
+
+// StopCondition reports whether generation should stop, given the output so far
+type StopCondition func(output string) bool
+
+// GenerateStreamUntil streams like llm.GenerateStream, but aborts generation as soon
+// as stop returns true for the accumulated output. The chunk that satisfied the
+// condition is still sent, followed by doneCh. Useful for providers without
+// flexible stop sequences.
+func GenerateStreamUntil(ctx context.Context, llm LLM, systemPrompt, prompt string, stop StopCondition, resultCh chan string, doneCh chan bool, errCh chan error) {
+	var output strings.Builder
+	err := consumeStream(ctx, llm, systemPrompt, prompt, func(chunk string) error {
+		output.WriteString(chunk)
+		select {
+		case resultCh <- chunk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if stop(output.String()) {
+			return errStopStream
+		}
+		return nil
+	})
+	if err != nil {
+		errCh <- err
+		return
+	}
+	doneCh <- true
+}
+
+// errStopStream is returned by consumeStream callbacks to end a stream early without error
+var errStopStream = errors.New("stop stream")
+
+// consumeStream runs llm.GenerateStream and calls fn for every chunk until the
+// stream completes. It hides provider differences: some providers close the
+// channels, others keep blocking until the context is canceled.
+func consumeStream(ctx context.Context, llm LLM, systemPrompt, prompt string, fn func(chunk string) error) error {
+	return consumeChannels(ctx, func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error) {
+		llm.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+	}, fn)
+}
+
+// consumeMessagesStream is consumeStream for GenerateWithMessagesStream
+func consumeMessagesStream(ctx context.Context, llm LLM, messages []Message, fn func(chunk string) error) error {
+	return consumeChannels(ctx, func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error) {
+		GenerateWithMessagesStream(ctx, llm, messages, resultCh, doneCh, errCh)
+	}, fn)
+}
+
+// consumeChannels runs a stream started by start, see consumeStream
+func consumeChannels(ctx context.Context, start func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error), fn func(chunk string) error) error {
+	genCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resultCh := make(chan string)
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+	go start(genCtx, resultCh, doneCh, errCh)
+
+	for {
+		select {
+		case chunk, ok := <-resultCh:
+			if !ok {
+				resultCh = nil
+				continue
+			}
+			if err := fn(chunk); err != nil {
+				cancel()
+				go drainStream(resultCh, doneCh, errCh)
+				if err == errStopStream {
+					return nil
+				}
+				return err
+			}
+		case _, ok := <-doneCh:
+			if !ok {
+				doneCh = nil
+				continue
+			}
+			return nil
+		case err, ok := <-errCh:
+			if !ok || err == nil {
+				errCh = nil
+				continue
+			}
+			return err
+		case <-ctx.Done():
+			cancel()
+			go drainStream(resultCh, doneCh, errCh)
+			return ctx.Err()
+		}
+	}
+}
+
+// drainStream consumes whatever a canceled stream still sends, so provider
+// goroutines blocked on unbuffered channels can exit. It stops once all
+// channels are closed or nothing arrives for a second.
+func drainStream(resultCh chan string, doneCh chan bool, errCh chan error) {
+	for resultCh != nil || doneCh != nil || errCh != nil {
+		select {
+		case _, ok := <-resultCh:
+			if !ok {
+				resultCh = nil
+			}
+		case _, ok := <-doneCh:
+			if !ok {
+				doneCh = nil
+			}
+		case _, ok := <-errCh:
+			if !ok {
+				errCh = nil
+			}
+		case <-time.After(time.Second):
+			return
+		}
+	}
+}
+
+// StopOnSentinel stops generation once the output contains sentinel
+func StopOnSentinel(sentinel string) StopCondition {
+	return func(output string) bool {
+		return strings.Contains(output, sentinel)
+	}
+}
+
+// StopOnBalancedJSON stops generation once the first JSON object or array
+// in the output is closed, ignoring brackets inside strings
+func StopOnBalancedJSON() StopCondition {
+	return func(output string) bool {
+		depth := 0
+		started := false
+		inString := false
+		escaped := false
+		for _, r := range output {
+			if inString {
+				switch {
+				case escaped:
+					escaped = false
+				case r == '\\':
+					escaped = true
+				case r == '"':
+					inString = false
+				}
+				continue
+			}
+			switch r {
+			case '"':
+				if started {
+					inString = true
+				}
+			case '{', '[':
+				depth++
+				started = true
+			case '}', ']':
+				if started {
+					depth--
+					if depth == 0 {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+}
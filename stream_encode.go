@@ -0,0 +1,196 @@
+package ai
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// StreamEvent is one event of a generation stream in the wire schema shared
+// by all stream encoders
+type StreamEvent struct {
+	// Type is "delta", "clear", "reasoning", "tool_call", "citation",
+	// "usage", "finish", "done" or "error"
+	Type string `json:"type"`
+	// Seq numbers the events of a stream starting at 0
+	Seq   int    `json:"seq"`
+	Text  string `json:"text,omitempty"`
+	Error string `json:"error,omitempty"`
+	// Citation is set for citation events, see Anthropic.StreamWithCitations
+	Citation *Citation `json:"citation,omitempty"`
+	// ToolCall is set for tool call events, see StreamEvents
+	ToolCall *ToolCallDelta `json:"tool_call,omitempty"`
+	// Usage is set for usage events
+	Usage *TokenUsage `json:"usage,omitempty"`
+	// FinishReason is set for finish events, one of the Finish constants
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+const (
+	StreamEventDelta = "delta"
+	// StreamEventClear discards the text of the previous delta events, when
+	// generation restarts after a failure, e.g. with FallbackLLM
+	StreamEventClear = "clear"
+	// StreamEventReasoning carries the thinking of reasoning models, see
+	// ReasoningStreamer
+	StreamEventReasoning = "reasoning"
+	// StreamEventToolCall carries a part of a tool call
+	StreamEventToolCall = "tool_call"
+	StreamEventCitation = "citation"
+	// StreamEventUsage reports the tokens used by the request
+	StreamEventUsage = "usage"
+	// StreamEventFinish reports why generation stopped
+	StreamEventFinish = "finish"
+	StreamEventDone   = "done"
+	StreamEventError  = "error"
+)
+
+// Finish reasons of StreamEventFinish events, normalized across providers
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool_calls"
+	FinishContentFilter = "content_filter"
+)
+
+// ToolCallDelta is a part of a streamed tool call. The first part of a call
+// has its ID and Name, the Arguments of all parts with the same Index
+// concatenate to the JSON arguments.
+type ToolCallDelta struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// TokenUsage is the number of tokens used by a request, as reported by the
+// provider
+type TokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// StreamEncoder writes stream events in a wire format
+type StreamEncoder interface {
+	Encode(w io.Writer, event StreamEvent) error
+	// ContentType is the HTTP content type of the encoded stream
+	ContentType() string
+}
+
+// NDJSONEncoder writes each event as a line of JSON
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) Encode(w io.Writer, event StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (NDJSONEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// SSEEncoder writes server-sent events named by the event type, with the
+// sequence number as id and the event as JSON data
+type SSEEncoder struct{}
+
+func (SSEEncoder) Encode(w io.Writer, event StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	return err
+}
+
+func (SSEEncoder) ContentType() string {
+	return "text/event-stream"
+}
+
+// WebSocketEncoder writes each event as JSON in an unmasked WebSocket text
+// frame (RFC 6455), for servers that write to a hijacked connection. The
+// handshake and closing the connection are up to the caller.
+type WebSocketEncoder struct{}
+
+func (WebSocketEncoder) Encode(w io.Writer, event StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// FIN bit and text opcode
+	header := []byte{0x81}
+	switch n := len(data); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	_, err = w.Write(append(header, data...))
+	return err
+}
+
+func (WebSocketEncoder) ContentType() string {
+	return ""
+}
+
+// textEvent returns the event of a chunk of GenerateStream
+func textEvent(chunk string) StreamEvent {
+	if chunk == "[CLEAR]" {
+		return StreamEvent{Type: StreamEventClear}
+	}
+	return StreamEvent{Type: StreamEventDelta, Text: chunk}
+}
+
+// EncodeStream streams a generation to w with enc: a delta event per chunk,
+// or a clear event for a "[CLEAR]" chunk, then a done event, or an error event if generation fails. Clients that are
+// an EventStreamer also send reasoning, usage and finish events. w is flushed after
+// every event if it is an http.Flusher. The generation error is returned after
+// it was written.
+func EncodeStream(ctx context.Context, llm LLM, systemPrompt, prompt string, w io.Writer, enc StreamEncoder) error {
+	seq := 0
+	write := func(event StreamEvent) error {
+		event.Seq = seq
+		seq++
+		if err := enc.Encode(w, event); err != nil {
+			return err
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return nil
+	}
+
+	var writeErr error
+	var err error
+	if s, ok := llm.(EventStreamer); ok {
+		err = s.StreamEvents(ctx, promptMessages(systemPrompt, prompt), nil, func(event StreamEvent) error {
+			writeErr = write(event)
+			return writeErr
+		})
+	} else {
+		err = consumeStream(ctx, llm, systemPrompt, prompt, func(chunk string) error {
+			writeErr = write(textEvent(chunk))
+			return writeErr
+		})
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		if werr := write(StreamEvent{Type: StreamEventError, Error: err.Error()}); werr != nil {
+			return werr
+		}
+		return err
+	}
+	return write(StreamEvent{Type: StreamEventDone})
+}
@@ -0,0 +1,228 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+)
+
+// EventStreamer is implemented by clients that stream typed events
+type EventStreamer interface {
+	// StreamEvents streams a reply to messages, which may call tools, as
+	// delta, reasoning, tool_call, usage and finish events numbered from 0.
+	// The stream ends when it returns. Returning an error from fn stops the
+	// stream and returns the error.
+	StreamEvents(ctx context.Context, messages []Message, tools []Tool, fn func(StreamEvent) error) error
+}
+
+// StreamEvents streams the reply of llm to messages as typed events, see
+// EventStreamer. Other clients only send delta events, and fail if tools are
+// given.
+//
+//	err := ai.StreamEvents(ctx, llm, messages, tools, func(e ai.StreamEvent) error {
+//		switch e.Type {
+//		case ai.StreamEventDelta:
+//			fmt.Print(e.Text)
+//		case ai.StreamEventUsage:
+//			account(e.Usage)
+//		}
+//		return nil
+//	})
+func StreamEvents(ctx context.Context, llm LLM, messages []Message, tools []Tool, fn func(StreamEvent) error) error {
+	if s, ok := llm.(EventStreamer); ok {
+		return s.StreamEvents(ctx, messages, tools, fn)
+	}
+	return streamTextEvents(ctx, llm, messages, tools, fn)
+}
+
+// streamTextEvents streams the text of a reply as delta events
+func streamTextEvents(ctx context.Context, llm LLM, messages []Message, tools []Tool, fn func(StreamEvent) error) error {
+	if len(tools) > 0 {
+		return fmt.Errorf("%s cannot stream tool calls", llm.GetModel())
+	}
+	seq := 0
+	return consumeMessagesStream(ctx, llm, messages, func(chunk string) error {
+		event := textEvent(chunk)
+		event.Seq = seq
+		seq++
+		return fn(event)
+	})
+}
+
+// openAIFinishReasons maps finish reasons of compatible servers that differ
+// from OpenAI's
+var openAIFinishReasons = map[string]string{"eos": FinishStop, "function_call": FinishToolCalls}
+
+// StreamEvents streams text, reasoning and tool call deltas, then the finish
+// reason and the usage
+func (o *OpenAI) StreamEvents(ctx context.Context, messages []Message, tools []Tool, fn func(StreamEvent) error) error {
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return err
+	}
+	if len(tools) > 0 {
+		params.Tools = openai.F(openAITools(tools))
+	}
+	params.StreamOptions = openai.F(openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.F(true)})
+	opts := o.requestParams(ctx, &params)
+	stream := o.client.Chat.Completions.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	seq := 0
+	emit := func(event StreamEvent) error {
+		if event.Type == StreamEventDelta || event.Type == StreamEventReasoning {
+			if event.Text == "" {
+				return nil
+			}
+		}
+		event.Seq = seq
+		seq++
+		return fn(event)
+	}
+	var think thinkSplitter
+	var finish string
+	var usage *TokenUsage
+	for stream.Next() {
+		chunk := stream.Current()
+		// sent in a last chunk without choices
+		if !chunk.JSON.Usage.IsNull() {
+			usage = &TokenUsage{InputTokens: int(chunk.Usage.PromptTokens), OutputTokens: int(chunk.Usage.CompletionTokens)}
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if err := emit(StreamEvent{Type: StreamEventReasoning, Text: extraReasoning(delta.JSON.ExtraFields)}); err != nil {
+			return err
+		}
+		reasoning, answer := think.write(delta.Content)
+		if err := emit(StreamEvent{Type: StreamEventReasoning, Text: reasoning}); err != nil {
+			return err
+		}
+		if err := emit(StreamEvent{Type: StreamEventDelta, Text: answer}); err != nil {
+			return err
+		}
+		for _, call := range delta.ToolCalls {
+			if err := emit(StreamEvent{Type: StreamEventToolCall, ToolCall: &ToolCallDelta{
+				Index:     int(call.Index),
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			}}); err != nil {
+				return err
+			}
+		}
+		if reason := string(chunk.Choices[0].FinishReason); reason != "" {
+			finish = reason
+			if mapped, ok := openAIFinishReasons[reason]; ok {
+				finish = mapped
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	reasoning, answer := think.flush()
+	if err := emit(StreamEvent{Type: StreamEventReasoning, Text: reasoning}); err != nil {
+		return err
+	}
+	if err := emit(StreamEvent{Type: StreamEventDelta, Text: answer}); err != nil {
+		return err
+	}
+	if finish != "" {
+		if err := emit(StreamEvent{Type: StreamEventFinish, FinishReason: finish}); err != nil {
+			return err
+		}
+	}
+	if usage == nil {
+		return nil
+	}
+	return emit(StreamEvent{Type: StreamEventUsage, Usage: usage})
+}
+
+// anthropicFinishReasons maps Claude stop reasons to finish reasons
+var anthropicFinishReasons = map[anthropic.MessagesStopReason]string{
+	anthropic.MessagesStopReasonEndTurn:      FinishStop,
+	anthropic.MessagesStopReasonStopSequence: FinishStop,
+	anthropic.MessagesStopReasonMaxTokens:    FinishLength,
+	anthropic.MessagesStopReasonToolUse:      FinishToolCalls,
+	anthropicStopReasonRefusal:               FinishContentFilter,
+}
+
+// StreamEvents streams text and tool call deltas, then the finish reason and
+// the usage. Clients on Bedrock only stream text.
+func (a *Anthropic) StreamEvents(ctx context.Context, messages []Message, tools []Tool, fn func(StreamEvent) error) error {
+	if a.bedrock != nil {
+		return streamTextEvents(ctx, a, messages, tools, fn)
+	}
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return err
+	}
+	req.Tools = anthropicTools(tools)
+
+	// Callbacks cannot return errors, the first error of fn cancels the stream
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var fnErr error
+	seq := 0
+	emit := func(event StreamEvent) {
+		if fnErr != nil {
+			return
+		}
+		event.Seq = seq
+		seq++
+		if fnErr = fn(event); fnErr != nil {
+			cancel()
+		}
+	}
+
+	// tool calls are numbered from 0 like OpenAI's, content blocks count text too
+	toolIndex := map[int]int{}
+	var usage TokenUsage
+	var stopReason anthropic.MessagesStopReason
+	_, err = a.client.CreateMessagesStream(ctx, anthropic.MessagesStreamRequest{
+		MessagesRequest: req,
+		OnMessageStart: func(data anthropic.MessagesEventMessageStartData) {
+			usage.InputTokens = data.Message.Usage.InputTokens
+		},
+		OnContentBlockStart: func(data anthropic.MessagesEventContentBlockStartData) {
+			block := data.ContentBlock
+			if block.Type != anthropic.MessagesContentTypeToolUse || block.MessageContentToolUse == nil {
+				return
+			}
+			toolIndex[data.Index] = len(toolIndex)
+			emit(StreamEvent{Type: StreamEventToolCall, ToolCall: &ToolCallDelta{Index: toolIndex[data.Index], ID: block.ID, Name: block.Name}})
+		},
+		OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
+			if data.Delta.Text != nil && *data.Delta.Text != "" {
+				emit(StreamEvent{Type: StreamEventDelta, Text: *data.Delta.Text})
+			}
+			if index, ok := toolIndex[data.Index]; ok && data.Delta.PartialJson != nil && *data.Delta.PartialJson != "" {
+				emit(StreamEvent{Type: StreamEventToolCall, ToolCall: &ToolCallDelta{Index: index, Arguments: *data.Delta.PartialJson}})
+			}
+		},
+		OnMessageDelta: func(data anthropic.MessagesEventMessageDeltaData) {
+			stopReason = data.Delta.StopReason
+			usage.OutputTokens = data.Usage.OutputTokens
+		},
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil && err != io.EOF {
+		return apiError(err)
+	}
+	if stopReason != "" {
+		finish, ok := anthropicFinishReasons[stopReason]
+		if !ok {
+			finish = string(stopReason)
+		}
+		emit(StreamEvent{Type: StreamEventFinish, FinishReason: finish})
+	}
+	emit(StreamEvent{Type: StreamEventUsage, Usage: &usage})
+	return fnErr
+}
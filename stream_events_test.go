@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// collectEvents returns the events of StreamEvents
+func collectEvents(t *testing.T, llm LLM, tools []Tool) []StreamEvent {
+	t.Helper()
+	var events []StreamEvent
+	err := StreamEvents(context.Background(), llm, []Message{{Role: RoleUser, Content: "Weather in Paris?"}}, tools, func(event StreamEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range events {
+		if e.Seq != i {
+			t.Fatalf("unexpected sequence numbers %+v", events)
+		}
+	}
+	return events
+}
+
+func TestOpenAIStreamEvents(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range []string{
+			`{"choices":[{"index":0,"delta":{"content":"Checking."}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+			`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`,
+		} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",%s\n\n", chunk[1:])
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	llm := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	events := collectEvents(t, llm, []Tool{weatherTool})
+	if options, _ := body["stream_options"].(map[string]interface{}); options["include_usage"] != true || body["tools"] == nil {
+		t.Errorf("unexpected request %v", body)
+	}
+	if len(events) != 5 || events[0].Text != "Checking." {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if c := events[1].ToolCall; events[1].Type != StreamEventToolCall || c.ID != "call_1" || c.Name != "get_weather" {
+		t.Errorf("unexpected tool call %+v", c)
+	}
+	if c := events[2].ToolCall; c.Index != 0 || c.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool call %+v", c)
+	}
+	if events[3].Type != StreamEventFinish || events[3].FinishReason != FinishToolCalls {
+		t.Errorf("unexpected finish %+v", events[3])
+	}
+	if u := events[4].Usage; events[4].Type != StreamEventUsage || u.InputTokens != 12 || u.OutputTokens != 7 {
+		t.Errorf("unexpected usage %+v", events[4])
+	}
+
+	// EncodeStream sends the typed events too
+	var buf bytes.Buffer
+	if err := EncodeStream(context.Background(), llm, "", "hi", &buf, NDJSONEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"finish_reason":"tool_calls"`) || !strings.Contains(buf.String(), `{"type":"done","seq":5}`) {
+		t.Errorf("unexpected encoded stream:\n%s", buf.String())
+	}
+}
+
+func TestAnthropicStreamEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":12,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		} {
+			var e struct{ Type string }
+			json.Unmarshal([]byte(event), &e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, event)
+		}
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	llm.SetBaseURL(server.URL)
+	events := collectEvents(t, llm, []Tool{weatherTool})
+	if len(events) != 6 || events[0].Text != "Checking." {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if c := events[1].ToolCall; c.Index != 0 || c.ID != "toolu_1" || c.Name != "get_weather" {
+		t.Errorf("unexpected tool call %+v", c)
+	}
+	if args := events[2].ToolCall.Arguments + events[3].ToolCall.Arguments; args != `{"city":"Paris"}` {
+		t.Errorf("unexpected arguments %s", args)
+	}
+	if events[4].FinishReason != FinishToolCalls || events[5].Usage.InputTokens != 12 || events[5].Usage.OutputTokens != 7 {
+		t.Errorf("unexpected events %+v", events[4:])
+	}
+}
+
+func TestStreamEventsText(t *testing.T) {
+	llm := NewMockLLM("mock", "Hello world")
+	llm.SetFailureProfile(FailureProfile{ChunkSize: 5})
+	events := collectEvents(t, llm, nil)
+	if len(events) == 0 || events[0].Type != StreamEventDelta || events[0].Text != "Hello" {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if err := StreamEvents(context.Background(), llm, nil, []Tool{weatherTool}, func(StreamEvent) error { return nil }); err == nil {
+		t.Error("expected an error for tools")
+	}
+}
@@ -0,0 +1,60 @@
+//go:build go1.23
+
+package ai
+
+import (
+	"context"
+	"iter"
+)
+
+// StreamChunk is a piece of a reply streamed by Stream
+type StreamChunk struct {
+	Text string
+	// Clear is set, with no text, when the output so far must be discarded
+	// because generation restarted, e.g. after FallbackLLM switched models.
+	// It replaces the "[CLEAR]" chunk of GenerateStream.
+	Clear bool
+}
+
+// Stream streams the reply of llm to prompt as an iterator, an alternative
+// to the channels of GenerateStream that handles their closing and
+// cancellation. The sequence ends after the last chunk, or after a single
+// error. Breaking out of the loop cancels generation.
+//
+//	for chunk, err := range ai.Stream(ctx, llm, "", "Tell me a story") {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Print(chunk.Text)
+//	}
+func Stream(ctx context.Context, llm LLM, systemPrompt, prompt string) iter.Seq2[StreamChunk, error] {
+	return streamSeq(ctx, func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error) {
+		llm.GenerateStream(ctx, systemPrompt, prompt, resultCh, doneCh, errCh)
+	})
+}
+
+// StreamMessages streams the reply of llm to messages as an iterator, see
+// Stream and GenerateWithMessagesStream
+func StreamMessages(ctx context.Context, llm LLM, messages []Message) iter.Seq2[StreamChunk, error] {
+	return streamSeq(ctx, func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error) {
+		GenerateWithMessagesStream(ctx, llm, messages, resultCh, doneCh, errCh)
+	})
+}
+
+func streamSeq(ctx context.Context, start func(ctx context.Context, resultCh chan string, doneCh chan bool, errCh chan error)) iter.Seq2[StreamChunk, error] {
+	return func(yield func(StreamChunk, error) bool) {
+		err := consumeChannels(ctx, start, func(text string) error {
+			chunk := StreamChunk{Text: text}
+			if text == "[CLEAR]" {
+				chunk = StreamChunk{Clear: true}
+			}
+			if !yield(chunk, nil) {
+				return errStopStream
+			}
+			return nil
+		})
+		if err != nil {
+			yield(StreamChunk{}, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+//go:build go1.23
+
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	llm := NewMockLLM("mock", "Hello world")
+	llm.SetFailureProfile(FailureProfile{ChunkSize: 3})
+
+	var out strings.Builder
+	for chunk, err := range Stream(ctx, llm, "", "hi") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString(chunk.Text)
+	}
+	if out.String() != "Hello world" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	// Breaking out of the loop stops generation
+	var n int
+	for range Stream(ctx, llm, "", "hi") {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("expected a single chunk, got %d", n)
+	}
+
+	// A fallback restart is reported as a Clear chunk
+	failing := &stubLLM{model: "failing", response: func(systemPrompt, prompt string) (string, error) {
+		return "", errors.New("down")
+	}}
+	var chunks []StreamChunk
+	for chunk, err := range StreamMessages(ctx, NewFallbackLLM([]LLM{failing, llm}, nil), []Message{{Role: RoleUser, Content: "hi"}}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) == 0 || !chunks[0].Clear || chunks[0].Text != "" {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+
+	var errs int
+	for _, err := range Stream(ctx, failing, "", "hi") {
+		if err == nil || err.Error() != "down" {
+			t.Errorf("unexpected error %v", err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("expected a single error, got %d", errs)
+	}
+}
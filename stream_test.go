@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chunkedLLM streams the response in fixed chunks
+type chunkedLLM struct {
+	stubLLM
+	chunks []string
+	// err fails the stream after the chunks
+	err error
+}
+
+func (c *chunkedLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	for _, chunk := range c.chunks {
+		select {
+		case resultCh <- chunk:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if c.err != nil {
+		select {
+		case errCh <- c.err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func TestGenerateStreamUntil(t *testing.T) {
+	llm := &chunkedLLM{chunks: []string{`{"a": "}`, `{", `, `"b": [1]}`, ` trailing`, ` text`}}
+
+	resultCh := make(chan string)
+	doneCh := make(chan bool)
+	errCh := make(chan error)
+	go GenerateStreamUntil(context.Background(), llm, "", "", StopOnBalancedJSON(), resultCh, doneCh, errCh)
+
+	var out strings.Builder
+	for done := false; !done; {
+		select {
+		case chunk := <-resultCh:
+			out.WriteString(chunk)
+		case <-doneCh:
+			done = true
+		case err := <-errCh:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if out.String() != `{"a": "}{", "b": [1]}` {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestStopOnSentinel(t *testing.T) {
+	stop := StopOnSentinel("<END>")
+	if stop("abc <EN") || !stop("abc <END> more") {
+		t.Fatalf("unexpected sentinel matching")
+	}
+}
+
+func TestEncodeStream(t *testing.T) {
+	llm := &chunkedLLM{chunks: []string{"Hel", "lo"}}
+
+	var buf bytes.Buffer
+	if err := EncodeStream(context.Background(), llm, "", "", &buf, NDJSONEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"delta","seq":0,"text":"Hel"}
+{"type":"delta","seq":1,"text":"lo"}
+{"type":"done","seq":2}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected NDJSON:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	failing := &stubLLM{response: func(string, string) (string, error) { return "", errors.New("boom") }}
+	if err := EncodeStream(context.Background(), failing, "", "", &buf, SSEEncoder{}); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected generation error, got %v", err)
+	}
+	if want := "id: 0\nevent: error\ndata: {\"type\":\"error\",\"seq\":0,\"error\":\"boom\"}\n\n"; buf.String() != want {
+		t.Fatalf("unexpected SSE: %q", buf.String())
+	}
+
+	// A restart of generation is a clear event, not a delta
+	buf.Reset()
+	router := NewFallbackLLM([]LLM{
+		&chunkedLLM{stubLLM: stubLLM{model: "primary"}, chunks: []string{"Hel"}, err: errors.New("reset")},
+		&chunkedLLM{stubLLM: stubLLM{model: "fallback"}, chunks: []string{"Hi"}},
+	}, nil)
+	if err := EncodeStream(context.Background(), router, "", "", &buf, NDJSONEncoder{}); err != nil {
+		t.Fatal(err)
+	}
+	want = `{"type":"delta","seq":0,"text":"Hel"}
+{"type":"clear","seq":1}
+{"type":"delta","seq":2,"text":"Hi"}
+{"type":"done","seq":3}
+`
+	if buf.String() != want {
+		t.Fatalf("unexpected NDJSON:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	WebSocketEncoder{}.Encode(&buf, StreamEvent{Type: StreamEventDelta, Text: strings.Repeat("a", 200)})
+	frame := buf.Bytes()
+	if frame[0] != 0x81 || frame[1] != 126 || int(frame[2])<<8|int(frame[3]) != len(frame)-4 {
+		t.Fatalf("invalid WebSocket frame header % x", frame[:4])
+	}
+}
+
+func TestGenerateWithMessagesStream(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		if strings.Contains(r.URL.Path, ":streamGenerateContent") {
+			for _, text := range []string{"Hel", "lo"} {
+				fmt.Fprintf(w, "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":%q}]}}]}\n\n", text)
+			}
+			return
+		}
+		for _, text := range []string{"Hel", "lo"} {
+			fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be nice"},
+		{Role: RoleUser, Content: "hi"},
+		{Role: RoleAssistant, Content: "hello"},
+		{Role: RoleUser, Content: "again"},
+	}
+	collect := func(llm LLM) string {
+		var out strings.Builder
+		if err := consumeMessagesStream(context.Background(), llm, messages, func(chunk string) error {
+			out.WriteString(chunk)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	if got := collect(NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)); got != "Hello" || len(body["messages"].([]interface{})) != 4 {
+		t.Errorf("unexpected stream %q for %v", got, body)
+	}
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if got := collect(gemini); got != "Hello" || len(body["contents"].([]interface{})) != 3 || body["systemInstruction"] == nil {
+		t.Errorf("unexpected stream %q for %v", got, body)
+	}
+
+	// Other clients get a transcript
+	var prompts []string
+	stub := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		prompts = append(prompts, systemPrompt, prompt)
+		return "ok", nil
+	}}
+	if got := collect(stub); got != "ok" || prompts[0] != "Be nice" || !strings.Contains(prompts[1], "Assistant: hello") {
+		t.Errorf("unexpected stream %q for %q", got, prompts)
+	}
+	// which cannot carry media
+	audio := []Message{{Role: RoleUser, Parts: []ContentPart{TextPart("transcribe"), AudioPart(strings.NewReader("RIFF"), "audio/wav")}}}
+	if err := consumeMessagesStream(context.Background(), stub, audio, func(string) error { return nil }); err == nil || !strings.Contains(err.Error(), "media") {
+		t.Errorf("expected an error for audio parts, got %v", err)
+	}
+}
+
+func TestGenerateStreamFunc(t *testing.T) {
+	llm := NewMockLLM("mock", "Hello world")
+	llm.SetFailureProfile(FailureProfile{ChunkSize: 3})
+
+	var out strings.Builder
+	err := GenerateStreamFunc(context.Background(), llm, "", "hi", func(chunk string) error {
+		out.WriteString(chunk)
+		return nil
+	})
+	if err != nil || out.String() != "Hello world" {
+		t.Fatalf("unexpected output %q, %v", out.String(), err)
+	}
+
+	errFull := errors.New("full")
+	var chunks int
+	err = GenerateStreamFunc(context.Background(), llm, "", "hi", func(chunk string) error {
+		chunks++
+		return errFull
+	})
+	if err != errFull || chunks != 1 {
+		t.Errorf("expected the callback error after one chunk, got %v after %d", err, chunks)
+	}
+}
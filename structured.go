@@ -0,0 +1,360 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/openai/openai-go"
+)
+
+// StructuredGenerator is implemented by clients with native schema
+// constrained output
+type StructuredGenerator interface {
+	// GenerateStructured returns a JSON reply to messages conforming to
+	// schema, name identifies the schema for providers that require one
+	GenerateStructured(ctx context.Context, messages []Message, name string, schema *Schema) (string, error)
+}
+
+// StructuredOutputError is returned when the output does not conform to the schema
+type StructuredOutputError struct {
+	Output string
+	Err    error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("output does not match schema: %v", e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+const structuredSystemPrompt = `Reply with a single JSON value matching this JSON Schema, without explanations or code fences:
+%s`
+
+// GenerateStructured returns a JSON reply to messages conforming to schema.
+// Native structured output is used if llm is a StructuredGenerator (OpenAI
+// json_schema, Gemini response schemas, a forced tool call for Claude),
+// otherwise the schema is added to the system prompt. The output is
+// validated either way, a StructuredOutputError is returned if it does not
+// conform.
+func GenerateStructured(ctx context.Context, llm LLM, messages []Message, name string, schema *Schema) (string, error) {
+	if name == "" {
+		name = "response"
+	}
+	if g, ok := llm.(StructuredGenerator); ok {
+		return g.GenerateStructured(ctx, messages, name, schema)
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	prompt := Message{Role: RoleSystem, Content: fmt.Sprintf(structuredSystemPrompt, schemaJSON)}
+	res, err := llm.GenerateWithMessages(ctx, append([]Message{prompt}, messages...))
+	if err != nil {
+		return "", err
+	}
+	return validateStructured(schema, res)
+}
+
+// StructuredOptions configures GenerateAsWithOptions
+type StructuredOptions struct {
+	// Retries is the number of times the model is asked to fix output that
+	// does not conform to the schema
+	Retries int
+	// Name identifies the schema for providers that require one, defaults to
+	// the name of the type
+	Name string
+}
+
+// DefaultStructuredOptions are used by GenerateAs
+var DefaultStructuredOptions = StructuredOptions{Retries: 1}
+
+// GenerateAs generates a T, see GenerateAsWithOptions
+//
+//	type Person struct {
+//		Name string `json:"name"`
+//		Age  int    `json:"age"`
+//	}
+//	person, err := ai.GenerateAs[Person](ctx, llm, "", "Extract: Ann is 30")
+func GenerateAs[T any](ctx context.Context, llm LLM, systemPrompt, prompt string) (T, error) {
+	return GenerateAsWithOptions[T](ctx, llm, systemPrompt, prompt, DefaultStructuredOptions)
+}
+
+// schemaNamePattern matches names accepted by all providers
+var schemaNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// GenerateAsWithOptions derives a schema from T (see SchemaFrom), requests
+// structured output with it (see GenerateStructured) and unmarshals the
+// result. Output that does not conform is sent back to the model with the
+// validation error, up to opts.Retries times.
+func GenerateAsWithOptions[T any](ctx context.Context, llm LLM, systemPrompt, prompt string, opts StructuredOptions) (T, error) {
+	var res T
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	schema, err := SchemaFrom(t)
+	if err != nil {
+		return res, err
+	}
+	name := opts.Name
+	if name == "" && schemaNamePattern.MatchString(t.Name()) {
+		name = t.Name()
+	}
+
+	var messages []Message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: systemPrompt})
+	}
+	messages = append(messages, Message{Role: RoleUser, Content: prompt})
+	for attempt := 0; ; attempt++ {
+		output, err := GenerateStructured(ctx, llm, messages, name, schema)
+		if err == nil {
+			if err = json.Unmarshal([]byte(output), &res); err != nil {
+				err = &StructuredOutputError{Output: output, Err: err}
+			}
+		}
+		var outputErr *StructuredOutputError
+		if err == nil || !errors.As(err, &outputErr) || attempt >= opts.Retries {
+			return res, err
+		}
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: outputErr.Output},
+			Message{Role: RoleUser, Content: fmt.Sprintf(structuredRetryPrompt, outputErr.Err)},
+		)
+	}
+}
+
+const structuredRetryPrompt = `Your reply does not match the JSON Schema: %v
+Reply again with the corrected JSON only.`
+
+// validateStructured checks output against schema, removing code fences
+func validateStructured(schema *Schema, output string) (string, error) {
+	output = trimCodeFence(output)
+	if err := schema.ValidateJSON([]byte(output)); err != nil {
+		return "", &StructuredOutputError{Output: output, Err: err}
+	}
+	return output, nil
+}
+
+// strictSchema returns the schema for OpenAI strict mode, which requires
+// objects to list all properties as required and forbid others. It reports
+// false if the schema has optional properties.
+func strictSchema(s *Schema) (map[string]interface{}, bool) {
+	if s == nil || s.typ == "" {
+		return nil, false
+	}
+	m := s.Map()
+	if s.items != nil {
+		items, ok := strictSchema(s.items)
+		if !ok {
+			return nil, false
+		}
+		m["items"] = items
+	}
+	if s.typ == "object" {
+		if len(s.required) != len(s.order) {
+			return nil, false
+		}
+		props := map[string]interface{}{}
+		for _, name := range s.order {
+			prop, ok := strictSchema(s.properties[name])
+			if !ok {
+				return nil, false
+			}
+			props[name] = prop
+		}
+		m["properties"] = props
+		m["additionalProperties"] = false
+	}
+	return m, true
+}
+
+// GenerateStructured uses json_schema response formats, in strict mode if
+// all properties of the schema are required
+func (o *OpenAI) GenerateStructured(ctx context.Context, messages []Message, name string, schema *Schema) (string, error) {
+	params, err := o.messagesParams(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+
+	format := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:   openai.F(name),
+		Schema: openai.F[interface{}](schema.Map()),
+	}
+	if strict, ok := strictSchema(schema); ok && schema.typ == "object" {
+		format.Schema = openai.F[interface{}](strict)
+		format.Strict = openai.F(true)
+	}
+	params.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
+		openai.ResponseFormatJSONSchemaParam{
+			Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+			JSONSchema: openai.F(format),
+		},
+	)
+
+	opts := o.requestParams(ctx, &params)
+	resp, err := o.client.Chat.Completions.New(ctx, params, opts...)
+	if err != nil {
+		return "", err
+	}
+	text, err := o.content(resp)
+	if err != nil {
+		return "", err
+	}
+	return validateStructured(schema, text)
+}
+
+// GenerateStructured emulates structured output with a tool whose input is
+// the schema, which Claude is forced to call. Schemas that are not objects
+// are wrapped in a "value" property, as tool inputs must be objects.
+func (a *Anthropic) GenerateStructured(ctx context.Context, messages []Message, name string, schema *Schema) (string, error) {
+	req, err := a.newRequest(ctx, "", messages)
+	if err != nil {
+		return "", err
+	}
+
+	input := schema
+	wrapped := schema.Type() != "object"
+	if wrapped {
+		input = Object().Prop("value", schema).Required("value")
+	}
+	req.Tools = []anthropic.ToolDefinition{{
+		Name:        name,
+		Description: "Respond with the answer in this format",
+		InputSchema: input,
+	}}
+	req.ToolChoice = &anthropic.ToolChoice{Type: "tool", Name: name}
+
+	resp, err := a.client.CreateMessages(ctx, req)
+	if err != nil {
+		return "", apiError(err)
+	}
+	if resp.StopReason == anthropicStopReasonRefusal {
+		return "", &RefusalError{Model: a.model, Message: resp.GetFirstContentText()}
+	}
+	for _, content := range resp.Content {
+		if content.Type != anthropic.MessagesContentTypeToolUse || content.MessageContentToolUse == nil {
+			continue
+		}
+		output := string(content.Input)
+		if wrapped {
+			var v struct {
+				Value json.RawMessage `json:"value"`
+			}
+			if err := json.Unmarshal(content.Input, &v); err != nil {
+				return "", &StructuredOutputError{Output: output, Err: err}
+			}
+			output = string(v.Value)
+		}
+		return validateStructured(schema, output)
+	}
+	return "", errors.New("no structured output generated")
+}
+
+// GenerateStructured uses Gemini response schemas
+func (g *GoogleSimpleLLM) GenerateStructured(ctx context.Context, messages []Message, name string, schema *Schema) (string, error) {
+	req, err := g.restRequest(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	req.GenerationConfig["responseMimeType"] = "application/json"
+	req.GenerationConfig["responseSchema"] = geminiSchema(schema)
+
+	resp, err := g.generateContent(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return validateStructured(schema, text.String())
+}
+
+// GenerateStructured uses Gemini response schemas
+func (g *Google) GenerateStructured(ctx context.Context, messages []Message, name string, schema *Schema) (string, error) {
+	text, err := g.generateMessages(ctx, messages, vertexSchema(schema))
+	if err != nil {
+		return "", err
+	}
+	return validateStructured(schema, text)
+}
+
+// geminiFormats are the string formats supported by Gemini response schemas
+var geminiFormats = map[string]bool{"date-time": true, "enum": true}
+
+// geminiSchema converts a schema to the OpenAPI subset used by the Gemini
+// REST API, with upper case types
+func geminiSchema(s *Schema) map[string]interface{} {
+	m := map[string]interface{}{}
+	if s.typ != "" {
+		m["type"] = strings.ToUpper(s.typ)
+	}
+	if s.description != "" {
+		m["description"] = s.description
+	}
+	if geminiFormats[s.format] {
+		m["format"] = s.format
+	}
+	if len(s.enum) > 0 {
+		m["enum"] = s.enum
+	}
+	if s.typ == "object" && len(s.order) > 0 {
+		props := map[string]interface{}{}
+		for _, name := range s.order {
+			props[name] = geminiSchema(s.properties[name])
+		}
+		m["properties"] = props
+		m["propertyOrdering"] = s.order
+		if len(s.required) > 0 {
+			m["required"] = s.required
+		}
+	}
+	if s.items != nil {
+		m["items"] = geminiSchema(s.items)
+	}
+	return m
+}
+
+// vertexSchema converts a schema for the Vertex AI SDK
+func vertexSchema(s *Schema) *genai.Schema {
+	res := &genai.Schema{
+		Description: s.description,
+		Enum:        s.enum,
+		Required:    s.required,
+	}
+	if geminiFormats[s.format] {
+		res.Format = s.format
+	}
+	switch s.typ {
+	case "string":
+		res.Type = genai.TypeString
+	case "number":
+		res.Type = genai.TypeNumber
+	case "integer":
+		res.Type = genai.TypeInteger
+	case "boolean":
+		res.Type = genai.TypeBoolean
+	case "array":
+		res.Type = genai.TypeArray
+	case "object":
+		res.Type = genai.TypeObject
+	}
+	if len(s.order) > 0 {
+		res.Properties = make(map[string]*genai.Schema, len(s.order))
+		for _, name := range s.order {
+			res.Properties[name] = vertexSchema(s.properties[name])
+		}
+	}
+	if s.items != nil {
+		res.Items = vertexSchema(s.items)
+	}
+	return res
+}
@@ -0,0 +1,152 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+var personSchema = Object().
+	Prop("name", String()).
+	Prop("age", Integer()).
+	Required("name", "age")
+
+func TestGenerateStructuredPrompted(t *testing.T) {
+	answer := "```json\n{\"name\": \"Ann\", \"age\": 30}\n```"
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		return answer, nil
+	}}
+	messages := []Message{{Role: RoleUser, Content: "Ann is 30"}}
+
+	res, err := GenerateStructured(context.Background(), llm, messages, "person", personSchema)
+	if err != nil || res != `{"name": "Ann", "age": 30}` {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+
+	answer = `{"name": "Ann"}`
+	_, err = GenerateStructured(context.Background(), llm, messages, "person", personSchema)
+	var outputErr *StructuredOutputError
+	if !errors.As(err, &outputErr) || outputErr.Output != answer {
+		t.Fatalf("expected StructuredOutputError, got %v", err)
+	}
+}
+
+func TestOpenAIGenerateStructured(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{\"name\":\"Ann\",\"age\":30}"}}]}`)
+	}))
+	defer server.Close()
+
+	llm := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	messages := []Message{{Role: RoleUser, Content: "Ann is 30"}}
+	res, err := GenerateStructured(context.Background(), llm, messages, "person", personSchema)
+	if err != nil || res != `{"name":"Ann","age":30}` {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	format := body["response_format"].(map[string]interface{})
+	jsonSchema := format["json_schema"].(map[string]interface{})
+	if format["type"] != "json_schema" || jsonSchema["name"] != "person" || jsonSchema["strict"] != true ||
+		jsonSchema["schema"].(map[string]interface{})["additionalProperties"] != false {
+		t.Errorf("unexpected response format %v", format)
+	}
+
+	// Optional properties are not allowed in strict mode
+	optional := Object().Prop("name", String()).Prop("age", Integer()).Required("name")
+	if _, err := llm.GenerateStructured(context.Background(), messages, "person", optional); err != nil {
+		t.Fatal(err)
+	}
+	if strict, _ := body["response_format"].(map[string]interface{})["json_schema"].(map[string]interface{})["strict"].(bool); strict {
+		t.Error("expected non strict mode for optional properties")
+	}
+}
+
+func TestAnthropicGenerateStructured(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","stop_reason":"tool_use",
+			"content":[{"type":"tool_use","id":"toolu_1","name":"tags","input":{"value":["a","b"]}}]}`)
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	llm.SetBaseURL(server.URL)
+	res, err := GenerateStructured(context.Background(), llm, []Message{{Role: RoleUser, Content: "tags?"}}, "tags", Array(String()))
+	if err != nil || res != `["a","b"]` {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	choice := body["tool_choice"].(map[string]interface{})
+	if choice["type"] != "tool" || choice["name"] != "tags" {
+		t.Errorf("unexpected tool choice %v", choice)
+	}
+}
+
+func TestGeminiGenerateStructured(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"{\"name\":\"Ann\",\"age\":30}"}]}}]}`)
+	}))
+	defer server.Close()
+
+	llm := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	llm.SetBaseURL(server.URL)
+	res, err := GenerateStructured(context.Background(), llm, []Message{{Role: RoleUser, Content: "Ann is 30"}}, "person", personSchema)
+	if err != nil || res != `{"name":"Ann","age":30}` {
+		t.Fatalf("unexpected result %q, %v", res, err)
+	}
+	config := body["generationConfig"].(map[string]interface{})
+	schema := config["responseSchema"].(map[string]interface{})
+	if config["responseMimeType"] != "application/json" || schema["type"] != "OBJECT" ||
+		schema["properties"].(map[string]interface{})["age"].(map[string]interface{})["type"] != "INTEGER" {
+		t.Errorf("unexpected generation config %v", config)
+	}
+
+	vertex := vertexSchema(Object().Prop("tags", Array(String().Enum("a", "b"))).Required("tags"))
+	if vertex.Type != genai.TypeObject || vertex.Properties["tags"].Items.Type != genai.TypeString ||
+		len(vertex.Properties["tags"].Items.Enum) != 2 || vertex.Required[0] != "tags" {
+		t.Errorf("unexpected vertex schema %+v", vertex)
+	}
+}
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestGenerateAs(t *testing.T) {
+	var prompts []string
+	answers := []string{`{"name": "Ann", "age": "thirty"}`, `{"name": "Ann", "age": 30}`}
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}}
+
+	p, err := GenerateAs[person](context.Background(), llm, "Extract people", "Ann is 30")
+	if err != nil || p.Name != "Ann" || p.Age != 30 {
+		t.Fatalf("unexpected result %+v, %v", p, err)
+	}
+	if len(prompts) != 2 || prompts[1] == "Ann is 30" {
+		t.Errorf("expected a retry with the validation error, got %q", prompts)
+	}
+
+	answers = []string{`{"name": "Ann"}`}
+	_, err = GenerateAsWithOptions[person](context.Background(), llm, "", "Ann", StructuredOptions{})
+	var outputErr *StructuredOutputError
+	if !errors.As(err, &outputErr) {
+		t.Fatalf("expected StructuredOutputError without retries, got %v", err)
+	}
+}
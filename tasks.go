@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Summarize summarizes text in at most maxWords words, 0 for no limit.
+// The prompt gets Text and MaxWords, see PromptSummarize.
+func Summarize(ctx context.Context, llm LLM, text string, maxWords int) (string, error) {
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptSummarize, struct {
+		Text     string
+		MaxWords int
+	}{text, maxWords})
+	if err != nil {
+		return "", err
+	}
+	res, err := llm.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res), nil
+}
+
+// Extract fills v, a pointer to a struct, with data extracted from text. The
+// schema is derived from v (see SchemaFrom) and the answer is validated
+// against it. The prompt gets Text and Schema (as JSON), see PromptExtract.
+func Extract(ctx context.Context, llm LLM, text string, v any) error {
+	schema, err := SchemaFrom(v)
+	if err != nil {
+		return err
+	}
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptExtract, struct {
+		Text   string
+		Schema string
+	}{text, string(schemaJSON)})
+	if err != nil {
+		return err
+	}
+	res, err := llm.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return err
+	}
+	data := []byte(trimCodeFence(res))
+	if err := schema.ValidateJSON(data); err != nil {
+		return fmt.Errorf("invalid extraction: %v", err)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Classify returns the label of labels that best fits text. The answer is
+// matched case-insensitively, an error is returned if it is not a label.
+// The prompt gets Text and Labels, see PromptClassify.
+func Classify(ctx context.Context, llm LLM, text string, labels []string) (string, error) {
+	if len(labels) == 0 {
+		return "", fmt.Errorf("no labels")
+	}
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptClassify, struct {
+		Text   string
+		Labels []string
+	}{text, labels})
+	if err != nil {
+		return "", err
+	}
+	res, err := llm.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return "", err
+	}
+	answer := strings.Trim(strings.TrimSpace(res), "\"'`.")
+	for _, label := range labels {
+		if strings.EqualFold(answer, label) {
+			return label, nil
+		}
+	}
+	return "", fmt.Errorf("model answered with unknown label %q", answer)
+}
+
+// Rewrite rewrites text following instructions, e.g. "make it formal".
+// The prompt gets Text and Instructions, see PromptRewrite.
+func Rewrite(ctx context.Context, llm LLM, text, instructions string) (string, error) {
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptRewrite, struct {
+		Text         string
+		Instructions string
+	}{text, instructions})
+	if err != nil {
+		return "", err
+	}
+	res, err := llm.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(res), nil
+}
+
+// Verdict is the result of Judge
+type Verdict struct {
+	Pass bool
+	// Reason is the answer of the judge, starting with PASS or FAIL
+	Reason string
+}
+
+// Judge asks llm whether output, the response to input, satisfies criteria.
+// The prompt gets Criteria, Input and Output and must make the model answer
+// with PASS or FAIL on the first line, see PromptJudge.
+func Judge(ctx context.Context, llm LLM, criteria, input, output string) (*Verdict, error) {
+	systemPrompt, prompt, err := renderPrompt(ctx, PromptJudge, struct {
+		Criteria string
+		Input    string
+		Output   string
+	}{criteria, input, output})
+	if err != nil {
+		return nil, err
+	}
+	res, err := llm.Generate(ctx, systemPrompt, prompt)
+	if err != nil {
+		return nil, err
+	}
+	res = strings.TrimSpace(res)
+	return &Verdict{
+		Pass:   strings.HasPrefix(strings.ToUpper(res), "PASS"),
+		Reason: res,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinPrompts(t *testing.T) {
+	for _, name := range []string{PromptSummarize, PromptExtract, PromptClassify, PromptRewrite, PromptJudge, PromptDescribeImage} {
+		p := Prompt(name)
+		if p == nil || p.Version < 1 || PromptVersion(name, 1) == nil {
+			t.Fatalf("missing built-in prompt %s", name)
+		}
+	}
+	if Prompt("unknown") != nil {
+		t.Error("expected nil for unknown prompt")
+	}
+
+	systemPrompt, prompt, err := Prompt(PromptClassify).Render(struct {
+		Text   string
+		Labels []string
+	}{"I love it", []string{"positive", "negative"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(systemPrompt, "one of the given labels") || !strings.Contains(prompt, "- negative\n") || !strings.HasSuffix(prompt, "I love it") {
+		t.Errorf("unexpected rendering %q %q", systemPrompt, prompt)
+	}
+}
+
+func TestSetPrompt(t *testing.T) {
+	if _, err := ParsePrompt("custom", 1, `{{define "system"}}x{{end}}`); err == nil {
+		t.Error("expected error for prompt without user template")
+	}
+
+	custom, err := ParsePrompt(PromptSummarize, 2, `{{define "system"}}Be brief.{{end}}{{define "user"}}TL;DR: {{.Text}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPrompt(PromptSummarize, custom)
+	defer SetPrompt(PromptSummarize, nil)
+
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		return systemPrompt + "|" + prompt, nil
+	}}
+	res, err := Summarize(context.Background(), llm, "long text", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "Be brief.|TL;DR: long text" {
+		t.Errorf("override not used: %q", res)
+	}
+
+	SetPrompt(PromptSummarize, nil)
+	res, _ = Summarize(context.Background(), llm, "long text", 10)
+	if !strings.Contains(res, "at most 10 words") {
+		t.Errorf("built-in prompt not restored: %q", res)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	ctx := context.Background()
+	answer := ""
+	llm := &stubLLM{model: "stub", response: func(systemPrompt, prompt string) (string, error) {
+		return answer, nil
+	}}
+
+	answer = " Positive.\n"
+	label, err := Classify(ctx, llm, "I love it", []string{"positive", "negative"})
+	if err != nil || label != "positive" {
+		t.Errorf("unexpected label %q, %v", label, err)
+	}
+	answer = "neutral"
+	if _, err := Classify(ctx, llm, "ok", []string{"positive", "negative"}); err == nil {
+		t.Error("expected error for unknown label")
+	}
+
+	var person struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	answer = "```json\n{\"name\": \"Ann\", \"age\": 30}\n```"
+	if err := Extract(ctx, llm, "Ann is 30", &person); err != nil || person.Name != "Ann" || person.Age != 30 {
+		t.Errorf("unexpected extraction %+v, %v", person, err)
+	}
+	answer = `{"name": "Ann"}`
+	if err := Extract(ctx, llm, "Ann", &person); err == nil {
+		t.Error("expected error for missing required field")
+	}
+
+	answer = "FAIL: rude"
+	verdict, err := Judge(ctx, llm, "must be polite", "hi", "go away")
+	if err != nil || verdict.Pass || verdict.Reason != "FAIL: rude" {
+		t.Errorf("unexpected verdict %+v, %v", verdict, err)
+	}
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context that attributes requests made with it to
+// tenant, for limits resolved by a PolicyResolver
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or ""
+func TenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// TenantPolicy holds the limits of a tenant, zero values disable a limit
+type TenantPolicy struct {
+	// TokensPerMinute is enforced by ThrottleLLM
+	TokensPerMinute int
+	// TokenBudget is the number of tokens a tenant may use per BudgetPeriod,
+	// enforced by BudgetLLM
+	TokenBudget int
+	// BudgetPeriod is how often the budget resets, 0 for never
+	BudgetPeriod time.Duration
+}
+
+// PolicyResolver looks up the limits of a tenant, e.g. by its plan. It is
+// called for every request, so it should be cheap or cache. A nil policy
+// means no limits.
+type PolicyResolver interface {
+	ResolvePolicy(ctx context.Context, tenant string) (*TenantPolicy, error)
+}
+
+// PolicyResolverFunc adapts a function to a PolicyResolver
+type PolicyResolverFunc func(ctx context.Context, tenant string) (*TenantPolicy, error)
+
+func (f PolicyResolverFunc) ResolvePolicy(ctx context.Context, tenant string) (*TenantPolicy, error) {
+	return f(ctx, tenant)
+}
+
+// StaticPolicies resolves policies from a map by tenant, tenants missing from
+// the map get the policy of "" if present
+type StaticPolicies map[string]*TenantPolicy
+
+func (p StaticPolicies) ResolvePolicy(ctx context.Context, tenant string) (*TenantPolicy, error) {
+	if policy, ok := p[tenant]; ok {
+		return policy, nil
+	}
+	return p[""], nil
+}
+
+// BudgetExceededError is returned when a tenant has used up its token budget
+type BudgetExceededError struct {
+	Tenant string
+	Budget int
+	// ResetAt is when the budget resets, zero if it never does
+	ResetAt time.Time
+}
+
+func (e *BudgetExceededError) Error() string {
+	msg := fmt.Sprintf("token budget of %d exceeded for tenant %q", e.Budget, e.Tenant)
+	if !e.ResetAt.IsZero() {
+		msg += fmt.Sprintf(", resets at %s", e.ResetAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// BudgetLLM enforces per-tenant token budgets resolved by a PolicyResolver.
+// Prompts and responses are counted with the model's tokenizer, see
+// TokenizerFor. A request is rejected if its prompt does not fit in the
+// remaining budget, the response may overshoot it.
+type BudgetLLM struct {
+	LLM
+	resolver PolicyResolver
+
+	mu    sync.Mutex
+	spent map[string]*budgetPeriod
+}
+
+type budgetPeriod struct {
+	start  time.Time
+	tokens int
+}
+
+// NewBudgetLLM creates a BudgetLLM
+func NewBudgetLLM(llm LLM, resolver PolicyResolver) *BudgetLLM {
+	return &BudgetLLM{LLM: llm, resolver: resolver, spent: make(map[string]*budgetPeriod)}
+}
+
+// Spent returns the tokens the tenant used in the current period
+func (b *BudgetLLM) Spent(tenant string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.spent[tenant]; ok {
+		return p.tokens
+	}
+	return 0
+}
+
+// period returns the current budget period of tenant, the caller must hold
+// the lock
+func (b *BudgetLLM) period(tenant string, length time.Duration) *budgetPeriod {
+	now := time.Now()
+	p, ok := b.spent[tenant]
+	if !ok || (length > 0 && now.Sub(p.start) >= length) {
+		p = &budgetPeriod{start: now}
+		b.spent[tenant] = p
+	}
+	return p
+}
+
+func (b *BudgetLLM) do(ctx context.Context, input string, fn func() (string, error)) (string, error) {
+	tenant := TenantFromContext(ctx)
+	policy, err := b.resolver.ResolvePolicy(ctx, tenant)
+	if err != nil {
+		return "", err
+	}
+	if policy == nil || policy.TokenBudget <= 0 {
+		return fn()
+	}
+
+	tokens := CountTokens(b.LLM.GetModel(), input)
+	b.mu.Lock()
+	p := b.period(tenant, policy.BudgetPeriod)
+	if p.tokens+tokens > policy.TokenBudget {
+		b.mu.Unlock()
+		err := &BudgetExceededError{Tenant: tenant, Budget: policy.TokenBudget}
+		if policy.BudgetPeriod > 0 {
+			err.ResetAt = p.start.Add(policy.BudgetPeriod)
+		}
+		return "", err
+	}
+	p.tokens += tokens
+	b.mu.Unlock()
+
+	res, err := fn()
+	b.mu.Lock()
+	p.tokens += CountTokens(b.LLM.GetModel(), res)
+	b.mu.Unlock()
+	return res, err
+}
+
+func (b *BudgetLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return b.do(ctx, systemPrompt+prompt, func() (string, error) {
+		return b.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (b *BudgetLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	_, err := b.do(ctx, systemPrompt+prompt, func() (string, error) {
+		var out string
+		err := consumeStream(ctx, b.LLM, systemPrompt, prompt, func(chunk string) error {
+			out += chunk
+			select {
+			case resultCh <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		return out, err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (b *BudgetLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	return b.do(ctx, prompt, func() (string, error) {
+		return b.LLM.GenerateWithImage(ctx, prompt, image, mimeType)
+	})
+}
+
+func (b *BudgetLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	return b.do(ctx, prompt, func() (string, error) {
+		return b.LLM.GenerateWithImages(ctx, prompt, images, mimeTypes)
+	})
+}
+
+func (b *BudgetLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	var input string
+	for _, msg := range messages {
+		input += msg.text()
+	}
+	return b.do(ctx, input, func() (string, error) {
+		return b.LLM.GenerateWithMessages(ctx, messages)
+	})
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTenantPolicies(t *testing.T) {
+	stub := &stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		return "ok", nil
+	}}
+	policies := StaticPolicies{
+		"free": {TokensPerMinute: 8, TokenBudget: 12, BudgetPeriod: time.Hour},
+		"":     {},
+	}
+	free := WithTenant(context.Background(), "free")
+	pro := WithTenant(context.Background(), "pro")
+
+	throttled := NewThrottleLLM(stub, 10, 0)
+	throttled.SetPolicyResolver(policies)
+	if _, err := throttled.Generate(free, "", strings.Repeat("a", 32)); err != nil {
+		t.Fatal(err)
+	}
+	_, err := throttled.Generate(WithMaxQueueWait(free, 20*time.Millisecond), "", "hi")
+	if !errors.Is(err, ErrThrottleTimeout) {
+		t.Fatalf("expected tenant to be throttled, got %v", err)
+	}
+	if _, err := throttled.Generate(WithMaxQueueWait(pro, 20*time.Millisecond), "", "hi"); err != nil {
+		t.Fatalf("other tenants should not be throttled: %v", err)
+	}
+
+	budget := NewBudgetLLM(stub, policies)
+	for i := 0; i < 2; i++ {
+		if _, err := budget.Generate(free, "", strings.Repeat("a", 16)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spent := budget.Spent("free"); spent != 10 {
+		t.Fatalf("unexpected spent tokens %d", spent)
+	}
+	_, err = budget.Generate(free, "", strings.Repeat("a", 16))
+	var budgetErr *BudgetExceededError
+	if !errors.As(err, &budgetErr) || budgetErr.Tenant != "free" || budgetErr.ResetAt.IsZero() {
+		t.Fatalf("expected budget error, got %v", err)
+	}
+	if _, err := budget.Generate(pro, "", strings.Repeat("a", 100)); err != nil {
+		t.Fatalf("tenants without budget should not be limited: %v", err)
+	}
+
+	// The text of content parts is counted
+	budget = NewBudgetLLM(stub, policies)
+	if _, err := budget.GenerateWithMessages(free, Chat{}.UserParts(TextPart(strings.Repeat("a", 16))).Messages()); err != nil {
+		t.Fatal(err)
+	}
+	if spent := budget.Spent("free"); spent != 5 {
+		t.Fatalf("unexpected spent tokens %d for content parts", spent)
+	}
+}
@@ -0,0 +1,532 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go"
+	goopenai "github.com/sashabaranov/go-openai"
+)
+
+var (
+	// ErrThrottleQueueFull is returned when a request would be queued but the
+	// queue is at capacity
+	ErrThrottleQueueFull = errors.New("throttle queue is full")
+	// ErrThrottleTimeout is returned when a request waited longer than its max wait
+	ErrThrottleTimeout = errors.New("throttle max wait exceeded")
+)
+
+// throttleDefaultPause is how long requests are held after a rate limit error
+// that does not say when to retry
+const throttleDefaultPause = 2 * time.Second
+
+// ThrottleStats reports the state of a ThrottleLLM queue
+type ThrottleStats struct {
+	Queued    int // requests waiting now
+	MaxQueued int // highest queue depth seen
+	Throttled int // rate limit errors received
+	Rejected  int // requests rejected because the queue was full
+	TimedOut  int // requests that gave up after their max wait
+}
+
+// ThrottleLLM parks rate limited requests in a bounded FIFO queue and releases
+// them as quota recovers, based on Retry-After and an optional tokens per
+// minute budget, instead of failing fast. Once a request is rate limited, new
+// requests queue up behind it until the provider is ready again.
+type ThrottleLLM struct {
+	LLM
+	maxQueue int
+	maxWait  time.Duration
+	tpm      int
+
+	mu          sync.Mutex
+	queue       []*throttleWaiter
+	pausedUntil time.Time
+	window      tokenWindow
+	stats       ThrottleStats
+
+	resolver PolicyResolver
+	tenants  map[string]*tokenWindow
+
+	metrics     Metrics
+	metricsName string
+}
+
+type throttleWaiter struct {
+	wake chan struct{}
+}
+
+type throttleUsage struct {
+	at     time.Time
+	tokens int
+}
+
+// tokenWindow tracks token usage over the last minute
+type tokenWindow struct {
+	usage []*throttleUsage
+}
+
+// wait returns how long a request of the given size has to wait until enough
+// of the usage leaves the window to stay within tpm
+func (w *tokenWindow) wait(now time.Time, tokens, tpm int) time.Duration {
+	var used int
+	recent := w.usage[:0]
+	for _, u := range w.usage {
+		if now.Sub(u.at) < time.Minute {
+			recent = append(recent, u)
+			used += u.tokens
+		}
+	}
+	w.usage = recent
+
+	var d time.Duration
+	excess := used + tokens - tpm
+	for _, u := range w.usage {
+		if excess <= 0 {
+			break
+		}
+		excess -= u.tokens
+		if wait := u.at.Add(time.Minute).Sub(now); wait > d {
+			d = wait
+		}
+	}
+	return d
+}
+
+func (w *tokenWindow) add(tokens int) *throttleUsage {
+	u := &throttleUsage{at: time.Now(), tokens: tokens}
+	w.usage = append(w.usage, u)
+	return u
+}
+
+// NewThrottleLLM creates a ThrottleLLM holding up to maxQueue requests, each
+// waiting at most maxWait (0 for no limit besides the context)
+func NewThrottleLLM(llm LLM, maxQueue int, maxWait time.Duration) *ThrottleLLM {
+	return &ThrottleLLM{LLM: llm, maxQueue: maxQueue, maxWait: maxWait}
+}
+
+// SetTokensPerMinute sets the tokens per minute budget, 0 to disable. Prompts
+// and responses are counted with the model's tokenizer, see TokenizerFor.
+func (t *ThrottleLLM) SetTokensPerMinute(tpm int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.tpm = tpm
+}
+
+// SetPolicyResolver enables per-tenant tokens per minute limits, resolved for
+// the tenant of each request (see WithTenant). A tenant over its limit waits
+// before entering the queue, so it does not hold up other tenants.
+func (t *ThrottleLLM) SetPolicyResolver(resolver PolicyResolver) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resolver = resolver
+}
+
+// Stats returns the queue metrics
+func (t *ThrottleLLM) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	stats := t.stats
+	stats.Queued = len(t.queue)
+	return stats
+}
+
+// SetMetrics reports the queue depth and queue events to metrics, labeled
+// with name
+func (t *ThrottleLLM) SetMetrics(metrics Metrics, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.metrics, t.metricsName = metrics, name
+}
+
+// queueChanged reports the queue depth, the caller must hold the lock
+func (t *ThrottleLLM) queueChanged() {
+	if t.metrics != nil {
+		t.metrics.SetGauge(MetricQueueDepth, float64(len(t.queue)), map[string]string{"queue": t.metricsName})
+	}
+}
+
+// queueEvent reports a queue event, the caller must hold the lock
+func (t *ThrottleLLM) queueEvent(event string) {
+	if t.metrics != nil {
+		t.metrics.AddCounter(MetricQueueEvents, 1, map[string]string{"queue": t.metricsName, "event": event})
+	}
+}
+
+type maxQueueWaitKey struct{}
+
+// WithMaxQueueWait returns a context that overrides the max wait of
+// ThrottleLLM for requests made with it
+func WithMaxQueueWait(ctx context.Context, maxWait time.Duration) context.Context {
+	return context.WithValue(ctx, maxQueueWaitKey{}, maxWait)
+}
+
+// rateLimited reports whether err is a rate limit error and how long the
+// provider asked to wait (0 if it did not say)
+func rateLimited(err error) (bool, time.Duration) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true, retryAfter(httpErr.Header)
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) && openaiErr.StatusCode == http.StatusTooManyRequests {
+		if openaiErr.Response != nil {
+			return true, retryAfter(openaiErr.Response.Header)
+		}
+		return true, 0
+	}
+	var goopenaiErr *goopenai.APIError
+	if errors.As(err, &goopenaiErr) && goopenaiErr.HTTPStatusCode == http.StatusTooManyRequests {
+		return true, 0
+	}
+	var anthropicErr *AnthropicError
+	if errors.As(err, &anthropicErr) && anthropicErr.Type == "rate_limit_error" {
+		return true, 0
+	}
+	var miniMaxErr *MiniMaxError
+	if errors.As(err, &miniMaxErr) && miniMaxErr.Code == 1002 {
+		return true, 0
+	}
+	return false, 0
+}
+
+// retryAfter parses the Retry-After header (seconds or HTTP date) and the
+// retry-after-ms header used by OpenAI
+func retryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// delay returns how long a request of the given size has to wait, the caller
+// must hold the lock
+func (t *ThrottleLLM) delay(tokens int) time.Duration {
+	now := time.Now()
+	d := t.pausedUntil.Sub(now)
+	if t.tpm <= 0 {
+		return d
+	}
+	if wait := t.window.wait(now, tokens, t.tpm); wait > d {
+		d = wait
+	}
+	return d
+}
+
+// wakeHead wakes the first waiter, the caller must hold the lock
+func (t *ThrottleLLM) wakeHead() {
+	if len(t.queue) == 0 {
+		return
+	}
+	select {
+	case t.queue[0].wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *ThrottleLLM) remove(w *throttleWaiter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, queued := range t.queue {
+		if queued == w {
+			t.queue = append(t.queue[:i], t.queue[i+1:]...)
+			t.queueChanged()
+			if i == 0 {
+				t.wakeHead()
+			}
+			return
+		}
+	}
+}
+
+// reserve adds tokens to the budget usage, the caller must hold the lock
+func (t *ThrottleLLM) reserve(tokens int) *throttleUsage {
+	if t.tpm > 0 {
+		return t.window.add(tokens)
+	}
+	return &throttleUsage{at: time.Now(), tokens: tokens}
+}
+
+// tenantWindow resolves the tokens per minute limit of the request's tenant,
+// it returns a nil window if there is none
+func (t *ThrottleLLM) tenantWindow(ctx context.Context) (*tokenWindow, int, error) {
+	t.mu.Lock()
+	resolver := t.resolver
+	t.mu.Unlock()
+	if resolver == nil {
+		return nil, 0, nil
+	}
+	tenant := TenantFromContext(ctx)
+	policy, err := resolver.ResolvePolicy(ctx, tenant)
+	if err != nil || policy == nil || policy.TokensPerMinute <= 0 {
+		return nil, 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tenants == nil {
+		t.tenants = make(map[string]*tokenWindow)
+	}
+	w, ok := t.tenants[tenant]
+	if !ok {
+		w = &tokenWindow{}
+		t.tenants[tenant] = w
+	}
+	return w, policy.TokensPerMinute, nil
+}
+
+// acquireTenant waits until the tenant window has room for the request and
+// reserves its tokens
+func (t *ThrottleLLM) acquireTenant(ctx context.Context, w *tokenWindow, tpm, tokens int, deadline <-chan time.Time) (*throttleUsage, error) {
+	for {
+		t.mu.Lock()
+		d := w.wait(time.Now(), tokens, tpm)
+		if d <= 0 {
+			defer t.mu.Unlock()
+			return w.add(tokens), nil
+		}
+		t.mu.Unlock()
+
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-deadline:
+			timer.Stop()
+			t.mu.Lock()
+			t.stats.TimedOut++
+			t.queueEvent("timeout")
+			t.mu.Unlock()
+			return nil, ErrThrottleTimeout
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// acquire waits for the turn of a request and reserves its tokens. Requests
+// that were rate limited go to the front of the queue.
+func (t *ThrottleLLM) acquire(ctx context.Context, tokens int, deadline <-chan time.Time, front bool) (*throttleUsage, error) {
+	t.mu.Lock()
+	if len(t.queue) == 0 && t.delay(tokens) <= 0 {
+		defer t.mu.Unlock()
+		return t.reserve(tokens), nil
+	}
+	if len(t.queue) >= t.maxQueue {
+		t.stats.Rejected++
+		t.queueEvent("rejected")
+		t.mu.Unlock()
+		return nil, ErrThrottleQueueFull
+	}
+	w := &throttleWaiter{wake: make(chan struct{}, 1)}
+	if front {
+		t.queue = append([]*throttleWaiter{w}, t.queue...)
+	} else {
+		t.queue = append(t.queue, w)
+	}
+	if len(t.queue) > t.stats.MaxQueued {
+		t.stats.MaxQueued = len(t.queue)
+	}
+	t.queueChanged()
+	t.mu.Unlock()
+
+	for {
+		var ready <-chan time.Time
+		t.mu.Lock()
+		if t.queue[0] == w {
+			d := t.delay(tokens)
+			if d <= 0 {
+				t.queue = t.queue[1:]
+				t.queueChanged()
+				t.wakeHead()
+				defer t.mu.Unlock()
+				return t.reserve(tokens), nil
+			}
+			ready = time.After(d)
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-w.wake:
+		case <-ready:
+		case <-deadline:
+			t.remove(w)
+			t.mu.Lock()
+			t.stats.TimedOut++
+			t.queueEvent("timeout")
+			t.mu.Unlock()
+			return nil, ErrThrottleTimeout
+		case <-ctx.Done():
+			t.remove(w)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pause holds new requests after a rate limit error
+func (t *ThrottleLLM) pause(wait time.Duration) {
+	if wait <= 0 {
+		wait = throttleDefaultPause
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Throttled++
+	t.queueEvent("throttled")
+	if until := time.Now().Add(wait); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// do runs fn when the request's turn comes, queueing it again while it is
+// rate limited
+func (t *ThrottleLLM) do(ctx context.Context, input string, fn func() (string, error)) (string, error) {
+	maxWait := t.maxWait
+	if d, ok := ctx.Value(maxQueueWaitKey{}).(time.Duration); ok {
+		maxWait = d
+	}
+	var deadline <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	tenant, tenantTPM, err := t.tenantWindow(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tokens := CountTokens(t.LLM.GetModel(), input)
+	var lastErr error
+	for requeued := false; ; requeued = true {
+		var tenantUsage *throttleUsage
+		if tenant != nil {
+			if tenantUsage, err = t.acquireTenant(ctx, tenant, tenantTPM, tokens, deadline); err != nil {
+				if lastErr != nil && err == ErrThrottleTimeout {
+					return "", fmt.Errorf("%w: %w", err, lastErr)
+				}
+				return "", err
+			}
+		}
+		usage, err := t.acquire(ctx, tokens, deadline, requeued)
+		if err != nil {
+			if tenantUsage != nil {
+				t.mu.Lock()
+				tenantUsage.tokens = 0
+				t.mu.Unlock()
+			}
+			if lastErr != nil && (err == ErrThrottleTimeout || err == ErrThrottleQueueFull) {
+				return "", fmt.Errorf("%w: %w", err, lastErr)
+			}
+			return "", err
+		}
+
+		res, err := fn()
+		limited, wait := rateLimited(err)
+		t.mu.Lock()
+		if limited {
+			// the provider rejected the request, so it did not use the budget
+			usage.tokens = 0
+		} else if err == nil {
+			usage.tokens += CountTokens(t.LLM.GetModel(), res)
+		}
+		if tenantUsage != nil {
+			tenantUsage.tokens = usage.tokens
+		}
+		t.mu.Unlock()
+		if !limited {
+			return res, err
+		}
+		t.pause(wait)
+		lastErr = err
+	}
+}
+
+func (t *ThrottleLLM) Generate(ctx context.Context, systemPrompt, prompt string) (string, error) {
+	return t.do(ctx, systemPrompt+prompt, func() (string, error) {
+		return t.LLM.Generate(ctx, systemPrompt, prompt)
+	})
+}
+
+func (t *ThrottleLLM) GenerateStream(ctx context.Context, systemPrompt, prompt string, resultCh chan string, doneCh chan bool, errCh chan error) {
+	_, err := t.do(ctx, systemPrompt+prompt, func() (string, error) {
+		var out string
+		var sent bool
+		err := consumeStream(ctx, t.LLM, systemPrompt, prompt, func(chunk string) error {
+			sent = true
+			out += chunk
+			select {
+			case resultCh <- chunk:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if limited, _ := rateLimited(err); limited && sent {
+			// output was already sent, so the request can't be queued again
+			return out, fmt.Errorf("rate limited mid-stream: %v", err)
+		}
+		return out, err
+	})
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case doneCh <- true:
+	case <-ctx.Done():
+	}
+}
+
+func (t *ThrottleLLM) GenerateWithImage(ctx context.Context, prompt string, image io.Reader, mimeType MimeType) (string, error) {
+	imageBufs, err := bufferImages([]io.Reader{image})
+	if err != nil {
+		return "", err
+	}
+	return t.do(ctx, prompt, func() (string, error) {
+		return t.LLM.GenerateWithImage(ctx, prompt, newReadersFromBuffers(imageBufs)[0], mimeType)
+	})
+}
+
+func (t *ThrottleLLM) GenerateWithImages(ctx context.Context, prompt string, images []io.Reader, mimeTypes []MimeType) (string, error) {
+	imageBufs, err := bufferImages(images)
+	if err != nil {
+		return "", err
+	}
+	return t.do(ctx, prompt, func() (string, error) {
+		return t.LLM.GenerateWithImages(ctx, prompt, newReadersFromBuffers(imageBufs), mimeTypes)
+	})
+}
+
+func (t *ThrottleLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	replay, err := bufferMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	var input string
+	for _, msg := range messages {
+		input += msg.text()
+	}
+	return t.do(ctx, input, func() (string, error) {
+		return t.LLM.GenerateWithMessages(ctx, replay())
+	})
+}
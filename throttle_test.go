@@ -0,0 +1,75 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleLLM(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	stub := &stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			return "", &HTTPError{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0.05"}}}
+		}
+		return "ok " + prompt, nil
+	}}
+	llm := NewThrottleLLM(stub, 2, time.Second)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	errs := make([]error, 3)
+	for i, prompt := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(i int, prompt string) {
+			defer wg.Done()
+			results[i], errs[i] = llm.Generate(context.Background(), "", prompt)
+		}(i, prompt)
+		time.Sleep(10 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if errs[0] != nil || results[0] != "ok a" || errs[1] != nil || results[1] != "ok b" {
+		t.Fatalf("unexpected results %q, %v", results, errs)
+	}
+	if !errors.Is(errs[2], ErrThrottleQueueFull) {
+		t.Fatalf("expected full queue, got %v", errs[2])
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatalf("Retry-After was not respected")
+	}
+	stats := llm.Stats()
+	if stats.Throttled != 1 || stats.Rejected != 1 || stats.MaxQueued != 2 || stats.Queued != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	limited := NewThrottleLLM(&stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		return "", &AnthropicError{Type: "rate_limit_error", Message: "slow down"}
+	}}, 10, time.Minute)
+	_, err := limited.Generate(WithMaxQueueWait(context.Background(), 20*time.Millisecond), "", "hi")
+	if !errors.Is(err, ErrThrottleTimeout) || !errors.As(err, new(*AnthropicError)) {
+		t.Fatalf("expected max wait error, got %v", err)
+	}
+}
+
+func TestThrottleTokensPerMinute(t *testing.T) {
+	llm := NewThrottleLLM(&stubLLM{model: "m", response: func(systemPrompt, prompt string) (string, error) {
+		return "", nil
+	}}, 10, 20*time.Millisecond)
+	llm.SetTokensPerMinute(10)
+
+	if _, err := llm.Generate(context.Background(), "", "0123456789012345678901234567890123456789"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := llm.Generate(context.Background(), "", "hi"); !errors.Is(err, ErrThrottleTimeout) {
+		t.Fatalf("expected the budget to be used up, got %v", err)
+	}
+}
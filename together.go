@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"github.com/openai/openai-go/option"
+)
+
+// TogetherParams are Together AI specific request parameters
+type TogetherParams struct {
+	// RepetitionPenalty penalizes repeated tokens, 1.0 means no penalty (optional)
+	RepetitionPenalty *float64
+	// SafetyModel moderates the output with a Together hosted safety model,
+	// e.g. "meta-llama/Meta-Llama-Guard-3-8B" (optional)
+	SafetyModel string
+	// TopK limits sampling to the k most likely tokens (optional)
+	TopK *int
+	// MinP is the minimum token probability relative to the most likely one (optional)
+	MinP *float64
+}
+
+// https://docs.together.ai/reference/chat-completions-1
+func NewTogether(apiKey string, model string, maxTokens int64, temperature float64, isJson bool, params *TogetherParams) *OpenAI {
+	return NewOpenAICompatible("https://api.together.xyz/v1/", apiKey, model, maxTokens, temperature, isJson, togetherOptions(params)...)
+}
+
+// togetherOptions sets the extra body fields of params
+func togetherOptions(params *TogetherParams) []option.RequestOption {
+	var opts []option.RequestOption
+	if params != nil {
+		if params.RepetitionPenalty != nil {
+			opts = append(opts, option.WithJSONSet("repetition_penalty", *params.RepetitionPenalty))
+		}
+		if params.SafetyModel != "" {
+			opts = append(opts, option.WithJSONSet("safety_model", params.SafetyModel))
+		}
+		if params.TopK != nil {
+			opts = append(opts, option.WithJSONSet("top_k", *params.TopK))
+		}
+		if params.MinP != nil {
+			opts = append(opts, option.WithJSONSet("min_p", *params.MinP))
+		}
+	}
+	return opts
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTogetherParams(t *testing.T) {
+	var body map[string]interface{}
+	srv := newCaptureServer(t, &body)
+
+	penalty, topK, minP := 1.1, 40, 0.05
+	llm := NewOpenAICompatible(srv.URL+"/", "key", "meta-llama/Llama-3.3-70B-Instruct-Turbo", 100, 0.5, false,
+		togetherOptions(&TogetherParams{
+			RepetitionPenalty: &penalty,
+			SafetyModel:       "meta-llama/Meta-Llama-Guard-3-8B",
+			TopK:              &topK,
+			MinP:              &minP,
+		})...)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if body["repetition_penalty"] != 1.1 || body["safety_model"] != "meta-llama/Meta-Llama-Guard-3-8B" ||
+		body["top_k"] != float64(40) || body["min_p"] != 0.05 {
+		t.Fatalf("params not set: %v", body)
+	}
+
+	// Unset params are omitted
+	topK, body = 0, nil
+	llm = NewOpenAICompatible(srv.URL+"/", "key", "meta-llama/Llama-3.3-70B-Instruct-Turbo", 100, 0.5, false,
+		togetherOptions(&TogetherParams{TopK: &topK})...)
+	if _, err := llm.Generate(context.Background(), "", "hi"); err != nil {
+		t.Fatalf("Error generating: %v", err)
+	}
+	if body["top_k"] != float64(0) {
+		t.Fatalf("zero top_k should be sent when set: %v", body)
+	}
+	for _, key := range []string{"repetition_penalty", "safety_model", "min_p"} {
+		if _, ok := body[key]; ok {
+			t.Fatalf("unset param %s sent: %v", key, body)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Tokenizer counts the tokens of text for a model family
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer estimates tokens from the text length. CJK characters
+// count as one token each, other characters as 1/CharsPerToken.
+type HeuristicTokenizer struct {
+	CharsPerToken float64
+}
+
+func (h HeuristicTokenizer) CountTokens(text string) int {
+	var cjk, other int
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + int(math.Ceil(float64(other)/h.CharsPerToken))
+}
+
+// defaultTokenizer is used for models without a registered tokenizer
+var defaultTokenizer Tokenizer = HeuristicTokenizer{CharsPerToken: 4}
+
+var tokenizers = struct {
+	sync.RWMutex
+	byPrefix map[string]Tokenizer
+}{byPrefix: map[string]Tokenizer{
+	"gpt-4o":     HeuristicTokenizer{CharsPerToken: 4.2},
+	"gpt-4.1":    HeuristicTokenizer{CharsPerToken: 4.2},
+	"gpt-4":      HeuristicTokenizer{CharsPerToken: 4},
+	"gpt-3.5":    HeuristicTokenizer{CharsPerToken: 4},
+	"o1":         HeuristicTokenizer{CharsPerToken: 4.2},
+	"o3":         HeuristicTokenizer{CharsPerToken: 4.2},
+	"o4":         HeuristicTokenizer{CharsPerToken: 4.2},
+	"claude":     HeuristicTokenizer{CharsPerToken: 3.5},
+	"gemini":     HeuristicTokenizer{CharsPerToken: 4},
+	"gemma":      HeuristicTokenizer{CharsPerToken: 4},
+	"llama":      HeuristicTokenizer{CharsPerToken: 3.8},
+	"meta-llama": HeuristicTokenizer{CharsPerToken: 3.8},
+	"mistral":    HeuristicTokenizer{CharsPerToken: 3.6},
+	"mixtral":    HeuristicTokenizer{CharsPerToken: 3.6},
+	"qwen":       HeuristicTokenizer{CharsPerToken: 3.8},
+}}
+
+// RegisterTokenizer sets the tokenizer for models whose name starts with
+// prefix, e.g. a tiktoken encoding loaded with LoadTiktoken for "gpt-4o".
+// Only tiktoken encodings can be loaded, other model families such as the
+// SentencePiece based gemma and llama are estimated unless a Tokenizer
+// implementation is registered for them. The longest matching prefix wins,
+// a nil tokenizer removes the prefix.
+func RegisterTokenizer(prefix string, t Tokenizer) {
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+	if t == nil {
+		delete(tokenizers.byPrefix, strings.ToLower(prefix))
+		return
+	}
+	tokenizers.byPrefix[strings.ToLower(prefix)] = t
+}
+
+// TokenizerFor returns the tokenizer registered for model, or a heuristic.
+// Provider prefixes are ignored, e.g. "meta-llama/Llama-3.3-70B" matches
+// "llama" and "anthropic.claude-3-haiku" matches "claude".
+func TokenizerFor(model string) Tokenizer {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	names := []string{name}
+	if _, rest, ok := strings.Cut(name, "."); ok {
+		names = append(names, rest)
+	}
+
+	tokenizers.RLock()
+	defer tokenizers.RUnlock()
+	var best string
+	var found Tokenizer
+	for prefix, t := range tokenizers.byPrefix {
+		for _, n := range names {
+			if strings.HasPrefix(n, prefix) && len(prefix) > len(best) {
+				best, found = prefix, t
+			}
+		}
+	}
+	if found == nil {
+		return defaultTokenizer
+	}
+	return found
+}
+
+// CountTokens counts the tokens of text with the tokenizer of model
+func CountTokens(model, text string) int {
+	return TokenizerFor(model).CountTokens(text)
+}
+
+// TruncateTokens cuts text to at most maxTokens tokens of model, it returns ""
+// if maxTokens is not positive
+func TruncateTokens(model, text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	t := TokenizerFor(model)
+	if t.CountTokens(text) <= maxTokens {
+		return text
+	}
+	// the longest prefix that fits, in runes
+	runes := []rune(text)
+	n := sort.Search(len(runes)+1, func(i int) bool {
+		return t.CountTokens(string(runes[:i])) > maxTokens
+	})
+	return string(runes[:n-1])
+}
+
+// ModelPrice is the price of a model in dollars per million tokens
+type ModelPrice struct {
+	Input  float64
+	Output float64
+}
+
+// EstimateCost estimates the cost of a request in dollars from its input and
+// output text
+func EstimateCost(model, input, output string, price ModelPrice) float64 {
+	t := TokenizerFor(model)
+	return (float64(t.CountTokens(input))*price.Input + float64(t.CountTokens(output))*price.Output) / 1e6
+}
+
+// tiktokenSplit approximates the pre-tokenization of the cl100k and o200k
+// encodings. Go regexps have no lookahead, so runs of spaces before a word
+// are not split off, which may change counts slightly.
+var tiktokenSplit = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// bpeTokenizer is a byte pair encoding with tiktoken ranks
+type bpeTokenizer struct {
+	ranks map[string]int
+}
+
+// LoadTiktoken loads a tiktoken encoding from its rank file, e.g.
+// cl100k_base.tiktoken, with lines of a base64 token and its rank
+func LoadTiktoken(r io.Reader) (Tokenizer, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid tiktoken line %d", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on line %d: %v", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on line %d: %v", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &bpeTokenizer{ranks: ranks}, nil
+}
+
+func (b *bpeTokenizer) CountTokens(text string) int {
+	var n int
+	for _, piece := range tiktokenSplit.FindAllString(text, -1) {
+		if _, ok := b.ranks[piece]; ok {
+			n++
+			continue
+		}
+		n += b.countPiece(piece)
+	}
+	return n
+}
+
+// countPiece merges the bytes of piece by rank and returns the number of parts
+func (b *bpeTokenizer) countPiece(piece string) int {
+	parts := make([]string, 0, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts = append(parts, piece[i:i+1])
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+1 < len(parts); i++ {
+			if rank, ok := b.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts)
+}
@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTokenizerRegistry(t *testing.T) {
+	if got := CountTokens("unknown-model", "abcdefgh"); got != 2 {
+		t.Fatalf("unexpected heuristic count %d", got)
+	}
+	if got := CountTokens("m", "日本語"); got != 3 {
+		t.Fatalf("CJK characters should count as one token each, got %d", got)
+	}
+
+	// a tiny encoding where "hello" and " world" are single tokens
+	var ranks strings.Builder
+	for i, token := range []string{"h", "e", "l", "o", " ", "w", "r", "d", "he", "ll", "hell", "hello", " w", "or", " wor", " worl", "ld", " world"} {
+		fmt.Fprintf(&ranks, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), i)
+	}
+	bpe, err := LoadTiktoken(strings.NewReader(ranks.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterTokenizer("test-bpe", bpe)
+	defer RegisterTokenizer("test-bpe", nil)
+
+	if got := CountTokens("provider/test-bpe-large", "hello world"); got != 2 {
+		t.Fatalf("expected 2 tokens, got %d", got)
+	}
+	if got := CountTokens("test-bpe", "hello wd"); got != 3 {
+		t.Fatalf("expected 3 tokens, got %d", got)
+	}
+	if got := TruncateTokens("test-bpe", "hello world hello", 2); got != "hello world" {
+		t.Fatalf("unexpected truncation %q", got)
+	}
+	if got := TruncateTokens("test-bpe", "hello", -1); got != "" {
+		t.Fatalf("expected nothing to fit in a negative limit, got %q", got)
+	}
+	if got := EstimateCost("test-bpe", "hello", "hello world", ModelPrice{Input: 1, Output: 2}); math.Abs(got-5e-6) > 1e-12 {
+		t.Fatalf("unexpected cost %v", got)
+	}
+
+	RegisterTokenizer("test-bpe", nil)
+	if TokenizerFor("test-bpe") != defaultTokenizer {
+		t.Error("expected the tokenizer to be removed")
+	}
+}
@@ -0,0 +1,255 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Tool is a function the model can call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema
+
+	// Handler executes the tool with the JSON arguments provided by the model (optional)
+	Handler func(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+// ToolCall is a tool invocation requested by the model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// ToolCaller is implemented by clients that support tool (function) calling
+type ToolCaller interface {
+	GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*Response, error)
+}
+
+// ToolResult is the outcome of a tool call, to be sent back to the model
+type ToolResult struct {
+	CallID  string
+	Name    string
+	Content string
+	IsError bool
+}
+
+// Message returns the RoleTool message sending the result back to the model
+func (r ToolResult) Message() Message {
+	return Message{Role: RoleTool, Content: r.Content, ToolCallID: r.CallID, ToolName: r.Name, IsError: r.IsError}
+}
+
+// ToolResultMessages returns the messages of results, see ToolResult.Message
+func ToolResultMessages(results []ToolResult) []Message {
+	messages := make([]Message, len(results))
+	for i, res := range results {
+		messages[i] = res.Message()
+	}
+	return messages
+}
+
+// Call executes the tool. Invalid arguments and execution errors are
+// returned as error results, so the model can see them and recover.
+func (t Tool) Call(ctx context.Context, call ToolCall) ToolResult {
+	res := ToolResult{CallID: call.ID, Name: t.Name}
+	if t.Handler == nil {
+		res.Content = fmt.Sprintf("tool %s has no handler", t.Name)
+		res.IsError = true
+		return res
+	}
+	content, err := t.Handler(ctx, call.Arguments)
+	if err != nil {
+		res.Content = err.Error()
+		res.IsError = true
+		return res
+	}
+	res.Content = content
+	return res
+}
+
+// ToolFromFunc creates a tool from a typed Go function. The parameter schema is
+// derived from the args struct (see SchemaFrom); model-provided arguments are
+// validated and unmarshaled into it, and the result is marshaled to JSON
+// (strings are returned as is). It panics if no schema can be derived from A.
+//
+//	tool := ai.ToolFromFunc("get_weather", "Get current weather", getWeather)
+func ToolFromFunc[A any, R any](name, description string, fn func(ctx context.Context, args A) (R, error)) Tool {
+	schema, err := SchemaFrom(reflect.TypeOf((*A)(nil)).Elem())
+	if err != nil {
+		panic(fmt.Sprintf("ai: tool %s: %v", name, err))
+	}
+	if schema.Type() != "object" {
+		panic(fmt.Sprintf("ai: tool %s: arguments must be a struct, got %s", name, schema.Type()))
+	}
+
+	return Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  schema,
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			args, err := decodeArguments[A](schema, arguments)
+			if err != nil {
+				return "", err
+			}
+			result, err := fn(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			if s, ok := any(result).(string); ok {
+				return s, nil
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to marshal result: %v", err)
+			}
+			return string(data), nil
+		},
+	}
+}
+
+// DecodeArguments validates the arguments of call against the parameters of
+// tool and unmarshals them into an A, for calls the caller executes itself:
+//
+//	args, err := ai.DecodeArguments[WeatherArgs](tool, res.ToolCalls[0])
+func DecodeArguments[A any](tool Tool, call ToolCall) (A, error) {
+	return decodeArguments[A](tool.Parameters, call.Arguments)
+}
+
+func decodeArguments[A any](schema *Schema, arguments json.RawMessage) (A, error) {
+	var args A
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if schema != nil {
+		if err := schema.ValidateJSON(arguments); err != nil {
+			return args, fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return args, fmt.Errorf("invalid arguments: %v", err)
+	}
+	return args, nil
+}
+
+// ToolExecutor runs the tool calls requested by the model in one turn
+type ToolExecutor struct {
+	Tools []Tool
+	// MaxConcurrency limits the number of calls running at once, 0 means no limit
+	MaxConcurrency int
+	// Timeout is the default per call timeout, 0 means no timeout
+	Timeout time.Duration
+	// Timeouts overrides Timeout for specific tools by name
+	Timeouts map[string]time.Duration
+	// Trace is called after each call completes (optional, may be called concurrently)
+	Trace func(ToolTrace)
+	// Policy restricts the calls that may run (optional), see WithToolPolicy
+	// for per session policies
+	Policy *ToolPolicy
+}
+
+// ToolTrace describes a single executed tool call
+type ToolTrace struct {
+	Call     ToolCall
+	Result   ToolResult
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Execute runs calls concurrently and returns results in the order of calls,
+// which is the order providers expect tool results to be sent back
+func (e *ToolExecutor) Execute(ctx context.Context, calls []ToolCall) []ToolResult {
+	results := make([]ToolResult, len(calls))
+	limit := e.MaxConcurrency
+	if limit <= 0 || limit > len(calls) {
+		limit = len(calls)
+	}
+	sem := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call ToolCall) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = ToolResult{CallID: call.ID, Name: call.Name, Content: ctx.Err().Error(), IsError: true}
+				return
+			}
+			defer func() { <-sem }()
+
+			start := time.Now()
+			results[i] = e.call(ctx, call)
+			if e.Trace != nil {
+				e.Trace(ToolTrace{Call: call, Result: results[i], Start: start, Duration: time.Since(start)})
+			}
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+func (e *ToolExecutor) call(ctx context.Context, call ToolCall) ToolResult {
+	policies := []*ToolPolicy{e.Policy}
+	if p, ok := ctx.Value(toolPolicyKey{}).(*ToolPolicy); ok {
+		policies = append(policies, p)
+	}
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		if err := policy.Check(call); err != nil {
+			return ToolResult{CallID: call.ID, Name: call.Name, Content: err.Error(), IsError: true}
+		}
+	}
+
+	var tool *Tool
+	for i := range e.Tools {
+		if e.Tools[i].Name == call.Name {
+			tool = &e.Tools[i]
+			break
+		}
+	}
+	if tool == nil {
+		return ToolResult{CallID: call.ID, Name: call.Name, Content: fmt.Sprintf("unknown tool: %s", call.Name), IsError: true}
+	}
+
+	timeout := e.Timeout
+	if t, ok := e.Timeouts[call.Name]; ok {
+		timeout = t
+	}
+	if timeout <= 0 {
+		return safeCall(ctx, tool, call)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Run in a goroutine so a handler ignoring ctx can't block the turn
+	resCh := make(chan ToolResult, 1)
+	go func() {
+		resCh <- safeCall(ctx, tool, call)
+	}()
+	select {
+	case res := <-resCh:
+		return res
+	case <-ctx.Done():
+		return ToolResult{CallID: call.ID, Name: call.Name, Content: fmt.Sprintf("tool %s: %v", call.Name, ctx.Err()), IsError: true}
+	}
+}
+
+// safeCall calls tool, a panic of its handler is returned as an error result
+// instead of crashing the process
+func safeCall(ctx context.Context, tool *Tool, call ToolCall) (res ToolResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = ToolResult{CallID: call.ID, Name: call.Name, Content: fmt.Sprintf("tool %s panicked: %v", call.Name, r), IsError: true}
+		}
+	}()
+	return tool.Call(ctx, call)
+}
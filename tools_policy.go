@@ -0,0 +1,200 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ToolPolicy restricts which tools the model may call and with what arguments.
+// It is checked by ToolExecutor before a call runs, denied calls are returned
+// to the model as error results.
+type ToolPolicy struct {
+	// Allow lists the tools that may be called, empty allows all tools
+	Allow []string
+	// Deny lists tools that may never be called, it takes precedence over Allow
+	Deny []string
+	// Constraints check the arguments of tools by tool name
+	Constraints map[string][]ArgConstraint
+}
+
+// ArgConstraint checks one argument of a tool call. Calls without the
+// argument pass, mark it required in the tool schema if it must be present.
+type ArgConstraint struct {
+	// Path is the argument name, fields of nested objects are separated by
+	// dots, e.g. "request.url". Each element of an array is checked.
+	Path string
+	// Check returns an error if the value is not allowed. Strings are passed
+	// as is, other values as JSON.
+	Check func(value string) error
+}
+
+// ToolPermissionError is returned when a policy denies a tool call
+type ToolPermissionError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *ToolPermissionError) Error() string {
+	return fmt.Sprintf("permission denied for tool %s: %s", e.Tool, e.Reason)
+}
+
+type toolPolicyKey struct{}
+
+// WithToolPolicy returns a context that applies policy to tool calls executed
+// with it in addition to ToolExecutor.Policy, so one executor can serve chat
+// sessions with different permissions. A call runs only if both policies
+// permit it, a nil policy permits all calls.
+func WithToolPolicy(ctx context.Context, policy *ToolPolicy) context.Context {
+	return context.WithValue(ctx, toolPolicyKey{}, policy)
+}
+
+// Check returns a ToolPermissionError if the policy does not permit the call
+func (p *ToolPolicy) Check(call ToolCall) error {
+	for _, name := range p.Deny {
+		if name == call.Name {
+			return &ToolPermissionError{Tool: call.Name, Reason: "tool is denied"}
+		}
+	}
+	if len(p.Allow) > 0 {
+		allowed := false
+		for _, name := range p.Allow {
+			allowed = allowed || name == call.Name
+		}
+		if !allowed {
+			return &ToolPermissionError{Tool: call.Name, Reason: "tool is not allowed"}
+		}
+	}
+
+	constraints := p.Constraints[call.Name]
+	if len(constraints) == 0 {
+		return nil
+	}
+	var args interface{}
+	if len(call.Arguments) > 0 {
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			return &ToolPermissionError{Tool: call.Name, Reason: fmt.Sprintf("invalid arguments: %v", err)}
+		}
+		// Handlers decoding into structs match keys case insensitively and
+		// keep the last duplicate, which would not be the checked value
+		if key := duplicateKey(args); key != "" {
+			return &ToolPermissionError{Tool: call.Name, Reason: fmt.Sprintf("duplicate argument %s", key)}
+		}
+	}
+	for _, c := range constraints {
+		for _, value := range argValues(args, strings.Split(c.Path, ".")) {
+			if err := c.Check(value); err != nil {
+				return &ToolPermissionError{Tool: call.Name, Reason: fmt.Sprintf("%s: %v", c.Path, err)}
+			}
+		}
+	}
+	return nil
+}
+
+// duplicateKey returns a key of an object in v that another key of the same
+// object equals case insensitively, "" if there is none
+func duplicateKey(v interface{}) string {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, el := range v {
+			if key := duplicateKey(el); key != "" {
+				return key
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key, field := range v {
+			for _, seen := range keys {
+				if strings.EqualFold(key, seen) {
+					return key
+				}
+			}
+			keys = append(keys, key)
+			if key := duplicateKey(field); key != "" {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// argValues returns the values at path, arrays are expanded. Keys match case
+// insensitively, like they do when decoding into a struct.
+func argValues(v interface{}, path []string) []string {
+	if arr, ok := v.([]interface{}); ok {
+		var values []string
+		for _, el := range arr {
+			values = append(values, argValues(el, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		if s, ok := v.(string); ok {
+			return []string{s}
+		}
+		data, _ := json.Marshal(v)
+		return []string{string(data)}
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	for key, field := range obj {
+		if strings.EqualFold(key, path[0]) && field != nil {
+			return argValues(field, path[1:])
+		}
+	}
+	return nil
+}
+
+// PathUnder allows file paths inside one of dirs. Relative paths are resolved
+// against the first dir. Symlinks are not resolved.
+func PathUnder(dirs ...string) func(value string) error {
+	return func(value string) error {
+		path := value
+		if !filepath.IsAbs(path) && len(dirs) > 0 {
+			path = filepath.Join(dirs[0], path)
+		}
+		path = filepath.Clean(path)
+		for _, dir := range dirs {
+			rel, err := filepath.Rel(filepath.Clean(dir), path)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("path %s is outside of the allowed directories", value)
+	}
+}
+
+// URLDomain allows http(s) URLs on one of domains or their subdomains
+func URLDomain(domains ...string) func(value string) error {
+	return func(value string) error {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return fmt.Errorf("invalid URL %s", value)
+		}
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		for _, domain := range domains {
+			domain = strings.ToLower(domain)
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return nil
+			}
+		}
+		return fmt.Errorf("domain %s is not allowed", host)
+	}
+}
+
+// OneOf allows only the given values
+func OneOf(values ...string) func(value string) error {
+	return func(value string) error {
+		for _, v := range values {
+			if v == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %s is not allowed", value)
+	}
+}
@@ -0,0 +1,406 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"github.com/liushuangls/go-anthropic/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type weatherArgs struct {
+	City  string `json:"city" jsonschema:"description=City name"`
+	Units string `json:"units,omitempty" jsonschema:"enum=metric,enum=imperial"`
+}
+
+type weatherResult struct {
+	Temp float64 `json:"temp"`
+}
+
+func TestToolFromFunc(t *testing.T) {
+	tool := ToolFromFunc("get_weather", "Get current weather", func(ctx context.Context, args weatherArgs) (weatherResult, error) {
+		if args.City == "Atlantis" {
+			return weatherResult{}, errors.New("unknown city")
+		}
+		return weatherResult{Temp: 21.5}, nil
+	})
+
+	if got := tool.Parameters.RequiredProperties(); len(got) != 1 || got[0] != "city" {
+		t.Fatalf("unexpected required properties: %v", got)
+	}
+
+	res := tool.Call(context.Background(), ToolCall{ID: "1", Arguments: json.RawMessage(`{"city":"Paris"}`)})
+	if res.IsError || res.Content != `{"temp":21.5}` || res.CallID != "1" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	res = tool.Call(context.Background(), ToolCall{Arguments: json.RawMessage(`{"units":"kelvin"}`)})
+	if !res.IsError {
+		t.Fatalf("expected validation error: %+v", res)
+	}
+
+	res = tool.Call(context.Background(), ToolCall{Arguments: json.RawMessage(`{"city":"Atlantis"}`)})
+	if !res.IsError || res.Content != "unknown city" {
+		t.Fatalf("expected execution error: %+v", res)
+	}
+}
+
+func TestDecodeArguments(t *testing.T) {
+	tool := ToolFromFunc("get_weather", "Get current weather", func(ctx context.Context, args weatherArgs) (string, error) {
+		return "", nil
+	})
+	args, err := DecodeArguments[weatherArgs](tool, ToolCall{Arguments: json.RawMessage(`{"city":"Paris","units":"metric"}`)})
+	if err != nil || args.City != "Paris" || args.Units != "metric" {
+		t.Fatalf("unexpected arguments %+v, %v", args, err)
+	}
+	if _, err := DecodeArguments[weatherArgs](tool, ToolCall{}); err == nil {
+		t.Error("expected error for missing required city")
+	}
+}
+
+func TestToolExecutor(t *testing.T) {
+	slow := ToolFromFunc("slow", "", func(ctx context.Context, args struct{}) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	echo := ToolFromFunc("echo", "", func(ctx context.Context, args struct {
+		Text string `json:"text"`
+	}) (string, error) {
+		return args.Text, nil
+	})
+
+	var traced int32
+	exec := &ToolExecutor{
+		Tools:          []Tool{slow, echo},
+		MaxConcurrency: 2,
+		Timeouts:       map[string]time.Duration{"slow": 10 * time.Millisecond},
+		Trace:          func(ToolTrace) { atomic.AddInt32(&traced, 1) },
+	}
+
+	results := exec.Execute(context.Background(), []ToolCall{
+		{ID: "a", Name: "slow"},
+		{ID: "b", Name: "echo", Arguments: json.RawMessage(`{"text":"hi"}`)},
+		{ID: "c", Name: "missing"},
+	})
+	if len(results) != 3 || results[0].CallID != "a" || results[1].CallID != "b" || results[2].CallID != "c" {
+		t.Fatalf("results out of order: %+v", results)
+	}
+	if !results[0].IsError {
+		t.Fatalf("expected timeout error: %+v", results[0])
+	}
+	if results[1].IsError || results[1].Content != "hi" {
+		t.Fatalf("unexpected echo result: %+v", results[1])
+	}
+	if !results[2].IsError {
+		t.Fatalf("expected unknown tool error: %+v", results[2])
+	}
+	if atomic.LoadInt32(&traced) != 3 {
+		t.Fatalf("expected 3 traces, got %d", traced)
+	}
+
+	// Panics of handlers, with and without a timeout, are error results
+	crash := ToolFromFunc("crash", "", func(ctx context.Context, args struct {
+		Items []string `json:"items"`
+	}) (string, error) {
+		return args.Items[0], nil
+	})
+	exec = &ToolExecutor{Tools: []Tool{crash}}
+	results = exec.Execute(context.Background(), []ToolCall{{ID: "d", Name: "crash", Arguments: json.RawMessage(`{"items":[]}`)}})
+	if !results[0].IsError || !strings.Contains(results[0].Content, "panicked") {
+		t.Fatalf("expected a panic error: %+v", results[0])
+	}
+	exec.Timeout = time.Second
+	results = exec.Execute(context.Background(), []ToolCall{{ID: "e", Name: "crash", Arguments: json.RawMessage(`{"items":[]}`)}})
+	if !results[0].IsError || !strings.Contains(results[0].Content, "panicked") {
+		t.Fatalf("expected a panic error with a timeout: %+v", results[0])
+	}
+}
+
+func TestToolPolicy(t *testing.T) {
+	var readPaths []string
+	read := ToolFromFunc("read_file", "", func(ctx context.Context, args struct {
+		Path string `json:"path"`
+	}) (string, error) {
+		readPaths = append(readPaths, args.Path)
+		return "contents", nil
+	})
+	fetch := ToolFromFunc("fetch", "", func(ctx context.Context, args struct {
+		URLs []string `json:"urls"`
+	}) (string, error) {
+		return "fetched", nil
+	})
+	exec := &ToolExecutor{
+		Tools: []Tool{read, fetch},
+		Policy: &ToolPolicy{Constraints: map[string][]ArgConstraint{
+			"fetch": {{Path: "urls", Check: URLDomain("example.com")}},
+		}},
+	}
+
+	session := WithToolPolicy(context.Background(), &ToolPolicy{
+		Allow: []string{"read_file", "fetch"},
+		Constraints: map[string][]ArgConstraint{
+			"read_file": {{Path: "path", Check: PathUnder("/srv/data")}},
+		},
+	})
+	results := exec.Execute(session, []ToolCall{
+		{ID: "a", Name: "read_file", Arguments: json.RawMessage(`{"path":"reports/q1.txt"}`)},
+		{ID: "b", Name: "read_file", Arguments: json.RawMessage(`{"path":"../../etc/passwd"}`)},
+		{ID: "c", Name: "fetch", Arguments: json.RawMessage(`{"urls":["https://docs.example.com/a","https://evil.com"]}`)},
+	})
+	if results[0].IsError || results[0].Content != "contents" {
+		t.Fatalf("expected allowed call: %+v", results[0])
+	}
+	if !results[1].IsError || !strings.Contains(results[1].Content, "outside") {
+		t.Fatalf("expected path to be denied: %+v", results[1])
+	}
+	if !results[2].IsError || !strings.Contains(results[2].Content, "evil.com") {
+		t.Fatalf("expected executor policy to deny the domain: %+v", results[2])
+	}
+
+	allowed := ToolCall{ID: "d", Name: "fetch", Arguments: json.RawMessage(`{"urls":["https://example.com"]}`)}
+	results = exec.Execute(WithToolPolicy(context.Background(), &ToolPolicy{Deny: []string{"fetch"}}), []ToolCall{allowed})
+	if !results[0].IsError || !strings.Contains(results[0].Content, "denied") {
+		t.Fatalf("expected session policy to deny fetch: %+v", results[0])
+	}
+
+	evil := ToolCall{ID: "e", Name: "fetch", Arguments: json.RawMessage(`{"urls":["https://evil.com"]}`)}
+	results = exec.Execute(WithToolPolicy(context.Background(), nil), []ToolCall{allowed, evil})
+	if results[0].IsError || !results[1].IsError {
+		t.Fatalf("expected a nil session policy to keep the executor policy: %+v", results)
+	}
+
+	// Struct decoding matches keys case insensitively and keeps the last one
+	readPaths = nil
+	results = exec.Execute(session, []ToolCall{
+		{ID: "f", Name: "read_file", Arguments: json.RawMessage(`{"path":"/srv/data/x","PATH":"/etc/passwd"}`)},
+		{ID: "g", Name: "read_file", Arguments: json.RawMessage(`{"PATH":"/etc/passwd"}`)},
+	})
+	if !results[0].IsError || !strings.Contains(results[0].Content, "duplicate") {
+		t.Fatalf("expected duplicate keys to be denied: %+v", results[0])
+	}
+	if !results[1].IsError || !strings.Contains(results[1].Content, "outside") {
+		t.Fatalf("expected the path to be checked case insensitively: %+v", results[1])
+	}
+	if len(readPaths) != 0 {
+		t.Fatalf("handler called with %q", readPaths)
+	}
+}
+
+var weatherTool = Tool{
+	Name:        "get_weather",
+	Description: "Get current weather",
+	Parameters:  Object().Prop("city", String()).Required("city"),
+}
+
+func TestAnthropicGenerateWithTools(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-5-sonnet-20241022",
+			"content":[{"type":"text","text":"Checking."},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],
+			"stop_reason":"tool_use"}`)
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	llm.client = anthropic.NewClient("key", anthropic.WithBaseURL(server.URL))
+
+	var _ ToolCaller = llm
+	res, err := llm.GenerateWithTools(context.Background(), []Message{{Role: RoleUser, Content: "Weather in Paris?"}},
+		[]Tool{weatherTool, {Name: "now", Description: "Current time"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := body["tools"].([]interface{})
+	if len(tools) != 2 || tools[0].(map[string]interface{})["input_schema"].(map[string]interface{})["required"] == nil ||
+		tools[1].(map[string]interface{})["input_schema"].(map[string]interface{})["type"] != "object" {
+		t.Errorf("unexpected tools %v", body["tools"])
+	}
+	if res.Text != "Checking." || res.Model != "claude-3-5-sonnet-20241022" || len(res.ToolCalls) != 1 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	call := res.ToolCalls[0]
+	if call.ID != "toolu_1" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+
+	// a tool result after a user message without content
+	if _, err := llm.GenerateWithTools(context.Background(), []Message{{Role: RoleUser}, {Role: RoleTool, ToolCallID: "toolu_1", Content: "sunny"}}, []Tool{weatherTool}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeminiGenerateWithTools(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-2.0-flash:generateContent" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]}}],
+			"modelVersion":"gemini-2.0-flash-001"}`)
+	}))
+	defer server.Close()
+
+	llm := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, true, nil)
+	llm.SetBaseURL(server.URL)
+
+	var _ ToolCaller = llm
+	res, err := llm.GenerateWithTools(context.Background(), []Message{{Role: RoleUser, Content: "Weather in Paris?"}}, []Tool{weatherTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	declarations := body["tools"].([]interface{})[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+	if len(declarations) != 1 || declarations[0].(map[string]interface{})["name"] != "get_weather" {
+		t.Errorf("unexpected tools %v", body["tools"])
+	}
+	if _, ok := body["generationConfig"].(map[string]interface{})["responseMimeType"]; ok {
+		t.Error("JSON mode should be disabled with tools")
+	}
+	if res.Model != "gemini-2.0-flash-001" || len(res.ToolCalls) != 1 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	call := res.ToolCalls[0]
+	if call.ID != "get_weather" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+}
+
+func TestVertexGenerateWithTools(t *testing.T) {
+	fake := &fakePredictionServer{response: func(req *aiplatformpb.GenerateContentRequest) *aiplatformpb.GenerateContentResponse {
+		args, _ := structpb.NewStruct(map[string]interface{}{"city": "Paris"})
+		return &aiplatformpb.GenerateContentResponse{Candidates: []*aiplatformpb.Candidate{{
+			Content: &aiplatformpb.Content{Role: "model", Parts: []*aiplatformpb.Part{
+				{Data: &aiplatformpb.Part_FunctionCall{FunctionCall: &aiplatformpb.FunctionCall{Name: "get_weather", Args: args}}},
+			}},
+		}}}
+	}}
+	llm := newFakeVertex(t, fake, "gemini-2.0-flash-001")
+
+	var _ ToolCaller = llm
+	messages := []Message{
+		{Role: RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: RoleAssistant, ToolCalls: []ToolCall{
+			{ID: "get_weather", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+			{ID: "get_weather", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Rome"}`)},
+		}},
+		{Role: RoleTool, ToolCallID: "get_weather", ToolName: "get_weather", Content: `{"temp":21}`},
+		{Role: RoleTool, ToolCallID: "get_weather", ToolName: "get_weather", Content: `{"temp":25}`},
+	}
+	res, err := llm.GenerateWithTools(context.Background(), messages, []Tool{weatherTool})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := fake.requests[0]
+	declarations := req.Tools[0].FunctionDeclarations
+	if len(declarations) != 1 || declarations[0].Name != "get_weather" || declarations[0].Parameters.Required[0] != "city" {
+		t.Errorf("unexpected tools %v", req.Tools)
+	}
+	if len(req.Contents) != 3 || req.Contents[1].Parts[0].GetFunctionCall().GetName() != "get_weather" {
+		t.Fatalf("unexpected contents %v", req.Contents)
+	}
+	if results := req.Contents[2]; len(results.Parts) != 2 || results.Parts[1].GetFunctionResponse().GetName() != "get_weather" || results.Role != "user" {
+		t.Errorf("expected the tool results to be sent as function responses of one content, got %v", results)
+	}
+	if len(res.ToolCalls) != 1 {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	call := res.ToolCalls[0]
+	if call.ID != "get_weather" || call.Name != "get_weather" || string(call.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool call %+v", call)
+	}
+}
+
+func TestToolMessages(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/chat/completions":
+			fmt.Fprint(w, `{"id":"1","object":"chat.completion","model":"m","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Sunny in Paris, rainy in Oslo."}}]}`)
+		case "/messages":
+			fmt.Fprint(w, `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Sunny."}],"stop_reason":"end_turn"}`)
+		default:
+			fmt.Fprint(w, `{"candidates":[{"content":{"role":"model","parts":[{"text":"Sunny."}]}}]}`)
+		}
+	}))
+	defer server.Close()
+
+	res := &Response{Text: "Checking.", ToolCalls: []ToolCall{
+		{ID: "call_1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+		{ID: "call_2", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Oslo"}`)},
+	}}
+	messages := append([]Message{{Role: RoleUser, Content: "Weather in Paris and Oslo?"}, res.Message()},
+		ToolResultMessages([]ToolResult{
+			{CallID: "call_1", Name: "get_weather", Content: "sunny"},
+			{CallID: "call_2", Content: "service down", IsError: true},
+		})...)
+	msg := func(i int) map[string]interface{} {
+		for _, key := range []string{"messages", "contents"} {
+			if list, ok := body[key].([]interface{}); ok && i < len(list) {
+				return list[i].(map[string]interface{})
+			}
+		}
+		t.Fatalf("no message %d in %v", i, body)
+		return nil
+	}
+
+	openAI := NewOpenAICompatible(server.URL, "key", "m", 100, 0, false)
+	if _, err := openAI.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	calls := msg(1)["tool_calls"].([]interface{})
+	if len(calls) != 2 || calls[1].(map[string]interface{})["function"].(map[string]interface{})["arguments"] != `{"city":"Oslo"}` {
+		t.Errorf("unexpected OpenAI tool calls %v", msg(1))
+	}
+	if msg(2)["role"] != "tool" || msg(2)["tool_call_id"] != "call_1" || msg(3)["tool_call_id"] != "call_2" {
+		t.Errorf("unexpected OpenAI tool results %v %v", msg(2), msg(3))
+	}
+
+	anthropic := NewAnthropic("key", "claude-3-5-sonnet-latest", 100, 0, false)
+	anthropic.SetBaseURL(server.URL)
+	if _, err := anthropic.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if list := body["messages"].([]interface{}); len(list) != 3 {
+		t.Fatalf("expected the tool results in one message, got %v", list)
+	}
+	use := msg(1)["content"].([]interface{})[1].(map[string]interface{})
+	results := msg(2)["content"].([]interface{})
+	if use["type"] != "tool_use" || use["id"] != "call_1" || use["input"].(map[string]interface{})["city"] != "Paris" {
+		t.Errorf("unexpected Anthropic tool use %v", use)
+	}
+	if msg(2)["role"] != "user" || len(results) != 2 || results[1].(map[string]interface{})["tool_use_id"] != "call_2" ||
+		results[1].(map[string]interface{})["is_error"] != true {
+		t.Errorf("unexpected Anthropic tool results %v", msg(2))
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 100, false, nil)
+	gemini.SetBaseURL(server.URL)
+	if _, err := gemini.GenerateResponse(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	parts := msg(2)["parts"].([]interface{})
+	second := parts[1].(map[string]interface{})["functionResponse"].(map[string]interface{})
+	if msg(1)["parts"].([]interface{})[1].(map[string]interface{})["functionCall"] == nil || len(parts) != 2 ||
+		second["name"] != "get_weather" || second["response"].(map[string]interface{})["error"] != "service down" {
+		t.Errorf("unexpected Gemini tool messages %v %v", msg(1), msg(2))
+	}
+
+	_, prompt := messagesToPrompt(messages)
+	if !strings.Contains(prompt, `[call get_weather {"city":"Oslo"}]`) || !strings.Contains(prompt, "Tool result (get_weather): sunny") {
+		t.Errorf("unexpected flattened prompt %q", prompt)
+	}
+}
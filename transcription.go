@@ -0,0 +1,192 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// TranscriptionOptions configures a transcription
+type TranscriptionOptions struct {
+	// Language is the ISO-639-1 code of the speech, e.g. "en" (optional,
+	// detected otherwise)
+	Language string
+	// Prompt guides the transcription, e.g. with names and terms it contains
+	// (optional)
+	Prompt string
+	// Timestamps requests the segments of the transcript with their times
+	Timestamps bool
+}
+
+// TranscriptSegment is a timed part of a transcript
+type TranscriptSegment struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// Transcript is the text of speech
+type Transcript struct {
+	Text string
+	// Language is the detected language, if reported by the provider
+	Language string
+	// Segments are set if timestamps were requested
+	Segments []TranscriptSegment
+}
+
+// Transcriber converts speech to text
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio io.Reader, mimeType MimeType, opts TranscriptionOptions) (*Transcript, error)
+}
+
+// transcriptSegments are segments with times in seconds
+type transcriptSegments []struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+func (s transcriptSegments) segments() []TranscriptSegment {
+	var res []TranscriptSegment
+	for _, segment := range s {
+		res = append(res, TranscriptSegment{
+			Start: time.Duration(segment.Start * float64(time.Second)),
+			End:   time.Duration(segment.End * float64(time.Second)),
+			Text:  strings.TrimSpace(segment.Text),
+		})
+	}
+	return res
+}
+
+// openAITranscriptionFiles are the file names of audio formats, OpenAI
+// detects the format from the extension
+var openAITranscriptionFiles = map[MimeType]string{
+	MimeTypeWAV:  "audio.wav",
+	MimeTypeMP3:  "audio.mp3",
+	MimeTypeFLAC: "audio.flac",
+	MimeTypeOGG:  "audio.ogg",
+	MimeTypeOpus: "audio.ogg",
+}
+
+// OpenAITranscriber transcribes audio with the OpenAI transcriptions API
+type OpenAITranscriber struct {
+	client *openai.Client
+	model  string
+}
+
+// NewOpenAITranscriber creates a transcriber for model, e.g. "whisper-1" or
+// "gpt-4o-transcribe"
+func NewOpenAITranscriber(apiKey, model string) *OpenAITranscriber {
+	return NewOpenAI(apiKey, "", 0, 0, false).Transcriber(model)
+}
+
+// Transcriber returns a transcriber for model sharing the client, e.g. for
+// OpenAI compatible servers
+func (o *OpenAI) Transcriber(model string) *OpenAITranscriber {
+	return &OpenAITranscriber{client: o.client, model: model}
+}
+
+// Transcribe transcribes audio. Timestamps are only supported by whisper-1.
+func (t *OpenAITranscriber) Transcribe(ctx context.Context, audio io.Reader, mimeType MimeType, opts TranscriptionOptions) (*Transcript, error) {
+	name, ok := openAITranscriptionFiles[mimeType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio format %s", mimeType)
+	}
+	params := openai.AudioTranscriptionNewParams{
+		File:  openai.FileParam(audio, name, string(mimeType)),
+		Model: openai.F(openai.AudioModel(t.model)),
+	}
+	if opts.Language != "" {
+		params.Language = openai.F(opts.Language)
+	}
+	if opts.Prompt != "" {
+		params.Prompt = openai.F(opts.Prompt)
+	}
+	if opts.Timestamps {
+		params.ResponseFormat = openai.F(openai.AudioResponseFormatVerboseJSON)
+		params.TimestampGranularities = openai.F([]openai.AudioTranscriptionNewParamsTimestampGranularity{
+			openai.AudioTranscriptionNewParamsTimestampGranularitySegment,
+		})
+	}
+	resp, err := t.client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	res := &Transcript{Text: resp.Text}
+	if !opts.Timestamps {
+		return res, nil
+	}
+
+	// the SDK only decodes the text of verbose responses
+	var verbose struct {
+		Language string             `json:"language"`
+		Segments transcriptSegments `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(resp.JSON.RawJSON()), &verbose); err != nil {
+		return nil, fmt.Errorf("failed to decode segments: %v", err)
+	}
+	res.Language = verbose.Language
+	res.Segments = verbose.Segments.segments()
+	return res, nil
+}
+
+const transcribeSystemPrompt = `Transcribe the speech in the audio verbatim, in its original language. Do not translate, summarize or describe it.`
+
+// GeminiTranscriber transcribes audio by prompting a model that understands
+// audio, e.g. Gemini
+type GeminiTranscriber struct {
+	llm LLM
+}
+
+// NewGeminiTranscriber creates a transcriber with llm, a Gemini client
+// (NewGoogleSimpleAlt or NewGoogle) or another LLM accepting audio messages
+func NewGeminiTranscriber(llm LLM) *GeminiTranscriber {
+	return &GeminiTranscriber{llm: llm}
+}
+
+func (t *GeminiTranscriber) Transcribe(ctx context.Context, audio io.Reader, mimeType MimeType, opts TranscriptionOptions) (*Transcript, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Transcribe this audio.")
+	if opts.Language != "" {
+		fmt.Fprintf(&prompt, "\nThe speech is in the language %s.", opts.Language)
+	}
+	if opts.Prompt != "" {
+		fmt.Fprintf(&prompt, "\nContext: %s", opts.Prompt)
+	}
+	schema := Object().
+		Prop("text", String().Desc("The full transcript")).
+		Prop("language", String().Desc("ISO-639-1 code of the speech language")).
+		Required("text", "language")
+	if opts.Timestamps {
+		prompt.WriteString("\nAlso split the transcript into segments of one or a few sentences, with their start and end times in seconds.")
+		schema.Prop("segments", Array(Object().
+			Prop("start", Number()).
+			Prop("end", Number()).
+			Prop("text", String()).
+			Required("start", "end", "text"))).
+			Required("segments")
+	}
+
+	messages := []Message{
+		{Role: RoleSystem, Content: transcribeSystemPrompt},
+		{Role: RoleUser, Parts: []ContentPart{AudioPart(audio, mimeType), TextPart(prompt.String())}},
+	}
+	output, err := GenerateStructured(ctx, t.llm, messages, "transcript", schema)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Text     string             `json:"text"`
+		Language string             `json:"language"`
+		Segments transcriptSegments `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(output), &v); err != nil {
+		return nil, &StructuredOutputError{Output: output, Err: err}
+	}
+	return &Transcript{Text: strings.TrimSpace(v.Text), Language: v.Language, Segments: v.Segments.segments()}, nil
+}
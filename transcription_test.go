@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscribers(t *testing.T) {
+	var form map[string]string
+	var geminiReq geminiGenerateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/audio/transcriptions":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Fatal(err)
+			}
+			form = map[string]string{"filename": r.MultipartForm.File["file"][0].Filename}
+			for key, values := range r.MultipartForm.Value {
+				form[key] = values[0]
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text": "Hello there. General Kenobi.", "language": "english", "segments": [
+				{"start": 0, "end": 1.5, "text": " Hello there."},
+				{"start": 1.5, "end": 3, "text": " General Kenobi."}]}`))
+		case strings.HasSuffix(r.URL.Path, ":generateContent"):
+			json.NewDecoder(r.Body).Decode(&geminiReq)
+			output := `{"text": "Bonjour.", "language": "fr", "segments": [{"start": 0.5, "end": 1, "text": "Bonjour."}]}`
+			json.NewEncoder(w).Encode(map[string]interface{}{"candidates": []interface{}{
+				map[string]interface{}{"content": geminiContent{Parts: []geminiPart{{Text: output}}}},
+			}})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	openAI := NewOpenAICompatible(server.URL, "key", "", 0, 0, false).Transcriber("whisper-1")
+	res, err := openAI.Transcribe(ctx, strings.NewReader("audio"), MimeTypeMP3, TranscriptionOptions{Language: "en", Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form["filename"] != "audio.mp3" || form["language"] != "en" || form["response_format"] != "verbose_json" {
+		t.Errorf("unexpected form %v", form)
+	}
+	if res.Text != "Hello there. General Kenobi." || len(res.Segments) != 2 || res.Segments[1].Start != 1500*time.Millisecond || res.Segments[1].Text != "General Kenobi." {
+		t.Errorf("unexpected transcript %+v", res)
+	}
+	if _, err := openAI.Transcribe(ctx, strings.NewReader("audio"), MimeTypePCM, TranscriptionOptions{}); err == nil {
+		t.Error("expected an error for raw PCM")
+	}
+
+	gemini := NewGoogleSimpleAlt("key", "gemini-2.0-flash", 0, false, nil)
+	gemini.SetBaseURL(server.URL + "/")
+	res, err = NewGeminiTranscriber(gemini).Transcribe(ctx, strings.NewReader("audio"), MimeTypeWAV, TranscriptionOptions{Timestamps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := geminiReq.Contents[0].Parts
+	if len(parts) != 2 || parts[0].InlineData == nil || parts[0].InlineData.MimeType != "audio/wav" || geminiReq.GenerationConfig["responseSchema"] == nil {
+		t.Errorf("unexpected request %+v", geminiReq)
+	}
+	if res.Text != "Bonjour." || res.Language != "fr" || len(res.Segments) != 1 || res.Segments[0].Start != 500*time.Millisecond {
+		t.Errorf("unexpected transcript %+v", res)
+	}
+}
@@ -0,0 +1,132 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+)
+
+// TruncateOptions configures TruncateHistory
+type TruncateOptions struct {
+	// ContextWindow is the number of input and output tokens of the model
+	ContextWindow int
+	// ReserveTokens are kept free for the response, e.g. the max tokens of
+	// the client
+	ReserveTokens int
+	// OnTruncate is called with the dropped messages (optional)
+	OnTruncate func(dropped []Message)
+}
+
+// ContextLengthError is returned when messages exceed the context window
+// even after truncation
+type ContextLengthError struct {
+	Tokens int
+	Budget int
+}
+
+func (e *ContextLengthError) Error() string {
+	return fmt.Sprintf("messages of %d tokens exceed the context budget of %d tokens", e.Tokens, e.Budget)
+}
+
+// TruncateHistory drops the oldest non-system messages until messages fit
+// the context window of model minus ReserveTokens, counting tokens with
+// TokenizerFor. A message is dropped together with the tool results that
+// follow it, so a tool result is never kept without its call. System
+// messages and the last message are always kept, and the history starts with
+// a user message unless only the last message and its tool results are left.
+// It returns the kept and dropped messages, and a ContextLengthError if the
+// kept messages still don't fit.
+func TruncateHistory(model string, messages []Message, opts TruncateOptions) (kept, dropped []Message, err error) {
+	budget := opts.ContextWindow - opts.ReserveTokens
+	tokens := make([]int, len(messages))
+	total := 0
+	for i, msg := range messages {
+		tokens[i] = CountTokens(model, msg.text()) + messageTokenOverhead
+		total += tokens[i]
+	}
+	if total <= budget || len(messages) == 0 {
+		return messages, nil, nil
+	}
+
+	// turns are the non-system messages with the tool results that follow
+	// them, as [start, end) ranges
+	var turns [][2]int
+	for i := 0; i < len(messages); i++ {
+		if messages[i].Role == RoleSystem {
+			continue
+		}
+		end := i + 1
+		for end < len(messages) && messages[end].Role == RoleTool {
+			end++
+		}
+		turns = append(turns, [2]int{i, end})
+		i = end - 1
+	}
+
+	// turns are dropped from the start of the history, until it fits and
+	// its first message is not a reply or a tool result. The last turn is
+	// kept.
+	drop := make([]bool, len(messages))
+	for i := 0; i+1 < len(turns); i++ {
+		start, end := turns[i][0], turns[i][1]
+		if total <= budget && messages[start].Role != RoleAssistant && messages[start].Role != RoleTool {
+			break
+		}
+		for j := start; j < end; j++ {
+			drop[j] = true
+			total -= tokens[j]
+		}
+	}
+	for i, msg := range messages {
+		if drop[i] {
+			dropped = append(dropped, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	if len(dropped) > 0 && opts.OnTruncate != nil {
+		opts.OnTruncate(dropped)
+	}
+	if total > budget {
+		return kept, dropped, &ContextLengthError{Tokens: total, Budget: budget}
+	}
+	return kept, dropped, nil
+}
+
+// TruncatingLLM drops the oldest messages of conversations that would exceed
+// the context window of the model before sending them, see TruncateHistory
+type TruncatingLLM struct {
+	LLM
+	opts TruncateOptions
+}
+
+// NewTruncatingLLM creates a TruncatingLLM
+//
+//	llm := ai.NewTruncatingLLM(client, ai.TruncateOptions{ContextWindow: 128000, ReserveTokens: 4096})
+func NewTruncatingLLM(llm LLM, opts TruncateOptions) *TruncatingLLM {
+	return &TruncatingLLM{LLM: llm, opts: opts}
+}
+
+func (t *TruncatingLLM) truncate(ctx context.Context, messages []Message) ([]Message, error) {
+	kept, _, err := TruncateHistory(requestModel(ctx, t.LLM.GetModel()), messages, t.opts)
+	return kept, err
+}
+
+func (t *TruncatingLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	messages, err := t.truncate(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return t.LLM.GenerateWithMessages(ctx, messages)
+}
+
+func (t *TruncatingLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	messages, err := t.truncate(ctx, messages)
+	if err != nil {
+		select {
+		case errCh <- err:
+		case <-ctx.Done():
+		}
+		return
+	}
+	GenerateWithMessagesStream(ctx, t.LLM, messages, resultCh, doneCh, errCh)
+}
@@ -0,0 +1,141 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTruncateHistory(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: long},
+		{Role: RoleAssistant, Content: long},
+		{Role: RoleUser, Content: "short"},
+		{Role: RoleAssistant, Content: "reply"},
+		{Role: RoleUser, Content: "question"},
+	}
+	size := func(msgs []Message) int {
+		n := 0
+		for _, msg := range msgs {
+			n += CountTokens("gpt-4o", msg.Content) + messageTokenOverhead
+		}
+		return n
+	}
+
+	kept, dropped, err := TruncateHistory("gpt-4o", messages, TruncateOptions{ContextWindow: size(messages)})
+	if err != nil || len(kept) != len(messages) || dropped != nil {
+		t.Fatalf("expected no truncation, got %d %d %v", len(kept), len(dropped), err)
+	}
+
+	// dropping the first user message leaves a leading reply, which goes too
+	var reported []Message
+	kept, dropped, err = TruncateHistory("gpt-4o", messages, TruncateOptions{
+		ContextWindow: size(messages) + 100,
+		ReserveTokens: 101,
+		OnTruncate:    func(d []Message) { reported = d },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 4 || kept[0].Role != RoleSystem || kept[1].Content != "short" || len(dropped) != 2 || len(reported) != 2 {
+		t.Errorf("unexpected truncation: kept %v, dropped %d", kept, len(dropped))
+	}
+
+	var lengthErr *ContextLengthError
+	kept, _, err = TruncateHistory("gpt-4o", messages, TruncateOptions{ContextWindow: 10})
+	if !errors.As(err, &lengthErr) || len(kept) != 2 || kept[1].Content != "question" {
+		t.Errorf("expected a context length error keeping system and last messages, got %v %v", kept, err)
+	}
+}
+
+func TestTruncateHistoryToolCalls(t *testing.T) {
+	long := strings.Repeat("word ", 100)
+	call := func(id string) Message {
+		return Message{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: id, Name: "search", Arguments: json.RawMessage(`{}`)}}}
+	}
+	result := func(id, content string) Message {
+		return Message{Role: RoleTool, ToolCallID: id, ToolName: "search", Content: content}
+	}
+	size := func(msgs []Message) int {
+		n := 0
+		for _, msg := range msgs {
+			n += CountTokens("gpt-4o", msg.text()) + messageTokenOverhead
+		}
+		return n
+	}
+
+	// A call and its results are dropped together, the last turn is kept
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: long},
+		call("c1"), result("c1", long),
+		call("c2"), result("c2", "found"),
+	}
+	kept, dropped, err := TruncateHistory("gpt-4o", messages, TruncateOptions{ContextWindow: size(messages) - 1})
+	if err != nil || len(kept) != 3 || kept[1].ToolCalls[0].ID != "c2" || kept[2].ToolCallID != "c2" || len(dropped) != 3 {
+		t.Fatalf("unexpected truncation: kept %v, dropped %d, %v", kept, len(dropped), err)
+	}
+
+	// Truncation stops at the first user message once the rest fits
+	messages = []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: long},
+		call("c1"), result("c1", "found"),
+		{Role: RoleAssistant, Content: "done"},
+		{Role: RoleUser, Content: "next"},
+		call("c2"), result("c2", "found"), result("c2", "more"),
+	}
+	kept, _, err = TruncateHistory("gpt-4o", messages, TruncateOptions{ContextWindow: size(messages) - 1})
+	if err != nil || len(kept) != 5 || kept[1].Content != "next" {
+		t.Fatalf("unexpected truncation: kept %v, %v", kept, err)
+	}
+	for i, msg := range kept {
+		if msg.Role == RoleTool && kept[i-1].Role != RoleTool && len(kept[i-1].ToolCalls) == 0 {
+			t.Errorf("tool result kept without its call: %v", kept)
+		}
+	}
+}
+
+func TestTruncatingLLM(t *testing.T) {
+	var received []Message
+	client := &messagesLLM{stubLLM: stubLLM{model: "gpt-4o"}, fn: func(messages []Message) { received = messages }}
+	llm := NewTruncatingLLM(client, TruncateOptions{ContextWindow: 40})
+	messages := []Message{
+		{Role: RoleUser, Content: strings.Repeat("word ", 100)},
+		{Role: RoleAssistant, Content: "ok"},
+		{Role: RoleUser, Content: "question"},
+	}
+	if _, err := llm.GenerateWithMessages(context.Background(), messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].Content != "question" {
+		t.Errorf("expected the last message only, got %v", received)
+	}
+	if err := consumeMessagesStream(context.Background(), llm, messages, func(string) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 {
+		t.Errorf("expected the stream to be truncated, got %v", received)
+	}
+}
+
+// messagesLLM records the messages it receives
+type messagesLLM struct {
+	stubLLM
+	fn func([]Message)
+}
+
+func (m *messagesLLM) GenerateWithMessages(ctx context.Context, messages []Message) (string, error) {
+	m.fn(messages)
+	return "ok", nil
+}
+
+func (m *messagesLLM) GenerateWithMessagesStream(ctx context.Context, messages []Message, resultCh chan string, doneCh chan bool, errCh chan error) {
+	m.fn(messages)
+	resultCh <- "ok"
+	doneCh <- true
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1beta1"
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// ListModels returns the models Google publishes in the Vertex AI Model
+// Garden of the first location of the client, e.g. "gemini-2.0-flash-001".
+// The Model Garden API is in beta, and the list also has models that do not
+// generate text, such as Imagen.
+func (g *Google) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if len(g.locations) == 0 {
+		return nil, fmt.Errorf("no locations configured")
+	}
+	opts := append([]option.ClientOption{
+		option.WithEndpoint(g.locations[0] + "-aiplatform.googleapis.com:443"),
+	}, g.clientOpts...)
+	client, err := aiplatform.NewModelGardenClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create model garden client: %v", err)
+	}
+	defer client.Close()
+
+	var models []ModelInfo
+	it := client.ListPublisherModels(ctx, &aiplatformpb.ListPublisherModelsRequest{Parent: "publishers/google"})
+	for {
+		m, err := it.Next()
+		if err == iterator.Done {
+			return models, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, ModelInfo{ID: strings.TrimPrefix(m.Name, "publishers/google/models/")})
+	}
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/aiplatform/apiv1beta1/aiplatformpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type fakeModelGardenServer struct {
+	aiplatformpb.UnimplementedModelGardenServiceServer
+}
+
+func (s *fakeModelGardenServer) ListPublisherModels(ctx context.Context, req *aiplatformpb.ListPublisherModelsRequest) (*aiplatformpb.ListPublisherModelsResponse, error) {
+	if req.PageToken == "" {
+		return &aiplatformpb.ListPublisherModelsResponse{
+			PublisherModels: []*aiplatformpb.PublisherModel{{Name: req.Parent + "/models/gemini-2.0-flash-001"}},
+			NextPageToken:   "next",
+		}, nil
+	}
+	return &aiplatformpb.ListPublisherModelsResponse{
+		PublisherModels: []*aiplatformpb.PublisherModel{{Name: req.Parent + "/models/imagen-3.0-generate-002"}},
+	}, nil
+}
+
+func TestVertexListModels(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	aiplatformpb.RegisterModelGardenServiceServer(server, &fakeModelGardenServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	g := &Google{
+		projectID: "p",
+		locations: []string{"us-central1"},
+		model:     "gemini-2.0-flash-001",
+		clientOpts: []option.ClientOption{
+			option.WithEndpoint(lis.Addr().String()),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		},
+	}
+	models, err := ListModels(context.Background(), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].ID != "gemini-2.0-flash-001" || models[1].ID != "imagen-3.0-generate-002" {
+		t.Errorf("unexpected models %+v", models)
+	}
+}
@@ -0,0 +1,198 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// VertexTuning manages Gemini supervised tuning jobs in a Vertex AI location
+//
+//	tuning, _ := google.Tuning(ctx)
+//	defer tuning.Close()
+//	job, _ := tuning.Create(ctx, ai.FineTuneParams{
+//		BaseModel:    "gemini-1.5-flash-002",
+//		TrainingFile: "gs://bucket/train.jsonl",
+//	})
+//	job, _ = tuning.Wait(ctx, job.ID, time.Minute)
+//	tuned := google.WithModel(job.Model)
+type VertexTuning struct {
+	client *aiplatform.GenAiTuningClient
+	parent string
+}
+
+// Tuning returns the tuning API of the first location of the client, Close
+// it when done
+func (g *Google) Tuning(ctx context.Context) (*VertexTuning, error) {
+	if len(g.locations) == 0 {
+		return nil, fmt.Errorf("no locations configured")
+	}
+	location := g.locations[0]
+	opts := append([]option.ClientOption{
+		option.WithEndpoint(location + "-aiplatform.googleapis.com:443"),
+	}, g.clientOpts...)
+	client, err := aiplatform.NewGenAiTuningClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tuning client: %v", err)
+	}
+	return &VertexTuning{
+		client: client,
+		parent: fmt.Sprintf("projects/%s/locations/%s", g.projectID, location),
+	}, nil
+}
+
+func (v *VertexTuning) Close() error {
+	return v.client.Close()
+}
+
+// Create starts a supervised tuning job. TrainingFile and ValidationFile are
+// JSONL datasets in Cloud Storage ("gs://..."), Suffix is the display name of
+// the tuned model. Seed is not supported.
+func (v *VertexTuning) Create(ctx context.Context, params FineTuneParams) (FineTuneJob, error) {
+	spec := &aiplatformpb.SupervisedTuningSpec{
+		TrainingDatasetUri:   params.TrainingFile,
+		ValidationDatasetUri: params.ValidationFile,
+	}
+	if params.Epochs > 0 {
+		spec.HyperParameters = &aiplatformpb.SupervisedHyperParameters{EpochCount: int64(params.Epochs)}
+	}
+	job, err := v.client.CreateTuningJob(ctx, &aiplatformpb.CreateTuningJobRequest{
+		Parent: v.parent,
+		TuningJob: &aiplatformpb.TuningJob{
+			SourceModel:           &aiplatformpb.TuningJob_BaseModel{BaseModel: params.BaseModel},
+			TuningSpec:            &aiplatformpb.TuningJob_SupervisedTuningSpec{SupervisedTuningSpec: spec},
+			TunedModelDisplayName: params.Suffix,
+		},
+	})
+	if err != nil {
+		return FineTuneJob{}, err
+	}
+	return vertexTuningJob(job), nil
+}
+
+// Get returns the current state of a job, id is its resource name
+func (v *VertexTuning) Get(ctx context.Context, id string) (FineTuneJob, error) {
+	job, err := v.client.GetTuningJob(ctx, &aiplatformpb.GetTuningJobRequest{Name: id})
+	if err != nil {
+		return FineTuneJob{}, err
+	}
+	return vertexTuningJob(job), nil
+}
+
+// List returns the jobs of the location
+func (v *VertexTuning) List(ctx context.Context) ([]FineTuneJob, error) {
+	var jobs []FineTuneJob
+	iter := v.client.ListTuningJobs(ctx, &aiplatformpb.ListTuningJobsRequest{Parent: v.parent})
+	for {
+		job, err := iter.Next()
+		if err == iterator.Done {
+			return jobs, nil
+		}
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, vertexTuningJob(job))
+	}
+}
+
+// Cancel requests the cancellation of a job, which happens asynchronously.
+// The returned job may still be running.
+func (v *VertexTuning) Cancel(ctx context.Context, id string) (FineTuneJob, error) {
+	if err := v.client.CancelTuningJob(ctx, &aiplatformpb.CancelTuningJobRequest{Name: id}); err != nil {
+		return FineTuneJob{}, err
+	}
+	return v.Get(ctx, id)
+}
+
+// Wait polls the job every interval until it is done. It returns the job with
+// an error if it failed or was cancelled.
+func (v *VertexTuning) Wait(ctx context.Context, id string, interval time.Duration) (FineTuneJob, error) {
+	return waitFineTune(ctx, id, interval, v.Get)
+}
+
+// vertexJobStatus maps Vertex AI job states, others are running
+var vertexJobStatus = map[aiplatformpb.JobState]FineTuneStatus{
+	aiplatformpb.JobState_JOB_STATE_QUEUED:              FineTuneQueued,
+	aiplatformpb.JobState_JOB_STATE_PENDING:             FineTuneQueued,
+	aiplatformpb.JobState_JOB_STATE_SUCCEEDED:           FineTuneSucceeded,
+	aiplatformpb.JobState_JOB_STATE_PARTIALLY_SUCCEEDED: FineTuneSucceeded,
+	aiplatformpb.JobState_JOB_STATE_FAILED:              FineTuneFailed,
+	aiplatformpb.JobState_JOB_STATE_EXPIRED:             FineTuneFailed,
+	aiplatformpb.JobState_JOB_STATE_CANCELLED:           FineTuneCancelled,
+}
+
+func vertexTuningJob(job *aiplatformpb.TuningJob) FineTuneJob {
+	res := FineTuneJob{
+		ID:        job.Name,
+		BaseModel: job.GetBaseModel(),
+		Status:    FineTuneRunning,
+		CreatedAt: job.GetCreateTime().AsTime(),
+	}
+	if status, ok := vertexJobStatus[job.State]; ok {
+		res.Status = status
+	}
+	if spec := job.GetSupervisedTuningSpec(); spec != nil {
+		res.TrainingFile = spec.TrainingDatasetUri
+	}
+	if job.TunedModel != nil {
+		// The endpoint serves the tuned model, the model itself cannot be
+		// called directly
+		res.Model = job.TunedModel.Endpoint
+	}
+	if job.Error != nil {
+		res.Error = job.Error.Message
+	}
+	if job.EndTime != nil {
+		res.FinishedAt = job.EndTime.AsTime()
+	}
+	return res
+}
+
+// resourceLocation matches the location of resource names
+var resourceLocation = regexp.MustCompile(`(?:^|/)locations/([^/]+)/`)
+
+// WithModel returns a client using model, which shares the connections of g.
+// Closing it does not close them, g must be closed afterwards and not before.
+// Model can be the endpoint of a tuned model ("projects/.../endpoints/..."),
+// as returned in FineTuneJob.Model. Such endpoints are regional, so only the
+// clients of the endpoint location are used, if g has one.
+func (g *Google) WithModel(model string) *Google {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	c := &Google{
+		projectID:      g.projectID,
+		clientOpts:     g.clientOpts,
+		clients:        g.clients,
+		locations:      g.locations,
+		model:          model,
+		safetySettings: g.safetySettings,
+		maxTokens:      g.maxTokens,
+		temperature:    g.temperature,
+		isJson:         g.isJson,
+		labels:         g.labels,
+		stop:           g.stop,
+		sampling:       g.sampling,
+		shared:         true,
+	}
+	if m := resourceLocation.FindStringSubmatch(model); m != nil {
+		var clients []*genai.Client
+		var locations []string
+		for i, location := range g.locations {
+			if location == m[1] {
+				clients = append(clients, g.clients[i])
+				locations = append(locations, location)
+			}
+		}
+		if len(clients) > 0 {
+			c.clients, c.locations = clients, locations
+		}
+	}
+	return c
+}
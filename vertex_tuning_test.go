@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type fakeTuningServer struct {
+	aiplatformpb.UnimplementedGenAiTuningServiceServer
+	created   *aiplatformpb.CreateTuningJobRequest
+	polls     int
+	cancelled string
+}
+
+func (s *fakeTuningServer) CancelTuningJob(ctx context.Context, req *aiplatformpb.CancelTuningJobRequest) (*emptypb.Empty, error) {
+	s.cancelled = req.Name
+	return &emptypb.Empty{}, nil
+}
+
+func (s *fakeTuningServer) CreateTuningJob(ctx context.Context, req *aiplatformpb.CreateTuningJobRequest) (*aiplatformpb.TuningJob, error) {
+	s.created = req
+	job := req.TuningJob
+	job.Name = req.Parent + "/tuningJobs/1"
+	job.State = aiplatformpb.JobState_JOB_STATE_PENDING
+	return job, nil
+}
+
+func (s *fakeTuningServer) GetTuningJob(ctx context.Context, req *aiplatformpb.GetTuningJobRequest) (*aiplatformpb.TuningJob, error) {
+	s.polls++
+	job := &aiplatformpb.TuningJob{Name: req.Name, State: aiplatformpb.JobState_JOB_STATE_RUNNING}
+	if s.polls > 1 {
+		job.State = aiplatformpb.JobState_JOB_STATE_SUCCEEDED
+		job.TunedModel = &aiplatformpb.TunedModel{
+			Model:    "projects/p/locations/europe-west4/models/1",
+			Endpoint: "projects/p/locations/europe-west4/endpoints/2",
+		}
+	}
+	return job, nil
+}
+
+func TestVertexTuning(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeTuningServer{}
+	server := grpc.NewServer()
+	aiplatformpb.RegisterGenAiTuningServiceServer(server, fake)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	g := &Google{
+		projectID: "p",
+		locations: []string{"us-central1", "europe-west4"},
+		clients:   []*genai.Client{{}, {}},
+		model:     "gemini-1.5-flash-002",
+		clientOpts: []option.ClientOption{
+			option.WithEndpoint(lis.Addr().String()),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		},
+	}
+	ctx := context.Background()
+	vertex, err := g.Tuning(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vertex.Close()
+	var tuning FineTuner = vertex
+
+	job, err := tuning.Create(ctx, FineTuneParams{BaseModel: "gemini-1.5-flash-002", TrainingFile: "gs://b/train.jsonl", Epochs: 2})
+	if err != nil || job.ID != "projects/p/locations/us-central1/tuningJobs/1" || job.Status != FineTuneQueued {
+		t.Fatalf("unexpected job %+v, %v", job, err)
+	}
+	spec := fake.created.TuningJob.GetSupervisedTuningSpec()
+	if spec.TrainingDatasetUri != "gs://b/train.jsonl" || spec.HyperParameters.EpochCount != 2 {
+		t.Errorf("unexpected tuning spec %v", spec)
+	}
+
+	if cancelled, err := tuning.Cancel(ctx, job.ID); err != nil || fake.cancelled != job.ID || cancelled.Status != FineTuneRunning {
+		t.Fatalf("unexpected cancelled job %+v, %v", cancelled, err)
+	}
+
+	job, err = tuning.Wait(ctx, job.ID, time.Millisecond)
+	if err != nil || job.Model != "projects/p/locations/europe-west4/endpoints/2" {
+		t.Fatalf("unexpected job %+v, %v", job, err)
+	}
+	tuned := g.WithModel(job.Model)
+	if tuned.GetModel() != "europe-west4/"+job.Model || len(tuned.clients) != 1 || g.model != "gemini-1.5-flash-002" {
+		t.Errorf("unexpected tuned client %s %v", tuned.GetModel(), tuned.locations)
+	}
+	// the fake clients would panic if the shared connections were closed
+	if err := tuned.Close(); err != nil {
+		t.Errorf("closing the tuned client: %v", err)
+	}
+}
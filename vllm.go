@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"github.com/openai/openai-go/option"
+)
+
+// VLLMParams are vLLM specific sampling and guided decoding parameters
+type VLLMParams struct {
+	// BestOf generates n candidates server side and returns the best one (optional)
+	BestOf int
+	// UseBeamSearch uses beam search instead of sampling (optional)
+	UseBeamSearch bool
+	// GuidedJSON constrains the output to the schema (optional)
+	GuidedJSON *Schema
+	// GuidedRegex constrains the output to the regular expression (optional)
+	GuidedRegex string
+}
+
+// NewVLLM creates a client for a vLLM OpenAI-compatible server, baseURL is
+// e.g. "http://localhost:8000/v1/". apiKey may be empty unless the server
+// was started with --api-key.
+// https://docs.vllm.ai/en/latest/serving/openai_compatible_server.html#extra-parameters
+func NewVLLM(baseURL, apiKey string, model string, maxTokens int64, temperature float64, isJson bool, params *VLLMParams) *OpenAI {
+	if apiKey == "" {
+		apiKey = "EMPTY"
+	}
+	var opts []option.RequestOption
+	if params != nil {
+		if params.BestOf > 0 {
+			opts = append(opts, option.WithJSONSet("best_of", params.BestOf))
+		}
+		if params.UseBeamSearch {
+			opts = append(opts, option.WithJSONSet("use_beam_search", true))
+		}
+		if params.GuidedJSON != nil {
+			opts = append(opts, option.WithJSONSet("guided_json", params.GuidedJSON.Map()))
+		}
+		if params.GuidedRegex != "" {
+			opts = append(opts, option.WithJSONSet("guided_regex", params.GuidedRegex))
+		}
+	}
+	return NewOpenAICompatible(baseURL, apiKey, model, maxTokens, temperature, isJson, opts...)
+}
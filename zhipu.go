@@ -0,0 +1,87 @@
+package ai
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openai/openai-go/option"
+)
+
+// zhipuTokenTTL is how long signed Zhipu tokens are valid, they are renewed a
+// minute before they expire
+const zhipuTokenTTL = 30 * time.Minute
+
+// https://open.bigmodel.cn/dev/api/normal-model/glm-4
+// Zhipu's API is OpenAI compatible, including streaming, but authenticates
+// with a JWT signed by the secret part of the "id.secret" API key instead of
+// the key itself.
+func NewZhipu(apiKey string, model string, maxTokens int64, temperature float64, isJson bool) *OpenAI {
+	signer := &zhipuSigner{apiKey: apiKey}
+	return NewOpenAICompatible("https://open.bigmodel.cn/api/paas/v4/", apiKey, model, maxTokens, temperature, isJson,
+		option.WithMiddleware(signer.middleware))
+}
+
+// zhipuSigner signs and caches Zhipu auth tokens
+type zhipuSigner struct {
+	apiKey  string
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (s *zhipuSigner) middleware(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	token, err := s.get(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return next(req)
+}
+
+func (s *zhipuSigner) get(now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && now.Add(time.Minute).Before(s.expires) {
+		return s.token, nil
+	}
+	token, err := signZhipuToken(s.apiKey, now, zhipuTokenTTL)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expires = token, now.Add(zhipuTokenTTL)
+	return token, nil
+}
+
+// signZhipuToken creates an HS256 JWT for an "id.secret" API key
+func signZhipuToken(apiKey string, now time.Time, ttl time.Duration) (string, error) {
+	id, secret, ok := strings.Cut(apiKey, ".")
+	if !ok || id == "" || secret == "" {
+		return "", fmt.Errorf("invalid Zhipu API key, expected id.secret")
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "sign_type": "SIGN"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"api_key":   id,
+		"exp":       now.Add(ttl).UnixMilli(),
+		"timestamp": now.UnixMilli(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go/option"
+)
+
+func TestZhipu(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path == "/models" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"object":"list","data":[{"id":"glm-4","object":"model","created":1,"owned_by":"zhipu"}]}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"id\":\"1\",\"created\":1,\"model\":\"glm-4\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"你好\"}}]}\n\n")
+		io.WriteString(w, "data: {\"id\":\"1\",\"created\":1,\"model\":\"glm-4\",\"choices\":[{\"index\":0,\"finish_reason\":\"stop\",\"delta\":{\"role\":\"assistant\",\"content\":\"!\"}}],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2,\"total_tokens\":7}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	signer := &zhipuSigner{apiKey: "key-id.secret"}
+	llm := NewOpenAICompatible(server.URL+"/", "key-id.secret", "glm-4", 100, 0.5, false,
+		option.WithMiddleware(signer.middleware))
+
+	var res strings.Builder
+	err := consumeStream(context.Background(), llm, "", "hi", func(chunk string) error {
+		res.WriteString(chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.String() != "你好!" {
+		t.Errorf("unexpected response %q", res.String())
+	}
+
+	parts := strings.Split(strings.TrimPrefix(auth, "Bearer "), ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a JWT, got %q", auth)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if base64.RawURLEncoding.EncodeToString(mac.Sum(nil)) != parts[2] {
+		t.Errorf("invalid signature")
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claims map[string]interface{}
+	json.Unmarshal(payload, &claims)
+	if claims["api_key"] != "key-id" {
+		t.Errorf("unexpected claims %v", claims)
+	}
+
+	// Metadata requests are signed too
+	auth = ""
+	models, err := llm.ListModels(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "glm-4" || strings.Count(auth, ".") != 2 {
+		t.Errorf("unexpected models %v, %v with authorization %q", models, err, auth)
+	}
+}